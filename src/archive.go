@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Board archives.
+// Expired boards are gone for good. With ARCHIVE_DIR set, the archiver writes a gzipped JSON export of each board to it
+// before the board is deleted. Boards expiring within ArchiveLead are archived again on every sweep, so the archive misses
+// at most the last archiveSweepInterval of changes.
+// The export is the same document as "Download as JSON" on the dashboard. End-to-end encrypted boards are archived
+// as ciphertext, the server never has their key.
+// With encryption at rest on (ENCRYPTION_KEYS), archives are sealed with the same envelope keys as the board data, and
+// decrypted on download. -rotate-encryption re-encrypts them too. See encryption.go
+//
+// Archives are downloaded at /board/{id}/archive, with the creator or owner token in the X-Owner-Token header.
+// Not in the URL, where it would end up in proxy logs and browser history.
+// The board is gone by then, so the owner is kept next to the archive, in <id>.owner.json, to check the token.
+// Archives are kept until removed from the directory.
+
+const (
+	ArchiveLead          = 5 * time.Minute
+	archiveSweepInterval = time.Minute
+	archiveVersion       = 1
+	ownerTokenHeader     = "X-Owner-Token"
+)
+
+// BoardArchive is the exported board. The frontend's download builds the same document. See downloadJson in Dashboard.vue
+type BoardArchive struct {
+	Version  int               `json:"version"`
+	Board    ArchivedBoard     `json:"board"`
+	Messages []ArchivedMessage `json:"messages"`
+}
+
+type ArchivedBoard struct {
+	Id                    string `json:"id"`
+	Name                  string `json:"name"`
+	Team                  string `json:"team"`
+	CreatedDateUtcSeconds int64  `json:"createdDateUtcSeconds"`
+	E2EE                  bool   `json:"e2ee,omitempty"` // Content of messages and comments is ciphertext
+}
+
+type ArchivedMessage struct {
+	Column       ArchivedColumn    `json:"column"`
+	Content      string            `json:"content"`
+	User         string            `json:"user"`
+	Likes        int64             `json:"likes"`
+	OfflineLikes int64             `json:"offlineLikes"`
+	Comments     []ArchivedComment `json:"comments"`
+}
+
+type ArchivedColumn struct {
+	Id   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type ArchivedComment struct {
+	Content string `json:"content"`
+	User    string `json:"user"`
+}
+
+// archiveOwner is what's needed to check owner tokens once the board is gone.
+type archiveOwner struct {
+	Creator    string `json:"creator"`
+	Owner      string `json:"owner"`
+	OwnerEpoch int64  `json:"ownerEpoch"`
+}
+
+type Archiver struct {
+	store Store
+	dir   string
+	crypt *fieldCipher // Encryption at rest, nil when disabled
+	now   func() time.Time
+}
+
+func newArchiver(store Store, dir string, crypt *fieldCipher) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("cannot create archive dir: %w", err)
+	}
+	return &Archiver{store: store, dir: dir, crypt: crypt, now: time.Now}, nil
+}
+
+func (a *Archiver) run() {
+	ticker := time.NewTicker(archiveSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.sweep()
+	}
+}
+
+// sweep archives boards expiring within ArchiveLead. Returns how many were archived.
+func (a *Archiver) sweep() int {
+	ids, ok := a.store.BoardsExpiringBefore(a.now().Add(ArchiveLead))
+	if !ok {
+		return 0
+	}
+	archived := 0
+	for _, id := range ids {
+		if a.archive(id) {
+			archived++
+		}
+	}
+	return archived
+}
+
+// archive writes the board's archive, replacing an earlier one. Returns false if the board is already gone.
+func (a *Archiver) archive(boardId string) bool {
+	if !validArchiveId(boardId) {
+		return false
+	}
+	data, ok := a.store.GetBoardAggregatedData(boardId)
+	if !ok || data.Board == nil || data.Board.Id == "" {
+		return false
+	}
+	doc := buildBoardArchive(a.store, data)
+	b := data.Board
+	owner := archiveOwner{Creator: b.Creator, Owner: b.Owner, OwnerEpoch: b.OwnerEpoch}
+
+	// Owner first, an archive is never served without it
+	if err := a.writeFile(boardId+".owner.json", func(f *os.File) error { return json.NewEncoder(f).Encode(owner) }); err != nil {
+		slog.Error("Failed to write board archive owner", "err", err, "board", boardId)
+		return false
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(doc); err != nil {
+		slog.Error("Failed to encode board archive", "err", err, "board", boardId)
+		return false
+	}
+	gz.Close()
+	content := a.crypt.seal(buf.String(), boardId, archiveRecord(), "json.gz")
+	if err := a.writeFile(boardId+".json.gz", func(f *os.File) error {
+		_, err := f.WriteString(content)
+		return err
+	}); err != nil {
+		slog.Error("Failed to write board archive", "err", err, "board", boardId)
+		return false
+	}
+	slog.Debug("Board archived", "board", boardId, "messages", len(doc.Messages))
+	return true
+}
+
+// writeFile writes to a temp file and renames it, so downloads never see a partly written archive.
+func (a *Archiver) writeFile(name string, write func(f *os.File) error) error {
+	f, err := os.CreateTemp(a.dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op once renamed
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(a.dir, name))
+}
+
+// rotateEncryption re-encrypts archives with the active key, and encrypts the ones written before encryption was enabled.
+// Returns how many were rewritten.
+func (a *Archiver) rotateEncryption() (int, bool) {
+	if a.crypt == nil {
+		return 0, true
+	}
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		slog.Error("Failed to list board archives", "err", err)
+		return 0, false
+	}
+	rotated := 0
+	for _, entry := range entries {
+		boardId, ok := strings.CutSuffix(entry.Name(), ".json.gz")
+		if !ok || !validArchiveId(boardId) {
+			continue
+		}
+		content, err := a.readArchive(boardId)
+		if err != nil {
+			slog.Error("Failed to read board archive", "err", err, "board", boardId)
+			return rotated, false
+		}
+		if !a.crypt.needsRotation(content.stored) {
+			continue
+		}
+		sealed := a.crypt.seal(content.plain, boardId, archiveRecord(), "json.gz")
+		if err := a.writeFile(boardId+".json.gz", func(f *os.File) error {
+			_, err := f.WriteString(sealed)
+			return err
+		}); err != nil {
+			slog.Error("Failed to write board archive", "err", err, "board", boardId)
+			return rotated, false
+		}
+		rotated++
+	}
+	return rotated, true
+}
+
+type archiveContent struct {
+	stored  string // As written to the file, possibly encrypted
+	plain   string // The gzipped export
+	modTime time.Time
+}
+
+// readArchive reads a board's archive, and decrypts it if it was written with encryption at rest.
+func (a *Archiver) readArchive(boardId string) (*archiveContent, error) {
+	path := filepath.Join(a.dir, boardId+".json.gz")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := a.crypt.open(string(stored), boardId, archiveRecord(), "json.gz")
+	if err != nil {
+		return nil, err
+	}
+	return &archiveContent{stored: string(stored), plain: plain, modTime: info.ModTime()}, nil
+}
+
+func buildBoardArchive(s Store, data *BoardAggregatedData) *BoardArchive {
+	b := data.Board
+	doc := &BoardArchive{
+		Version:  archiveVersion,
+		Board:    ArchivedBoard{Id: b.Id, Name: b.Name, Team: b.Team, CreatedDateUtcSeconds: b.CreatedAtUtc, E2EE: b.E2EE},
+		Messages: make([]ArchivedMessage, 0, len(data.Messages)),
+	}
+
+	columns := slices.Clone(data.Columns)
+	slices.SortFunc(columns, func(x, y *BoardColumn) int { return cmp.Compare(x.Position, y.Position) })
+	commentsByMsg := make(map[string][]ArchivedComment)
+	for _, c := range data.Comments {
+		commentsByMsg[c.ParentId] = append(commentsByMsg[c.ParentId], ArchivedComment{Content: c.Content, User: archivedUser(c)})
+	}
+	msgIds := make([]string, 0, len(data.Messages))
+	for _, m := range data.Messages {
+		msgIds = append(msgIds, m.Id)
+	}
+	likes, _ := s.GetLikesInfo(b.Id, "", msgIds...)
+
+	// Grouped by column, like on the dashboard. Cards of removed columns aren't shown there either.
+	for _, col := range columns {
+		for _, m := range data.Messages {
+			if m.Category != col.Id {
+				continue
+			}
+			comments := commentsByMsg[m.Id]
+			if comments == nil {
+				comments = []ArchivedComment{}
+			}
+			doc.Messages = append(doc.Messages, ArchivedMessage{
+				Column:       ArchivedColumn{Id: col.Id, Text: col.Text},
+				Content:      m.Content,
+				User:         archivedUser(m),
+				Likes:        likes[m.Id].Count,
+				OfflineLikes: m.OfflineLikes,
+				Comments:     comments,
+			})
+		}
+	}
+	return doc
+}
+
+func archivedUser(m *Message) string {
+	if m.Anonymous {
+		return "Anonymous"
+	}
+	return m.ByNickname
+}
+
+// validArchiveId keeps board ids from the URL inside the archive dir.
+func validArchiveId(id string) bool {
+	return id != "" && len(id) <= MaxIdSizeBytes && !strings.ContainsAny(id, `/\.`) && id == filepath.Base(id)
+}
+
+// handleDownload serves a board's archive to holders of the creator or owner token.
+func (a *Archiver) handleDownload(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !validArchiveId(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var owner archiveOwner
+	ownerJson, err := os.ReadFile(filepath.Join(a.dir, id+".owner.json"))
+	if err != nil || json.Unmarshal(ownerJson, &owner) != nil {
+		http.NotFound(w, r)
+		return
+	}
+	b := &Board{Id: id, Creator: owner.Creator, Owner: owner.Owner, OwnerEpoch: owner.OwnerEpoch}
+	token := r.Header.Get(ownerTokenHeader)
+	if !isCreatorToken(b, b.Creator, token) && !tokenMatches(token, ownerToken(b)) {
+		slog.Warn("Archive download without a valid owner token", "board", id, "ip", remoteIP(r))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	content, err := a.readArchive(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read board archive", "err", err, "board", id)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="QuickRetro_%s.json.gz"`, id))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", content.modTime, strings.NewReader(content.plain))
+}
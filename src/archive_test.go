@@ -0,0 +1,193 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestArchiver_Sweep(t *testing.T) {
+	s, _ := newTestMemoryStore(t)
+	b := createTestBoard(t, s, "b1")
+	s.EnsureUser(b.Id, "u1", "Alice")
+	s.Save(&Message{Id: "m1", By: "u1", ByNickname: "Alice", Group: b.Id, Category: "col02", Content: "Slow builds"}, AsNewMessage)
+	s.Save(&Message{Id: "m2", By: "u1", ByNickname: "Alice", Group: b.Id, Category: "col01", Content: "Shipped", Anonymous: true}, AsNewMessage)
+	s.Save(&Message{Id: "c1", By: "u1", ByNickname: "Alice", Group: b.Id, ParentId: "m1", Content: "+1"}, AsNewComment)
+	s.Like(b.Id, "m1", "u1", true)
+
+	a, err := newArchiver(s, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := a.sweep(); n != 0 {
+		t.Fatalf("archived %d boards, none expire within %s", n, ArchiveLead)
+	}
+
+	a.now = func() time.Time { return time.Now().Add(testTTL) }
+	if n := a.sweep(); n != 1 {
+		t.Fatalf("archived %d boards, want 1", n)
+	}
+
+	f, err := os.Open(filepath.Join(a.dir, "b1.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc BoardArchive
+	if err := json.NewDecoder(gz).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != 1 || doc.Board.Id != "b1" || doc.Board.Name != "Retro" || doc.Board.CreatedDateUtcSeconds != b.CreatedAtUtc {
+		t.Errorf("board = %+v", doc.Board)
+	}
+	if len(doc.Messages) != 2 {
+		t.Fatalf("messages = %+v", doc.Messages)
+	}
+	// Ordered by column position
+	shipped, slow := doc.Messages[0], doc.Messages[1]
+	if shipped.Column.Text != "Good" || shipped.User != "Anonymous" || len(shipped.Comments) != 0 {
+		t.Errorf("anonymous card = %+v", shipped)
+	}
+	if slow.Column.Id != "col02" || slow.Content != "Slow builds" || slow.User != "Alice" || slow.Likes != 1 {
+		t.Errorf("card = %+v", slow)
+	}
+	if len(slow.Comments) != 1 || slow.Comments[0].Content != "+1" || slow.Comments[0].User != "Alice" {
+		t.Errorf("comments = %+v", slow.Comments)
+	}
+}
+
+func TestArchiver_Download(t *testing.T) {
+	s, _ := newTestMemoryStore(t)
+	s.CreateBoard(&Board{Id: "b1", Name: "Retro", Owner: "owner", Creator: "owner", Status: InProgress}, nil, 0)
+	b, _ := s.GetBoard("b1")
+	s.UpdateBoardOwner(b, "bob")
+	b, _ = s.GetBoard("b1")
+
+	a, err := newArchiver(s, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.archive("b1") {
+		t.Fatal("archive failed")
+	}
+
+	download := func(id, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/board/"+id+"/archive", nil)
+		r.Header.Set(ownerTokenHeader, token)
+		r = mux.SetURLVars(r, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		a.handleDownload(w, r)
+		return w
+	}
+
+	for name, token := range map[string]string{"creator": creatorToken("b1", "owner"), "owner": ownerToken(b)} {
+		w := download("b1", token)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+			t.Errorf("%s token: status %d", name, w.Code)
+		}
+	}
+	if w := download("b1", ""); w.Code != http.StatusForbidden {
+		t.Errorf("without token: status %d", w.Code)
+	}
+	// Not taken from the URL
+	r := httptest.NewRequest(http.MethodGet, "/board/b1/archive?token="+ownerToken(b), nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "b1"})
+	w := httptest.NewRecorder()
+	a.handleDownload(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("token in query: status %d", w.Code)
+	}
+	if w := download("b1", "x"); w.Code != http.StatusForbidden {
+		t.Errorf("invalid token: status %d", w.Code)
+	}
+	if w := download("b1", creatorToken("b1", "bob")); w.Code != http.StatusForbidden {
+		t.Errorf("token of another user: status %d", w.Code)
+	}
+	for _, id := range []string{"b2", "..", "b1.owner"} {
+		if w := download(id, creatorToken(id, "owner")); w.Code != http.StatusNotFound {
+			t.Errorf("%q: status %d", id, w.Code)
+		}
+	}
+}
+
+func TestArchiver_Encrypted(t *testing.T) {
+	s, _ := newTestMemoryStore(t)
+	b := createTestBoard(t, s, "b1")
+	s.Save(&Message{Id: "m1", By: "owner", ByNickname: "Alice", Group: "b1", Category: "col01", Content: "Slow builds"}, AsNewMessage)
+	dir := t.TempDir()
+
+	a, err := newArchiver(s, dir, mustFieldCipher(t, "k1:"+testEncryptionKey('a')))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.archive("b1") {
+		t.Fatal("archive failed")
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, "b1.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(stored), encPrefix+"k1.") {
+		t.Fatalf("archive not encrypted: %.20q", stored)
+	}
+
+	download := func(a *Archiver) *BoardArchive {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/board/b1/archive", nil)
+		r.Header.Set(ownerTokenHeader, ownerToken(b))
+		r = mux.SetURLVars(r, map[string]string{"id": "b1"})
+		w := httptest.NewRecorder()
+		a.handleDownload(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d", w.Code)
+		}
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc BoardArchive
+		if err := json.NewDecoder(gz).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		return &doc
+	}
+	if doc := download(a); len(doc.Messages) != 1 || doc.Messages[0].Content != "Slow builds" {
+		t.Errorf("messages = %+v", doc.Messages)
+	}
+
+	// Rotate to a new key, then drop the old one
+	a.crypt = mustFieldCipher(t, "k2:"+testEncryptionKey('b')+",k1:"+testEncryptionKey('a'))
+	if n, ok := a.rotateEncryption(); !ok || n != 1 {
+		t.Fatalf("rotated %d, %t", n, ok)
+	}
+	if n, _ := a.rotateEncryption(); n != 0 {
+		t.Errorf("rotated %d again", n)
+	}
+	a.crypt = mustFieldCipher(t, "k2:"+testEncryptionKey('b'))
+	if doc := download(a); doc.Board.Name != "Retro" {
+		t.Errorf("board = %+v", doc.Board)
+	}
+
+	// Without the key, the archive isn't served
+	a.crypt = nil
+	r := httptest.NewRequest(http.MethodGet, "/board/b1/archive", nil)
+	r.Header.Set(ownerTokenHeader, ownerToken(b))
+	r = mux.SetURLVars(r, map[string]string{"id": "b1"})
+	w := httptest.NewRecorder()
+	a.handleDownload(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("without key: status %d", w.Code)
+	}
+}
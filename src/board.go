@@ -1,14 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
-	"net/url"
-	"slices"
 	"unicode/utf8"
 
 	"github.com/gorilla/mux"
@@ -33,6 +31,12 @@ type Board struct {
 	Team              string      `redis:"team"`
 	Owner             string      `redis:"owner"`
 	Creator           string      `redis:"creator"`
+	OwnerEpoch        int64       `redis:"ownerEpoch"`       // Bumped on every ownership transfer. Invalidates owner tokens issued earlier. See owner_token.go
+	PasscodeHash      string      `redis:"passcodeHash"`     // Empty when the board has no passcode. Never send to clients. See passcode.go
+	InviteOnly        bool        `redis:"inviteOnly"`       // New users need an invite to join. See invite.go
+	MaxContentLength  int         `redis:"maxContentLength"` // Characters per card or comment, 0 for the instance limit. See content.go
+	E2EE              bool        `redis:"e2ee"`             // Card content is encrypted by clients. The server only sees ciphertext. See content.go
+	TeamId            string      `redis:"teamId"`           // Empty for boards outside of a team space. See team.go
 	Status            BoardStatus `redis:"status"`
 	Mask              bool        `redis:"mask"`
 	Lock              bool        `redis:"lock"`
@@ -47,6 +51,12 @@ type BoardColumn struct {
 	Color     string `redis:"color" json:"color"`
 	Position  int    `redis:"pos" json:"pos"`
 	IsDefault bool   `redis:"isDefault" json:"isDefault"`
+
+	// Column rules, see columns.go
+	MaxCards    int    `redis:"maxCards" json:"maxCards"` // Cards per user. 0 is unlimited.
+	Mask        bool   `redis:"mask" json:"mask"`         // Masks the cards of this column, like Board.Mask does for all of them
+	Lock        bool   `redis:"lock" json:"lock"`         // No new cards, edits or moves in or out
+	Description string `redis:"desc" json:"description"`  // Prompt shown under the column name
 }
 
 func (b BoardColumn) String() string {
@@ -57,12 +67,19 @@ type CreateBoardReq struct {
 	Name                string         `json:"name"`
 	Team                string         `json:"team"`
 	Owner               string         `json:"owner"`
-	CfTurnstileResponse string         `json:"cfTurnstileResponse"`
+	ChallengeResponse   string         `json:"challengeResponse"`   // Response of the bot protection challenge. See challenge.go
+	CfTurnstileResponse string         `json:"cfTurnstileResponse"` // Deprecated: use challengeResponse
+	Passcode            string         `json:"passcode"`            // Optional
+	MaxContentLength    int            `json:"maxContentLength"`    // Optional. Can only lower the instance limit.
+	E2EE                bool           `json:"e2ee"`                // Optional. Clients encrypt card content with a key the server never sees.
+	Retention           string         `json:"retention"`           // Optional. Time until the board is deleted, e.g. "1h" or "14d". See expiry.go
+	TeamId              string         `json:"teamId"`              // Optional. Team space to create the board in. See team.go
 	Columns             []*BoardColumn `json:"columns"`
 }
 
 type CreateBoardRes struct {
-	Id string `json:"id"`
+	Id         string `json:"id"`
+	OwnerToken string `json:"ownerToken"` // Creator credential. Pass it as the "token" query param when joining the board.
 }
 
 type GetBoardRes struct {
@@ -71,17 +88,16 @@ type GetBoardRes struct {
 	IsOwner bool   `json:"isOwner"`
 }
 
-// Check if the request Origin header is in the configured allowed origins list.
+// Check if the request Origin header matches the configured allowed origins. See origins.go
 func isOriginAllowed(r *http.Request) bool {
-	origin := r.Header.Get("Origin")
-	return origin != "" && slices.Contains(config.Server.AllowedOrigins, origin)
+	return isOriginAllowedValue(r.Header.Get("Origin"))
 }
 
 // Creates a new board and returns it
-func HandleCreateBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request) {
+func HandleCreateBoard(c Store, w http.ResponseWriter, r *http.Request) {
 	// Validate Origin
 	if !isOriginAllowed(r) {
-		slog.Warn("Rejected request with disallowed origin", "origin", r.Header.Get("Origin"), "remote", r.RemoteAddr)
+		slog.Warn("Rejected request with disallowed origin", "origin", r.Header.Get("Origin"), "remote", remoteIP(r))
 		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
@@ -99,24 +115,26 @@ func HandleCreateBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Add Turnstile validation
-	if envConfig.TurnstileEnabled {
-		if createReq.CfTurnstileResponse == "" {
-			http.Error(w, "CAPTCHA verification required", http.StatusBadRequest)
-			return
-		}
+	// Signed-in users always create boards as themselves
+	if s, ok := sessionFromRequest(r); ok {
+		createReq.Owner = s.UserId
+	} else if loginRequiredToCreate() || isIdentityUserId(createReq.Owner) {
+		http.Error(w, "Login required", http.StatusUnauthorized)
+		return
+	}
 
-		// Get client IP (consider X-Forwarded-For if behind proxy)
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			slog.Error("Error parsing remote address", "error", err)
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+	// Bot protection. See challenge.go
+	if challenge != nil {
+		response := cmp.Or(createReq.ChallengeResponse, createReq.CfTurnstileResponse)
+		if response == "" {
+			http.Error(w, "CAPTCHA verification required", http.StatusBadRequest)
 			return
 		}
 
-		valid, err := verifyTurnstile(createReq.CfTurnstileResponse, ip)
+		ip := remoteIP(r)
+		valid, err := challenge.Verify(r.Context(), response, ip)
 		if err != nil || !valid {
-			slog.Warn("Turnstile verification failed", "error", err, "ip", ip)
+			slog.Warn("Challenge verification failed", "provider", challenge.Name(), "error", err, "ip", ip)
 			http.Error(w, "CAPTCHA verification failed", http.StatusBadRequest)
 			return
 		}
@@ -130,7 +148,7 @@ func HandleCreateBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request
 	// 	w.WriteHeader(http.StatusBadRequest)
 	// 	return
 	// }
-	if len(createReq.Columns) == 0 || len(createReq.Columns) > 5 {
+	if len(createReq.Columns) == 0 || len(createReq.Columns) > maxColumns() {
 		slog.Error("Invalid Columns data in create board request payload")
 		http.Error(w, "Invalid columns data", http.StatusBadRequest)
 		return
@@ -143,7 +161,7 @@ func HandleCreateBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request
 			return
 		}
 		textLen := utf8.RuneCountInString(col.Text)
-		if len(col.Id) > MaxColumnIdSizeBytes || len(col.Color) > MaxColorSizeBytes || textLen > config.Data.MaxCategoryTextLength {
+		if len(col.Id) > MaxColumnIdSizeBytes || len(col.Color) > MaxColorSizeBytes || textLen > config.Data.MaxCategoryTextLength || !validColumnRules(col) {
 			slog.Error("Column info exceeds limit in create board request payload", "col", col.Id, "len", textLen, "len-color", len(col.Color))
 			http.Error(w, "Column info exceeds limit", http.StatusBadRequest)
 			return
@@ -169,17 +187,68 @@ func HandleCreateBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if createReq.Passcode != "" && !validPasscodeLength(createReq.Passcode) {
+		slog.Error("Invalid passcode length in create board request payload")
+		http.Error(w, fmt.Sprintf("Passcode must be %d to %d characters", MinPasscodeLength, MaxPasscodeLength), http.StatusBadRequest)
+		return
+	}
+
+	if createReq.MaxContentLength < 0 {
+		slog.Error("Invalid max content length in create board request payload")
+		http.Error(w, "Invalid max content length", http.StatusBadRequest)
+		return
+	}
+
+	retention, err := parseRetention(createReq.Retention)
+	if err != nil {
+		slog.Error("Invalid retention in create board request payload", "retention", createReq.Retention)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var team *Team
+	if createReq.TeamId != "" {
+		if !teamsEnabled() || !validTeamId(createReq.TeamId) {
+			http.Error(w, "Team not found", http.StatusBadRequest)
+			return
+		}
+		var found bool
+		if team, found = c.GetTeam(createReq.TeamId); !found {
+			http.Error(w, "Team not found", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Start creation
 	id := shortuuid.New()
-	board := &Board{Id: id, Name: createReq.Name, Team: createReq.Team, Owner: createReq.Owner, Creator: createReq.Owner, Status: InProgress, Lock: false, Mask: true}
+	board := &Board{Id: id, Name: createReq.Name, Team: createReq.Team, Owner: createReq.Owner, Creator: createReq.Owner, Status: InProgress, Lock: false, Mask: true, MaxContentLength: createReq.MaxContentLength, E2EE: createReq.E2EE}
+	if team != nil {
+		board.TeamId, board.Team = team.Id, team.Name
+	}
 
-	// Save to Redis
-	if ok := c.CreateBoard(board, createReq.Columns); !ok {
+	if createReq.Passcode != "" {
+		hash, err := hashPasscode(createReq.Passcode)
+		if err != nil {
+			slog.Error("Error hashing board passcode", "details", err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		board.PasscodeHash = hash
+	}
+
+	// Save to store
+	if ok := c.CreateBoard(board, createReq.Columns, retention); !ok {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	if team != nil {
+		// The store sets the creation and expiry times
+		if stored, ok := c.GetBoard(board.Id); !ok || !c.AddTeamBoard(team.Id, stored, teamRetention()) {
+			slog.Error("Failed to add board to team", "board", board.Id, "team", team.Id)
+		}
+	}
 
-	data, err := json.Marshal(CreateBoardRes{Id: board.Id})
+	data, err := json.Marshal(CreateBoardRes{Id: board.Id, OwnerToken: creatorToken(board.Id, board.Creator)})
 	if err != nil {
 		slog.Error("Error marshalling CreateBoardRes", "details", err.Error())
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -193,35 +262,9 @@ func HandleCreateBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request
 	w.Write(data)
 }
 
-func verifyTurnstile(token, remoteIP string) (bool, error) {
-	if envConfig.TurnstileSecretKey == "" {
-		return false, fmt.Errorf("TURNSTILE_SECRET_KEY not configured")
-	}
-
-	data := url.Values{}
-	data.Set("secret", envConfig.TurnstileSecretKey)
-	data.Set("response", token)
-	data.Set("remoteip", remoteIP)
-
-	resp, err := http.PostForm(config.Server.TurnstileSiteVerifyUrl, data)
-	if err != nil {
-		return false, err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Success bool `json:"success"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false, err
-	}
-
-	return result.Success, nil
-}
-
 // Deprecated: No longer used
 // Returns board by id
-func HandleGetBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request) {
+func HandleGetBoard(c Store, w http.ResponseWriter, r *http.Request) {
 
 	// Validate request
 	id, ok := mux.Vars(r)["id"]
@@ -253,7 +296,7 @@ func HandleGetBoard(c *RedisConnector, w http.ResponseWriter, r *http.Request) {
 
 // Deprecated: No longer used
 // Returns messages by board
-func HandleRefresh(red *RedisConnector, w http.ResponseWriter, r *http.Request) {
+func HandleRefresh(red Store, w http.ResponseWriter, r *http.Request) {
 	// Todo: Validate properly
 	// Validate request.
 	id, ok := mux.Vars(r)["id"]
@@ -281,7 +324,7 @@ func HandleRefresh(red *RedisConnector, w http.ResponseWriter, r *http.Request)
 		for _, m := range msgs {
 			ids = append(ids, m.Id)
 		}
-		likesInfo, likesOk := red.GetLikesInfo(user, ids...)
+		likesInfo, likesOk := red.GetLikesInfo(id, user, ids...)
 
 		for _, m := range msgs {
 			msgRes := m.NewMessageResponse()
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets in the bbolt file
+var (
+	bucketBoards      = []byte("boards")        // boardId -> JSON encoded boardDoc
+	bucketBoardExpiry = []byte("boards_expiry") // boardId -> AutoDeleteAtUtc (big endian uint64). Lets the sweeper skip decoding documents.
+	bucketTeams       = []byte("teams")         // teamId -> JSON encoded teamDoc
+	bucketTeamExpiry  = []byte("teams_expiry")  // teamId -> AutoDeleteAtUtc (big endian uint64)
+	bucketUserBoards  = []byte("user_boards")   // userId NUL boardId -> empty. Boards of a user are found with a prefix scan.
+	bucketUserTokens  = []byte("user_tokens")   // userId -> empty, once the user token was acknowledged
+)
+
+// boltDocs keeps board documents in a bbolt file, so boards survive restarts and can be kept for months.
+type boltDocs struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the bbolt file at path and returns a DocStore backed by it.
+// Boards expire timeToLive after creation and are deleted by the sweeper.
+func NewBoltStore(path string, timeToLive time.Duration) (*DocStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("creating directory for %s: %w", path, err)
+		}
+	}
+
+	// Timeout guards against a second instance holding the file lock.
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt file %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketBoards, bucketBoardExpiry, bucketTeams, bucketTeamExpiry, bucketUserBoards, bucketUserTokens} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating bolt buckets: %w", err)
+	}
+
+	s := newDocStore(&boltDocs{db: db}, timeToLive)
+	// Catch up on boards that expired while the app was down
+	if n := s.deleteExpired(); n > 0 {
+		slog.Info("Deleted expired boards", "count", n)
+	}
+	return s, nil
+}
+
+func (b *boltDocs) get(boardId string) (*boardDoc, bool) {
+	d := &boardDoc{}
+	if !b.getDoc(bucketBoards, boardId, d) {
+		return nil, false
+	}
+	return d, true
+}
+
+func (b *boltDocs) put(d *boardDoc) bool {
+	return b.putDoc(bucketBoards, bucketBoardExpiry, d.Board.Id, d, d.Board.AutoDeleteAtUtc)
+}
+
+func (b *boltDocs) del(boardId string) bool {
+	return b.delDoc(bucketBoards, bucketBoardExpiry, boardId)
+}
+
+func (b *boltDocs) expired(nowUtcSeconds int64) []string {
+	return b.expiredDocs(bucketBoardExpiry, nowUtcSeconds)
+}
+
+func (b *boltDocs) getTeam(teamId string) (*teamDoc, bool) {
+	d := &teamDoc{}
+	if !b.getDoc(bucketTeams, teamId, d) {
+		return nil, false
+	}
+	return d, true
+}
+
+func (b *boltDocs) putTeam(d *teamDoc) bool {
+	return b.putDoc(bucketTeams, bucketTeamExpiry, d.Team.Id, d, d.Team.AutoDeleteAtUtc)
+}
+
+func (b *boltDocs) delTeam(teamId string) bool {
+	return b.delDoc(bucketTeams, bucketTeamExpiry, teamId)
+}
+
+func (b *boltDocs) expiredTeams(nowUtcSeconds int64) []string {
+	return b.expiredDocs(bucketTeamExpiry, nowUtcSeconds)
+}
+
+func userBoardKey(userId, boardId string) []byte {
+	return []byte(userId + "\x00" + boardId)
+}
+
+func (b *boltDocs) boardsOfUser(userId string) []string {
+	var ids []string
+	prefix := userBoardKey(userId, "")
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketUserBoards).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			ids = append(ids, string(k[len(prefix):]))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to read user boards from bolt", "err", err, "user", userId)
+	}
+	return ids
+}
+
+func (b *boltDocs) addUserBoard(userId, boardId string) bool {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUserBoards).Put(userBoardKey(userId, boardId), []byte{})
+	})
+	if err != nil {
+		slog.Error("Failed to write user board to bolt", "err", err, "user", userId, "board", boardId)
+		return false
+	}
+	return true
+}
+
+func (b *boltDocs) delUserBoard(userId, boardId string) bool {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketUserBoards).Delete(userBoardKey(userId, boardId)); err != nil {
+			return err
+		}
+		prefix := userBoardKey(userId, "")
+		if k, _ := tx.Bucket(bucketUserBoards).Cursor().Seek(prefix); k == nil || !bytes.HasPrefix(k, prefix) {
+			return tx.Bucket(bucketUserTokens).Delete([]byte(userId))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to delete user board from bolt", "err", err, "user", userId, "board", boardId)
+		return false
+	}
+	return true
+}
+
+func (b *boltDocs) userTokenAcked(userId string) bool {
+	acked := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		acked = tx.Bucket(bucketUserTokens).Get([]byte(userId)) != nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to read user token from bolt", "err", err, "user", userId)
+		return true // Don't issue the token on errors
+	}
+	return acked
+}
+
+func (b *boltDocs) ackUserToken(userId string) bool {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUserTokens).Put([]byte(userId), []byte{})
+	})
+	if err != nil {
+		slog.Error("Failed to write user token to bolt", "err", err, "user", userId)
+		return false
+	}
+	return true
+}
+
+// getDoc decodes the document with the id into d. Returns false if there is none.
+func (b *boltDocs) getDoc(bucket []byte, id string, d any) bool {
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, d)
+	})
+	if err != nil {
+		slog.Error("Failed to read document from bolt", "err", err, "bucket", string(bucket), "id", id)
+		return false
+	}
+	return found
+}
+
+// putDoc writes the document, and its expiry to the expiry bucket.
+func (b *boltDocs) putDoc(bucket, expiryBucket []byte, id string, d any, autoDeleteAtUtc int64) bool {
+	data, err := json.Marshal(d)
+	if err != nil {
+		slog.Error("Failed to marshal document", "err", err, "bucket", string(bucket), "id", id)
+		return false
+	}
+
+	expiry := make([]byte, 8)
+	binary.BigEndian.PutUint64(expiry, uint64(autoDeleteAtUtc))
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(id)
+		if err := tx.Bucket(bucket).Put(key, data); err != nil {
+			return err
+		}
+		return tx.Bucket(expiryBucket).Put(key, expiry)
+	})
+	if err != nil {
+		slog.Error("Failed to write document to bolt", "err", err, "bucket", string(bucket), "id", id)
+		return false
+	}
+	return true
+}
+
+func (b *boltDocs) delDoc(bucket, expiryBucket []byte, id string) bool {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(id)
+		if err := tx.Bucket(bucket).Delete(key); err != nil {
+			return err
+		}
+		return tx.Bucket(expiryBucket).Delete(key)
+	})
+	if err != nil {
+		slog.Error("Failed to delete document from bolt", "err", err, "bucket", string(bucket), "id", id)
+		return false
+	}
+	return true
+}
+
+func (b *boltDocs) expiredDocs(expiryBucket []byte, nowUtcSeconds int64) []string {
+	var ids []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(expiryBucket).ForEach(func(k, v []byte) error {
+			if len(v) == 8 && int64(binary.BigEndian.Uint64(v)) <= nowUtcSeconds {
+				ids = append(ids, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		slog.Error("Failed to scan expiry in bolt", "err", err, "bucket", string(expiryBucket))
+	}
+	return ids
+}
+
+func (b *boltDocs) close() {
+	if err := b.db.Close(); err != nil {
+		slog.Error("Failed to close bolt file", "err", err)
+	}
+}
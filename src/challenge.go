@@ -0,0 +1,269 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bot protection for board creation.
+// The provider is selected with provider in [challenge] of config.toml. The frontend shows the provider's widget (see config.js),
+// and sends the response it gets with the create request. HandleCreateBoard verifies it.
+// securityHeaders allows the provider's origins in the CSP.
+//
+// Turnstile, hCaptcha and reCAPTCHA are verified with their siteverify endpoints, which can be pointed at a local stub.
+// "pow" is a proof-of-work challenge served by the app itself, for instances that can't use a third party.
+const (
+	ChallengeNone      = "none"
+	ChallengeTurnstile = "turnstile"
+	ChallengeHCaptcha  = "hcaptcha"
+	ChallengeReCaptcha = "recaptcha"
+	ChallengePoW       = "pow"
+)
+
+type challengeProvider interface {
+	Name() string
+	SiteKey() string      // Public key for the widget. Empty when there is none.
+	ScriptURL() string    // Script of the widget. Empty when the frontend doesn't need one.
+	CSPSources() []string // Origins the widget loads scripts, styles and frames from, and connects to.
+	// Verify checks the response the client got from the challenge.
+	Verify(ctx context.Context, response, remoteIP string) (bool, error)
+}
+
+// challenge is nil when bot protection is disabled.
+var challenge challengeProvider
+
+// newChallengeProvider sets up the provider selected in config. Without one, TURNSTILE_ENABLED=true selects Turnstile.
+func newChallengeProvider(env EnvironmentConfig, store Store) (challengeProvider, error) {
+	cfg := config.Challenge
+	name := cfg.Provider
+	if name == "" && env.TurnstileEnabled {
+		name = ChallengeTurnstile
+	}
+
+	switch name {
+	case "", ChallengeNone:
+		return nil, nil
+	case ChallengeTurnstile:
+		return &siteVerifyProvider{
+			name:      ChallengeTurnstile,
+			siteKey:   cmp.Or(env.ChallengeSiteKey, env.TurnstileSiteKey),
+			secret:    cmp.Or(env.ChallengeSecretKey, env.TurnstileSecretKey),
+			verifyURL: cmp.Or(cfg.TurnstileVerifyUrl, config.Server.TurnstileSiteVerifyUrl),
+			scriptURL: "https://challenges.cloudflare.com/turnstile/v0/api.js?render=explicit",
+			sources:   []string{"https://challenges.cloudflare.com"},
+		}, nil
+	case ChallengeHCaptcha:
+		// Test keys always pass. https://docs.hcaptcha.com/#integration-testing-test-keys
+		siteKey, secret, err := siteVerifyKeys(ChallengeHCaptcha, env.ChallengeSiteKey, env.ChallengeSecretKey,
+			"10000000-ffff-ffff-ffff-000000000001", "0x0000000000000000000000000000000000000000")
+		if err != nil {
+			return nil, err
+		}
+		return &siteVerifyProvider{
+			name:      ChallengeHCaptcha,
+			siteKey:   siteKey,
+			secret:    secret,
+			verifyURL: cfg.HCaptchaVerifyUrl,
+			scriptURL: "https://js.hcaptcha.com/1/api.js?render=explicit",
+			sources:   []string{"https://hcaptcha.com", "https://*.hcaptcha.com"},
+		}, nil
+	case ChallengeReCaptcha:
+		// Test keys always pass. https://developers.google.com/recaptcha/docs/faq
+		siteKey, secret, err := siteVerifyKeys(ChallengeReCaptcha, env.ChallengeSiteKey, env.ChallengeSecretKey,
+			"6LeIxAcTAAAAAJcZVRqyHh71UMIEGNQ_MXjiZKhI", "6LeIxAcTAAAAAGG-vFI1TnRWxMZNFuojJ4WifJWe")
+		if err != nil {
+			return nil, err
+		}
+		return &siteVerifyProvider{
+			name:      ChallengeReCaptcha,
+			siteKey:   siteKey,
+			secret:    secret,
+			verifyURL: cfg.ReCaptchaVerifyUrl,
+			scriptURL: "https://www.google.com/recaptcha/api.js?render=explicit",
+			sources:   []string{"https://www.google.com/recaptcha/", "https://www.gstatic.com/recaptcha/", "https://recaptcha.google.com/recaptcha/"},
+			minScore:  cfg.ReCaptchaMinScore,
+		}, nil
+	case ChallengePoW:
+		expiry, err := parseDuration(cfg.PoWExpiry)
+		if err != nil || expiry <= 0 {
+			return nil, fmt.Errorf("invalid pow_expiry %q", cfg.PoWExpiry)
+		}
+		if cfg.PoWDifficulty < 1 || cfg.PoWDifficulty > 32 {
+			return nil, fmt.Errorf("pow_difficulty must be 1 to 32, got %d", cfg.PoWDifficulty)
+		}
+		return &powProvider{store: store, difficulty: cfg.PoWDifficulty, expiry: expiry, now: time.Now}, nil
+	}
+	return nil, fmt.Errorf("unknown challenge provider %q", name)
+}
+
+// siteVerifyKeys returns the site and secret key of a provider. Missing keys are an error, so a forgotten key can't leave it accepting everything.
+// The provider's test keys accept every response. They are only used without keys, when use_test_keys is set for development.
+func siteVerifyKeys(name, siteKey, secret, testSiteKey, testSecret string) (string, string, error) {
+	if siteKey != "" && secret != "" {
+		return siteKey, secret, nil
+	}
+	if config.Challenge.UseTestKeys {
+		slog.Warn("Using test keys for bot protection. Every response passes, don't use this in production.", "provider", name)
+		return testSiteKey, testSecret, nil
+	}
+	return "", "", fmt.Errorf("%s needs CHALLENGE_SITE_KEY and CHALLENGE_SECRET_KEY, or use_test_keys in [challenge] for development", name)
+}
+
+// siteVerifyProvider verifies responses of widgets with a siteverify endpoint. Turnstile, hCaptcha and reCAPTCHA share the same API.
+type siteVerifyProvider struct {
+	name      string
+	siteKey   string
+	secret    string
+	verifyURL string
+	scriptURL string
+	sources   []string
+	minScore  float64 // Responses scoring lower (reCAPTCHA v3) fail. 0 ignores scores.
+}
+
+var siteVerifyClient = &http.Client{Timeout: 10 * time.Second}
+
+func (p *siteVerifyProvider) Name() string         { return p.name }
+func (p *siteVerifyProvider) SiteKey() string      { return p.siteKey }
+func (p *siteVerifyProvider) ScriptURL() string    { return p.scriptURL }
+func (p *siteVerifyProvider) CSPSources() []string { return p.sources }
+
+func (p *siteVerifyProvider) Verify(ctx context.Context, response, remoteIP string) (bool, error) {
+	if p.secret == "" {
+		return false, fmt.Errorf("%s secret key not configured", p.name)
+	}
+
+	data := url.Values{}
+	data.Set("secret", p.secret)
+	data.Set("response", response)
+	if remoteIP != "" {
+		data.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.verifyURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := siteVerifyClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s siteverify returned %s", p.name, resp.Status)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		Score      *float64 `json:"score"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	if !result.Success {
+		slog.Debug("Challenge response rejected", "provider", p.name, "errorCodes", result.ErrorCodes)
+		return false, nil
+	}
+	if p.minScore > 0 && result.Score != nil && *result.Score < p.minScore {
+		slog.Debug("Challenge score too low", "provider", p.name, "score", *result.Score)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Proof-of-work.
+// GET /api/challenge returns a signed challenge "<difficulty>.<expires unix>.<nonce>.<signature>". The browser looks for a counter
+// for which SHA-256 of "<challenge>.<counter>" starts with <difficulty> zero bits, and sends "<challenge>.<counter>" as the response.
+// Each extra bit of difficulty doubles the average work. Solutions can only be used once.
+type powProvider struct {
+	store      Store
+	difficulty int
+	expiry     time.Duration
+	now        func() time.Time
+}
+
+type powChallengeRes struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+}
+
+func (p *powProvider) Name() string         { return ChallengePoW }
+func (p *powProvider) SiteKey() string      { return "" }
+func (p *powProvider) ScriptURL() string    { return "" }
+func (p *powProvider) CSPSources() []string { return nil }
+
+func signPoWChallenge(challenge string) string {
+	mac := hmac.New(sha256.New, []byte(tokenSecret))
+	mac.Write([]byte("pow\x00" + challenge))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newChallenge issues a challenge with the configured difficulty.
+func (p *powProvider) newChallenge() string {
+	challenge := strconv.Itoa(p.difficulty) + "." + strconv.FormatInt(p.now().Add(p.expiry).Unix(), 10) + "." + rand.Text()
+	return challenge + "." + signPoWChallenge(challenge)
+}
+
+func (p *powProvider) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(powChallengeRes{Challenge: p.newChallenge(), Difficulty: p.difficulty})
+}
+
+func (p *powProvider) Verify(_ context.Context, response, remoteIP string) (bool, error) {
+	parts := strings.Split(response, ".")
+	if len(parts) != 5 {
+		return false, errors.New("malformed proof-of-work response")
+	}
+	challenge := strings.Join(parts[:3], ".")
+	if !tokenMatches(parts[3], signPoWChallenge(challenge)) {
+		return false, errors.New("invalid proof-of-work challenge signature")
+	}
+	// Signed by us, so these parse
+	difficulty, _ := strconv.Atoi(parts[0])
+	expires, _ := strconv.ParseInt(parts[1], 10, 64)
+	remaining := time.Unix(expires, 0).Sub(p.now())
+	if remaining <= 0 {
+		return false, errors.New("proof-of-work challenge expired")
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(response))) < difficulty {
+		return false, nil
+	}
+
+	// Single use. Spent challenges are counted like requests, until they expire anyway.
+	uses, _, ok := p.store.CountRequest("pow", parts[2], remaining)
+	if !ok {
+		slog.Warn("Cannot check proof-of-work reuse. Accepting.", "ip", remoteIP)
+		return true, nil
+	}
+	if uses > 1 {
+		return false, errors.New("proof-of-work challenge already used")
+	}
+	return true, nil
+}
+
+func leadingZeroBits(sum [32]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSiteVerifyStub answers like the siteverify endpoints. "pass" succeeds, anything else fails.
+// score is returned when not nil, like reCAPTCHA v3.
+func newSiteVerifyStub(t *testing.T, secret string, score *float64) *httptest.Server {
+	t.Helper()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Method != http.MethodPost || r.PostForm.Get("secret") != secret || r.PostForm.Get("remoteip") != "10.0.0.1" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		res := map[string]any{"success": r.PostForm.Get("response") == "pass"}
+		if score != nil {
+			res["score"] = *score
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func TestSiteVerifyProviders(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+
+	for _, tt := range []struct {
+		provider, source string
+		setURL           func(url string)
+	}{
+		{ChallengeTurnstile, "https://challenges.cloudflare.com", func(url string) { config.Challenge.TurnstileVerifyUrl = url }},
+		{ChallengeHCaptcha, "https://*.hcaptcha.com", func(url string) { config.Challenge.HCaptchaVerifyUrl = url }},
+		{ChallengeReCaptcha, "https://www.gstatic.com/recaptcha/", func(url string) { config.Challenge.ReCaptchaVerifyUrl = url }},
+	} {
+		t.Run(tt.provider, func(t *testing.T) {
+			config.Challenge.Provider = tt.provider
+			tt.setURL(newSiteVerifyStub(t, "secret", nil).URL)
+
+			p, err := newChallengeProvider(EnvironmentConfig{ChallengeSiteKey: "site", ChallengeSecretKey: "secret"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name() != tt.provider || p.SiteKey() != "site" || p.ScriptURL() == "" || !strings.Contains(strings.Join(p.CSPSources(), " "), tt.source) {
+				t.Errorf("unexpected provider %s %q %q %v", p.Name(), p.SiteKey(), p.ScriptURL(), p.CSPSources())
+			}
+			if ok, err := p.Verify(context.Background(), "pass", "10.0.0.1"); !ok || err != nil {
+				t.Errorf("valid response: %v, %v", ok, err)
+			}
+			if ok, err := p.Verify(context.Background(), "fail", "10.0.0.1"); ok || err != nil {
+				t.Errorf("invalid response: %v, %v", ok, err)
+			}
+			// Wrong secret, the stub answers 400
+			wrong, _ := newChallengeProvider(EnvironmentConfig{ChallengeSiteKey: "site", ChallengeSecretKey: "other"}, nil)
+			if ok, err := wrong.Verify(context.Background(), "pass", "10.0.0.1"); ok || err == nil {
+				t.Errorf("wrong secret: %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestReCaptchaMinScore(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Challenge.Provider = ChallengeReCaptcha
+	config.Challenge.ReCaptchaMinScore = 0.5
+
+	for _, score := range []float64{0.3, 0.9} {
+		config.Challenge.ReCaptchaVerifyUrl = newSiteVerifyStub(t, "secret", &score).URL
+		p, _ := newChallengeProvider(EnvironmentConfig{ChallengeSiteKey: "site", ChallengeSecretKey: "secret"}, nil)
+		if ok, _ := p.Verify(context.Background(), "pass", "10.0.0.1"); ok != (score >= 0.5) {
+			t.Errorf("score %v: verified %v", score, ok)
+		}
+	}
+}
+
+func TestNewChallengeProvider(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+
+	for _, name := range []string{"", ChallengeNone} {
+		config.Challenge.Provider = name
+		if p, err := newChallengeProvider(EnvironmentConfig{}, nil); p != nil || err != nil {
+			t.Errorf("provider %q: %v, %v", name, p, err)
+		}
+	}
+	// Missing keys fail, unless test keys are asked for
+	for _, name := range []string{ChallengeHCaptcha, ChallengeReCaptcha} {
+		config.Challenge.Provider = name
+		config.Challenge.UseTestKeys = false
+		if p, err := newChallengeProvider(EnvironmentConfig{ChallengeSiteKey: "site"}, nil); p != nil || err == nil {
+			t.Errorf("%s without a secret key: %v, %v", name, p, err)
+		}
+		config.Challenge.UseTestKeys = true
+		if p, err := newChallengeProvider(EnvironmentConfig{}, nil); err != nil || p.SiteKey() == "" {
+			t.Errorf("%s with test keys: %v", name, err)
+		}
+	}
+
+	config.Challenge.Provider = "friendlycaptcha"
+	if _, err := newChallengeProvider(EnvironmentConfig{}, nil); err == nil {
+		t.Error("unknown provider accepted")
+	}
+	config.Challenge.Provider = ChallengePoW
+	config.Challenge.PoWExpiry = "5m"
+	config.Challenge.PoWDifficulty = 0
+	if _, err := newChallengeProvider(EnvironmentConfig{}, nil); err == nil {
+		t.Error("pow without difficulty accepted")
+	}
+}
+
+// solvePoW does what the browser does. See frontend/src/utils/pow.ts
+func solvePoW(challenge string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		response := challenge + "." + strconv.Itoa(counter)
+		if leadingZeroBits(sha256.Sum256([]byte(response))) >= difficulty {
+			return response
+		}
+	}
+}
+
+func TestPoWProvider(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	now := time.Now()
+	p := &powProvider{store: store, difficulty: 8, expiry: time.Minute, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	w := httptest.NewRecorder()
+	p.handleChallenge(w, httptest.NewRequest(http.MethodGet, "/api/challenge", nil))
+	var res powChallengeRes
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil || res.Difficulty != 8 {
+		t.Fatalf("challenge %+v, %v", res, err)
+	}
+
+	response := solvePoW(res.Challenge, res.Difficulty)
+	if ok, err := p.Verify(ctx, response, "10.0.0.1"); !ok || err != nil {
+		t.Fatalf("solved challenge: %v, %v", ok, err)
+	}
+	if ok, err := p.Verify(ctx, response, "10.0.0.1"); ok || err == nil {
+		t.Error("solution used twice")
+	}
+
+	// Not enough work. Counters are tried until one fails the difficulty.
+	fresh := p.newChallenge()
+	for counter := 0; ; counter++ {
+		unsolved := fresh + "." + strconv.Itoa(counter)
+		if leadingZeroBits(sha256.Sum256([]byte(unsolved))) < 8 {
+			if ok, _ := p.Verify(ctx, unsolved, "10.0.0.1"); ok {
+				t.Error("unsolved challenge accepted")
+			}
+			break
+		}
+	}
+
+	// Easier difficulty than issued
+	easy := strings.Replace(p.newChallenge(), "8.", "1.", 1)
+	if ok, err := p.Verify(ctx, solvePoW(easy, 1), "10.0.0.1"); ok || err == nil {
+		t.Error("challenge with changed difficulty accepted")
+	}
+
+	expiring := solvePoW(p.newChallenge(), 8)
+	now = now.Add(2 * time.Minute)
+	if ok, err := p.Verify(ctx, expiring, "10.0.0.1"); ok || err == nil {
+		t.Error("expired challenge accepted")
+	}
+
+	if ok, err := p.Verify(ctx, "garbage", "10.0.0.1"); ok || err == nil {
+		t.Error("malformed response accepted")
+	}
+}
+
+func TestHandleCreateBoard_Challenge(t *testing.T) {
+	prevConfig, prevChallenge := config, challenge
+	t.Cleanup(func() { config, challenge = prevConfig, prevChallenge })
+	config.Server.AllowedOrigins = []string{"https://localhost"}
+	config.Data.MaxTextLength = 80
+	config.Data.MaxCategoryTextLength = 80
+	config.Challenge.Provider = ChallengeHCaptcha
+	config.Challenge.HCaptchaVerifyUrl = newSiteVerifyStub(t, "secret", nil).URL
+	challenge, _ = newChallengeProvider(EnvironmentConfig{ChallengeSiteKey: "site", ChallengeSecretKey: "secret"}, nil)
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	create := func(fields string) int {
+		body := `{"name":"Retro","owner":"alice","columns":[{"id":"col01","text":"Good","color":"green","pos":1}]` + fields + `}`
+		r := httptest.NewRequest(http.MethodPost, "/api/board/create", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Origin", "https://localhost")
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		HandleCreateBoard(store, w, r)
+		return w.Code
+	}
+
+	if code := create(""); code != http.StatusBadRequest {
+		t.Errorf("without response: %d", code)
+	}
+	if code := create(`,"challengeResponse":"fail"`); code != http.StatusBadRequest {
+		t.Errorf("failed challenge: %d", code)
+	}
+	if code := create(`,"challengeResponse":"pass"`); code != http.StatusCreated {
+		t.Errorf("passed challenge: %d", code)
+	}
+	// Older clients
+	if code := create(`,"cfTurnstileResponse":"pass"`); code != http.StatusCreated {
+		t.Errorf("passed challenge in cfTurnstileResponse: %d", code)
+	}
+}
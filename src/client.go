@@ -3,7 +3,7 @@ package main
 import (
 	"log/slog"
 	"net/http"
-	"slices"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -28,20 +28,51 @@ const (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		origin := r.Header.Get("Origin")
-		return origin != "" && slices.Contains(config.Server.AllowedOrigins, origin)
-	},
+	// Negotiate permessage-deflate. Join snapshots (RegisterResponse) are large and compress well.
+	EnableCompression: true,
+	// Preferred first. See codec.go
+	Subprotocols: []string{SubprotocolMsgpack, SubprotocolJSON},
+	CheckOrigin:  isOriginAllowed,
 }
 
 type Client struct {
-	hub     *Hub
-	conn    *websocket.Conn
-	limiter *ClientRateLimiter
-	send    chan any
-	id      string // This is the user uuid
-	xid     string // The is the externally exposed uuid of the user
-	group   string // This can be a board/room
+	hub    *Hub
+	conn   *websocket.Conn
+	limits *ClientLimits
+	codec  wireCodec // JSON or MessagePack, negotiated through Sec-WebSocket-Protocol
+	send   chan any
+	id     string                 // This is the user uuid
+	xid    string                 // The is the externally exposed uuid of the user
+	group  string                 // This can be a board/room
+	token  atomic.Pointer[string] // Owner or creator token. Replaced by the hub when ownership is transferred to this user.
+	// Moderator token. Set by the hub when the owner makes this user a moderator, cleared when they are removed.
+	modToken atomic.Pointer[string]
+	// User token, only on the first connection of an anonymous user. Sent with the RegisterResponse. See user_boards.go
+	userToken string
+}
+
+// credential returns the owner token presented by the client, or "" if it has none.
+func (c *Client) credential() string {
+	if t := c.token.Load(); t != nil {
+		return *t
+	}
+	return ""
+}
+
+func (c *Client) setCredential(token string) {
+	c.token.Store(&token)
+}
+
+// moderatorCredential returns the moderator token presented by the client, or "" if it has none.
+func (c *Client) moderatorCredential() string {
+	if t := c.modToken.Load(); t != nil {
+		return *t
+	}
+	return ""
+}
+
+func (c *Client) setModeratorCredential(token string) {
+	c.modToken.Store(&token)
 }
 
 func (c *Client) read() {
@@ -60,7 +91,10 @@ func (c *Client) read() {
 	for {
 		// Read from socket and parse
 		var event Event
-		err := c.conn.ReadJSON(&event)
+		_, data, err := c.conn.ReadMessage()
+		if err == nil {
+			err = c.codec.decode(data, &event)
+		}
 		if err != nil {
 			slog.Error("Leave", "err", err, "user", c.id)
 			break
@@ -70,11 +104,23 @@ func (c *Client) read() {
 		event.Group = c.group
 		event.By = c.id
 		event.Xid = c.xid
+		event.Token = c.credential()
+		event.ModeratorToken = c.moderatorCredential()
 
-		// Rate limit: drop message if client is sending too fast
-		if c.limiter != nil && !c.limiter.Allow() {
-			slog.Warn("WebSocket rate limit exceeded, dropping message", "user", c.id, "type", event.Type)
-			continue
+		// Rate limit: drop message if client is sending too fast, disconnect if it keeps doing so
+		if c.limits != nil {
+			allowed, disconnect := c.limits.Allow(event.Type)
+			if disconnect {
+				slog.Warn("WebSocket rate limit strikes exceeded, disconnecting", "user", c.id, "board", c.group)
+				(&RateLimitedEvent{Group: c.group, Xid: c.xid}).Handle(nil, c.hub)
+				msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, CloseRateLimited)
+				_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+				break
+			}
+			if !allowed {
+				slog.Warn("WebSocket rate limit exceeded, dropping message", "user", c.id, "type", event.Type)
+				continue
+			}
 		}
 
 		event.Handle(c.hub)
@@ -97,7 +143,16 @@ func (c *Client) write() {
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			if err := c.conn.WriteJSON(message); err != nil {
+			if f, ok := message.(closeFrame); ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, f.reason))
+				return
+			}
+			data, err := c.codec.encode(message)
+			if err != nil {
+				slog.Error("Error encoding message for socket", "err", err, "user", c.id)
+				continue
+			}
+			if err := c.conn.WriteMessage(c.codec.frameType(), data); err != nil {
 				slog.Error("Error when writing to socket", "err", err, "user", c.id)
 				return // return or break?
 			}
@@ -125,26 +180,29 @@ func handleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	nickname := r.URL.Query().Get("nickname")
+	// Signed-in users join with their identity. Their nickname can't be changed from the client.
+	s, reason := checkUserCredential(r, user)
+	if reason != "" {
+		rejectWebSocket(w, r, reason)
+		return
+	}
+	if s != nil {
+		nickname = s.Nickname
+	}
 	if nickname == "" || utf8.RuneCountInString(nickname) > config.Data.MaxTextLength {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	// if !hub.redis.BoardExists(board) {
+	// if !hub.store.BoardExists(board) {
 	// 	w.WriteHeader(http.StatusNotFound)
 	// 	return
 	// }
 
 	// If board doesn't exist, upgrade and close immediately with a reason
-	if !hub.redis.BoardExists(board) {
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			slog.Error("Board not found", "board", board)
-			return
-		}
-		// Send close control frame with code + reason
-		msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "BOARDNOTFOUND")
-		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
-		conn.Close()
+	b, ok := hub.store.GetBoard(board)
+	if !ok {
+		slog.Error("Board not found", "board", board)
+		rejectWebSocket(w, r, "BOARDNOTFOUND")
 		return
 	}
 
@@ -152,37 +210,128 @@ func handleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	// If yes, and doing it before upgrading, send w.WriteHeader(http.StatusConflict)...
 	// ... Or upgrade and send a custom close code
 
-	u, ok := hub.redis.EnsureUser(board, user, nickname)
+	// Owner credential. Optional, only owners (and the creator) have one.
+	// Unverifiable tokens are dropped here so they can't be used for privileged events later.
+	token := handshakeCredential(r, CredentialOwnerToken)
+	isOwner := isVerifiedOwner(b, user, token) || isCreatorToken(b, user, token)
+	if token != "" && !isOwner {
+		slog.Warn("Ignoring invalid owner token", "board", board, "user", user)
+		token = ""
+	}
+
+	// Moderator credential. Like the owner token, an unverifiable one is dropped.
+	modToken := handshakeCredential(r, CredentialModeratorToken)
+	if modToken != "" {
+		if u, known := hub.store.GetUser(board, user); !known || !isVerifiedModerator(board, u, modToken) {
+			slog.Warn("Ignoring invalid moderator token", "board", board, "user", user)
+			modToken = ""
+		}
+	}
+
+	// Banned users can't come back. See kick.go
+	if !isOwner && hub.store.IsBanned(board, user) {
+		rejectWebSocket(w, r, CloseBanned)
+		return
+	}
+
+	// Invite link. A valid invite admits the user without the passcode. Owners don't need one.
+	// Only checked here. It is used up once the rest of the join can't fail, see below.
+	invite := r.URL.Query().Get("invite")
+	invited := false
+	if invite != "" && !isOwner {
+		if !validInviteId(invite) || !hub.store.CheckInvite(board, invite, user) {
+			slog.Warn("Invalid, expired or used up invite", "board", board, "user", user)
+			rejectWebSocket(w, r, CloseInviteInvalid)
+			return
+		}
+		invited = true
+	}
+	// Invite only board. Users who joined before can come back without an invite.
+	if b.InviteOnly && !isOwner && !invited {
+		if _, known := hub.store.GetUser(board, user); !known {
+			rejectWebSocket(w, r, CloseInviteRequired)
+			return
+		}
+	}
+
+	// Passcode protected board. Owners and invited users don't need the passcode.
+	var responseHeader http.Header
+	if b.PasscodeHash != "" && !isOwner && !invited {
+		reason, cookie := checkJoinPasscode(hub.store, r, b, user)
+		if reason != "" {
+			rejectWebSocket(w, r, reason)
+			return
+		}
+		if cookie != nil {
+			responseHeader = http.Header{"Set-Cookie": []string{cookie.String()}}
+		}
+	}
+
+	// Use the invite just before upgrading, so failed joins don't count. Handshakes the upgrader would refuse are
+	// turned away first. Users already counted for the invite aren't counted again.
+	// This comes before EnsureUser, because users saved on the board can come back to invite-only boards without an invite.
+	if invited {
+		if !websocket.IsWebSocketUpgrade(r) || !upgrader.CheckOrigin(r) {
+			slog.Warn("Invalid websocket handshake with invite", "board", board, "user", user)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !hub.store.UseInvite(board, invite, user) {
+			slog.Warn("Invite used up while joining", "board", board, "user", user)
+			rejectWebSocket(w, r, CloseInviteInvalid)
+			return
+		}
+	}
+
+	u, ok := hub.store.EnsureUser(board, user, nickname)
 	if !ok {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	// Participant counts in the team space. See team.go
+	if b.TeamId != "" {
+		hub.store.AddTeamBoardParticipant(b.TeamId, board, user)
+	}
 
 	slog.Info("Join", "board", board, "user", user)
 	// Upgrade http request to websocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		slog.Error("Error when upgrading to websocket", "err", err)
 		return
 	}
 
-	// Represent the websocket connection as a "Client".
-	// Create per-client rate limiter from config (if enabled)
-	var wsLimiter *ClientRateLimiter
-	if config.Websocket.RateLimit.Enabled {
-		wsRefill, err := parseDuration(config.Websocket.RateLimit.RefillInterval)
-		if err == nil && config.Websocket.RateLimit.Burst > 0 {
-			wsLimiter = NewClientRateLimiter(config.Websocket.RateLimit.Burst, wsRefill)
-		}
+	// Represent the websocket connection as a "Client", with its rate limits from config (if enabled)
+	client := &Client{id: user, xid: u.Xid, group: board, conn: conn, codec: codecFor(conn.Subprotocol()), send: make(chan any, 256), hub: hub, limits: newClientLimits()}
+	if token != "" {
+		client.setCredential(token)
+	}
+	if modToken != "" {
+		client.setModeratorCredential(modToken)
+	}
+	// Sent until the client acknowledges it. See UserTokenAckEvent
+	if s == nil && hub.store.UserTokenPending(user) {
+		client.userToken = userToken(user)
 	}
-
-	// Represent the websocket connection as a "Client".
-	client := &Client{id: user, xid: u.Xid, group: board, conn: conn, send: make(chan any, 256), hub: hub, limiter: wsLimiter}
 
 	// Register the connection/client with the Hub
 	client.hub.register <- client
-	client.hub.redis.Subscribe(board)
+	client.hub.store.Subscribe(board)
 
 	go client.read()
 	go client.write()
 }
+
+// rejectWebSocket upgrades and closes immediately with a reason the frontend can act on, e.g. "BOARDNOTFOUND".
+// Browsers don't expose the HTTP status of a failed handshake, but they do expose close reasons.
+func rejectWebSocket(w http.ResponseWriter, r *http.Request, reason string) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("Error when upgrading to websocket for rejection", "err", err, "reason", reason)
+		return
+	}
+	// Send close control frame with code + reason
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
+}
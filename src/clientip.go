@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Client IPs behind reverse proxies.
+// Behind a proxy (e.g. the bundled Caddy setup), r.RemoteAddr is the proxy. The proxy passes the client's address in
+// Forwarded, X-Forwarded-For or X-Real-IP. Anyone can send these headers, so they are only honoured when the request
+// comes from a source in trusted_proxies in [server] of config.toml.
+// Only the header set by client_ip_header is read. A proxy that sets X-Forwarded-For passes a client's own Forwarded
+// header through untouched, so reading every header would let that one win.
+//
+// withClientIP resolves the address once per request and carries it in the request context.
+// remoteIP returns it, for bot protection, logging and the IP-based limits.
+
+// trustedProxies is empty when the app is reached directly. Headers are ignored then.
+var trustedProxies []netip.Prefix
+
+// clientIPHeader is the header the trusted proxies set. X-Forwarded-For unless configured.
+var clientIPHeader = "X-Forwarded-For"
+
+type clientIPKey struct{}
+
+// parseTrustedProxies parses CIDRs, or single addresses, from config.
+func parseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// parseClientIPHeader checks client_ip_header. Empty means X-Forwarded-For.
+func parseClientIPHeader(name string) (string, error) {
+	if name = strings.TrimSpace(name); name == "" {
+		return "X-Forwarded-For", nil
+	}
+	for _, header := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		if strings.EqualFold(name, header) {
+			return header, nil
+		}
+	}
+	return "", fmt.Errorf("invalid client_ip_header %q, use Forwarded, X-Forwarded-For or X-Real-IP", name)
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// withClientIP puts the client IP of the request in its context.
+func withClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := resolveClientIP(r, trustedProxies, clientIPHeader)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+// remoteIP is the address of the client. It is the peer connecting to this server, unless that is a trusted proxy.
+func remoteIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return resolveClientIP(r, trustedProxies, clientIPHeader)
+}
+
+// resolveClientIP walks the forwarding chain in header from the nearest hop back, skipping trusted proxies.
+// The first address that isn't a trusted proxy is the client. Earlier entries could have been made up by the client.
+func resolveClientIP(r *http.Request, trusted []netip.Prefix, header string) string {
+	peer, ok := parseHostIP(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !isTrustedProxy(peer, trusted) {
+		return peer.String()
+	}
+
+	hops, ok := forwardedHops(r.Header, header)
+	if !ok {
+		return peer.String()
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHostIP(hops[i])
+		if !ok {
+			// Obfuscated ("unknown", "_hidden") or garbled. Nothing before it can be trusted.
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedHops returns the addresses proxies added to header, nearest last. Other headers are ignored.
+func forwardedHops(h http.Header, header string) ([]string, bool) {
+	switch header {
+	case "Forwarded":
+		values := h.Values("Forwarded")
+		var hops []string
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(value, `"`))
+				}
+			}
+		}
+		return hops, len(hops) > 0
+	case "X-Real-IP":
+		value := strings.TrimSpace(h.Get("X-Real-IP"))
+		return []string{value}, value != ""
+	default:
+		values := h.Values("X-Forwarded-For")
+		if len(values) == 0 {
+			return nil, false
+		}
+		hops := strings.Split(strings.Join(values, ","), ",")
+		for i := range hops {
+			hops[i] = strings.TrimSpace(hops[i])
+		}
+		return hops, true
+	}
+}
+
+// parseHostIP parses "ip", "ip:port", "[ipv6]" or "[ipv6]:port".
+func parseHostIP(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
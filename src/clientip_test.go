@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.5 ", "::1", "fd00::1/64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.5/32", "::1/128", "fd00::/64"}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, prefix, want[i])
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy", ""} {
+		if _, err := parseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("%q accepted", invalid)
+		}
+	}
+}
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, _ := parseTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"})
+	const xff, fwd, xri = "X-Forwarded-For", "Forwarded", "X-Real-IP"
+
+	for _, tt := range []struct {
+		name, remoteAddr, header string
+		headers                  map[string]string
+		want                     string
+	}{
+		{"direct", "203.0.113.7:5000", xff, nil, "203.0.113.7"},
+		{"untrusted peer can't spoof", "203.0.113.7:5000", xff, map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.4", "Forwarded": "for=1.2.3.4"}, "203.0.113.7"},
+		{"trusted proxy without headers", "10.0.0.2:5000", xff, nil, "10.0.0.2"},
+		{"x-forwarded-for", "10.0.0.2:5000", xff, map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		// The client prepended a fake address. The proxy appended the real one.
+		{"x-forwarded-for spoofed by client", "10.0.0.2:5000", xff, map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		// The proxy only sets X-Forwarded-For and passes the client's own Forwarded header through.
+		{"forwarded spoofed by client", "10.0.0.2:5000", xff, map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"x-forwarded-for through proxies", "10.0.0.2:5000", xff, map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.9, 10.0.0.3"}, "198.51.100.1"},
+		{"x-forwarded-for garbled", "10.0.0.2:5000", xff, map[string]string{"X-Forwarded-For": "198.51.100.1, garbage"}, "10.0.0.2"},
+		{"x-real-ip", "10.0.0.2:5000", xri, map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"x-real-ip not configured", "10.0.0.2:5000", xff, map[string]string{"X-Real-IP": "1.2.3.4"}, "10.0.0.2"},
+		{"forwarded", "10.0.0.2:5000", fwd, map[string]string{"Forwarded": `for=1.2.3.4, for="[2001:db8::17]:4711";proto=https`}, "2001:db8::17"},
+		{"forwarded ignores x-forwarded-for", "10.0.0.2:5000", fwd, map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "1.2.3.4"}, "198.51.100.1"},
+		{"forwarded obfuscated", "10.0.0.2:5000", fwd, map[string]string{"Forwarded": "for=unknown"}, "10.0.0.2"},
+		{"ipv6 proxy", "[fd00::2]:5000", xff, map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.2]:5000", xff, map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"only proxies", "10.0.0.2:5000", xff, map[string]string{"X-Forwarded-For": "10.0.0.4, 10.0.0.3"}, "10.0.0.4"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := resolveClientIP(r, trusted, tt.header); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClientIPHeader(t *testing.T) {
+	for in, want := range map[string]string{"": "X-Forwarded-For", "forwarded": "Forwarded", " x-real-ip ": "X-Real-IP", "X-Forwarded-For": "X-Forwarded-For"} {
+		if got, err := parseClientIPHeader(in); err != nil || got != want {
+			t.Errorf("parseClientIPHeader(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := parseClientIPHeader("X-Client-IP"); err == nil {
+		t.Error("expected an error for an unsupported header")
+	}
+}
+
+func TestWithClientIP(t *testing.T) {
+	prev := trustedProxies
+	t.Cleanup(func() { trustedProxies = prev })
+	trustedProxies, _ = parseTrustedProxies([]string{"10.0.0.0/8"})
+
+	var got string
+	handler := withClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = remoteIP(r)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got != "198.51.100.1" {
+		t.Errorf("remoteIP = %s", got)
+	}
+
+	// No proxies configured
+	trustedProxies = nil
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got != "10.0.0.2" {
+		t.Errorf("remoteIP without trusted proxies = %s", got)
+	}
+}
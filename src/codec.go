@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Websocket subprotocols offered through Sec-WebSocket-Protocol.
+// Clients that don't ask for one get JSON.
+const (
+	SubprotocolJSON    = "quickretro.json"
+	SubprotocolMsgpack = "quickretro.msgpack"
+)
+
+// Credentials are offered as extra subprotocols, "quickretro.<name>.<base64url value>".
+// Browsers can't set other headers on websocket handshakes, and unlike query params, headers stay out of URLs and
+// access logs. These are never selected, so clients sending them must also offer SubprotocolJSON or SubprotocolMsgpack.
+const (
+	credentialPrefix         = "quickretro."
+	CredentialOwnerToken     = "token"
+	CredentialModeratorToken = "modtoken"
+	CredentialPasscode       = "passcode"
+)
+
+// handshakeCredential returns the credential offered under name, or "" if there is none.
+func handshakeCredential(r *http.Request, name string) string {
+	prefix := credentialPrefix + name + "."
+	for _, protocol := range websocket.Subprotocols(r) {
+		if value, ok := strings.CutPrefix(protocol, prefix); ok {
+			decoded, err := base64.RawURLEncoding.DecodeString(value)
+			if err != nil {
+				return ""
+			}
+			return string(decoded)
+		}
+	}
+	return ""
+}
+
+// wireCodec encodes responses and decodes incoming events for one websocket connection.
+type wireCodec interface {
+	frameType() int // websocket.TextMessage or websocket.BinaryMessage
+	encode(v any) ([]byte, error)
+	decode(data []byte, e *Event) error
+}
+
+func codecFor(subprotocol string) wireCodec {
+	if subprotocol == SubprotocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) frameType() int { return websocket.TextMessage }
+
+func (jsonCodec) encode(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) decode(data []byte, e *Event) error { return json.Unmarshal(data, e) }
+
+// msgpackCodec uses the json struct tags, so MessagePack clients see the same field names as JSON clients.
+type msgpackCodec struct{}
+
+func (msgpackCodec) frameType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode converts the event payload to JSON. Handlers, and the pub/sub in between, only deal with JSON payloads.
+func (msgpackCodec) decode(data []byte, e *Event) error {
+	var in struct {
+		Type    string             `msgpack:"typ"`
+		Payload msgpack.RawMessage `msgpack:"pyl"`
+	}
+	if err := msgpack.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	var payload any
+	if len(in.Payload) > 0 {
+		if err := msgpack.Unmarshal(in.Payload, &payload); err != nil {
+			return err
+		}
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	e.Type = in.Type
+	e.Payload = raw
+	return nil
+}
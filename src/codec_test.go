@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackCodec_DecodeConvertsPayloadToJSON(t *testing.T) {
+	data, err := msgpack.Marshal(map[string]any{
+		"typ": "msg",
+		"pyl": map[string]any{"id": "m1", "msg": "hello", "cat": "col01", "anon": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e Event
+	if err := (msgpackCodec{}).decode(data, &e); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if e.Type != "msg" {
+		t.Errorf("Type = %q, want msg", e.Type)
+	}
+
+	var p MessageEvent
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		t.Fatalf("payload is not JSON: %v (%s)", err, e.Payload)
+	}
+	if p.Id != "m1" || p.Content != "hello" || p.Category != "col01" || !p.Anonymous {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestMsgpackCodec_EncodeUsesJSONFieldNames(t *testing.T) {
+	data, err := (msgpackCodec{}).encode(LikeMessageResponse{Type: "like", Id: "m1", Likes: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := msgpack.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["typ"] != "like" || got["id"] != "m1" {
+		t.Errorf("encoded = %v", got)
+	}
+	if _, ok := got["Likes"]; ok {
+		t.Errorf("Go field name used instead of json tag: %v", got)
+	}
+}
+
+// credentialSubprotocol offers a credential in the handshake, like the frontend does. See handshakeCredential
+func credentialSubprotocol(name, value string) string {
+	return credentialPrefix + name + "." + base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func TestHandshakeCredential(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ws/board/board1/user/alice/meet?token=fromquery", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", SubprotocolJSON+", "+credentialSubprotocol("passcode", "pass word ü"))
+	if got := handshakeCredential(r, "passcode"); got != "pass word ü" {
+		t.Errorf("passcode = %q", got)
+	}
+	// Query params aren't credentials
+	if got := handshakeCredential(r, CredentialOwnerToken); got != "" {
+		t.Errorf("token = %q, want none", got)
+	}
+	r.Header.Set("Sec-WebSocket-Protocol", credentialPrefix+CredentialOwnerToken+".not*base64")
+	if got := handshakeCredential(r, CredentialOwnerToken); got != "" {
+		t.Errorf("undecodable token = %q, want none", got)
+	}
+}
+
+// dialTestBoard starts a websocket server for one board and connects a user with the given subprotocols.
+func dialTestBoard(t *testing.T, subprotocols ...string) (*websocket.Conn, *http.Response) {
+	t.Helper()
+	prevOrigins, prevMaxText := config.Server.AllowedOrigins, config.Data.MaxTextLength
+	config.Server.AllowedOrigins = []string{"https://localhost"}
+	config.Data.MaxTextLength = 80
+	t.Cleanup(func() { config.Server.AllowedOrigins, config.Data.MaxTextLength = prevOrigins, prevMaxText })
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	store.CreateBoard(&Board{Id: "board1", Name: "Retro", Owner: "alice", Creator: "alice"}, []*BoardColumn{{Id: "col01", Text: "Good", Position: 1}}, 0)
+	hub := newHub(store)
+	go hub.run()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/board/{board}/user/{user}/meet", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(hub, w, r)
+	})
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	protocols := append(subprotocols, credentialSubprotocol(CredentialOwnerToken, creatorToken("board1", "alice")))
+	dialer := websocket.Dialer{Subprotocols: protocols, EnableCompression: true}
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/board/board1/user/alice/meet?nickname=Alice"
+	conn, resp, err := dialer.Dial(url, http.Header{"Origin": []string{"https://localhost"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp
+}
+
+func TestWebsocket_MsgpackRoundTrip(t *testing.T) {
+	conn, resp := dialTestBoard(t, SubprotocolMsgpack)
+
+	if conn.Subprotocol() != SubprotocolMsgpack {
+		t.Fatalf("negotiated subprotocol %q, want %q", conn.Subprotocol(), SubprotocolMsgpack)
+	}
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Errorf("permessage-deflate not negotiated, extensions %q", ext)
+	}
+
+	reg, _ := msgpack.Marshal(map[string]any{"typ": "reg", "pyl": map[string]any{}})
+	if err := conn.WriteMessage(websocket.BinaryMessage, reg); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frameType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if frameType != websocket.BinaryMessage {
+		t.Errorf("frame type %d, want binary", frameType)
+	}
+	var res map[string]any
+	if err := msgpack.Unmarshal(data, &res); err != nil {
+		t.Fatalf("response is not MessagePack: %v", err)
+	}
+	if res["typ"] != "reg" || res["boardName"] != "Retro" || res["isBoardOwner"] != true {
+		t.Errorf("reg response = %v", res)
+	}
+}
+
+func TestWebsocket_DefaultsToJSON(t *testing.T) {
+	conn, _ := dialTestBoard(t)
+
+	if conn.Subprotocol() != "" {
+		t.Errorf("negotiated subprotocol %q, want none", conn.Subprotocol())
+	}
+	if err := conn.WriteJSON(map[string]any{"typ": "reg", "pyl": map[string]any{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frameType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var res RegisterResponse
+	if frameType != websocket.TextMessage || json.Unmarshal(data, &res) != nil || res.Type != "reg" {
+		t.Errorf("frame type %d, response %s", frameType, data)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// Column limits.
+// Boards have up to [data] max_columns columns. Column ids are "col01", "col02" and so on, so the limit can't go over 99.
+const (
+	DefaultMaxColumns = 5
+	MaxColumnsLimit   = 99
+
+	MaxColumnDescriptionLength = 280 // Characters
+)
+
+// Column rules.
+// Board.Mask and Board.Lock apply to every column. The owner can also set them per column, with a limit of cards per user.
+// Masking only changes how the frontend shows cards, like Board.Mask. Locks and limits are enforced in MessageEvent and CategoryChangeEvent.
+// Cards over a rule are rejected with "msgrej", see MessageRejectedEvent.
+const (
+	RejectColumnLocked = "COLLOCKED"
+	RejectColumnFull   = "COLFULL"
+)
+
+// maxColumns is the configured limit of columns per board.
+func maxColumns() int {
+	if config.Data.MaxColumns <= 0 {
+		return DefaultMaxColumns
+	}
+	return config.Data.MaxColumns
+}
+
+func validateMaxColumns(n int) error {
+	if n < 0 || n > MaxColumnsLimit {
+		return fmt.Errorf("max_columns must be between 1 and %d, or 0 for the default of %d", MaxColumnsLimit, DefaultMaxColumns)
+	}
+	return nil
+}
+
+// sameColumnIds reports whether order lists every column id in ids exactly once.
+func sameColumnIds(ids []string, order []string) bool {
+	if len(ids) != len(order) {
+		return false
+	}
+	seen := make(map[string]bool, len(order))
+	for _, id := range order {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func validColumnRules(col *BoardColumn) bool {
+	return col.MaxCards >= 0 && utf8.RuneCountInString(col.Description) <= MaxColumnDescriptionLength
+}
+
+// findColumn returns the column with the id, or nil.
+func findColumn(cols []*BoardColumn, id string) *BoardColumn {
+	for _, col := range cols {
+		if col.Id == id {
+			return col
+		}
+	}
+	return nil
+}
+
+// columnRejectReason checks the rules of the column for a card of userId. It is empty if the card is allowed.
+// newCard is false for edits and comments, they don't count against MaxCards.
+func columnRejectReason(s Store, boardId, userId string, col *BoardColumn, newCard bool) string {
+	if col == nil {
+		return ""
+	}
+	if col.Lock {
+		return RejectColumnLocked
+	}
+	if !newCard || col.MaxCards == 0 {
+		return ""
+	}
+	messages, ok := s.GetMessages(boardId)
+	if !ok {
+		slog.Error("Cannot count cards for column limit", "board", boardId, "col", col.Id)
+		return RejectColumnFull
+	}
+	count := 0
+	for _, m := range messages {
+		if isMessage(m) && m.By == userId && m.Category == col.Id {
+			count++
+		}
+	}
+	if count >= col.MaxCards {
+		return RejectColumnFull
+	}
+	return ""
+}
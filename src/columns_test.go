@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func columnOrder(t *testing.T, s Store, boardId string) []string {
+	t.Helper()
+	cols, ok := s.GetBoardColumns(boardId)
+	if !ok {
+		t.Fatal("GetBoardColumns failed")
+	}
+	ids := make([]string, 0, len(cols))
+	for _, c := range cols {
+		ids = append(ids, c.Id)
+	}
+	return ids
+}
+
+func TestColumnMoveEvent(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+
+	// Only the owner
+	tb.send(bob, "colmove", ColumnMoveEvent{Order: []string{"col02", "col01"}})
+	if r := receive(bob); r != nil {
+		t.Fatalf("non-owner moved columns: %+v", r)
+	}
+
+	// The order has to list exactly the active columns
+	for _, invalid := range [][]string{
+		nil,
+		{"col02"},
+		{"col02", "col02"},
+		{"col02", "col03"},
+		{"col02", "col01", "col03"},
+	} {
+		tb.send(owner, "colmove", ColumnMoveEvent{Order: invalid})
+		if r := receive(bob); r != nil {
+			t.Errorf("order %v accepted: %+v", invalid, r)
+		}
+	}
+	if got := columnOrder(t, tb.store, tb.board.Id); !slices.Equal(got, []string{"col01", "col02"}) {
+		t.Fatalf("columns moved by invalid orders: %v", got)
+	}
+
+	tb.send(owner, "colmove", ColumnMoveEvent{Order: []string{"col02", "col01"}})
+	res, ok := receive(bob).(*ColumnMoveResponse)
+	if !ok || !slices.Equal(res.Order, []string{"col02", "col01"}) {
+		t.Fatalf("received %+v", res)
+	}
+	cols, _ := tb.store.GetBoardColumns(tb.board.Id)
+	if cols[0].Id != "col02" || cols[0].Position != 1 || cols[1].Id != "col01" || cols[1].Position != 2 {
+		t.Errorf("columns after move: %+v, %+v", cols[0], cols[1])
+	}
+	if cols[0].Text != "Bad" || cols[0].Color != "red" {
+		t.Errorf("moving changed the column: %+v", cols[0])
+	}
+	receive(owner)
+
+	// Not in a locked board
+	tb.store.UpdateBoardLock(tb.board, true)
+	tb.send(owner, "colmove", ColumnMoveEvent{Order: []string{"col01", "col02"}})
+	if r := receive(bob); r != nil {
+		t.Errorf("columns moved in locked board: %+v", r)
+	}
+}
+
+func TestColumnsChangeEvent_RenameAndRecolorWithCards(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Data.MaxCategoryTextLength = 80
+
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	receive(owner)
+	receive(bob)
+
+	// The column keeps its id and its cards
+	tb.send(owner, "colreset", ColumnsChangeEvent{Columns: []*BoardColumn{
+		{Id: "col01", Text: "Went well", Color: "sky", Position: 1},
+		{Id: "col02", Text: "Bad", Color: "red", Position: 2},
+	}})
+	if _, ok := receive(bob).(*ColumnsChangeResponse); !ok {
+		t.Fatal("renaming a column with cards was rejected")
+	}
+	cols, _ := tb.store.GetBoardColumns(tb.board.Id)
+	if cols[0].Text != "Went well" || cols[0].Color != "sky" {
+		t.Errorf("column not updated: %+v", cols[0])
+	}
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m1"); m.Category != "col01" {
+		t.Errorf("card moved to %q", m.Category)
+	}
+
+	// Removing it is still blocked
+	tb.send(owner, "colreset", ColumnsChangeEvent{Columns: []*BoardColumn{
+		{Id: "col02", Text: "Bad", Color: "red", Position: 1},
+	}})
+	if r := receive(bob); r != nil {
+		t.Errorf("removed a column with cards: %+v", r)
+	}
+}
+
+func TestColumnsChangeEvent_MaxColumns(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Data.MaxCategoryTextLength = 80
+
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	columns := func(n int) []*BoardColumn {
+		cols := make([]*BoardColumn, n)
+		for i := range cols {
+			cols[i] = &BoardColumn{Id: fmt.Sprintf("col%02d", i+1), Text: "Column", Color: "green", Position: i + 1}
+		}
+		return cols
+	}
+
+	tb.send(owner, "colreset", ColumnsChangeEvent{Columns: columns(DefaultMaxColumns + 1)})
+	if r := receive(bob); r != nil {
+		t.Fatalf("more than the default limit accepted: %+v", r)
+	}
+
+	config.Data.MaxColumns = 7
+	tb.send(owner, "colreset", ColumnsChangeEvent{Columns: columns(7)})
+	if _, ok := receive(bob).(*ColumnsChangeResponse); !ok {
+		t.Fatal("columns within the configured limit rejected")
+	}
+	tb.send(owner, "colreset", ColumnsChangeEvent{Columns: columns(8)})
+	if r := receive(bob); r != nil {
+		t.Errorf("more than the configured limit accepted: %+v", r)
+	}
+}
+
+func TestValidateMaxColumns(t *testing.T) {
+	for n, valid := range map[int]bool{0: true, 1: true, 5: true, MaxColumnsLimit: true, -1: false, MaxColumnsLimit + 1: false} {
+		if err := validateMaxColumns(n); (err == nil) != valid {
+			t.Errorf("validateMaxColumns(%d) = %v", n, err)
+		}
+	}
+}
+
+// setColumnRules replaces the columns of the test board with col01 and col02, with the rules applied to col01.
+func setColumnRules(t *testing.T, tb *testBoard, rules BoardColumn) {
+	t.Helper()
+	cols, _ := tb.store.GetBoardColumns(tb.board.Id)
+	rules.Id, rules.Text, rules.Color, rules.Position = "col01", "Good", "green", 1
+	if !tb.store.ResetBoardColumns(tb.board, cols, []*BoardColumn{&rules, {Id: "col02", Text: "Bad", Color: "red", Position: 2}}) {
+		t.Fatal("ResetBoardColumns failed")
+	}
+}
+
+func TestColumnRules_MaxCards(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+	setColumnRules(t, tb, BoardColumn{MaxCards: 2})
+
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "one", Category: "col01"})
+	tb.send(alice, "msg", MessageEvent{Id: "m2", Content: "two", Category: "col01", Anonymous: true})
+	receive(alice)
+	receive(alice)
+
+	tb.send(alice, "msg", MessageEvent{Id: "m3", Content: "three", Category: "col01"})
+	if res, ok := receive(alice).(*MessageRejectedResponse); !ok || res.Id != "m3" || res.Reason != RejectColumnFull {
+		t.Fatalf("third card: received %+v", res)
+	}
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m3"); ok {
+		t.Error("card over the limit saved")
+	}
+
+	// Edits, comments, other columns and other users aren't limited
+	for _, ev := range []MessageEvent{
+		{Id: "m1", Content: "edited", Category: "col01"},
+		{Id: "c1", Content: "comment", ParentId: "m1"},
+		{Id: "m4", Content: "elsewhere", Category: "col02"},
+	} {
+		tb.send(alice, "msg", ev)
+		if _, ok := receive(alice).(MessageResponse); !ok {
+			t.Errorf("%s was rejected", ev.Id)
+		}
+	}
+	tb.send(bob, "msg", MessageEvent{Id: "m5", Content: "bob's", Category: "col01"})
+	if _, ok := receive(bob).(MessageResponse); !ok {
+		t.Error("another user's card was rejected")
+	}
+
+	// Moving a card in counts too
+	receive(alice)
+	tb.send(alice, "catchng", CategoryChangeEvent{MessageId: "m4", NewCategory: "col01", OldCategory: "col02"})
+	if res, ok := receive(alice).(*MessageRejectedResponse); !ok || res.Reason != RejectColumnFull {
+		t.Errorf("move into a full column: received %+v", res)
+	}
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m4"); m.Category != "col02" {
+		t.Errorf("card moved to %s", m.Category)
+	}
+}
+
+func TestColumnRules_Lock(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	alice := tb.join("alice", "Alice")
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	tb.send(alice, "msg", MessageEvent{Id: "m2", Content: "other", Category: "col02"})
+	setColumnRules(t, tb, BoardColumn{Lock: true})
+	for receive(alice) != nil {
+	}
+
+	for _, ev := range []MessageEvent{
+		{Id: "m3", Content: "new", Category: "col01"},
+		{Id: "m1", Content: "edited", Category: "col01"},
+		{Id: "c1", Content: "comment", ParentId: "m1"},
+	} {
+		tb.send(alice, "msg", ev)
+		if res, ok := receive(alice).(*MessageRejectedResponse); !ok || res.Id != ev.Id || res.Reason != RejectColumnLocked {
+			t.Errorf("%s: received %+v", ev.Id, res)
+		}
+	}
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m1"); m.Content != "hello" {
+		t.Errorf("card in locked column edited to %q", m.Content)
+	}
+
+	// No moves in or out, also by the owner
+	tb.send(alice, "catchng", CategoryChangeEvent{MessageId: "m1", NewCategory: "col02", OldCategory: "col01"})
+	tb.send(owner, "catchng", CategoryChangeEvent{MessageId: "m2", NewCategory: "col01", OldCategory: "col02"})
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m1"); m.Category != "col01" {
+		t.Error("card moved out of a locked column")
+	}
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m2"); m.Category != "col02" {
+		t.Error("card moved into a locked column")
+	}
+
+	// The other column is open
+	tb.send(alice, "msg", MessageEvent{Id: "m4", Content: "open", Category: "col02"})
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m4"); !ok {
+		t.Error("card in open column rejected")
+	}
+}
+
+func TestColumnsChangeEvent_Rules(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Data.MaxCategoryTextLength = 80
+
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+
+	for _, invalid := range []BoardColumn{
+		{MaxCards: -1},
+		{Description: strings.Repeat("a", MaxColumnDescriptionLength+1)},
+	} {
+		invalid.Id, invalid.Text, invalid.Color, invalid.Position = "col01", "Good", "green", 1
+		tb.send(owner, "colreset", ColumnsChangeEvent{Columns: []*BoardColumn{&invalid}})
+		if r := receive(bob); r != nil {
+			t.Errorf("invalid rules accepted: %+v", r)
+		}
+	}
+
+	ruled := &BoardColumn{Id: "col01", Text: "Good", Color: "green", Position: 1, MaxCards: 1, Mask: true, Lock: true, Description: "Wins only"}
+	tb.send(owner, "colreset", ColumnsChangeEvent{Columns: []*BoardColumn{ruled}})
+	res, ok := receive(bob).(*ColumnsChangeResponse)
+	if !ok || len(res.BoardColumns) != 1 || *res.BoardColumns[0] != *ruled {
+		t.Fatalf("received %+v", res)
+	}
+	if cols, _ := tb.store.GetBoardColumns(tb.board.Id); *cols[0] != *ruled {
+		t.Errorf("stored %+v", cols[0])
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Content pipeline.
+// Card and comment text runs through a list of content filters in MessageEvent.Handle, before it is saved.
+// A filter returns the text to keep, or a contentError to reject it. Rejections are sent back to the author only.
+// The filters and their order are set up from [content] in config.toml by newContentPipeline.
+//
+// On end-to-end encrypted boards, content is encrypted by clients with a key the server never sees, and is an opaque base64 blob here.
+// The pipeline is skipped for them. checkEncryptedContent only checks that the content looks encrypted and isn't too long.
+
+// Reasons sent to the author when their card or comment is rejected.
+const (
+	RejectTooLong      = "TOOLONG"
+	RejectDenyWord     = "DENYWORD"
+	RejectNotEncrypted = "NOTENCRYPTED" // Plain text on an end-to-end encrypted board
+)
+
+// Encrypted content is base64(nonce + ciphertext + tag) of AES-GCM. See frontend/src/utils/e2ee.ts
+const (
+	e2eeOverheadBytes   = 12 + 16 // nonce + tag
+	e2eeMaxBytesPerChar = 4       // UTF-8
+)
+
+type contentError struct {
+	reason string
+}
+
+func (e *contentError) Error() string {
+	return "content rejected: " + e.reason
+}
+
+// contentFilter is a step of the content pipeline.
+type contentFilter func(content string, b *Board) (string, error)
+
+var contentPipeline []contentFilter
+
+// newContentPipeline builds the filters from config. Length is always checked, after everything that changes the text.
+func newContentPipeline() []contentFilter {
+	cfg := config.Content
+	var filters []contentFilter
+	if cfg.Normalize {
+		filters = append(filters, normalizeContent)
+	}
+	if cfg.StripHTML {
+		filters = append(filters, stripHTML)
+	}
+	if len(cfg.DenyWords) > 0 {
+		if f := denyWords(cfg.DenyWords, cfg.DenyAction == "mask"); f != nil {
+			filters = append(filters, f)
+		}
+	}
+	return append(filters, checkContentLength)
+}
+
+// filterContent runs the content through the pipeline.
+func filterContent(content string, b *Board) (string, error) {
+	if b != nil && b.E2EE {
+		return checkEncryptedContent(content, b)
+	}
+	var err error
+	for _, f := range contentPipeline {
+		if content, err = f(content, b); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// normalizeContent converts to NFC, so visually equal text is stored the same way, and removes control and format
+// characters (e.g. bidi overrides, zero-width characters) except newlines and tabs.
+func normalizeContent(content string, _ *Board) (string, error) {
+	content = strings.ToValidUTF8(content, "")
+	content = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, content)
+	return norm.NFC.String(content), nil
+}
+
+// Tags start with a letter, "/" or "!", so text like "a < b" is kept.
+var htmlTag = regexp.MustCompile(`(?s)<!--.*?-->|<[a-zA-Z/!][^<>]*>`)
+
+// stripHTML removes tags. Repeated, so removing one tag can't complete another, e.g. "<<b>script>".
+func stripHTML(content string, _ *Board) (string, error) {
+	for range 3 {
+		stripped := htmlTag.ReplaceAllString(content, "")
+		if stripped == content {
+			break
+		}
+		content = stripped
+	}
+	return content, nil
+}
+
+// denyWords rejects, or masks with "*", whole words in the list. Matching is case-insensitive.
+func denyWords(words []string, mask bool) contentFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	re, err := regexp.Compile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	if err != nil {
+		slog.Error("Invalid deny words in config. Deny words ignored.", "err", err)
+		return nil
+	}
+	return func(content string, _ *Board) (string, error) {
+		if !re.MatchString(content) {
+			return content, nil
+		}
+		if !mask {
+			return "", &contentError{reason: RejectDenyWord}
+		}
+		return re.ReplaceAllStringFunc(content, func(w string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(w))
+		}), nil
+	}
+}
+
+// checkContentLength rejects text over the board's limit. See maxContentLength.
+func checkContentLength(content string, b *Board) (string, error) {
+	if limit := maxContentLength(b); limit > 0 && utf8.RuneCountInString(content) > limit {
+		return "", &contentError{reason: RejectTooLong}
+	}
+	return content, nil
+}
+
+// maxContentLength is the most characters a card or comment can have on the board. 0 is no limit other than the websocket message size.
+// Boards can choose a lower limit than the instance's when they are created.
+func maxContentLength(b *Board) int {
+	limit := config.Content.MaxLength
+	if b != nil && b.MaxContentLength > 0 && (limit <= 0 || b.MaxContentLength < limit) {
+		limit = b.MaxContentLength
+	}
+	return limit
+}
+
+// checkEncryptedContent accepts content of end-to-end encrypted boards. Text can't be normalized or filtered,
+// and length is checked in bytes: the limit is the size of the longest text within maxContentLength, once encrypted.
+func checkEncryptedContent(content string, b *Board) (string, error) {
+	if content == "" {
+		return content, nil
+	}
+	if limit := maxContentLength(b); limit > 0 &&
+		len(content) > base64.StdEncoding.EncodedLen(e2eeOverheadBytes+limit*e2eeMaxBytesPerChar) {
+		return "", &contentError{reason: RejectTooLong}
+	}
+	blob, err := base64.StdEncoding.DecodeString(content)
+	if err != nil || len(blob) < e2eeOverheadBytes {
+		return "", &contentError{reason: RejectNotEncrypted}
+	}
+	return content, nil
+}
+
+// rejectReason is the reason sent to the author for a pipeline error.
+func rejectReason(err error) string {
+	var ce *contentError
+	if errors.As(err, &ce) {
+		return ce.reason
+	}
+	return err.Error()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestContentFilters(t *testing.T) {
+	prev := config.Content
+	t.Cleanup(func() { config.Content = prev })
+	config.Content.Normalize = true
+	config.Content.StripHTML = true
+	config.Content.MaxLength = 20
+	config.Content.DenyWords = []string{"darn", "heck"}
+
+	tests := []struct {
+		name, in, want, reason string
+		mask                   bool
+		board                  *Board
+	}{
+		{name: "plain", in: "Good sprint", want: "Good sprint"},
+		{name: "controls and bidi overrides", in: "a\x00b\u202ec\u200bd\nok", want: "abcd\nok"},
+		{name: "NFC", in: "cafe\u0301", want: "caf\u00e9"},
+		{name: "tags", in: "<b>bold</b> <script>x</script>", want: "bold x"},
+		{name: "nested tags", in: "<<b>script>", want: ""},
+		{name: "comparison kept", in: "a < b > c", want: "a < b > c"},
+		{name: "deny word rejected", in: "what the HECK", reason: RejectDenyWord},
+		{name: "deny word inside a word", in: "darning", want: "darning"},
+		{name: "deny word masked", in: "what the heck", want: "what the ****", mask: true},
+		{name: "too long", in: "this card is way too long", reason: RejectTooLong},
+		{name: "board limit", in: "eleven char", reason: RejectTooLong, board: &Board{MaxContentLength: 10}},
+		{name: "board can't raise the limit", in: "this card is way too long", reason: RejectTooLong, board: &Board{MaxContentLength: 100}},
+		{name: "limit counts characters", in: "ééééééééééééééééééé", want: "ééééééééééééééééééé"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Content.DenyAction = "reject"
+			if tt.mask {
+				config.Content.DenyAction = "mask"
+			}
+			contentPipeline = newContentPipeline()
+			t.Cleanup(func() { contentPipeline = nil })
+
+			got, err := filterContent(tt.in, tt.board)
+			if tt.reason != "" {
+				if err == nil || rejectReason(err) != tt.reason {
+					t.Fatalf("got %q, %v, want rejection %s", got, err, tt.reason)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// The shipped config.toml keeps text as typed. Cleaning it up is opt-in.
+func TestContentPipeline_ShippedDefaultsKeepText(t *testing.T) {
+	prevConfig, prevPipeline := config, contentPipeline
+	t.Cleanup(func() { config, contentPipeline = prevConfig, prevPipeline })
+	if _, err := toml.DecodeFile("config.toml", &config); err != nil {
+		t.Fatal(err)
+	}
+	contentPipeline = newContentPipeline()
+
+	for _, text := range []string{"use the <Button> component", "<template> refactor", "cafe\u0301"} {
+		if got, err := filterContent(text, &Board{}); err != nil || got != text {
+			t.Errorf("%q became %q, %v", text, got, err)
+		}
+	}
+}
+
+func TestMessageEvent_ContentRejectedToAuthorOnly(t *testing.T) {
+	prev := config.Content
+	t.Cleanup(func() { config.Content, contentPipeline = prev, nil })
+	config.Content.StripHTML = true
+	config.Content.DenyWords = []string{"darn"}
+	config.Content.DenyAction = "reject"
+	contentPipeline = newContentPipeline()
+
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "darn it", Category: "col01"})
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m1"); ok {
+		t.Fatal("rejected message saved")
+	}
+	if res, ok := receive(alice).(*MessageRejectedResponse); !ok || res.Id != "m1" || res.Reason != RejectDenyWord {
+		t.Errorf("author received %+v", res)
+	}
+	if r := receive(bob); r != nil {
+		t.Errorf("other user received %+v", r)
+	}
+
+	// Clients can't send rejections
+	tb.send(bob, "msgrej", MessageRejectedEvent{By: "alice", Id: "m1", Reason: RejectTooLong})
+	if r := receive(alice); r != nil {
+		t.Errorf("client-sent rejection reached the author: %+v", r)
+	}
+
+	// Cleaned up content is what everyone gets
+	tb.send(alice, "msg", MessageEvent{Id: "m2", Content: "<i>fine</i>", Category: "col01"})
+	if res, ok := receive(bob).(MessageResponse); !ok || res.Content != "fine" {
+		t.Errorf("other user received %+v", res)
+	}
+}
+
+func TestCheckEncryptedContent(t *testing.T) {
+	prev := config.Content
+	t.Cleanup(func() { config.Content, contentPipeline = prev, nil })
+	config.Content.StripHTML = true
+	config.Content.MaxLength = 20
+	contentPipeline = newContentPipeline()
+
+	b := &Board{E2EE: true}
+	blob := base64.StdEncoding.EncodeToString(make([]byte, e2eeOverheadBytes+20*e2eeMaxBytesPerChar))
+	tooLong := base64.StdEncoding.EncodeToString(make([]byte, e2eeOverheadBytes+20*e2eeMaxBytesPerChar+1))
+
+	for _, tt := range []struct {
+		name, in, reason string
+	}{
+		{name: "longest allowed", in: blob},
+		{name: "empty", in: ""},
+		{name: "too long", in: tooLong, reason: RejectTooLong},
+		{name: "plain text", in: "<b>hi</b>", reason: RejectNotEncrypted},
+		{name: "shorter than nonce and tag", in: base64.StdEncoding.EncodeToString([]byte("hi")), reason: RejectNotEncrypted},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterContent(tt.in, b)
+			if tt.reason != "" {
+				if err == nil || rejectReason(err) != tt.reason {
+					t.Fatalf("got %q, %v, want rejection %s", got, err, tt.reason)
+				}
+				return
+			}
+			// Never changed, the server can't read it
+			if err != nil || got != tt.in {
+				t.Errorf("got %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestMessageEvent_E2EEBoard(t *testing.T) {
+	tb := newTestBoard(t)
+	tb.board = &Board{Id: "board2", Name: "Secret", Owner: "owner", Creator: "owner", Status: InProgress, E2EE: true}
+	tb.store.CreateBoard(tb.board, []*BoardColumn{{Id: "col01", Text: "Good", Color: "green", Position: 1}}, 0)
+	tb.store.Subscribe(tb.board.Id)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(alice, "reg", struct{}{})
+	if res, ok := receive(alice).(RegisterResponse); !ok || !res.BoardE2EE {
+		t.Fatalf("register response %+v, want boardE2ee", res)
+	}
+	receive(bob) // joining
+
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "not encrypted", Category: "col01"})
+	if res, ok := receive(alice).(*MessageRejectedResponse); !ok || res.Reason != RejectNotEncrypted {
+		t.Errorf("author received %+v", res)
+	}
+
+	blob := base64.StdEncoding.EncodeToString([]byte("nonce-------ciphertext and tag--"))
+	tb.send(alice, "msg", MessageEvent{Id: "m2", Content: blob, Category: "col01"})
+	if res, ok := receive(bob).(MessageResponse); !ok || res.Content != blob {
+		t.Errorf("other user received %+v", res)
+	}
+}
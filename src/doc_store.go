@@ -0,0 +1,999 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How often expired boards are removed.
+const docSweepInterval = time.Minute
+
+// boardDoc holds everything stored for a single board.
+// It mirrors the Redis keys documented in redis_keys.go. Since every key of a board is only reachable
+// through the board itself, the whole document expires at Board.AutoDeleteAtUtc.
+type boardDoc struct {
+	Board    Board                          `json:"board"`
+	Columns  map[string]*BoardColumn        `json:"columns"`  // board:col:{<boardId>}:<colId>. Only active columns are kept.
+	Users    map[string]*User               `json:"users"`    // board:user:{<boardId>}:<userId> and board:users:{<boardId>}
+	XidSeq   int64                          `json:"xidSeq"`   // board:user:xid:seq:{<boardId>}
+	Presence map[string]struct{}            `json:"presence"` // board:presence:{<boardId>}
+	Messages map[string]*Message            `json:"messages"` // msg:{<boardId>}:<messageId>. Messages and comments.
+	MsgIds   map[string]struct{}            `json:"msgIds"`   // board:msg:{<boardId>}
+	CmtIds   map[string]struct{}            `json:"cmtIds"`   // board:cmts:{<boardId>}
+	Pins     map[string]struct{}            `json:"pins"`     // board:pins:{<boardId>}
+	Likes    map[string]map[string]struct{} `json:"likes"`    // msg:likes:{<boardId>}:<messageId>
+
+	PassFailures map[string]*passFailures `json:"passFailures,omitempty"` // board:passfail:{<boardId>}:<ip>
+	Invites      map[string]*docInvite    `json:"invites,omitempty"`      // board:invite:{<boardId>}:<inviteId> and board:invite:users:{<boardId>}:<inviteId>
+	Banned       map[string]struct{}      `json:"banned,omitempty"`       // board:banned:{<boardId>}
+}
+
+// teamDoc holds a team space with its summaries of boards. It mirrors the team keys in redis_keys.go,
+// and expires at Team.AutoDeleteAtUtc.
+type teamDoc struct {
+	Team   Team                     `json:"team"`
+	Boards map[string]*teamDocBoard `json:"boards"` // team:board:{<teamId>}:<boardId> and team:boards:{<teamId>}
+}
+
+type teamDocBoard struct {
+	Board TeamBoard           `json:"board"`
+	Users map[string]struct{} `json:"users"` // team:board:users:{<teamId>}:<boardId>
+}
+
+// docInvite is an invite with the users who joined with it.
+type docInvite struct {
+	Invite Invite              `json:"invite"`
+	Users  map[string]struct{} `json:"users"`
+}
+
+// passFailures counts passcode attempts from one IP that weren't accepted, until ResetAtUtc.
+type passFailures struct {
+	Count      int64 `json:"count"`
+	ResetAtUtc int64 `json:"resetAtUtc"`
+}
+
+// requestWindow counts requests until resetAt.
+type requestWindow struct {
+	count   int64
+	resetAt time.Time
+}
+
+func newBoardDoc(b Board) *boardDoc {
+	return &boardDoc{
+		Board:    b,
+		Columns:  make(map[string]*BoardColumn),
+		Users:    make(map[string]*User),
+		Presence: make(map[string]struct{}),
+		Messages: make(map[string]*Message),
+		MsgIds:   make(map[string]struct{}),
+		CmtIds:   make(map[string]struct{}),
+		Pins:     make(map[string]struct{}),
+		Likes:    make(map[string]map[string]struct{}),
+	}
+}
+
+func (d *boardDoc) expired(now time.Time) bool {
+	return d.Board.AutoDeleteAtUtc <= now.UTC().Unix()
+}
+
+// boardDocs is where a DocStore keeps its board documents.
+type boardDocs interface {
+	get(boardId string) (*boardDoc, bool)
+	put(d *boardDoc) bool
+	del(boardId string) bool
+	expired(nowUtcSeconds int64) []string // Ids of boards whose AutoDeleteAtUtc has passed
+
+	getTeam(teamId string) (*teamDoc, bool)
+	putTeam(d *teamDoc) bool
+	delTeam(teamId string) bool
+	expiredTeams(nowUtcSeconds int64) []string
+
+	// Boards index of users. See GetUserBoards
+	boardsOfUser(userId string) []string
+	addUserBoard(userId, boardId string) bool
+	delUserBoard(userId, boardId string) bool // Also drops the user token mark with the user's last board
+	userTokenAcked(userId string) bool
+	ackUserToken(userId string) bool
+	close()
+}
+
+// DocStore is a Store that keeps each board as a single document, in memory (NewMemoryStore) or in a bbolt file (NewBoltStore).
+// Pub/sub only reaches clients of the same process, so it is meant for single-instance deployments and for tests.
+type DocStore struct {
+	mu         sync.Mutex
+	docs       boardDocs
+	timeToLive time.Duration
+	now        func() time.Time
+
+	requests map[string]*requestWindow // Request counts per rate limit scope and IP. Not persisted.
+
+	subMu      sync.Mutex
+	subscribed map[string]struct{}
+	broadcasts chan string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newDocStore(docs boardDocs, timeToLive time.Duration) *DocStore {
+	s := &DocStore{
+		docs:       docs,
+		timeToLive: timeToLive,
+		now:        time.Now,
+		requests:   make(map[string]*requestWindow),
+		subscribed: make(map[string]struct{}),
+		broadcasts: make(chan string, 256),
+		stop:       make(chan struct{}),
+	}
+	go s.sweep()
+	return s
+}
+
+// sweep periodically deletes expired boards. Reads also treat expired boards as missing, so this only frees space.
+func (s *DocStore) sweep() {
+	ticker := time.NewTicker(docSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *DocStore) deleteExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.docs.expired(s.now().UTC().Unix())
+	for _, id := range ids {
+		s.deleteBoard(id)
+		slog.Debug("Expired board deleted", "board", id)
+	}
+	for _, id := range s.docs.expiredTeams(s.now().UTC().Unix()) {
+		s.docs.delTeam(id)
+		slog.Debug("Expired team deleted", "team", id)
+	}
+	now := s.now()
+	for k, c := range s.requests {
+		if !c.resetAt.After(now) {
+			delete(s.requests, k)
+		}
+	}
+	return len(ids)
+}
+
+// doc returns the live document for a board, or nil if it doesn't exist or has expired. Caller must hold s.mu.
+func (s *DocStore) doc(boardId string) *boardDoc {
+	d, ok := s.docs.get(boardId)
+	if !ok {
+		return nil
+	}
+	if d.expired(s.now()) {
+		s.deleteBoard(boardId)
+		return nil
+	}
+	return d
+}
+
+// deleteBoard deletes the board's document, and drops the board from the boards index of its users. Caller must hold s.mu.
+func (s *DocStore) deleteBoard(boardId string) bool {
+	if d, ok := s.docs.get(boardId); ok {
+		for userId := range d.userIds() {
+			s.docs.delUserBoard(userId, boardId)
+		}
+	}
+	return s.docs.del(boardId)
+}
+
+// userIds are the users who have the board in their boards index.
+func (d *boardDoc) userIds() map[string]struct{} {
+	ids := make(map[string]struct{}, len(d.Users)+2)
+	for id := range d.Users {
+		ids[id] = struct{}{}
+	}
+	for _, id := range []string{d.Board.Creator, d.Board.Owner} {
+		if id != "" {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// team returns the live document for a team, or nil if it doesn't exist or has expired. Caller must hold s.mu.
+func (s *DocStore) team(teamId string) *teamDoc {
+	d, ok := s.docs.getTeam(teamId)
+	if !ok {
+		return nil
+	}
+	if d.Team.AutoDeleteAtUtc <= s.now().UTC().Unix() {
+		s.docs.delTeam(teamId)
+		return nil
+	}
+	return d
+}
+
+// view runs fn with the board document while holding the lock. Returns false if the board doesn't exist.
+// fn must not modify the document.
+func (s *DocStore) view(boardId string, fn func(d *boardDoc)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.doc(boardId)
+	if d == nil {
+		return false
+	}
+	fn(d)
+	return true
+}
+
+// update is like view, but saves the document after fn has modified it.
+func (s *DocStore) update(boardId string, fn func(d *boardDoc)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.doc(boardId)
+	if d == nil {
+		return false
+	}
+	fn(d)
+	return s.docs.put(d)
+}
+
+func (s *DocStore) Subscribe(channels ...string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for _, ch := range channels {
+		s.subscribed[ch] = struct{}{}
+	}
+}
+
+func (s *DocStore) Unsubscribe(channels ...string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for _, ch := range channels {
+		delete(s.subscribed, ch)
+	}
+}
+
+func (s *DocStore) Publish(channel string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Marshal error on publish", "err", err, "channel", channel)
+		return
+	}
+
+	s.subMu.Lock()
+	_, ok := s.subscribed[channel]
+	s.subMu.Unlock()
+	if !ok {
+		return
+	}
+
+	// Publish can be called from the Hub's own goroutine (e.g. when a user leaves).
+	// Never block it on a full channel.
+	select {
+	case s.broadcasts <- string(data):
+	default:
+		go func() { s.broadcasts <- string(data) }()
+	}
+}
+
+func (s *DocStore) Broadcasts() <-chan string {
+	return s.broadcasts
+}
+
+func (s *DocStore) CreateBoard(b *Board, cols []*BoardColumn, retention time.Duration) bool {
+	currentTime := s.now().UTC()
+
+	board := *b
+	board.CreatedAtUtc = currentTime.Unix()
+	board.AutoDeleteAtUtc = currentTime.Add(cmp.Or(retention, s.timeToLive)).Unix()
+
+	d := newBoardDoc(board)
+	for _, col := range cols {
+		c := *col
+		d.Columns[c.Id] = &c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.docs.put(d) {
+		slog.Error("Failed to create board", "board", b.Id)
+		return false
+	}
+	for userId := range d.userIds() {
+		s.docs.addUserBoard(userId, b.Id)
+	}
+	return true
+}
+
+func (s *DocStore) BoardExists(boardId string) bool {
+	exists := s.view(boardId, func(*boardDoc) {})
+	if !exists {
+		slog.Warn("Non-existent board", "boardId", boardId)
+	}
+	return exists
+}
+
+func (s *DocStore) GetBoard(boardId string) (*Board, bool) {
+	var b Board
+	ok := s.view(boardId, func(d *boardDoc) { b = d.Board })
+	if !ok {
+		return nil, false
+	}
+	return &b, true
+}
+
+func (s *DocStore) IsBoardOwner(boardId string, userId string) bool {
+	if userId == "" || boardId == "" {
+		return false
+	}
+	isOwner := false
+	s.view(boardId, func(d *boardDoc) { isOwner = d.Board.Owner == userId })
+	return isOwner
+}
+
+func (s *DocStore) IsBoardLocked(boardId string) bool {
+	if boardId == "" {
+		return true
+	}
+	locked := true
+	s.view(boardId, func(d *boardDoc) { locked = d.Board.Lock })
+	return locked
+}
+
+func (s *DocStore) UpdateMasking(b *Board, mask bool) bool {
+	return s.update(b.Id, func(d *boardDoc) { d.Board.Mask = mask })
+}
+
+func (s *DocStore) UpdateBoardLock(b *Board, lock bool) bool {
+	return s.update(b.Id, func(d *boardDoc) { d.Board.Lock = lock })
+}
+
+func (s *DocStore) UpdateBoardOwner(b *Board, owner string) bool {
+	return s.update(b.Id, func(d *boardDoc) {
+		d.Board.Owner = owner
+		d.Board.OwnerEpoch++
+		b.OwnerEpoch = d.Board.OwnerEpoch
+		s.docs.addUserBoard(owner, b.Id)
+	})
+}
+
+// UpdateBoardExpiry moves the expiry of the whole document. Everything of the board lives in it.
+func (s *DocStore) UpdateBoardExpiry(b *Board, expiresAtUtc int64) bool {
+	return s.update(b.Id, func(d *boardDoc) { d.Board.AutoDeleteAtUtc = expiresAtUtc })
+}
+
+func (s *DocStore) UpdateBoardPasscode(b *Board, passcodeHash string) bool {
+	return s.update(b.Id, func(d *boardDoc) { d.Board.PasscodeHash = passcodeHash })
+}
+
+func (s *DocStore) ReservePasscodeAttempt(boardId, ip string, window time.Duration) (int64, bool) {
+	var attempts int64
+	ok := s.update(boardId, func(d *boardDoc) {
+		now := s.now().UTC()
+		if d.PassFailures == nil {
+			d.PassFailures = make(map[string]*passFailures)
+		}
+		// Drop windows that have passed, for this and other IPs
+		for k, f := range d.PassFailures {
+			if f.ResetAtUtc <= now.Unix() {
+				delete(d.PassFailures, k)
+			}
+		}
+		f, exists := d.PassFailures[ip]
+		if !exists {
+			f = &passFailures{ResetAtUtc: now.Add(window).Unix()}
+			d.PassFailures[ip] = f
+		}
+		f.Count++
+		attempts = f.Count
+	})
+	return attempts, ok
+}
+
+func (s *DocStore) ReleasePasscodeAttempt(boardId, ip string) bool {
+	return s.update(boardId, func(d *boardDoc) {
+		if f, ok := d.PassFailures[ip]; ok && f.ResetAtUtc > s.now().UTC().Unix() && f.Count > 0 {
+			f.Count--
+		}
+	})
+}
+
+// CountRequest keeps counts in memory only. DocStore is single-instance, so there is nothing to share.
+func (s *DocStore) CountRequest(scope, ip string, window time.Duration) (int64, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	key := scope + ":" + ip
+	c, ok := s.requests[key]
+	if !ok || !c.resetAt.After(now) {
+		c = &requestWindow{resetAt: now.Add(window)}
+		s.requests[key] = c
+	}
+	c.count++
+	return c.count, c.resetAt.Sub(now), true
+}
+
+func (s *DocStore) UpdateBoardInviteOnly(b *Board, inviteOnly bool) bool {
+	return s.update(b.Id, func(d *boardDoc) { d.Board.InviteOnly = inviteOnly })
+}
+
+func (s *DocStore) CreateInvite(b *Board, inv *Invite) bool {
+	return s.update(b.Id, func(d *boardDoc) {
+		if d.Invites == nil {
+			d.Invites = make(map[string]*docInvite)
+		}
+		d.Invites[inv.Id] = &docInvite{Invite: *inv, Users: make(map[string]struct{})}
+	})
+}
+
+// liveInvite returns the invite unless it doesn't exist or has expired. Expired invites are removed.
+// Must be called from within update.
+func (s *DocStore) liveInvite(d *boardDoc, inviteId string) *docInvite {
+	inv, ok := d.Invites[inviteId]
+	if !ok {
+		return nil
+	}
+	if inv.Invite.ExpiresAtUtc <= s.now().UTC().Unix() {
+		delete(d.Invites, inviteId)
+		return nil
+	}
+	return inv
+}
+
+func (s *DocStore) GetInvites(boardId string) ([]*Invite, bool) {
+	var invites []*Invite
+	ok := s.view(boardId, func(d *boardDoc) {
+		now := s.now().UTC().Unix()
+		invites = make([]*Invite, 0, len(d.Invites))
+		for _, inv := range d.Invites {
+			if inv.Invite.ExpiresAtUtc <= now {
+				continue
+			}
+			c := inv.Invite
+			c.Uses = int64(len(inv.Users))
+			invites = append(invites, &c)
+		}
+	})
+	sortInvites(invites)
+	return invites, ok
+}
+
+func (s *DocStore) RevokeInvite(boardId, inviteId string) bool {
+	revoked := false
+	s.update(boardId, func(d *boardDoc) {
+		revoked = s.liveInvite(d, inviteId) != nil
+		delete(d.Invites, inviteId)
+	})
+	return revoked
+}
+
+func (s *DocStore) CheckInvite(boardId, inviteId, userId string) bool {
+	accepted := false
+	s.view(boardId, func(d *boardDoc) {
+		inv, ok := d.Invites[inviteId]
+		if !ok || inv.Invite.ExpiresAtUtc <= s.now().UTC().Unix() {
+			return
+		}
+		_, used := inv.Users[userId]
+		accepted = used || inv.Invite.MaxUses == 0 || int64(len(inv.Users)) < inv.Invite.MaxUses
+	})
+	return accepted
+}
+
+func (s *DocStore) UseInvite(boardId, inviteId, userId string) bool {
+	accepted := false
+	s.update(boardId, func(d *boardDoc) {
+		inv := s.liveInvite(d, inviteId)
+		if inv == nil {
+			return
+		}
+		if _, ok := inv.Users[userId]; ok {
+			accepted = true
+			return
+		}
+		if inv.Invite.MaxUses > 0 && int64(len(inv.Users)) >= inv.Invite.MaxUses {
+			return
+		}
+		if inv.Users == nil {
+			inv.Users = make(map[string]struct{})
+		}
+		inv.Users[userId] = struct{}{}
+		accepted = true
+	})
+	return accepted
+}
+
+func (s *DocStore) UpdateTimer(b *Board, expiryDurationInSeconds uint16) bool {
+	duration := time.Duration(expiryDurationInSeconds) * time.Second
+	expiryTime := s.now().UTC().Add(duration).Unix()
+	return s.update(b.Id, func(d *boardDoc) { d.Board.TimerExpiresAtUtc = expiryTime })
+}
+
+func (s *DocStore) StopTimer(b *Board) bool {
+	// Same as RedisConnector.StopTimer. One second before current time.
+	expiryTime := s.now().UTC().Unix() - 1
+	return s.update(b.Id, func(d *boardDoc) { d.Board.TimerExpiresAtUtc = expiryTime })
+}
+
+func (s *DocStore) DeleteAll(boardId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteBoard(boardId)
+}
+
+func (s *DocStore) BoardsExpiringBefore(t time.Time) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.docs.expired(t.UTC().Unix()), true
+}
+
+func (s *DocStore) GetBoardAggregatedData(boardId string) (*BoardAggregatedData, bool) {
+	var data *BoardAggregatedData
+	ok := s.view(boardId, func(d *boardDoc) {
+		b := d.Board
+		data = &BoardAggregatedData{
+			Board:            &b,
+			Columns:          d.columns(),
+			Users:            make([]*User, 0, len(d.Users)),
+			ActiveUserIds:    make(map[string]struct{}, len(d.Presence)),
+			Messages:         d.messagesIn(d.MsgIds),
+			Comments:         d.messagesIn(d.CmtIds),
+			PinnedMessageIds: setMembers(d.Pins),
+		}
+		for _, u := range d.Users {
+			c := *u
+			data.Users = append(data.Users, &c)
+		}
+		for id := range d.Presence {
+			data.ActiveUserIds[id] = struct{}{}
+		}
+	})
+	return data, ok
+}
+
+func (s *DocStore) GetBoardColumns(boardId string) ([]*BoardColumn, bool) {
+	cols := make([]*BoardColumn, 0)
+	s.view(boardId, func(d *boardDoc) { cols = d.columns() })
+	return cols, true
+}
+
+func (s *DocStore) IsBoardColumnActive(boardId, colId string) bool {
+	active := false
+	s.view(boardId, func(d *boardDoc) { _, active = d.Columns[colId] })
+	return active
+}
+
+func (s *DocStore) ResetBoardColumns(b *Board, oldCols []*BoardColumn, newCols []*BoardColumn) bool {
+	return s.update(b.Id, func(d *boardDoc) {
+		cols := make(map[string]*BoardColumn, len(newCols))
+		for _, col := range newCols {
+			c := *col
+			cols[c.Id] = &c
+		}
+		d.Columns = cols
+	})
+}
+
+func (s *DocStore) MoveBoardColumns(b *Board, order []string) bool {
+	moved := false
+	s.update(b.Id, func(d *boardDoc) {
+		if !sameColumnIds(slices.Collect(maps.Keys(d.Columns)), order) {
+			return
+		}
+		for i, id := range order {
+			d.Columns[id].Position = i + 1
+		}
+		moved = true
+	})
+	return moved
+}
+
+func (s *DocStore) EnsureUser(boardId, userId, nickname string) (*User, bool) {
+	var user User
+	ok := s.update(boardId, func(d *boardDoc) {
+		if u, exists := d.Users[userId]; exists {
+			u.Nickname = nickname
+			user = *u
+			s.docs.addUserBoard(userId, boardId) // Users who joined before the index existed
+			return
+		}
+		d.XidSeq++
+		u := &User{Id: userId, Xid: strconv.FormatInt(d.XidSeq, 10), Nickname: nickname}
+		d.Users[userId] = u
+		user = *u
+		s.docs.addUserBoard(userId, boardId)
+	})
+	if !ok {
+		slog.Error("Failed creating user. Board not found", "boardId", boardId, "userId", userId)
+		return nil, false
+	}
+	return &user, true
+}
+
+func (s *DocStore) GetUserBoards(userId string) ([]*Board, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var boards []*Board
+	for _, boardId := range s.docs.boardsOfUser(userId) {
+		d := s.doc(boardId)
+		if d == nil {
+			s.docs.delUserBoard(userId, boardId)
+			continue
+		}
+		b := d.Board
+		boards = append(boards, &b)
+	}
+	sortUserBoards(boards)
+	return boards, true
+}
+
+func (s *DocStore) UserTokenPending(userId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.docs.userTokenAcked(userId)
+}
+
+func (s *DocStore) AckUserToken(userId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.docs.ackUserToken(userId)
+}
+
+func (s *DocStore) GetUser(boardId string, userId string) (*User, bool) {
+	if boardId == "" || userId == "" {
+		return nil, false
+	}
+	var user *User
+	s.view(boardId, func(d *boardDoc) {
+		if u, ok := d.Users[userId]; ok {
+			c := *u
+			user = &c
+		}
+	})
+	return user, user != nil
+}
+
+func (s *DocStore) GetUserByXid(boardId string, xid string) (*User, bool) {
+	if boardId == "" || xid == "" {
+		return nil, false
+	}
+	var user *User
+	s.view(boardId, func(d *boardDoc) {
+		for _, u := range d.Users {
+			if u.Xid == xid {
+				c := *u
+				user = &c
+				return
+			}
+		}
+	})
+	return user, user != nil
+}
+
+func (s *DocStore) UpdateUserModerator(boardId, userId string, moderator bool) bool {
+	found := false
+	ok := s.update(boardId, func(d *boardDoc) {
+		if u, exists := d.Users[userId]; exists {
+			u.Moderator = moderator
+			u.ModeratorEpoch++
+			found = true
+		}
+	})
+	return ok && found
+}
+
+func (s *DocStore) BanUser(b *Board, userId string) bool {
+	return s.update(b.Id, func(d *boardDoc) {
+		if d.Banned == nil {
+			d.Banned = make(map[string]struct{})
+		}
+		d.Banned[userId] = struct{}{}
+	})
+}
+
+func (s *DocStore) IsBanned(boardId, userId string) bool {
+	banned := false
+	s.view(boardId, func(d *boardDoc) { _, banned = d.Banned[userId] })
+	return banned
+}
+
+func (s *DocStore) CommitUserPresence(boardId string, userId string) bool {
+	ok := s.update(boardId, func(d *boardDoc) { d.Presence[userId] = struct{}{} })
+	if !ok {
+		slog.Error("Failed committing user presence. Board not found", "boardId", boardId, "user", userId)
+	}
+	return ok
+}
+
+func (s *DocStore) RemoveUserPresence(boardId string, userId string) bool {
+	s.update(boardId, func(d *boardDoc) { delete(d.Presence, userId) })
+	return true
+}
+
+func (s *DocStore) GetMessage(boardId, msgId string) (*Message, bool) {
+	var msg *Message
+	s.view(boardId, func(d *boardDoc) {
+		if m, ok := d.Messages[msgId]; ok {
+			c := *m
+			msg = &c
+		}
+	})
+	return msg, msg != nil
+}
+
+func (s *DocStore) GetMessages(boardId string) ([]*Message, bool) {
+	messages := make([]*Message, 0)
+	s.view(boardId, func(d *boardDoc) { messages = d.messagesIn(d.MsgIds) })
+	return messages, true
+}
+
+func (s *DocStore) GetMessagesByIds(ids []string, boardId string) ([]*Message, bool) {
+	messages := make([]*Message, 0)
+	if len(ids) == 0 {
+		return messages, true
+	}
+	s.view(boardId, func(d *boardDoc) {
+		for _, id := range ids {
+			if m, ok := d.Messages[id]; ok {
+				c := *m
+				messages = append(messages, &c)
+			}
+		}
+	})
+	return messages, true
+}
+
+func (s *DocStore) Save(msg *Message, modes ...SaveMode) bool {
+	ok := s.update(msg.Group, func(d *boardDoc) {
+		m := *msg
+		d.Messages[m.Id] = &m
+
+		if len(modes) > 0 {
+			// Only considering first variadic argument in modes ...SaveMode
+			switch modes[0] {
+			case AsNewMessage:
+				d.MsgIds[m.Id] = struct{}{}
+			case AsNewComment:
+				d.CmtIds[m.Id] = struct{}{}
+			}
+		}
+	})
+	if !ok {
+		slog.Error("Failed to save message/comment. Board not found", "payloads", msg, "modes", modes)
+	}
+	return ok
+}
+
+func (s *DocStore) UpdateCategory(boardId, category string, msgId string, commentIds []string) bool {
+	return s.update(boardId, func(d *boardDoc) {
+		for _, id := range append([]string{msgId}, commentIds...) {
+			if m, ok := d.Messages[id]; ok {
+				m.Category = category
+			}
+		}
+	})
+}
+
+func (s *DocStore) DeleteMessage(group string, msgId string, commentIds []string) bool {
+	s.update(group, func(d *boardDoc) {
+		delete(d.Messages, msgId)
+		delete(d.Likes, msgId)
+		delete(d.Pins, msgId)
+		delete(d.MsgIds, msgId)
+		for _, cid := range commentIds {
+			delete(d.Messages, cid)
+			delete(d.CmtIds, cid)
+		}
+	})
+	return true
+}
+
+func (s *DocStore) DeleteComment(group string, commentId string) bool {
+	s.update(group, func(d *boardDoc) {
+		delete(d.Messages, commentId)
+		delete(d.CmtIds, commentId)
+	})
+	return true
+}
+
+func (s *DocStore) Like(boardId, msgId string, by string, like bool) bool {
+	affected := false
+	s.update(boardId, func(d *boardDoc) {
+		likes, ok := d.Likes[msgId]
+		if !ok {
+			likes = make(map[string]struct{})
+			d.Likes[msgId] = likes
+		}
+		_, liked := likes[by]
+		if like && !liked {
+			likes[by] = struct{}{}
+			affected = true
+		} else if !like && liked {
+			delete(likes, by)
+			affected = true
+		}
+	})
+	if !affected {
+		if like {
+			slog.Warn("Cannot like a message which is already liked", "msgId", msgId, "by", by, "like", like)
+		} else {
+			slog.Warn("Message must be liked for it to be unliked", "msgId", msgId, "by", by, "like", like)
+		}
+	}
+	return affected
+}
+
+func (s *DocStore) GetLikesCount(boardId, msgId string) int64 {
+	var count int64
+	s.view(boardId, func(d *boardDoc) { count = int64(len(d.Likes[msgId])) })
+	return count
+}
+
+func (s *DocStore) HasLiked(boardId, msgId string, users []string) []bool {
+	results := make([]bool, len(users))
+	s.view(boardId, func(d *boardDoc) {
+		likes := d.Likes[msgId]
+		for i, u := range users {
+			_, results[i] = likes[u]
+		}
+	})
+	return results
+}
+
+func (s *DocStore) GetLikesInfo(boardId, by string, msgIds ...string) (map[string]LikeInfo, bool) {
+	result := make(map[string]LikeInfo, len(msgIds))
+	s.view(boardId, func(d *boardDoc) {
+		for _, id := range msgIds {
+			likes := d.Likes[id]
+			_, liked := likes[by]
+			result[id] = LikeInfo{Count: int64(len(likes)), Liked: liked}
+		}
+	})
+	return result, true
+}
+
+func (s *DocStore) SaveOfflineLikes(boardId, msgId string, offlineLikes int64) bool {
+	saved := false
+	s.update(boardId, func(d *boardDoc) {
+		if m, ok := d.Messages[msgId]; ok {
+			m.OfflineLikes = offlineLikes
+			saved = true
+		}
+	})
+	return saved
+}
+
+func (s *DocStore) UpdateMessagePin(boardId, msgId string, pin bool) bool {
+	return s.update(boardId, func(d *boardDoc) {
+		if pin {
+			d.Pins[msgId] = struct{}{}
+		} else {
+			delete(d.Pins, msgId)
+		}
+	})
+}
+
+func (s *DocStore) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.docs.close()
+	})
+}
+
+// columns returns copies of the active columns ordered by position.
+func (d *boardDoc) columns() []*BoardColumn {
+	cols := make([]*BoardColumn, 0, len(d.Columns))
+	for _, col := range d.Columns {
+		c := *col
+		cols = append(cols, &c)
+	}
+	slices.SortFunc(cols, func(a, b *BoardColumn) int { return a.Position - b.Position })
+	return cols
+}
+
+// messagesIn returns copies of the messages whose ids are in the set. Ids without a message are skipped.
+func (d *boardDoc) messagesIn(ids map[string]struct{}) []*Message {
+	messages := make([]*Message, 0, len(ids))
+	for id := range ids {
+		if m, ok := d.Messages[id]; ok {
+			c := *m
+			messages = append(messages, &c)
+		}
+	}
+	return messages
+}
+
+// setMembers returns the members of a set as a non-nil slice, like SMEMBERS.
+func setMembers(set map[string]struct{}) []string {
+	members := make([]string, 0, len(set))
+	for id := range set {
+		members = append(members, id)
+	}
+	return members
+}
+
+func (s *DocStore) CreateTeam(t *Team, retention time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	d := &teamDoc{Team: *t, Boards: make(map[string]*teamDocBoard)}
+	d.Team.CreatedAtUtc = now.Unix()
+	d.Team.AutoDeleteAtUtc = now.Add(retention).Unix()
+	if !s.docs.putTeam(d) {
+		slog.Error("Failed to create team", "team", t.Id)
+		return false
+	}
+	return true
+}
+
+func (s *DocStore) GetTeam(teamId string) (*Team, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.team(teamId)
+	if d == nil {
+		return nil, false
+	}
+	t := d.Team
+	return &t, true
+}
+
+func (s *DocStore) AddTeamBoard(teamId string, b *Board, retention time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.team(teamId)
+	if d == nil {
+		return false
+	}
+	tb, ok := d.Boards[b.Id]
+	if !ok {
+		tb = &teamDocBoard{Users: make(map[string]struct{})}
+		d.Boards[b.Id] = tb
+	}
+	tb.Board = TeamBoard{Id: b.Id, Name: b.Name, CreatedAtUtc: b.CreatedAtUtc, AutoDeleteAtUtc: b.AutoDeleteAtUtc}
+	d.Team.AutoDeleteAtUtc = s.now().UTC().Add(retention).Unix()
+	return s.docs.putTeam(d)
+}
+
+func (s *DocStore) AddTeamBoardParticipant(teamId, boardId, userId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.team(teamId)
+	if d == nil || d.Boards[boardId] == nil {
+		return false
+	}
+	users := d.Boards[boardId].Users
+	if _, ok := users[userId]; ok {
+		return true
+	}
+	users[userId] = struct{}{}
+	return s.docs.putTeam(d)
+}
+
+func (s *DocStore) GetTeamBoards(teamId string) ([]*TeamBoard, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.team(teamId)
+	if d == nil {
+		return []*TeamBoard{}, true
+	}
+	boards := make([]*TeamBoard, 0, len(d.Boards))
+	for _, tb := range d.Boards {
+		b := tb.Board
+		b.Participants = int64(len(tb.Users))
+		boards = append(boards, &b)
+	}
+	slices.SortFunc(boards, func(x, y *TeamBoard) int {
+		return cmp.Or(cmp.Compare(y.CreatedAtUtc, x.CreatedAtUtc), cmp.Compare(x.Id, y.Id))
+	})
+	return boards, true
+}
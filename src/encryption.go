@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Encryption at rest (Redis store only).
+// With ENCRYPTION_KEYS set, RedisConnector encrypts card and comment text, author nicknames, column text and board names
+// before writing them, and decrypts them when reading. Handlers never see ciphertext.
+//
+// Envelope encryption: every value is encrypted with its own random AES-256-GCM data key. The data key is encrypted (wrapped)
+// with a master key from ENCRYPTION_KEYS, and stored next to the value with the master key's id:
+//
+//	enc1.<keyId>.<wrapped data key>.<nonce + ciphertext>    (base64url)
+//
+// ENCRYPTION_KEYS is a comma separated list of <keyId>:<base64 key>, with 32 byte keys. The first key encrypts, all of them decrypt.
+// To rotate, put a new key first, restart, run with -rotate-encryption to re-encrypt live boards, then drop the old key.
+//
+// Values are bound to their board, record and field through GCM additional data, so they can't be copied to another card.
+// Values written before encryption was enabled are read as they are, and encrypted by the next write or rotation.
+
+const encPrefix = "enc1."
+
+var encKeyId = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+var errNoEncryptionKey = errors.New("encryption key not configured")
+
+// fieldCipher encrypts single values. A nil fieldCipher leaves values as they are.
+type fieldCipher struct {
+	activeId string
+	keys     map[string]cipher.AEAD // Master keys by id
+}
+
+// newFieldCipher parses ENCRYPTION_KEYS. Returns nil when spec is empty.
+func newFieldCipher(spec string) (*fieldCipher, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	fc := &fieldCipher{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || !encKeyId.MatchString(id) {
+			return nil, fmt.Errorf("invalid encryption key entry, expected <keyId>:<base64 key>")
+		}
+		if _, dup := fc.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, base64 encoded", id)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		fc.keys[id] = aead
+		if fc.activeId == "" {
+			fc.activeId = id
+		}
+	}
+	return fc, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// aad identifies where a value is stored, e.g. ("board1", "msg/m1", "content").
+// The board id and record id don't change when keys are renamed (see MigrateKeys).
+func aad(boardId, record, field string) []byte {
+	return []byte(boardId + "\x00" + record + "\x00" + field)
+}
+
+// seal encrypts a value with a fresh data key. Empty values stay empty.
+func (fc *fieldCipher) seal(plaintext, boardId, record, field string) string {
+	if fc == nil || plaintext == "" {
+		return plaintext
+	}
+	dek := make([]byte, 32)
+	rand.Read(dek)
+	data, err := newGCM(dek)
+	if err != nil {
+		panic(err) // A 32 byte key is always valid
+	}
+	ad := aad(boardId, record, field)
+	master := fc.keys[fc.activeId]
+	wrapped := sealWithNonce(master, dek, ad)
+	sealed := sealWithNonce(data, []byte(plaintext), ad)
+
+	enc := base64.RawURLEncoding
+	return encPrefix + fc.activeId + "." + enc.EncodeToString(wrapped) + "." + enc.EncodeToString(sealed)
+}
+
+// sealWithNonce encrypts with a random nonce, and returns the nonce followed by the ciphertext.
+func sealWithNonce(aead cipher.AEAD, plaintext, ad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, ad)
+}
+
+// open decrypts a value written by seal. Values without the prefix are returned as they are.
+func (fc *fieldCipher) open(value, boardId, record, field string) (string, error) {
+	rest, encrypted := strings.CutPrefix(value, encPrefix)
+	if !encrypted {
+		return value, nil
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	if fc == nil {
+		return "", errNoEncryptionKey
+	}
+	master, ok := fc.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", errNoEncryptionKey, parts[0])
+	}
+	wrapped, err1 := base64.RawURLEncoding.DecodeString(parts[1])
+	sealed, err2 := base64.RawURLEncoding.DecodeString(parts[2])
+	if err := errors.Join(err1, err2); err != nil {
+		return "", err
+	}
+	ad := aad(boardId, record, field)
+	dek, err := openWithNonce(master, wrapped, ad)
+	if err != nil {
+		return "", err
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openWithNonce(data, sealed, ad)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func openWithNonce(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted value too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], ad)
+}
+
+// needsRotation reports whether a value isn't encrypted with the active key.
+func (fc *fieldCipher) needsRotation(value string) bool {
+	if fc == nil || value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, encPrefix+fc.activeId+".")
+}
+
+// Records of encrypted values. See aad.
+func boardRecord() string               { return "board" }
+func columnRecord(colId string) string  { return "col/" + colId }
+func messageRecord(msgId string) string { return "msg/" + msgId }
+func teamRecord() string                { return "team" }
+func archiveRecord() string             { return "archive" }
+
+// decrypt opens a value read from Redis. Values that can't be decrypted are logged and returned empty, never as ciphertext.
+func (c *RedisConnector) decrypt(value, boardId, record, field string) string {
+	plaintext, err := c.crypt.open(value, boardId, record, field)
+	if err != nil {
+		slog.Error("Cannot decrypt value", "err", err, "board", boardId, "record", record, "field", field)
+		return ""
+	}
+	return plaintext
+}
+
+func (c *RedisConnector) decryptBoard(b *Board) {
+	b.Name = c.decrypt(b.Name, b.Id, boardRecord(), "name")
+}
+
+func (c *RedisConnector) decryptColumn(boardId string, col *BoardColumn) {
+	col.Text = c.decrypt(col.Text, boardId, columnRecord(col.Id), "text")
+	col.Description = c.decrypt(col.Description, boardId, columnRecord(col.Id), "desc")
+}
+
+func (c *RedisConnector) decryptMessage(boardId string, m *Message) {
+	m.Content = c.decrypt(m.Content, boardId, messageRecord(m.Id), "content")
+	m.ByNickname = c.decrypt(m.ByNickname, boardId, messageRecord(m.Id), "nickname")
+}
+
+// rewriteIfUnchangedScript replaces a hash field only if it still has the value that was read, so rotating doesn't undo concurrent edits.
+// KEYS[1] hash, ARGV[1] field, ARGV[2] value read, ARGV[3] new value. Returns 1 when replaced.
+var rewriteIfUnchangedScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// RotateEncryption re-encrypts every value of live boards that isn't encrypted with the active key,
+// including values written before encryption was enabled. It is idempotent and can run while the app is serving.
+// Returns the number of values re-encrypted.
+func (c *RedisConnector) RotateEncryption() (int, bool) {
+	if c.crypt == nil {
+		slog.Error("Set ENCRYPTION_KEYS to rotate encryption")
+		return 0, false
+	}
+	rotated := 0
+	ok := c.scanKeys(keyBoard+"{*}", func(key string) bool {
+		boardId := strings.TrimSuffix(strings.TrimPrefix(key, keyBoard+"{"), "}")
+		n, ok := c.rotateBoard(boardId)
+		rotated += n
+		return ok
+	})
+	if !ok {
+		return rotated, false
+	}
+	ok = c.scanKeys(keyTeam+"{*}", func(key string) bool {
+		teamId := strings.TrimSuffix(strings.TrimPrefix(key, keyTeam+"{"), "}")
+		n, ok := c.rotateTeam(teamId)
+		rotated += n
+		return ok
+	})
+	return rotated, ok
+}
+
+// scanKeys calls fn for every key matching pattern. On Redis Cluster, every master is scanned.
+func (c *RedisConnector) scanKeys(pattern string, fn func(key string) bool) bool {
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, pattern, 500).Iterator()
+		for iter.Next(ctx) {
+			if !fn(iter.Val()) {
+				return errors.New("stopped")
+			}
+		}
+		return iter.Err()
+	}
+	var err error
+	if cluster, isCluster := c.client.(*redis.ClusterClient); isCluster {
+		var mu sync.Mutex // fn isn't safe for concurrent use
+		err = cluster.ForEachMaster(c.ctx, func(ctx context.Context, master *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return scan(ctx, master)
+		})
+	} else {
+		err = scan(c.ctx, c.client)
+	}
+	if err != nil {
+		slog.Error("Failed scanning keys", "err", err, "pattern", pattern)
+		return false
+	}
+	return true
+}
+
+// encryptedField is a hash field holding an encrypted value. scope is the id it is sealed with, usually the boardId.
+type encryptedField struct {
+	key, field, scope, record string
+}
+
+func (c *RedisConnector) rotateBoard(boardId string) (int, bool) {
+	fields := []encryptedField{{key: boardKey(boardId), field: "name", scope: boardId, record: boardRecord()}}
+
+	colIds, err1 := c.client.SMembers(c.ctx, boardColsKey(boardId)).Result()
+	msgIds, err2 := c.client.SMembers(c.ctx, boardMsgsKey(boardId)).Result()
+	cmtIds, err3 := c.client.SMembers(c.ctx, boardCmtsKey(boardId)).Result()
+	if err := errors.Join(err1, err2, err3); err != nil {
+		slog.Error("Failed reading board for encryption rotation", "err", err, "board", boardId)
+		return 0, false
+	}
+	for _, id := range colIds {
+		fields = append(fields,
+			encryptedField{key: boardColKey(boardId, id), field: "text", scope: boardId, record: columnRecord(id)},
+			encryptedField{key: boardColKey(boardId, id), field: "desc", scope: boardId, record: columnRecord(id)},
+		)
+	}
+	for _, id := range append(msgIds, cmtIds...) {
+		fields = append(fields,
+			encryptedField{key: msgKey(boardId, id), field: "content", scope: boardId, record: messageRecord(id)},
+			encryptedField{key: msgKey(boardId, id), field: "nickname", scope: boardId, record: messageRecord(id)},
+		)
+	}
+	return c.rotateFields(fields)
+}
+
+// rotateTeam re-encrypts the team's name, and the names in its summaries of boards. Those are sealed like the board's name.
+func (c *RedisConnector) rotateTeam(teamId string) (int, bool) {
+	fields := []encryptedField{{key: teamKey(teamId), field: "name", scope: teamId, record: teamRecord()}}
+
+	boardIds, err := c.client.ZRange(c.ctx, teamBoardsKey(teamId), 0, -1).Result()
+	if err != nil {
+		slog.Error("Failed reading team for encryption rotation", "err", err, "team", teamId)
+		return 0, false
+	}
+	for _, id := range boardIds {
+		fields = append(fields, encryptedField{key: teamBoardKey(teamId, id), field: "name", scope: id, record: boardRecord()})
+	}
+	return c.rotateFields(fields)
+}
+
+func (c *RedisConnector) rotateFields(fields []encryptedField) (int, bool) {
+	rotated := 0
+	for _, f := range fields {
+		value, err := c.client.HGet(c.ctx, f.key, f.field).Result()
+		if err == redis.Nil || !c.crypt.needsRotation(value) {
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed reading value for encryption rotation", "err", err, "scope", f.scope)
+			return rotated, false
+		}
+		plaintext, err := c.crypt.open(value, f.scope, f.record, f.field)
+		if err != nil {
+			// Keep going. The value stays unreadable until its key is added back.
+			slog.Error("Cannot decrypt value for rotation", "err", err, "scope", f.scope, "record", f.record, "field", f.field)
+			continue
+		}
+		sealed := c.crypt.seal(plaintext, f.scope, f.record, f.field)
+		done, err := rewriteIfUnchangedScript.Run(c.ctx, c.client, []string{f.key}, f.field, value, sealed).Int()
+		if err != nil {
+			slog.Error("Failed writing re-encrypted value", "err", err, "scope", f.scope)
+			return rotated, false
+		}
+		rotated += done // 0 when the value was changed meanwhile, by a write that encrypted it
+	}
+	return rotated, true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testEncryptionKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func mustFieldCipher(t *testing.T, spec string) *fieldCipher {
+	t.Helper()
+	fc, err := newFieldCipher(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+func TestFieldCipher(t *testing.T) {
+	fc := mustFieldCipher(t, "k1:"+testEncryptionKey('a'))
+
+	sealed := fc.seal("candid feedback", "b1", messageRecord("m1"), "content")
+	if !strings.HasPrefix(sealed, "enc1.k1.") || strings.Contains(sealed, "candid") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if again := fc.seal("candid feedback", "b1", messageRecord("m1"), "content"); again == sealed {
+		t.Error("same ciphertext for the same value, data keys not random")
+	}
+	if got, err := fc.open(sealed, "b1", messageRecord("m1"), "content"); err != nil || got != "candid feedback" {
+		t.Errorf("open = %q, %v", got, err)
+	}
+	// Bound to where it's stored
+	if _, err := fc.open(sealed, "b1", messageRecord("m2"), "content"); err == nil {
+		t.Error("value opened as another message")
+	}
+	if _, err := fc.open(sealed, "b2", messageRecord("m1"), "content"); err == nil {
+		t.Error("value opened on another board")
+	}
+	// Plaintext and empty values pass through
+	if got, err := fc.open("written before encryption", "b1", boardRecord(), "name"); err != nil || got != "written before encryption" {
+		t.Errorf("plaintext open = %q, %v", got, err)
+	}
+	if fc.seal("", "b1", boardRecord(), "name") != "" {
+		t.Error("empty value encrypted")
+	}
+	// Without the key
+	if _, err := (*fieldCipher)(nil).open(sealed, "b1", messageRecord("m1"), "content"); err == nil {
+		t.Error("opened without keys")
+	}
+
+	// Rotation keeps old keys for reading
+	rotated := mustFieldCipher(t, "k2:"+testEncryptionKey('b')+",k1:"+testEncryptionKey('a'))
+	if !rotated.needsRotation(sealed) || fc.needsRotation(sealed) || !fc.needsRotation("plain") {
+		t.Error("needsRotation mismatch")
+	}
+	if got, err := rotated.open(sealed, "b1", messageRecord("m1"), "content"); err != nil || got != "candid feedback" {
+		t.Errorf("open with old key = %q, %v", got, err)
+	}
+}
+
+func TestNewFieldCipher_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"k1", // no key
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"bad id:" + testEncryptionKey('a'),
+		"k1:" + testEncryptionKey('a') + ",k1:" + testEncryptionKey('b'),
+		"k1:not base64!",
+	} {
+		if _, err := newFieldCipher(spec); err == nil {
+			t.Errorf("newFieldCipher(%q) accepted", spec)
+		}
+	}
+	if fc, err := newFieldCipher(""); fc != nil || err != nil {
+		t.Error("empty spec should disable encryption")
+	}
+}
+
+func newTestEncryptedRedisStore(t *testing.T) (*RedisConnector, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s := newRedisConnector(context.Background(), rdb, testTTL)
+	s.crypt = mustFieldCipher(t, "k1:"+testEncryptionKey('a'))
+	t.Cleanup(s.Close)
+	return s, mr
+}
+
+// The whole Store contract holds with encryption enabled
+func TestStoreConformance_RedisEncrypted(t *testing.T) {
+	runStoreConformance(t, func(t *testing.T) (Store, func(time.Duration)) {
+		s, mr := newTestEncryptedRedisStore(t)
+		return s, mr.FastForward
+	})
+}
+
+func TestRedisConnector_EncryptsAtRest(t *testing.T) {
+	s, mr := newTestEncryptedRedisStore(t)
+	b := &Board{Id: "b1", Name: "Secret retro", Owner: "u1", Creator: "u1"}
+	s.CreateBoard(b, []*BoardColumn{{Id: "col01", Text: "Grumbles", Color: "red", Position: 1, Description: "What slowed us down?"}}, 0)
+	s.Save(&Message{Id: "m1", By: "u1", ByNickname: "Alice", Group: "b1", Content: "candid", Category: "col01"}, AsNewMessage)
+	s.CreateTeam(&Team{Id: "t1", Name: "Platform"}, time.Hour)
+	stored, _ := s.GetBoard("b1")
+	s.AddTeamBoard("t1", stored, time.Hour)
+
+	for key, field := range map[string]string{
+		boardKey("b1"):             "name",
+		boardColKey("b1", "col01"): "text",
+		msgKey("b1", "m1"):         "content",
+		teamKey("t1"):              "name",
+		teamBoardKey("t1", "b1"):   "name",
+	} {
+		if raw := mr.HGet(key, field); !strings.HasPrefix(raw, "enc1.k1.") {
+			t.Errorf("%s %s stored as %q", key, field, raw)
+		}
+	}
+	if raw := mr.HGet(msgKey("b1", "m1"), "nickname"); !strings.HasPrefix(raw, "enc1.k1.") {
+		t.Errorf("nickname stored as %q", raw)
+	}
+	if raw := mr.HGet(boardColKey("b1", "col01"), "desc"); !strings.HasPrefix(raw, "enc1.k1.") {
+		t.Errorf("column description stored as %q", raw)
+	}
+
+	// Plaintext written before encryption was enabled is still readable, then rotated
+	mr.HSet(msgKey("b1", "m1"), "content", "legacy")
+	if m, _ := s.GetMessage("b1", "m1"); m.Content != "legacy" {
+		t.Errorf("legacy content = %q", m.Content)
+	}
+
+	s.crypt = mustFieldCipher(t, "k2:"+testEncryptionKey('b')+",k1:"+testEncryptionKey('a'))
+	rotated, ok := s.RotateEncryption()
+	if !ok || rotated != 7 {
+		t.Fatalf("rotated %d, %v, want 7", rotated, ok)
+	}
+	if rotated, _ := s.RotateEncryption(); rotated != 0 {
+		t.Errorf("second rotation re-encrypted %d values", rotated)
+	}
+
+	// The old key is no longer needed
+	s.crypt = mustFieldCipher(t, "k2:"+testEncryptionKey('b'))
+	data, ok := s.GetBoardAggregatedData("b1")
+	if !ok || data.Board.Name != "Secret retro" || data.Columns[0].Text != "Grumbles" || data.Columns[0].Description != "What slowed us down?" ||
+		data.Messages[0].Content != "legacy" || data.Messages[0].ByNickname != "Alice" {
+		t.Errorf("after rotation: %+v %+v %+v", data.Board, data.Columns[0], data.Messages[0])
+	}
+	if team, _ := s.GetTeam("t1"); team.Name != "Platform" {
+		t.Errorf("team name after rotation = %q", team.Name)
+	}
+	if boards, _ := s.GetTeamBoards("t1"); len(boards) != 1 || boards[0].Name != "Secret retro" {
+		t.Errorf("team boards after rotation = %+v", boards)
+	}
+}
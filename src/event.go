@@ -8,7 +8,7 @@ import (
 )
 
 type Event struct {
-	Type string `json:"typ"` // Values can be one of "reg", "msg", "del", "delall", "like", "t", "timer", "catchng", "set", "pin". "closing" is not initiated from UI.
+	Type string `json:"typ"` // Values can be one of "reg", "msg", "del", "delall", "like", "t", "timer", "catchng", "set", "pin", "invcreate", "invrevoke", "invlist", "kick", "expiry", "colreset", "colmove", "utokack". "closing", "ratelimited" and "msgrej" are not initiated from UI.
 
 	// "Group", "By", "Xid" are ignored when sent from client. Each client's read goroutine overwrites them all the time.
 	// This is intended for allowing json marshalling/unmarshalling for redis pubsub. With `json:"-"` those fields will loose values during pubsub.
@@ -16,6 +16,12 @@ type Event struct {
 	By    string `json:"by"`
 	Xid   string `json:"xid"`
 
+	// Owner credential of the client, verified during the websocket handshake. See owner_token.go
+	// Never published, only the handling instance needs it.
+	Token string `json:"-"`
+	// Moderator credential of the client, verified the same way. See moderator.go
+	ModeratorToken string `json:"-"`
+
 	Payload json.RawMessage `json:"pyl"`
 }
 
@@ -27,18 +33,27 @@ type EventHandler interface {
 type eventFactory func(data json.RawMessage) (EventHandler, error)
 
 var registry = map[string]eventFactory{
-	"set":      makeFactory[SettingsEvent](),
-	"reg":      makeFactory[RegisterEvent](),
-	"msg":      makeFactory[MessageEvent](),
-	"like":     makeFactory[LikeMessageEvent](),
-	"pin":      makeFactory[PinMessageEvent](),
-	"del":      makeFactory[DeleteMessageEvent](),
-	"delall":   makeFactory[DeleteAllEvent](),
-	"catchng":  makeFactory[CategoryChangeEvent](),
-	"timer":    makeFactory[TimerEvent](),
-	"colreset": makeFactory[ColumnsChangeEvent](),
-	"closing":  makeFactory[UserClosingEvent](),
-	"t":        makeFactory[TypedEvent](),
+	"set":         makeFactory[SettingsEvent](),
+	"reg":         makeFactory[RegisterEvent](),
+	"msg":         makeFactory[MessageEvent](),
+	"like":        makeFactory[LikeMessageEvent](),
+	"pin":         makeFactory[PinMessageEvent](),
+	"del":         makeFactory[DeleteMessageEvent](),
+	"delall":      makeFactory[DeleteAllEvent](),
+	"catchng":     makeFactory[CategoryChangeEvent](),
+	"timer":       makeFactory[TimerEvent](),
+	"colreset":    makeFactory[ColumnsChangeEvent](),
+	"colmove":     makeFactory[ColumnMoveEvent](),
+	"invcreate":   makeFactory[InviteCreateEvent](),
+	"invrevoke":   makeFactory[InviteRevokeEvent](),
+	"invlist":     makeFactory[InviteListEvent](),
+	"kick":        makeFactory[KickEvent](),
+	"expiry":      makeFactory[BoardExpiryEvent](),
+	"closing":     makeFactory[UserClosingEvent](),
+	"ratelimited": makeFactory[RateLimitedEvent](),
+	"msgrej":      makeFactory[MessageRejectedEvent](),
+	"t":           makeFactory[TypedEvent](),
+	"utokack":     makeFactory[UserTokenAckEvent](),
 }
 
 func makeFactory[T any, PT interface {
@@ -1,10 +1,11 @@
 package main
 
 type UserDetails struct {
-	Nickname string `json:"nickname"`
-	Xid      string `json:"xid"`
-	Active   bool   `json:"active"`
-	IsOwner  bool   `json:"isOwner"`
+	Nickname    string `json:"nickname"`
+	Xid         string `json:"xid"`
+	Active      bool   `json:"active"`
+	IsOwner     bool   `json:"isOwner"`
+	IsModerator bool   `json:"isModerator"`
 }
 
 type RegisterResponse struct {
@@ -19,13 +20,19 @@ type RegisterResponse struct {
 	Comments                  []MessageResponse `json:"comments"` // Todo: Change to *MessageResponse
 	Pins                      []string          `json:"pins"`     // Pinned list of messageIds
 	BoardCreatedAtUtcSeconds  int64             `json:"boardCreatedAtUtcSeconds"`
-	BoardExpiryTimeUtcSeconds int64             `json:"boardExpiryUtcSeconds"` // Unix Timestamp Seconds
-	TimerExpiresInSeconds     uint16            `json:"timerExpiresInSeconds"` // uint16 since we are restricting timer to max 1 hour (3600 seconds)
+	BoardExpiryTimeUtcSeconds int64             `json:"boardExpiryUtcSeconds"`    // Unix Timestamp Seconds
+	BoardMaxExpiryUtcSeconds  int64             `json:"boardMaxExpiryUtcSeconds"` // Latest expiry the owner can set. See expiry.go
+	TimerExpiresInSeconds     uint16            `json:"timerExpiresInSeconds"`    // uint16 since we are restricting timer to max 1 hour (3600 seconds)
 	BoardMasking              bool              `json:"boardMasking"`
 	BoardLock                 bool              `json:"boardLock"`
+	BoardPasscode             bool              `json:"boardPasscode"` // True when joining requires a passcode
+	BoardInviteOnly           bool              `json:"boardInviteOnly"`
+	BoardE2EE                 bool              `json:"boardE2ee"` // Card content is end-to-end encrypted. Clients decrypt it with the key from the board link.
 	IsBoardOwner              bool              `json:"isBoardOwner"`
 	IsBoardCreator            bool              `json:"isBoardCreator"`
+	IsBoardModerator          bool              `json:"isBoardModerator"`
 	ShowWelcomePopup          bool              `json:"showWelcomePopup"`
+	UserToken                 string            `json:"userToken,omitempty"` // Only to anonymous users, until they acknowledge it. See user_boards.go
 	// Mine                      bool              `json:"mine"`
 }
 
@@ -41,10 +48,18 @@ type UserClosingResponse struct {
 }
 
 type SettingsResponse struct {
-	Type     string `json:"typ"`
-	OwnerXid string `json:"ownerXid"`
-	Mask     bool   `json:"mask"`
-	Lock     bool   `json:"lock"`
+	Type       string `json:"typ"`
+	OwnerXid   string `json:"ownerXid"`
+	Mask       bool   `json:"mask"`
+	Lock       bool   `json:"lock"`
+	Passcode   bool   `json:"passcode"` // True when joining requires a passcode
+	InviteOnly bool   `json:"inviteOnly"`
+	// Only sent when a moderator was added or removed
+	Moderator *ModeratorChange `json:"moderator,omitempty"`
+	// Only sent to the new owner after a transfer
+	OwnerToken string `json:"ownerToken,omitempty"`
+	// Only sent to a user who was made a moderator
+	ModeratorToken string `json:"moderatorToken,omitempty"`
 }
 
 type MessageResponse struct {
@@ -101,7 +116,42 @@ type ColumnsChangeResponse struct {
 	BoardColumns []*BoardColumn `json:"columns"` // Using same BoardColumn struct that is used for request and redis store. Todo - refactor later.
 }
 
+type ColumnMoveResponse struct {
+	Type  string   `json:"typ"`
+	Order []string `json:"order"` // Column ids. Positions follow the order, starting from 1.
+}
+
 type TypedResponse struct {
 	Type string `json:"typ"`
 	Xid  string `json:"xid"`
 }
+
+type KickResponse struct {
+	Type       string   `json:"typ"`
+	Xid        string   `json:"xid"`
+	Ban        bool     `json:"ban"`
+	MessageIds []string `json:"messageIds"` // Deleted cards and comments of the user, if any
+}
+
+type BoardExpiryResponse struct {
+	Type                      string `json:"typ"`
+	BoardExpiryTimeUtcSeconds int64  `json:"boardExpiryUtcSeconds"` // Unix Timestamp Seconds
+}
+
+type InvitesResponse struct {
+	Type    string    `json:"typ"`
+	Invites []*Invite `json:"invites"`
+}
+
+// Only sent to the board owner, when a user was disconnected for flooding the board.
+type RateLimitedResponse struct {
+	Type string `json:"typ"`
+	Xid  string `json:"xid"`
+}
+
+// Only sent to the author of a card or comment the content pipeline rejected. See content.go
+type MessageRejectedResponse struct {
+	Type   string `json:"typ"`
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+}
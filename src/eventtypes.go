@@ -16,16 +16,16 @@ func (p *RegisterEvent) Handle(e *Event, h *Hub) {
 	// Board existence is already validated during the WebSocket handshake in handleWebSocket.
 
 	// Execute
-	if ok := h.redis.CommitUserPresence(e.Group, e.By); !ok {
+	if ok := h.store.CommitUserPresence(e.Group, e.By); !ok {
 		return
 	}
 
 	// Publish to Redis (for broadcasting)
 	// *Message is nil as this is not a message related update. Find a better way. Generics?
-	h.redis.Publish(e.Group, &BroadcastArgs{Message: nil, Event: e})
+	h.store.Publish(e.Group, &BroadcastArgs{Message: nil, Event: e})
 }
 func (p *RegisterEvent) Broadcast(e *Event, m *Message, h *Hub) {
-	data, ok := h.redis.GetBoardAggregatedData(e.Group)
+	data, ok := h.store.GetBoardAggregatedData(e.Group)
 	if !ok {
 		slog.Error("Failed to get board aggregated data", "board", e.Group)
 		return
@@ -37,7 +37,7 @@ func (p *RegisterEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	userDetails := make([]UserDetails, len(users)) // Preallocate length instead of capacity. len == cap == len(users), so can index directly.
 	for in, u := range users {
 		_, isActive := activeUserIds[u.Id]
-		userDetails[in] = UserDetails{Nickname: u.Nickname, Xid: u.Xid, Active: isActive, IsOwner: u.Id == board.Owner}
+		userDetails[in] = UserDetails{Nickname: u.Nickname, Xid: u.Xid, Active: isActive, IsOwner: u.Id == board.Owner, IsModerator: u.Moderator}
 	}
 
 	// Prepare message details
@@ -47,7 +47,7 @@ func (p *RegisterEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	for in, m := range messages {
 		ids[in] = m.Id
 	}
-	likesInfo, likesOk := h.redis.GetLikesInfo(e.By, ids...)
+	likesInfo, likesOk := h.store.GetLikesInfo(e.Group, e.By, ids...)
 	if !likesOk {
 		slog.Warn("Failed to fetch likes info")
 	}
@@ -89,12 +89,16 @@ func (p *RegisterEvent) Broadcast(e *Event, m *Message, h *Hub) {
 		Xid:                       e.Xid,
 		BoardMasking:              board.Mask,
 		BoardLock:                 board.Lock,
+		BoardPasscode:             board.PasscodeHash != "",
+		BoardInviteOnly:           board.InviteOnly,
+		BoardE2EE:                 board.E2EE,
 		Users:                     userDetails,
 		Messages:                  messagesDetails,
 		Comments:                  commentDetails,
 		Pins:                      pinnedMessageIds,
 		TimerExpiresInSeconds:     uint16(remainingTimeInSeconds), // This shouldn't error out since we will restrict expiry to max 1 hour (3600 seconds) future time, when saving "board.TimerExpiresAtUtc".
 		BoardExpiryTimeUtcSeconds: board.AutoDeleteAtUtc,
+		BoardMaxExpiryUtcSeconds:  maxBoardExpiry(board),
 		BoardCreatedAtUtcSeconds:  board.CreatedAtUtc,
 		ShowWelcomePopup:          (nowUnix - board.CreatedAtUtc) < 10, // Show welcome popup for new board (less than 10 seconds)
 	}
@@ -121,8 +125,10 @@ func (p *RegisterEvent) Broadcast(e *Event, m *Message, h *Hub) {
 		// // Ensure those fields/slices aren't mutated after being sent from here.
 		// response := regResponse
 		if client.id == e.By {
-			regResponse.IsBoardOwner = client.id == board.Owner
+			regResponse.IsBoardOwner = isVerifiedOwner(board, client.id, client.credential())
 			regResponse.IsBoardCreator = client.id == board.Creator
+			regResponse.IsBoardModerator = isVerifiedModerator(board.Id, joiningUser, client.moderatorCredential())
+			regResponse.UserToken = client.userToken
 			select {
 			case client.send <- regResponse:
 			default:
@@ -171,7 +177,7 @@ func (p *UserClosingEvent) Handle(_ *Event, h *Hub) {
 	}
 
 	// Execute
-	removed := h.redis.RemoveUserPresence(p.Group, p.By)
+	removed := h.store.RemoveUserPresence(p.Group, p.By)
 	if !removed {
 		return
 	}
@@ -187,8 +193,51 @@ func (p *UserClosingEvent) Handle(_ *Event, h *Hub) {
 	var ev = &Event{Type: "closing", Payload: json.RawMessage(jsonifiedEvent)}
 	// Bad hack end
 
-	h.redis.Publish(p.Group, &BroadcastArgs{Message: nil, Event: ev})
+	h.store.Publish(p.Group, &BroadcastArgs{Message: nil, Event: ev})
 }
+
+// RateLimitedEvent is raised by a client's read goroutine when it disconnects the client for too many rate limit strikes.
+// The board owner is told who was disconnected. See ClientLimits.
+type RateLimitedEvent struct {
+	Group string `json:"grp"`
+	Xid   string `json:"xid"`
+}
+
+func (p *RateLimitedEvent) Handle(e *Event, h *Hub) {
+	// Only raised by the server. A client sending it could blame someone else.
+	if e != nil {
+		slog.Warn("Ignoring ratelimited event sent by a client", "board", e.Group, "user", e.By)
+		return
+	}
+	if p.Group == "" || p.Xid == "" {
+		return
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("Error marshalling RateLimitedEvent", "err", err, "payload", p)
+		return
+	}
+	h.store.Publish(p.Group, &BroadcastArgs{Event: &Event{Type: "ratelimited", Group: p.Group, Payload: payload}})
+}
+
+func (p *RateLimitedEvent) Broadcast(_ *Event, _ *Message, h *Hub) {
+	b, ok := h.store.GetBoard(p.Group)
+	if !ok {
+		return
+	}
+	response := &RateLimitedResponse{Type: "ratelimited", Xid: p.Xid}
+	for client := range h.clients[p.Group] {
+		if !isVerifiedOwner(b, client.id, client.credential()) {
+			continue
+		}
+		select {
+		case client.send <- response:
+		default:
+			slog.Warn("Client send buffer full, dropping message", "user", client.id)
+		}
+	}
+}
+
 func (p *UserClosingEvent) Broadcast(_ *Event, m *Message, h *Hub) {
 	response := &UserClosingResponse{Type: "closing", Xid: p.Xid}
 
@@ -206,26 +255,42 @@ func (p *UserClosingEvent) Broadcast(_ *Event, m *Message, h *Hub) {
 }
 
 type SettingsEvent struct {
-	OwnerXid *string `json:"ownerXid,omitempty"`
-	Mask     *bool   `json:"mask,omitempty"`
-	Lock     *bool   `json:"lock,omitempty"`
+	OwnerXid   *string          `json:"ownerXid,omitempty"`
+	Mask       *bool            `json:"mask,omitempty"`
+	Lock       *bool            `json:"lock,omitempty"`
+	Passcode   *string          `json:"passcode,omitempty"` // Empty string removes the passcode
+	InviteOnly *bool            `json:"inviteOnly,omitempty"`
+	Moderator  *ModeratorChange `json:"moderator,omitempty"` // See moderator.go
+	// Xid of the new owner, set by Handle only when this event transferred ownership. Never taken from clients.
+	TransferredTo string `json:"transferredTo,omitempty"`
 }
 
 func (p *SettingsEvent) Handle(e *Event, h *Hub) {
+	p.TransferredTo = ""
+
 	// Validate
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when handling SettingsEvent", "board", e.Group)
 		return
 	}
 
-	if b.Owner != e.By {
-		isCreator := b.Creator == e.By
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		isCreator := isCreatorToken(b, e.By, e.Token)
 		if isCreator && p.OwnerXid != nil && *p.OwnerXid == e.Xid {
 			// Creator is reclaiming the board.
 			// Prevent them from changing any other settings like mask or lock.
 			p.Mask = nil
 			p.Lock = nil
+			p.Passcode = nil
+			p.InviteOnly = nil
+			p.Moderator = nil
+		} else if canModerate(h.store, b, e) {
+			// Moderators can only mask and lock.
+			p.OwnerXid = nil
+			p.Passcode = nil
+			p.InviteOnly = nil
+			p.Moderator = nil
 		} else {
 			slog.Warn("Non-owner trying to update board when handling SettingsEvent", "board", e.Group, "user", e.By)
 			return
@@ -236,7 +301,7 @@ func (p *SettingsEvent) Handle(e *Event, h *Hub) {
 
 	// Update Mask if present
 	if p.Mask != nil && b.Mask != *p.Mask {
-		if h.redis.UpdateMasking(b, *p.Mask) {
+		if h.store.UpdateMasking(b, *p.Mask) {
 			b.Mask = *p.Mask
 			updated = true
 		}
@@ -244,27 +309,74 @@ func (p *SettingsEvent) Handle(e *Event, h *Hub) {
 
 	// Update Lock if present
 	if p.Lock != nil && b.Lock != *p.Lock {
-		if h.redis.UpdateBoardLock(b, *p.Lock) {
+		if h.store.UpdateBoardLock(b, *p.Lock) {
 			b.Lock = *p.Lock
 			updated = true
 		}
 	}
 
+	// Update Passcode if present. Users already on the board stay connected.
+	if p.Passcode != nil {
+		if *p.Passcode != "" && !validPasscodeLength(*p.Passcode) {
+			slog.Warn("Invalid passcode length when handling SettingsEvent", "board", e.Group)
+		} else if *p.Passcode == "" && b.PasscodeHash == "" {
+			slog.Warn("Board has no passcode to remove", "board", e.Group)
+		} else {
+			hash := ""
+			var err error
+			if *p.Passcode != "" {
+				hash, err = hashPasscode(*p.Passcode)
+			}
+			if err != nil {
+				slog.Error("Error hashing board passcode", "err", err, "board", e.Group)
+			} else if h.store.UpdateBoardPasscode(b, hash) {
+				b.PasscodeHash = hash
+				updated = true
+			}
+		}
+	}
+
+	// Update InviteOnly if present. Users who already joined can still come back without an invite.
+	if p.InviteOnly != nil && b.InviteOnly != *p.InviteOnly {
+		if h.store.UpdateBoardInviteOnly(b, *p.InviteOnly) {
+			b.InviteOnly = *p.InviteOnly
+			updated = true
+		}
+	}
+
+	// Add or remove a moderator. The owner is already privileged and can't be one.
+	if p.Moderator != nil {
+		user, userOk := h.store.GetUserByXid(e.Group, p.Moderator.Xid)
+		if !userOk || user.Id == b.Owner || user.Moderator == p.Moderator.Moderator {
+			slog.Warn("Could not resolve moderator or moderator unchanged", "board", e.Group, "xid", p.Moderator.Xid)
+			p.Moderator = nil
+		} else if h.store.UpdateUserModerator(b.Id, user.Id, p.Moderator.Moderator) {
+			updated = true
+			slog.Info("Moderator", "board", b.Id, "user", user.Id, "moderator", p.Moderator.Moderator)
+		} else {
+			p.Moderator = nil
+		}
+	}
+
 	// TODO: if *p.OwnerXid == e.Xid, then its assigning self no need hit redis and lookup..maybe this works when "Creator" is reclaiming?
 
 	// TODO: How about saving ownerXid in Board to prevent all the below redis calls? - Can't rely too on xid in payload. Rethink
 	// Update Owner if present
 	if p.OwnerXid != nil {
-		user, userOk := h.redis.GetUserByXid(e.Group, *p.OwnerXid)
+		user, userOk := h.store.GetUserByXid(e.Group, *p.OwnerXid)
 		if userOk && user.Id != "" && user.Id != b.Owner {
-			if h.redis.UpdateBoardOwner(b, user.Id) {
+			if h.store.UpdateBoardOwner(b, user.Id) {
 				b.Owner = user.Id
+				p.TransferredTo = user.Xid
 				updated = true
 				slog.Info("Transfer", "board", b.Id, "to", b.Owner)
 			}
 		} else {
 			slog.Warn("Could not resolve new owner or owner unchanged", "ownerXid", *p.OwnerXid)
 		}
+		if p.TransferredTo == "" {
+			p.OwnerXid = nil
+		}
 	}
 
 	if !updated {
@@ -272,31 +384,59 @@ func (p *SettingsEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
+	// Publish only the moderator change that was applied. Never publish the passcode.
+	p.Passcode = nil
+	e.Payload, _ = json.Marshal(p)
+
 	// TODO: Can BroadcastArgs be expanded now to accomodate more fields? To help reduce redis calls?
 	// Publish to Redis (for broadcasting)
-	h.redis.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
 }
 func (p *SettingsEvent) Broadcast(e *Event, m *Message, h *Hub) {
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when broadcasting SettingsEvent", "board", e.Group)
 		return
 	}
 
 	// TODO: How about saving ownerXid in Board to prevent all the below redis calls? - Can't rely too on xid in payload. Rethink
-	owner, ok := h.redis.GetUser(e.Group, b.Owner)
+	owner, ok := h.store.GetUser(e.Group, b.Owner)
 
 	response := &SettingsResponse{
-		Type:     "set",
-		OwnerXid: owner.Xid,
-		Mask:     b.Mask,
-		Lock:     b.Lock,
+		Type:       "set",
+		OwnerXid:   owner.Xid,
+		Mask:       b.Mask,
+		Lock:       b.Lock,
+		Passcode:   b.PasscodeHash != "",
+		InviteOnly: b.InviteOnly,
+		Moderator:  p.Moderator,
 	}
 
 	clients := h.clients[e.Group]
 	for client := range clients {
+		res := response
+		// This event transferred ownership. Only the new owner gets a token for the current epoch; the previous owner's token is no longer valid.
+		// Creators reclaiming the board already hold their creator token.
+		if p.TransferredTo != "" && client.xid == p.TransferredTo && client.id == b.Owner && !isVerifiedOwner(b, client.id, client.credential()) {
+			withToken := *response
+			withToken.OwnerToken = ownerToken(b)
+			client.setCredential(withToken.OwnerToken)
+			res = &withToken
+		}
+		// A moderator was added or removed. Only the added user gets a token, a removed one loses theirs.
+		if p.Moderator != nil && client.xid == p.Moderator.Xid {
+			token := ""
+			if u, ok := h.store.GetUser(e.Group, client.id); ok && p.Moderator.Moderator && u.Moderator {
+				token = moderatorToken(e.Group, u)
+			}
+			client.setModeratorCredential(token)
+			withToken := *res
+			withToken.ModeratorToken = token
+			res = &withToken
+		}
+
 		select {
-		case client.send <- response:
+		case client.send <- res:
 		default:
 			client.hub.unregister <- client
 		}
@@ -328,17 +468,48 @@ func (p *MessageEvent) Handle(e *Event, h *Hub) {
 	}
 
 	// Validate board lock
-	if h.redis.IsBoardLocked(e.Group) {
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when saving message", "board", e.Group)
+		return
+	}
+	if b.Lock {
 		slog.Warn("Cannot save message in read-only board", "board", e.Group)
 		return
 	}
 
+	// Content pipeline. See content.go
+	content, err := filterContent(p.Content, b)
+	if err != nil {
+		slog.Warn("Message content rejected", "board", e.Group, "msgId", p.Id, "reason", rejectReason(err))
+		rejectMessage(e, p.Id, rejectReason(err), h)
+		return
+	}
+	p.Content = content
+
 	// "OfflineLikes" aren't mapped here. Watch out for gotchas.
 	// Its fine when creating new messages where its populated to default value of 0.
 	// When updating existing messages, handleUpdate() takes care of it by just updating existing message's content field. Other fields are never changed.
 	msg := p.ToMessage(e.By, e.Xid, e.Group)
 
-	existing, exists := h.redis.GetMessage(msg.Id)
+	existing, exists := h.store.GetMessage(msg.Group, msg.Id)
+
+	// Column rules. Edits and comments belong to the column of their card. See columns.go
+	colId := msg.Category
+	if exists {
+		colId = existing.Category
+	} else if !isMessage(msg) {
+		if parent, ok := h.store.GetMessage(msg.Group, msg.ParentId); ok {
+			colId = parent.Category
+		}
+	}
+	cols, _ := h.store.GetBoardColumns(b.Id)
+	if reason := columnRejectReason(h.store, b.Id, e.By, findColumn(cols, colId), !exists && isMessage(msg)); reason != "" {
+		slog.Warn("Message rejected by column rules", "board", e.Group, "msgId", p.Id, "col", colId, "reason", reason)
+		rejectMessage(e, p.Id, reason, h)
+		return
+	}
+
 	saved := false
 
 	if !exists {
@@ -360,7 +531,7 @@ func (p *MessageEvent) Handle(e *Event, h *Hub) {
 			existing.Content = msg.Content
 			msg = existing
 		}
-		h.redis.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
+		h.store.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
 	}
 }
 func handleNewMessageOrComment(msg *Message, h *Hub) bool {
@@ -369,12 +540,12 @@ func handleNewMessageOrComment(msg *Message, h *Hub) bool {
 		if msg.Anonymous {
 			msg.ByXid, msg.ByNickname = "", ""
 		}
-		return h.redis.Save(msg, AsNewMessage)
+		return h.store.Save(msg, AsNewMessage)
 	}
 
 	// New Comment
 	// Validate parent message exists
-	parent, exists := h.redis.GetMessage(msg.ParentId)
+	parent, exists := h.store.GetMessage(msg.Group, msg.ParentId)
 	if !exists {
 		slog.Warn("Parent message not found", "commentId", msg.Id, "parentId", msg.ParentId)
 		return false
@@ -387,7 +558,7 @@ func handleNewMessageOrComment(msg *Message, h *Hub) bool {
 	// Ensure category is same as that of parent
 	msg.Category = parent.Category
 
-	return h.redis.Save(msg, AsNewComment)
+	return h.store.Save(msg, AsNewComment)
 }
 func handleUpdate(existing, updated *Message, h *Hub) bool {
 	// Validation: user can only update own message, and only content is editable
@@ -397,13 +568,13 @@ func handleUpdate(existing, updated *Message, h *Hub) bool {
 	}
 
 	existing.Content = updated.Content
-	return h.redis.Save(existing)
+	return h.store.Save(existing)
 }
 
 func (p *MessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	// Transform to Outgoing format (static per broadcast)
 	base := m.NewMessageResponse()
-	base.Likes = h.redis.GetLikesCount(m.Id)
+	base.Likes = h.store.GetLikesCount(m.Group, m.Id)
 	// Snapshot clients for this group
 	clients := h.clients[m.Group]
 	clientCount := len(clients)
@@ -417,7 +588,7 @@ func (p *MessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
 
 	// Bulk Redis query: SMISMEMBER
 	// Order of returned results corresponds to order of "ids" passed
-	likedList := h.redis.HasLiked(m.Id, ids)
+	likedList := h.store.HasLiked(m.Group, m.Id, ids)
 	for i, client := range clientList {
 		// Copy the base response
 		res := base
@@ -432,6 +603,43 @@ func (p *MessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	}
 }
 
+// MessageRejectedEvent tells the author that their card or comment was rejected by the content pipeline.
+// Only published by the server, see rejectMessage.
+type MessageRejectedEvent struct {
+	By     string `json:"by"`
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// rejectMessage sends the rejection to the author's connections, on whichever instance they are.
+func rejectMessage(e *Event, msgId, reason string, h *Hub) {
+	payload, err := json.Marshal(&MessageRejectedEvent{By: e.By, Id: msgId, Reason: reason})
+	if err != nil {
+		slog.Error("Error marshalling MessageRejectedEvent", "err", err)
+		return
+	}
+	h.store.Publish(e.Group, &BroadcastArgs{Event: &Event{Type: "msgrej", Group: e.Group, Payload: payload}})
+}
+
+func (p *MessageRejectedEvent) Handle(e *Event, _ *Hub) {
+	// A client sending it could show errors to someone else
+	slog.Warn("Ignoring msgrej event sent by a client", "board", e.Group, "user", e.By)
+}
+
+func (p *MessageRejectedEvent) Broadcast(e *Event, _ *Message, h *Hub) {
+	response := &MessageRejectedResponse{Type: "msgrej", Id: p.Id, Reason: p.Reason}
+	for client := range h.clients[e.Group] {
+		if client.id != p.By {
+			continue
+		}
+		select {
+		case client.send <- response:
+		default:
+			slog.Warn("Client send buffer full, dropping message", "user", client.id)
+		}
+	}
+}
+
 type LikeMessageEvent struct {
 	OfflineLikes *int64 `json:"offline_likes,omitempty"`
 	MessageId    string `json:"msgId"`
@@ -440,12 +648,12 @@ type LikeMessageEvent struct {
 
 func (p *LikeMessageEvent) Handle(e *Event, h *Hub) {
 	// Validate
-	if h.redis.IsBoardLocked(e.Group) {
+	if h.store.IsBoardLocked(e.Group) {
 		slog.Warn("Cannot update likes in read-only board", "board", e.Group)
 		return
 	}
 
-	msg, exists := h.redis.GetMessage(p.MessageId) // Todo: Check if fetching a message is needed for a like. Can avoid extra calls. Also BroadcastArgs.Message may not be needed here if removed.
+	msg, exists := h.store.GetMessage(e.Group, p.MessageId) // Todo: Check if fetching a message is needed for a like. Can avoid extra calls. Also BroadcastArgs.Message may not be needed here if removed.
 	if !exists {
 		slog.Warn("Message doesn't exist in LikeMessageEvent handle", "msgId", p.MessageId)
 		return
@@ -453,7 +661,8 @@ func (p *LikeMessageEvent) Handle(e *Event, h *Hub) {
 
 	if p.OfflineLikes != nil {
 		// Only board owner can set offline likes
-		if !h.redis.IsBoardOwner(e.Group, e.By) {
+		b, ok := h.store.GetBoard(e.Group)
+		if !ok || !isVerifiedOwner(b, e.By, e.Token) {
 			slog.Warn("Non-owner trying to update offline likes", "board", e.Group, "user", e.By)
 			return
 		}
@@ -471,28 +680,28 @@ func (p *LikeMessageEvent) Handle(e *Event, h *Hub) {
 		}
 		// Update offline likes in Redis
 		msg.OfflineLikes = *p.OfflineLikes
-		if !h.redis.SaveOfflineLikes(msg.Id, msg.OfflineLikes) {
+		if !h.store.SaveOfflineLikes(msg.Group, msg.Id, msg.OfflineLikes) {
 			slog.Warn("Failed to save offline likes in Redis", "msgId", msg.Id)
 			return
 		}
 		// Publish to Redis (for broadcasting)
-		h.redis.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
+		h.store.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
 		return
 	}
 
 	// Execute
-	liked := h.redis.Like(p.MessageId, e.By, p.Like)
+	liked := h.store.Like(e.Group, p.MessageId, e.By, p.Like)
 	if !liked {
 		return
 	}
 	// Publish to Redis (for broadcasting)
 	if liked {
-		h.redis.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
+		h.store.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
 	}
 }
 func (p *LikeMessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	base := m.NewLikeResponse()
-	base.Likes = h.redis.GetLikesCount(m.Id)
+	base.Likes = h.store.GetLikesCount(m.Group, m.Id)
 	// Snapshot clients for this group
 	clients := h.clients[m.Group]
 	clientCount := len(clients)
@@ -506,7 +715,7 @@ func (p *LikeMessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
 
 	// Bulk Redis query: SMISMEMBER
 	// Order of returned results corresponds to order of "ids" passed
-	likedList := h.redis.HasLiked(m.Id, ids)
+	likedList := h.store.HasLiked(m.Group, m.Id, ids)
 	for i, client := range clientList {
 		// Copy the base response
 		resp := base
@@ -527,7 +736,7 @@ type PinMessageEvent struct {
 
 func (p *PinMessageEvent) Handle(e *Event, h *Hub) {
 	// Validate
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when handling PinMessageEvent", "board", e.Group)
 		return
@@ -538,12 +747,12 @@ func (p *PinMessageEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
-	if b.Owner != e.By {
-		slog.Warn("Non-owner cannot pin", "board", e.Group, "user", e.By)
+	if !canModerate(h.store, b, e) {
+		slog.Warn("Non-moderator cannot pin", "board", e.Group, "user", e.By)
 		return
 	}
 
-	msg, exists := h.redis.GetMessage(p.MessageId) // Todo: Check if fetching a message is needed for a pin. Can avoid extra calls. Also BroadcastArgs.Message may not be needed here if removed.
+	msg, exists := h.store.GetMessage(e.Group, p.MessageId) // Todo: Check if fetching a message is needed for a pin. Can avoid extra calls. Also BroadcastArgs.Message may not be needed here if removed.
 	if !exists {
 		slog.Warn("Message doesn't exist in PinMessageEvent handle", "msgId", p.MessageId)
 		return
@@ -555,13 +764,13 @@ func (p *PinMessageEvent) Handle(e *Event, h *Hub) {
 	}
 
 	// Execute
-	pinned := h.redis.UpdateMessagePin(b.Id, msg.Id, p.Pin)
+	pinned := h.store.UpdateMessagePin(b.Id, msg.Id, p.Pin)
 	if !pinned {
 		return
 	}
 	// Publish to Redis (for broadcasting)
 	if pinned {
-		h.redis.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
+		h.store.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e})
 	}
 }
 func (p *PinMessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
@@ -588,12 +797,12 @@ type DeleteMessageEvent struct {
 
 func (p *DeleteMessageEvent) Handle(e *Event, h *Hub) {
 	// Validate
-	if h.redis.IsBoardLocked(e.Group) {
+	if h.store.IsBoardLocked(e.Group) {
 		slog.Warn("Cannot delete data in read-only board", "board", e.Group)
 		return
 	}
 
-	msg, exists := h.redis.GetMessage(p.MessageId)
+	msg, exists := h.store.GetMessage(e.Group, p.MessageId)
 	if !exists {
 		slog.Warn("Message or Comment doesn't exist in DeleteMessageEvent handle", "msgId", p.MessageId)
 		return
@@ -604,8 +813,13 @@ func (p *DeleteMessageEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
-	isBoardOwner := h.redis.IsBoardOwner(e.Group, e.By)
-	canExecute := (msg.By == e.By || isBoardOwner)
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling DeleteMessageEvent", "board", e.Group)
+		return
+	}
+	// Board owner and moderators can delete any message.
+	canExecute := msg.By == e.By || canModerate(h.store, b, e)
 	if !canExecute {
 		slog.Warn("User not authorized to delete message/comment", "msgId", msg.Id, "user", e.By)
 		return
@@ -615,14 +829,14 @@ func (p *DeleteMessageEvent) Handle(e *Event, h *Hub) {
 	deleted := false
 	if isMessage(msg) {
 		commentIds := getValidComments(h, msg, p.CommentIds)
-		deleted = h.redis.DeleteMessage(msg.Group, msg.Id, commentIds)
+		deleted = h.store.DeleteMessage(msg.Group, msg.Id, commentIds)
 	} else {
-		deleted = h.redis.DeleteComment(msg.Group, msg.Id)
+		deleted = h.store.DeleteComment(msg.Group, msg.Id)
 	}
 
 	// Publish: to Redis (for broadcasting)
 	if deleted {
-		h.redis.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e}) // Todo: Similar to "Like", BroadcastArgs.Message may not be needed here.
+		h.store.Publish(msg.Group, &BroadcastArgs{Message: msg, Event: e}) // Todo: Similar to "Like", BroadcastArgs.Message may not be needed here.
 	}
 }
 func (p *DeleteMessageEvent) Broadcast(e *Event, m *Message, h *Hub) {
@@ -643,24 +857,24 @@ type DeleteAllEvent struct{}
 
 func (p *DeleteAllEvent) Handle(e *Event, h *Hub) {
 	// Update Redis
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when handling DeleteAllEvent", "board", e.Group)
 		return
 	}
 	// validate
-	if b.Owner != e.By {
+	if !isVerifiedOwner(b, e.By, e.Token) {
 		slog.Warn("Non-owner cannot execute DeleteAllEvent", "board", e.Group, "user", e.By)
 		return
 	}
 	// Delete
-	if deleted := h.redis.DeleteAll(b.Id); !deleted {
+	if deleted := h.store.DeleteAll(b.Id); !deleted {
 		slog.Warn("Could not delete all related data for board.", "board", e.Group)
 		return
 	}
 	// Publish to Redis (for broadcasting)
 	// *Message is nil as this is not a message related update.
-	h.redis.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
 }
 func (p *DeleteAllEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	// Transform to Outgoing format
@@ -685,7 +899,7 @@ type CategoryChangeEvent struct {
 
 func (p *CategoryChangeEvent) Handle(e *Event, h *Hub) {
 	// Validate
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when handling CategoryChangeEvent", "board", e.Group)
 		return
@@ -696,7 +910,7 @@ func (p *CategoryChangeEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
-	msg, exists := h.redis.GetMessage(p.MessageId)
+	msg, exists := h.store.GetMessage(e.Group, p.MessageId)
 	if !exists {
 		slog.Warn("Message doesn't exist in CategoryChangeEvent handle", "msgId", p.MessageId)
 		return
@@ -712,28 +926,39 @@ func (p *CategoryChangeEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
-	if !h.redis.IsBoardColumnActive(e.Group, p.NewCategory) {
+	if !h.store.IsBoardColumnActive(e.Group, p.NewCategory) {
 		slog.Warn("Cannot move message to invalid or inactive category", "board", e.Group, "msgId", p.MessageId, "newCategory", p.NewCategory)
 		return
 	}
 
 	// Validate before changing category; especially if the message being moved is of the user who created/owns it.
-	// Board owner can change category of any message.
-	isBoardOwner := b.Owner == e.By
-	canExecute := (msg.By == e.By || isBoardOwner)
+	// Board owner and moderators can change category of any message.
+	canExecute := msg.By == e.By || canModerate(h.store, b, e)
 	if !canExecute {
 		slog.Warn("User not authorized to change category message/comment", "msgId", p.MessageId, "user", e.By)
 		return
 	}
 
+	// Column rules. The card leaves one column and is added to the other one. See columns.go
+	cols, _ := h.store.GetBoardColumns(b.Id)
+	reason := columnRejectReason(h.store, b.Id, msg.By, findColumn(cols, msg.Category), false)
+	if reason == "" {
+		reason = columnRejectReason(h.store, b.Id, msg.By, findColumn(cols, p.NewCategory), true)
+	}
+	if reason != "" {
+		slog.Warn("Category change rejected by column rules", "board", e.Group, "msgId", p.MessageId, "newCategory", p.NewCategory, "reason", reason)
+		rejectMessage(e, p.MessageId, reason, h)
+		return
+	}
+
 	// Execute
 	commentIds := getValidComments(h, msg, p.CommentIds)
-	updated := h.redis.UpdateCategory(p.NewCategory, p.MessageId, commentIds)
+	updated := h.store.UpdateCategory(e.Group, p.NewCategory, p.MessageId, commentIds)
 
 	// Publish to Redis (for broadcasting)
 	// *Message is nil as all message details need not be broadcasted. Event details should be enough.
 	if updated {
-		h.redis.Publish(msg.Group, &BroadcastArgs{Message: nil, Event: e})
+		h.store.Publish(msg.Group, &BroadcastArgs{Message: nil, Event: e})
 	}
 }
 func (p *CategoryChangeEvent) Broadcast(e *Event, m *Message, h *Hub) {
@@ -763,14 +988,14 @@ func (p *TimerEvent) Handle(e *Event, h *Hub) {
 	// Both are mutually exclusive mostly
 
 	// Validate
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when handling TimerEvent", "board", e.Group)
 		return
 	}
 	// Validate for both
-	if b.Owner != e.By {
-		slog.Warn("Non-owner trying to handle TimerEvent", "board", e.Group, "user", e.By)
+	if !canModerate(h.store, b, e) {
+		slog.Warn("Non-moderator trying to handle TimerEvent", "board", e.Group, "user", e.By)
 		return
 	}
 
@@ -788,14 +1013,14 @@ func (p *TimerEvent) Handle(e *Event, h *Hub) {
 			return
 		}
 
-		if updated := h.redis.StopTimer(b); !updated {
+		if updated := h.store.StopTimer(b); !updated {
 			slog.Warn("Skipping. Unable to update timer during 'Stop' operation.")
 			return
 		}
 
 		// Publish to Redis (for broadcasting)
 		// *Message is nil as this is not a message related update. Timer is a UI gimmick. Find a better way.
-		h.redis.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+		h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
 		return
 	}
 
@@ -806,21 +1031,21 @@ func (p *TimerEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
-	// Duration check validation is only when the board owner is trying to set the timer. "Stop" is ignored here.
+	// Duration check validation is only when the board owner or a moderator is trying to set the timer. "Stop" is ignored here.
 	if p.ExpiryDurationInSeconds < 1 || p.ExpiryDurationInSeconds > 3600 {
 		slog.Warn("Invalid timer duration. Valid duration range is between 1 to 3600 seconds.")
 		return
 	}
 
 	// Execute Update timer
-	if updated := h.redis.UpdateTimer(b, p.ExpiryDurationInSeconds); !updated {
+	if updated := h.store.UpdateTimer(b, p.ExpiryDurationInSeconds); !updated {
 		slog.Warn("Skipping. Unable to update timer information.")
 		return
 	}
 
 	// Publish to Redis (for broadcasting)
 	// *Message is nil as this is not a message related update. Timer is a UI gimmick. Find a better way.
-	h.redis.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
 }
 func timerIsRunning(expiresAt, now int64) bool {
 	return expiresAt > 0 && expiresAt > now
@@ -828,7 +1053,7 @@ func timerIsRunning(expiresAt, now int64) bool {
 func (p *TimerEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	// Transform to Outgoing format
 	// redis.Getboard() is called twice in Handle and Broadcast. Let it be that way for now. Don't want to add another field in BroadcastArgs{}.
-	board, boardOk := h.redis.GetBoard(e.Group)
+	board, boardOk := h.store.GetBoard(e.Group)
 	if !boardOk {
 		slog.Warn("Cannot find board when broadcasting TimerEvent", "board", e.Group)
 		return
@@ -866,7 +1091,7 @@ type ColumnsChangeEvent struct {
 
 func (p *ColumnsChangeEvent) Handle(e *Event, h *Hub) {
 	// validate
-	if len(p.Columns) == 0 || len(p.Columns) > 5 {
+	if len(p.Columns) == 0 || len(p.Columns) > maxColumns() {
 		slog.Warn("Invalid columns data passed in ColumnsChangeEvent", "board", e.Group)
 		return
 	}
@@ -876,12 +1101,12 @@ func (p *ColumnsChangeEvent) Handle(e *Event, h *Hub) {
 			return
 		}
 		textLen := utf8.RuneCountInString(col.Text)
-		if len(col.Id) > MaxColumnIdSizeBytes || len(col.Color) > MaxColorSizeBytes || textLen > config.Data.MaxCategoryTextLength {
+		if len(col.Id) > MaxColumnIdSizeBytes || len(col.Color) > MaxColorSizeBytes || textLen > config.Data.MaxCategoryTextLength || !validColumnRules(col) {
 			slog.Warn("Columns info exceeds limit in ColumnsChangeEvent", "col", col.Id, "len", textLen, "len-color", len(col.Color))
 			return
 		}
 	}
-	b, ok := h.redis.GetBoard(e.Group)
+	b, ok := h.store.GetBoard(e.Group)
 	if !ok {
 		slog.Warn("Cannot find board when handling ColumnsChangeEvent", "board", e.Group)
 		return
@@ -890,17 +1115,17 @@ func (p *ColumnsChangeEvent) Handle(e *Event, h *Hub) {
 		slog.Warn("Cannot change columns in read-only board", "board", e.Group)
 		return
 	}
-	if b.Owner != e.By {
+	if !isVerifiedOwner(b, e.By, e.Token) {
 		slog.Warn("Non-owner cannot execute ColumnsChangeEvent", "board", e.Group, "user", e.By)
 		return
 	}
 	// Prevent deleting a column with associated messages
-	cols, ok := h.redis.GetBoardColumns(b.Id)
+	cols, ok := h.store.GetBoardColumns(b.Id)
 	if !ok {
 		slog.Warn("Cannot get columns when handling ColumnsChangeEvent", "board", e.Group)
 		return
 	}
-	hasMessages, err := h.redis.HasMessagesForColumnsMarkedForRemoval(b.Id, cols, p.Columns)
+	hasMessages, err := HasMessagesForColumnsMarkedForRemoval(h.store, b.Id, cols, p.Columns)
 	if err != nil {
 		slog.Error(err.Error(), "board", e.Group)
 		return
@@ -911,13 +1136,13 @@ func (p *ColumnsChangeEvent) Handle(e *Event, h *Hub) {
 	}
 
 	// execute
-	if done := h.redis.ResetBoardColumns(b, cols, p.Columns); !done {
+	if done := h.store.ResetBoardColumns(b, cols, p.Columns); !done {
 		return
 	}
 
 	// Publish to Redis (for broadcasting)
 	// *Message is nil as this is not a message related update.
-	h.redis.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
 }
 func (p *ColumnsChangeEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	// Transform to Outgoing format
@@ -934,6 +1159,272 @@ func (p *ColumnsChangeEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	}
 }
 
+// ColumnMoveEvent reorders the columns without resending their definitions.
+type ColumnMoveEvent struct {
+	Order []string `json:"order"` // Ids of all active columns, in the new order
+}
+
+func (p *ColumnMoveEvent) Handle(e *Event, h *Hub) {
+	if len(p.Order) == 0 || len(p.Order) > maxColumns() {
+		slog.Warn("Invalid column order passed in ColumnMoveEvent", "board", e.Group)
+		return
+	}
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling ColumnMoveEvent", "board", e.Group)
+		return
+	}
+	if b.Lock {
+		slog.Warn("Cannot move columns in read-only board", "board", e.Group)
+		return
+	}
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		slog.Warn("Non-owner cannot execute ColumnMoveEvent", "board", e.Group, "user", e.By)
+		return
+	}
+	// Fails when the order doesn't list exactly the active columns
+	if !h.store.MoveBoardColumns(b, p.Order) {
+		slog.Warn("Column order doesn't match the columns in ColumnMoveEvent", "board", e.Group)
+		return
+	}
+
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+}
+func (p *ColumnMoveEvent) Broadcast(e *Event, m *Message, h *Hub) {
+	response := &ColumnMoveResponse{Type: "colmove", Order: p.Order}
+
+	clients := h.clients[e.Group]
+	for client := range clients {
+		select {
+		case client.send <- response:
+		default:
+			client.hub.unregister <- client
+		}
+	}
+}
+
+type InviteCreateEvent struct {
+	ExpiresInSeconds int64 `json:"expiresInSeconds"` // 0 uses the default. Capped at the board's auto delete time.
+	MaxUses          int64 `json:"maxUses"`          // 0 is unlimited
+}
+
+func (p *InviteCreateEvent) Handle(e *Event, h *Hub) {
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling InviteCreateEvent", "board", e.Group)
+		return
+	}
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		slog.Warn("Non-owner cannot create invites", "board", e.Group, "user", e.By)
+		return
+	}
+
+	expiresIn := defaultInviteExpiry
+	if p.ExpiresInSeconds != 0 {
+		expiresIn = time.Duration(p.ExpiresInSeconds) * time.Second
+	}
+	if expiresIn < MinInviteExpiry {
+		slog.Warn("Invite expiry too short", "board", e.Group, "expiresInSeconds", p.ExpiresInSeconds)
+		return
+	}
+	if p.MaxUses < 0 || p.MaxUses > MaxInviteUses {
+		slog.Warn("Invalid invite max uses", "board", e.Group, "maxUses", p.MaxUses)
+		return
+	}
+
+	invites, ok := h.store.GetInvites(b.Id)
+	if !ok {
+		return
+	}
+	if len(invites) >= MaxInvitesPerBoard {
+		slog.Warn("Invite limit reached", "board", e.Group, "limit", MaxInvitesPerBoard)
+		return
+	}
+
+	if !h.store.CreateInvite(b, newInvite(b, expiresIn, p.MaxUses, time.Now())) {
+		return
+	}
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+}
+func (p *InviteCreateEvent) Broadcast(e *Event, m *Message, h *Hub) {
+	broadcastInvites(e, h)
+}
+
+type InviteRevokeEvent struct {
+	Id string `json:"id"`
+}
+
+func (p *InviteRevokeEvent) Handle(e *Event, h *Hub) {
+	if !validInviteId(p.Id) {
+		slog.Warn("Invalid invite id", "board", e.Group)
+		return
+	}
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling InviteRevokeEvent", "board", e.Group)
+		return
+	}
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		slog.Warn("Non-owner cannot revoke invites", "board", e.Group, "user", e.By)
+		return
+	}
+	if !h.store.RevokeInvite(b.Id, p.Id) {
+		slog.Warn("Invite not found or already expired", "board", e.Group)
+		return
+	}
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+}
+func (p *InviteRevokeEvent) Broadcast(e *Event, m *Message, h *Hub) {
+	broadcastInvites(e, h)
+}
+
+type InviteListEvent struct{}
+
+func (p *InviteListEvent) Handle(e *Event, h *Hub) {
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling InviteListEvent", "board", e.Group)
+		return
+	}
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		slog.Warn("Non-owner cannot list invites", "board", e.Group, "user", e.By)
+		return
+	}
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+}
+func (p *InviteListEvent) Broadcast(e *Event, m *Message, h *Hub) {
+	broadcastInvites(e, h)
+}
+
+// broadcastInvites sends the current invites to the owner's connections. Other users never see invite ids.
+func broadcastInvites(e *Event, h *Hub) {
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when broadcasting invites", "board", e.Group)
+		return
+	}
+	invites, ok := h.store.GetInvites(e.Group)
+	if !ok {
+		return
+	}
+	response := &InvitesResponse{Type: "invites", Invites: invites}
+
+	clients := h.clients[e.Group]
+	for client := range clients {
+		if !isVerifiedOwner(b, client.id, client.credential()) {
+			continue
+		}
+		select {
+		case client.send <- response:
+		default:
+			client.hub.unregister <- client
+		}
+	}
+}
+
+type KickEvent struct {
+	Xid         string `json:"xid"`
+	Ban         bool   `json:"ban"`
+	DeleteCards bool   `json:"deleteCards"`
+
+	// Set by Handle for broadcasting to all instances. Never trusted from the client.
+	UserId     string   `json:"userId,omitempty"`
+	MessageIds []string `json:"messageIds,omitempty"`
+}
+
+func (p *KickEvent) Handle(e *Event, h *Hub) {
+	p.UserId, p.MessageIds = "", nil
+
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling KickEvent", "board", e.Group)
+		return
+	}
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		slog.Warn("Non-owner cannot kick", "board", e.Group, "user", e.By)
+		return
+	}
+	user, ok := h.store.GetUserByXid(e.Group, p.Xid)
+	if !ok || user.Id == b.Owner {
+		slog.Warn("Could not resolve user to kick, or trying to kick the owner", "board", e.Group, "xid", p.Xid)
+		return
+	}
+
+	if p.Ban && !h.store.BanUser(b, user.Id) {
+		return
+	}
+	if p.DeleteCards {
+		p.MessageIds = deleteUserCards(h.store, b.Id, user.Id)
+	}
+	p.UserId = user.Id
+	e.Payload, _ = json.Marshal(p)
+	slog.Info("Kick", "board", b.Id, "user", user.Id, "ban", p.Ban)
+
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+}
+func (p *KickEvent) Broadcast(e *Event, m *Message, h *Hub) {
+	response := &KickResponse{Type: "kick", Xid: p.Xid, Ban: p.Ban, MessageIds: p.MessageIds}
+
+	clients := h.clients[e.Group]
+	for client := range clients {
+		// Close all connections of the kicked user on this instance. The hub unregisters them when their read goroutine stops.
+		if client.id == p.UserId {
+			select {
+			case client.send <- closeFrame{reason: CloseKicked}:
+			default:
+				client.conn.Close()
+			}
+			continue
+		}
+
+		select {
+		case client.send <- response:
+		default:
+			client.hub.unregister <- client
+		}
+	}
+}
+
+type BoardExpiryEvent struct {
+	ExpiresAtUtc int64 `json:"expiresAtUtc"` // Unix Timestamp Seconds. See expiry.go for the allowed range.
+}
+
+func (p *BoardExpiryEvent) Handle(e *Event, h *Hub) {
+	b, ok := h.store.GetBoard(e.Group)
+	if !ok {
+		slog.Warn("Cannot find board when handling BoardExpiryEvent", "board", e.Group)
+		return
+	}
+	if !isVerifiedOwner(b, e.By, e.Token) {
+		slog.Warn("Non-owner cannot change board expiry", "board", e.Group, "user", e.By)
+		return
+	}
+	if !validBoardExpiry(b, p.ExpiresAtUtc, time.Now()) {
+		slog.Warn("Invalid board expiry", "board", e.Group, "expiresAtUtc", p.ExpiresAtUtc, "max", maxBoardExpiry(b))
+		return
+	}
+	if p.ExpiresAtUtc == b.AutoDeleteAtUtc {
+		return
+	}
+	if !h.store.UpdateBoardExpiry(b, p.ExpiresAtUtc) {
+		return
+	}
+	slog.Info("Board expiry changed", "board", b.Id, "from", b.AutoDeleteAtUtc, "to", p.ExpiresAtUtc)
+	h.store.Publish(b.Id, &BroadcastArgs{Message: nil, Event: e})
+}
+func (p *BoardExpiryEvent) Broadcast(e *Event, m *Message, h *Hub) {
+	response := &BoardExpiryResponse{Type: "expiry", BoardExpiryTimeUtcSeconds: p.ExpiresAtUtc}
+
+	clients := h.clients[e.Group]
+	for client := range clients {
+		select {
+		case client.send <- response:
+		default:
+			client.hub.unregister <- client
+		}
+	}
+}
+
 type TypedEvent struct{}
 
 func (p *TypedEvent) Handle(e *Event, h *Hub) {
@@ -941,7 +1432,7 @@ func (p *TypedEvent) Handle(e *Event, h *Hub) {
 		return
 	}
 
-	h.redis.Publish(e.Group, &BroadcastArgs{Message: nil, Event: e})
+	h.store.Publish(e.Group, &BroadcastArgs{Message: nil, Event: e})
 }
 func (p *TypedEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	response := &TypedResponse{Type: "t", Xid: e.Xid}
@@ -961,6 +1452,22 @@ func (p *TypedEvent) Broadcast(e *Event, m *Message, h *Hub) {
 	}
 }
 
+// UserTokenAckEvent is sent by the client once it saved the user token from its RegisterResponse. See user_boards.go
+// The token is sent with every RegisterResponse until then, so a lost response doesn't lose it.
+type UserTokenAckEvent struct {
+	Token string `json:"token"`
+}
+
+func (p *UserTokenAckEvent) Handle(e *Event, h *Hub) {
+	// Only clients that got the token can stop it from being sent
+	if !isUserToken(e.By, p.Token) {
+		slog.Warn("Invalid user token acknowledged", "board", e.Group, "user", e.By)
+		return
+	}
+	h.store.AckUserToken(e.By)
+}
+func (p *UserTokenAckEvent) Broadcast(e *Event, m *Message, h *Hub) {}
+
 // Helper struct from Broadcasting
 type BroadcastArgs struct {
 	Event   *Event
@@ -978,7 +1485,7 @@ func getValidComments(h *Hub, msg *Message, ids []string) []string {
 		return nil
 	}
 
-	cmts, ok := h.redis.GetMessagesByIds(ids, msg.Group)
+	cmts, ok := h.store.GetMessagesByIds(ids, msg.Group)
 	if !ok {
 		return nil
 	}
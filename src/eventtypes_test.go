@@ -0,0 +1,586 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Handler tests run against the in-memory DocStore, so no Redis is needed.
+// The hub isn't started. Published payloads are read from the store and dispatched by hand, like Hub.run does.
+
+type testBoard struct {
+	t     *testing.T
+	hub   *Hub
+	store *DocStore
+	board *Board
+}
+
+func newTestBoard(t *testing.T) *testBoard {
+	t.Helper()
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+
+	b := &Board{Id: "board1", Name: "Retro", Owner: "owner", Creator: "owner", Status: InProgress}
+	cols := []*BoardColumn{
+		{Id: "col01", Text: "Good", Color: "green", Position: 1},
+		{Id: "col02", Text: "Bad", Color: "red", Position: 2},
+	}
+	store.CreateBoard(b, cols, 0)
+	store.Subscribe(b.Id)
+
+	return &testBoard{t: t, hub: newHub(store), store: store, board: b}
+}
+
+// join registers a local client for the user without going through a websocket.
+func (tb *testBoard) join(userId, nickname string) *Client {
+	tb.t.Helper()
+	user, ok := tb.store.EnsureUser(tb.board.Id, userId, nickname)
+	if !ok {
+		tb.t.Fatalf("EnsureUser(%s) failed", userId)
+	}
+	c := &Client{hub: tb.hub, send: make(chan any, 16), id: userId, xid: user.Xid, group: tb.board.Id}
+	if _, ok := tb.hub.clients[c.group]; !ok {
+		tb.hub.clients[c.group] = make(map[*Client]bool)
+	}
+	tb.hub.clients[c.group][c] = true
+	return c
+}
+
+// joinAsCreator joins with the token HandleCreateBoard hands out to the board creator.
+func (tb *testBoard) joinAsCreator(userId, nickname string) *Client {
+	tb.t.Helper()
+	c := tb.join(userId, nickname)
+	c.setCredential(creatorToken(tb.board.Id, userId))
+	return c
+}
+
+// send handles an event from the client and dispatches what was published, if anything.
+func (tb *testBoard) send(c *Client, typ string, payload any) {
+	tb.t.Helper()
+	e := &Event{Type: typ, Group: c.group, By: c.id, Xid: c.xid, Token: c.credential(), ModeratorToken: c.moderatorCredential(), Payload: marshalPayload(tb.t, payload)}
+	e.Handle(tb.hub)
+
+	select {
+	case p := <-tb.store.Broadcasts():
+		tb.hub.dispatch(p)
+	default:
+	}
+}
+
+// receive returns the next response sent to the client, or nil if there is none.
+func receive(c *Client) any {
+	select {
+	case r := <-c.send:
+		return r
+	default:
+		return nil
+	}
+}
+
+func TestMessageEvent_SavesAndBroadcasts(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01", ByNickname: "Alice"})
+
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m1"); !ok {
+		t.Fatal("message not saved")
+	}
+
+	res, ok := receive(alice).(MessageResponse)
+	if !ok || res.Id != "m1" || !res.Mine || res.Content != "hello" {
+		t.Errorf("author received %+v", res)
+	}
+	res, ok = receive(bob).(MessageResponse)
+	if !ok || res.Id != "m1" || res.Mine {
+		t.Errorf("other user received %+v", res)
+	}
+}
+
+func TestMessageEvent_RejectedOnLockedBoard(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	tb.store.UpdateBoardLock(tb.board, true)
+
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m1"); ok {
+		t.Error("message saved on a locked board")
+	}
+	if r := receive(alice); r != nil {
+		t.Errorf("unexpected broadcast %+v", r)
+	}
+}
+
+func TestMessageEvent_UpdateKeepsOriginalFields(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "edited", Category: "col02"})
+	// Someone else cannot overwrite the message
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "hijacked", Category: "col01"})
+
+	m, _ := tb.store.GetMessage(tb.board.Id, "m1")
+	if m.Content != "edited" || m.Category != "col01" || m.By != "alice" {
+		t.Errorf("message = %+v", m)
+	}
+}
+
+func TestLikeMessageEvent(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	receive(alice)
+	receive(bob)
+
+	tb.send(bob, "like", LikeMessageEvent{MessageId: "m1", Like: true})
+
+	res, ok := receive(bob).(LikeMessageResponse)
+	if !ok || res.Likes != 1 || !res.Liked {
+		t.Errorf("liker received %+v", res)
+	}
+	res, ok = receive(alice).(LikeMessageResponse)
+	if !ok || res.Likes != 1 || res.Liked {
+		t.Errorf("author received %+v", res)
+	}
+
+	// Liking again is a no-op and isn't broadcast
+	tb.send(bob, "like", LikeMessageEvent{MessageId: "m1", Like: true})
+	if r := receive(bob); r != nil {
+		t.Errorf("unexpected broadcast %+v", r)
+	}
+}
+
+func TestLikeMessageEvent_OfflineLikesOwnerOnly(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+
+	prevMax := config.OfflineLikes.MaxCount
+	config.OfflineLikes.MaxCount = 10
+	t.Cleanup(func() { config.OfflineLikes.MaxCount = prevMax })
+
+	n := int64(3)
+	tb.send(bob, "like", LikeMessageEvent{MessageId: "m1", OfflineLikes: &n})
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m1"); m.OfflineLikes != 0 {
+		t.Errorf("non-owner set offline likes to %d", m.OfflineLikes)
+	}
+
+	tb.send(owner, "like", LikeMessageEvent{MessageId: "m1", OfflineLikes: &n})
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m1"); m.OfflineLikes != 3 {
+		t.Errorf("offline likes = %d, want 3", m.OfflineLikes)
+	}
+
+	n = 11
+	tb.send(owner, "like", LikeMessageEvent{MessageId: "m1", OfflineLikes: &n})
+	if m, _ := tb.store.GetMessage(tb.board.Id, "m1"); m.OfflineLikes != 3 {
+		t.Errorf("offline likes above max accepted: %d", m.OfflineLikes)
+	}
+}
+
+func TestPinMessageEvent_OwnerOnly(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	receive(owner)
+	receive(bob)
+
+	tb.send(bob, "pin", PinMessageEvent{MessageId: "m1", Pin: true})
+	if r := receive(bob); r != nil {
+		t.Errorf("non-owner pin was broadcast: %+v", r)
+	}
+
+	tb.send(owner, "pin", PinMessageEvent{MessageId: "m1", Pin: true})
+	res, ok := receive(bob).(*PinMessageResponse)
+	if !ok || res.Id != "m1" || !res.Pin {
+		t.Errorf("received %+v", res)
+	}
+	data, _ := tb.store.GetBoardAggregatedData(tb.board.Id)
+	if len(data.PinnedMessageIds) != 1 {
+		t.Errorf("pins = %v", data.PinnedMessageIds)
+	}
+}
+
+func TestDeleteMessageEvent(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	bob := tb.join("bob", "Bob")
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	tb.send(bob, "msg", MessageEvent{Id: "c1", Content: "reply", Category: "col01", ParentId: "m1"})
+	for len(alice.send) > 0 {
+		receive(alice)
+	}
+	for len(bob.send) > 0 {
+		receive(bob)
+	}
+
+	// Only the author or the owner can delete
+	tb.send(bob, "del", DeleteMessageEvent{MessageId: "m1", CommentIds: []string{"c1"}})
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m1"); !ok {
+		t.Fatal("message deleted by another user")
+	}
+
+	tb.send(alice, "del", DeleteMessageEvent{MessageId: "m1", CommentIds: []string{"c1"}})
+	for _, id := range []string{"m1", "c1"} {
+		if _, ok := tb.store.GetMessage(tb.board.Id, id); ok {
+			t.Errorf("%s not deleted", id)
+		}
+	}
+	res, ok := receive(bob).(DeleteMessageResponse)
+	if !ok || res.Id != "m1" {
+		t.Errorf("received %+v", res)
+	}
+}
+
+func TestDeleteAllEvent_OwnerOnly(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(bob, "delall", struct{}{})
+	if !tb.store.BoardExists(tb.board.Id) {
+		t.Fatal("board deleted by non-owner")
+	}
+
+	tb.send(owner, "delall", struct{}{})
+	if tb.store.BoardExists(tb.board.Id) {
+		t.Error("board not deleted")
+	}
+	if _, ok := receive(bob).(*DeleteAllResponse); !ok {
+		t.Error("delete all not broadcast")
+	}
+}
+
+func TestRegisterEvent_SendsBoardToJoiningUser(t *testing.T) {
+	tb := newTestBoard(t)
+	alice := tb.join("alice", "Alice")
+	owner := tb.joinAsCreator("owner", "Owner")
+	tb.send(alice, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	receive(alice)
+	receive(owner)
+
+	tb.send(owner, "reg", struct{}{})
+
+	reg, ok := receive(owner).(RegisterResponse)
+	if !ok {
+		t.Fatal("joining user did not receive RegisterResponse")
+	}
+	if !reg.IsBoardOwner || len(reg.BoardColumns) != 2 || len(reg.Messages) != 1 || reg.Messages[0].Mine {
+		t.Errorf("unexpected RegisterResponse %+v", reg)
+	}
+	join, ok := receive(alice).(UserJoiningResponse)
+	if !ok || join.Nickname != "Owner" {
+		t.Errorf("other user received %+v", join)
+	}
+}
+
+func TestInitTokenSecret(t *testing.T) {
+	prev := tokenSecret
+	t.Cleanup(func() { tokenSecret = prev })
+
+	if err := initTokenSecret("", true); err == nil {
+		t.Error("missing secret accepted with a shared store")
+	}
+	if err := initTokenSecret("", false); err != nil || tokenSecret != prev {
+		t.Errorf("missing secret without a shared store: err %v, secret changed %v", err, tokenSecret != prev)
+	}
+	if err := initTokenSecret("configured", true); err != nil || tokenSecret != "configured" {
+		t.Errorf("configured secret: err %v", err)
+	}
+}
+
+func TestOwnerEvents_RequireToken(t *testing.T) {
+	tb := newTestBoard(t)
+	// Knows the owner's user id, but not the token
+	impostor := tb.join("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	receive(impostor)
+	receive(bob)
+
+	impostor.setCredential("forged")
+	lock := true
+	tb.send(impostor, "pin", PinMessageEvent{MessageId: "m1", Pin: true})
+	tb.send(impostor, "set", SettingsEvent{Lock: &lock})
+	tb.send(impostor, "timer", TimerEvent{ExpiryDurationInSeconds: 60})
+	tb.send(impostor, "delall", struct{}{})
+
+	if r := receive(bob); r != nil {
+		t.Errorf("privileged event without token was broadcast: %+v", r)
+	}
+	b, _ := tb.store.GetBoard(tb.board.Id)
+	if b.Lock || b.TimerExpiresAtUtc != 0 || !tb.store.BoardExists(tb.board.Id) {
+		t.Errorf("board changed by impostor: %+v", b)
+	}
+}
+
+func TestSettingsEvent_TransferRotatesToken(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	carol := tb.join("carol", "Carol")
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	receive(owner)
+	receive(bob)
+	receive(carol)
+
+	tb.send(owner, "set", SettingsEvent{OwnerXid: &bob.xid})
+
+	res, ok := receive(bob).(*SettingsResponse)
+	if !ok || res.OwnerXid != bob.xid || res.OwnerToken == "" || bob.credential() != res.OwnerToken {
+		t.Fatalf("new owner received %+v", res)
+	}
+	res, ok = receive(carol).(*SettingsResponse)
+	if !ok || res.OwnerToken != "" {
+		t.Errorf("token leaked to another user: %+v", res)
+	}
+	receive(owner)
+
+	// The new owner can pin
+	tb.send(bob, "pin", PinMessageEvent{MessageId: "m1", Pin: true})
+	if _, ok := receive(carol).(*PinMessageResponse); !ok {
+		t.Error("new owner could not pin")
+	}
+	receive(owner)
+	receive(bob)
+
+	// Bob hands over to Carol. Bob's token is no longer valid, even if he gets the board back by id.
+	bobToken := bob.credential()
+	tb.send(bob, "set", SettingsEvent{OwnerXid: &carol.xid})
+	for _, c := range []*Client{owner, bob, carol} {
+		receive(c)
+	}
+	b, _ := tb.store.GetBoard(tb.board.Id)
+	b.Owner = "bob"
+	if isVerifiedOwner(b, "bob", bobToken) {
+		t.Error("token of a previous owner still valid")
+	}
+
+	// The creator can always reclaim
+	tb.send(owner, "set", SettingsEvent{OwnerXid: &owner.xid})
+	if b, _ := tb.store.GetBoard(tb.board.Id); b.Owner != "owner" {
+		t.Errorf("creator could not reclaim, owner is %s", b.Owner)
+	}
+}
+
+func TestSettingsEvent_TokenOnlyOnTransfer(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(owner, "set", SettingsEvent{OwnerXid: &bob.xid})
+	for _, c := range []*Client{owner, bob} {
+		receive(c)
+	}
+
+	// Someone else connected with Bob's id, without his token
+	impostor := tb.join("bob", "Bob")
+	lock := true
+	tb.send(bob, "set", SettingsEvent{OwnerXid: &bob.xid, Lock: &lock})
+	if res, ok := receive(impostor).(*SettingsResponse); !ok || res.OwnerToken != "" || impostor.credential() != "" {
+		t.Errorf("owner token sent without a transfer: %+v", res)
+	}
+
+	// Clients can't claim a transfer
+	receive(bob)
+	receive(owner)
+	unlock := false
+	tb.send(bob, "set", SettingsEvent{Lock: &unlock, TransferredTo: bob.xid})
+	if res, ok := receive(impostor).(*SettingsResponse); !ok || res.OwnerToken != "" || impostor.credential() != "" {
+		t.Errorf("owner token sent for a transfer claimed by the client: %+v", res)
+	}
+}
+
+func TestSettingsEvent_ModeratorToken(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	tb.send(owner, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	tb.send(owner, "set", SettingsEvent{Moderator: &ModeratorChange{Xid: bob.xid, Moderator: true}})
+	receive(owner)
+	receive(owner)
+	receive(bob)
+	bobToken := bob.moderatorCredential()
+
+	// Knowing Bob's id isn't enough
+	impostor := tb.join("bob", "Bob")
+	tb.send(impostor, "pin", PinMessageEvent{MessageId: "m1", Pin: true})
+	if r := receive(owner); r != nil {
+		t.Errorf("moderator event without token was broadcast: %+v", r)
+	}
+	impostor.setModeratorCredential("forged")
+	tb.send(impostor, "del", DeleteMessageEvent{MessageId: "m1"})
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m1"); !ok {
+		t.Error("card deleted with a forged moderator token")
+	}
+
+	// Removing Bob clears and invalidates his token, adding him again issues a new one
+	tb.send(owner, "set", SettingsEvent{Moderator: &ModeratorChange{Xid: bob.xid, Moderator: false}})
+	if bob.moderatorCredential() != "" {
+		t.Error("removed moderator kept the token on the connection")
+	}
+	tb.send(owner, "set", SettingsEvent{Moderator: &ModeratorChange{Xid: bob.xid, Moderator: true}})
+	u, _ := tb.store.GetUser(tb.board.Id, "bob")
+	if isVerifiedModerator(tb.board.Id, u, bobToken) {
+		t.Error("token of a removed moderator still valid")
+	}
+	if !isVerifiedModerator(tb.board.Id, u, bob.moderatorCredential()) {
+		t.Error("moderator added again has no valid token")
+	}
+}
+
+func TestSettingsEvent_Passcode(t *testing.T) {
+	cheapPasscodes(t)
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+
+	passcode := "s3cret"
+	tb.send(bob, "set", SettingsEvent{Passcode: &passcode})
+	if b, _ := tb.store.GetBoard(tb.board.Id); b.PasscodeHash != "" {
+		t.Fatal("non-owner set a passcode")
+	}
+
+	tb.send(owner, "set", SettingsEvent{Passcode: &passcode})
+	b, _ := tb.store.GetBoard(tb.board.Id)
+	if !verifyPasscode(b.PasscodeHash, passcode) {
+		t.Fatalf("passcode not saved, hash %q", b.PasscodeHash)
+	}
+	res, ok := receive(bob).(*SettingsResponse)
+	if !ok || !res.Passcode {
+		t.Errorf("received %+v", res)
+	}
+	receive(owner)
+
+	empty := ""
+	tb.send(owner, "set", SettingsEvent{Passcode: &empty})
+	if b, _ := tb.store.GetBoard(tb.board.Id); b.PasscodeHash != "" {
+		t.Error("passcode not removed")
+	}
+	if res, ok := receive(bob).(*SettingsResponse); !ok || res.Passcode {
+		t.Errorf("received %+v", res)
+	}
+}
+
+func TestInviteEvents_OwnerOnly(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+
+	tb.send(bob, "invcreate", InviteCreateEvent{ExpiresInSeconds: 3600})
+	if invites, _ := tb.store.GetInvites(tb.board.Id); len(invites) != 0 {
+		t.Fatalf("non-owner created an invite: %+v", invites)
+	}
+
+	tb.send(owner, "invcreate", InviteCreateEvent{ExpiresInSeconds: 3600, MaxUses: 5})
+	res, ok := receive(owner).(*InvitesResponse)
+	if !ok || len(res.Invites) != 1 || res.Invites[0].MaxUses != 5 {
+		t.Fatalf("owner received %+v", res)
+	}
+	if r := receive(bob); r != nil {
+		t.Errorf("invites sent to non-owner: %+v", r)
+	}
+
+	// Expiry can't outlive the board
+	tb.send(owner, "invcreate", InviteCreateEvent{ExpiresInSeconds: 30 * 24 * 3600})
+	res, _ = receive(owner).(*InvitesResponse)
+	b, _ := tb.store.GetBoard(tb.board.Id)
+	if res == nil || len(res.Invites) != 2 || res.Invites[1].ExpiresAtUtc != b.AutoDeleteAtUtc {
+		t.Errorf("owner received %+v", res)
+	}
+
+	for _, invalid := range []InviteCreateEvent{{ExpiresInSeconds: 10}, {MaxUses: -1}, {MaxUses: MaxInviteUses + 1}} {
+		tb.send(owner, "invcreate", invalid)
+		if r := receive(owner); r != nil {
+			t.Errorf("invalid invite %+v created: %+v", invalid, r)
+		}
+	}
+
+	id := res.Invites[0].Id
+	tb.send(bob, "invrevoke", InviteRevokeEvent{Id: id})
+	if invites, _ := tb.store.GetInvites(tb.board.Id); len(invites) != 2 {
+		t.Error("non-owner revoked an invite")
+	}
+	tb.send(owner, "invrevoke", InviteRevokeEvent{Id: id})
+	if res, ok := receive(owner).(*InvitesResponse); !ok || len(res.Invites) != 1 || res.Invites[0].Id == id {
+		t.Errorf("owner received %+v", res)
+	}
+
+	tb.send(owner, "invlist", struct{}{})
+	if res, ok := receive(owner).(*InvitesResponse); !ok || len(res.Invites) != 1 {
+		t.Errorf("owner received %+v", res)
+	}
+	tb.send(bob, "invlist", struct{}{})
+	if r := receive(bob); r != nil {
+		t.Errorf("invites listed to non-owner: %+v", r)
+	}
+}
+
+func TestSettingsEvent_Moderators(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	carol := tb.join("carol", "Carol")
+	tb.send(carol, "msg", MessageEvent{Id: "m1", Content: "hello", Category: "col01"})
+	for _, c := range []*Client{owner, bob, carol} {
+		receive(c)
+	}
+
+	// Only the owner can add moderators
+	tb.send(carol, "set", SettingsEvent{Moderator: &ModeratorChange{Xid: bob.xid, Moderator: true}})
+	if u, _ := tb.store.GetUser(tb.board.Id, "bob"); u.Moderator {
+		t.Fatal("non-owner added a moderator")
+	}
+	tb.send(owner, "set", SettingsEvent{Moderator: &ModeratorChange{Xid: bob.xid, Moderator: true}})
+	res, ok := receive(carol).(*SettingsResponse)
+	if !ok || res.Moderator == nil || res.Moderator.Xid != bob.xid || !res.Moderator.Moderator || res.ModeratorToken != "" {
+		t.Fatalf("received %+v", res)
+	}
+	receive(owner)
+	res, ok = receive(bob).(*SettingsResponse)
+	if !ok || res.ModeratorToken == "" || bob.moderatorCredential() != res.ModeratorToken {
+		t.Fatalf("new moderator received %+v", res)
+	}
+
+	// Moderators can pin, run the timer, lock and delete other users' cards
+	tb.send(bob, "pin", PinMessageEvent{MessageId: "m1", Pin: true})
+	if _, ok := receive(carol).(*PinMessageResponse); !ok {
+		t.Error("moderator could not pin")
+	}
+	tb.send(bob, "timer", TimerEvent{ExpiryDurationInSeconds: 60})
+	if _, ok := receive(carol).(*TimerResponse); !ok {
+		t.Error("moderator could not start the timer")
+	}
+	lock := true
+	tb.send(bob, "set", SettingsEvent{Lock: &lock})
+	if b, _ := tb.store.GetBoard(tb.board.Id); !b.Lock {
+		t.Error("moderator could not lock")
+	}
+	lock = false
+	tb.send(bob, "set", SettingsEvent{Lock: &lock})
+	tb.send(bob, "del", DeleteMessageEvent{MessageId: "m1"})
+	if _, ok := tb.store.GetMessage(tb.board.Id, "m1"); ok {
+		t.Error("moderator could not delete a card")
+	}
+
+	// ...but can't transfer ownership, manage moderators or delete all
+	tb.send(bob, "set", SettingsEvent{OwnerXid: &bob.xid, Moderator: &ModeratorChange{Xid: carol.xid, Moderator: true}})
+	tb.send(bob, "delall", struct{}{})
+	b, _ := tb.store.GetBoard(tb.board.Id)
+	if u, _ := tb.store.GetUser(tb.board.Id, "carol"); b.Owner != "owner" || u.Moderator || !tb.store.BoardExists(tb.board.Id) {
+		t.Errorf("moderator used owner privileges, board %+v", b)
+	}
+
+	// Removed moderators lose their privileges
+	tb.send(owner, "set", SettingsEvent{Moderator: &ModeratorChange{Xid: bob.xid, Moderator: false}})
+	tb.send(bob, "timer", TimerEvent{Stop: true})
+	if b, _ := tb.store.GetBoard(tb.board.Id); !timerIsRunning(b.TimerExpiresAtUtc, time.Now().UTC().Unix()) {
+		t.Error("removed moderator stopped the timer")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Board expiry.
+// Boards are deleted at Board.AutoDeleteAtUtc, auto_delete_duration after creation. The owner can move it with BoardExpiryEvent,
+// to no earlier than MinBoardExpiry from now, and no later than max_auto_delete_duration in [data] of config.toml after creation.
+// Without max_auto_delete_duration, boards can only be shortened.
+// The store moves the expiry of all data of the board with it.
+//
+// The creator can also choose the board's retention instead of auto_delete_duration, with CreateBoardReq.Retention.
+// It is between min_auto_delete_duration and max_auto_delete_duration.
+
+const MinBoardExpiry = 10 * time.Minute
+
+// maxBoardExpiry is the latest expiry the owner can set. A board already set to expire later (e.g. with durable_retention)
+// can keep its expiry.
+func maxBoardExpiry(b *Board) int64 {
+	limit := b.AutoDeleteAtUtc
+	if config.Data.MaxAutoDeleteDuration == "" {
+		return limit
+	}
+	d, err := parseDuration(config.Data.MaxAutoDeleteDuration)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid max_auto_delete_duration in config. Boards can only be shortened.", "value", config.Data.MaxAutoDeleteDuration)
+		return limit
+	}
+	return max(limit, b.CreatedAtUtc+int64(d/time.Second))
+}
+
+func validBoardExpiry(b *Board, expiresAtUtc int64, now time.Time) bool {
+	return expiresAtUtc >= now.Add(MinBoardExpiry).Unix() && expiresAtUtc <= maxBoardExpiry(b)
+}
+
+// retentionRange is the range of retentions boards can be created with.
+// Defaults to MinBoardExpiry, and auto_delete_duration when max_auto_delete_duration isn't set.
+func retentionRange() (time.Duration, time.Duration) {
+	minRetention, maxRetention := MinBoardExpiry, time.Duration(0)
+	if d, err := parseDuration(config.Data.MinAutoDeleteDuration); err == nil && d > 0 {
+		minRetention = d
+	}
+	if d, err := parseDuration(config.Data.MaxAutoDeleteDuration); err == nil && d > 0 {
+		maxRetention = d
+	} else if d, err := parseDuration(config.Data.AutoDeleteDuration); err == nil {
+		maxRetention = d
+	}
+	return minRetention, max(minRetention, maxRetention)
+}
+
+// parseRetention parses CreateBoardReq.Retention. Empty is 0, the store's default.
+func parseRetention(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	minRetention, maxRetention := retentionRange()
+	if d < minRetention || d > maxRetention {
+		return 0, fmt.Errorf("retention must be between %s and %s", minRetention, maxRetention)
+	}
+	return d, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoardExpiryEvent(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Data.MaxAutoDeleteDuration = "3h"
+
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	b, _ := tb.store.GetBoard(tb.board.Id)
+	expiry := func() int64 {
+		stored, _ := tb.store.GetBoard(tb.board.Id)
+		return stored.AutoDeleteAtUtc
+	}
+
+	if max := maxBoardExpiry(b); max != b.CreatedAtUtc+3*3600 {
+		t.Errorf("max expiry %d, want creation + 3h", max)
+	}
+
+	// Only the owner
+	tb.send(bob, "expiry", BoardExpiryEvent{ExpiresAtUtc: b.AutoDeleteAtUtc + 3600})
+	if r := receive(bob); r != nil || expiry() != b.AutoDeleteAtUtc {
+		t.Fatalf("non-owner changed expiry: %+v", r)
+	}
+
+	// Out of range
+	now := time.Now().Unix()
+	for _, invalid := range []int64{0, now, now + 60, b.CreatedAtUtc + 3*3600 + 1} {
+		tb.send(owner, "expiry", BoardExpiryEvent{ExpiresAtUtc: invalid})
+		if r := receive(bob); r != nil || expiry() != b.AutoDeleteAtUtc {
+			t.Errorf("expiry %d accepted: %+v", invalid, r)
+		}
+	}
+
+	extended := b.CreatedAtUtc + 3*3600
+	tb.send(owner, "expiry", BoardExpiryEvent{ExpiresAtUtc: extended})
+	res, ok := receive(bob).(*BoardExpiryResponse)
+	if !ok || res.BoardExpiryTimeUtcSeconds != extended || expiry() != extended {
+		t.Fatalf("extending: received %+v, stored %d", res, expiry())
+	}
+	receive(owner)
+
+	shortened := now + 3600
+	tb.send(owner, "expiry", BoardExpiryEvent{ExpiresAtUtc: shortened})
+	if res, ok := receive(owner).(*BoardExpiryResponse); !ok || res.BoardExpiryTimeUtcSeconds != shortened || expiry() != shortened {
+		t.Fatalf("shortening: received %+v, stored %d", res, expiry())
+	}
+}
+
+func TestMaxBoardExpiry_WithoutConfig(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Data.MaxAutoDeleteDuration = ""
+
+	b := &Board{CreatedAtUtc: 1000, AutoDeleteAtUtc: 5000}
+	if max := maxBoardExpiry(b); max != 5000 {
+		t.Errorf("max expiry %d, boards should only be shortened", max)
+	}
+	// Boards already set to expire later keep their expiry
+	config.Data.MaxAutoDeleteDuration = "1h"
+	if max := maxBoardExpiry(b); max != 5000 {
+		t.Errorf("max expiry %d", max)
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Data.AutoDeleteDuration = "2d"
+	config.Data.MinAutoDeleteDuration = "1h"
+	config.Data.MaxAutoDeleteDuration = "7d"
+
+	if d, err := parseRetention(""); err != nil || d != 0 {
+		t.Errorf("empty retention = %s, %v, want the default", d, err)
+	}
+	if d, err := parseRetention("14h"); err != nil || d != 14*time.Hour {
+		t.Errorf("14h = %s, %v", d, err)
+	}
+	for _, invalid := range []string{"30m", "8d", "-1h", "1 week", "3600"} {
+		if _, err := parseRetention(invalid); err == nil {
+			t.Errorf("%q accepted", invalid)
+		}
+	}
+
+	// Without a configured range, boards can be deleted sooner than auto_delete_duration, but not later
+	config.Data.MinAutoDeleteDuration, config.Data.MaxAutoDeleteDuration = "", ""
+	if minRetention, maxRetention := retentionRange(); minRetention != MinBoardExpiry || maxRetention != 48*time.Hour {
+		t.Errorf("range = %s to %s", minRetention, maxRetention)
+	}
+}
@@ -1,17 +1,27 @@
 import { BoardColumn } from '../models/BoardColumn'
 
 const createBoardUrl = `/api/board/create`
+const createTeamUrl = `/api/team/create`
+const challengeUrl = `/api/challenge`
+const authMeUrl = `/api/auth/me`
+const logoutUrl = `/auth/logout`
 
 export interface CreateBoardRequest {
   name: string
   team: string
   owner: string
   columns: BoardColumn[]
-  cfTurnstileResponse: string
+  challengeResponse: string // Response of the bot protection widget. See components/ChallengeWidget.vue
+  passcode: string // Optional. Empty for boards anyone with the link can join.
+  maxContentLength?: number // Optional. Characters per card or comment, can only lower the instance limit.
+  e2ee?: boolean // Optional. Card content is encrypted in the browser. See utils/e2ee.ts
+  retention?: string // Optional. Time until the board is deleted, e.g. "1h" or "14d". Instance default when not set.
+  teamId?: string // Optional. Team space to create the board in.
 }
 
 export interface CreateBoardResponse {
   id: string
+  ownerToken: string
 }
 
 export const createBoard = async (payload: CreateBoardRequest): Promise<CreateBoardResponse> => {
@@ -38,3 +48,119 @@ export const createBoard = async (payload: CreateBoardRequest): Promise<CreateBo
     throw error // Re-throw the error to maintain the Promise rejection
   }
 }
+
+export interface TeamBoardSummary {
+  id: string
+  name: string
+  createdAtUtc: number
+  autoDeleteAtUtc: number // When the board is, or was, deleted
+  participants: number
+  active: boolean // The board still exists
+}
+
+export interface TeamResponse {
+  id: string
+  name: string
+  createdAtUtc: number
+  boards: TeamBoardSummary[] // Newest first
+}
+
+export const createTeam = async (name: string): Promise<string> => {
+  const response = await fetch(createTeamUrl, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ name }),
+  })
+  if (!response.ok) {
+    throw new Error('Network response was not ok')
+  }
+  const data: { id: string } = await response.json()
+  return data.id
+}
+
+// getTeam returns the team with its boards, past and active. Null when there is no such team.
+export const getTeam = async (teamId: string): Promise<TeamResponse | null> => {
+  const response = await fetch(`/api/team/${encodeURIComponent(teamId)}`, { cache: 'no-store' })
+  if (response.status === 404) return null
+  if (!response.ok) {
+    throw new Error('Network response was not ok')
+  }
+  return response.json()
+}
+
+export interface UserBoard {
+  id: string
+  name: string
+  team: string
+  teamId?: string
+  role: 'owner' | 'creator' | 'participant'
+  createdAtUtc: number
+  autoDeleteAtUtc: number
+}
+
+// getUserBoards lists the live boards the user created, owns or joined, newest first.
+// Anonymous users need the user token from their first board connection. Signed-in users need none.
+export const getUserBoards = async (userId: string, userToken: string): Promise<UserBoard[]> => {
+  const response = await fetch(`/api/user/${encodeURIComponent(userId)}/boards`, {
+    cache: 'no-store',
+    headers: userToken ? { 'X-User-Token': userToken } : {},
+  })
+  if (!response.ok) {
+    throw new Error('Network response was not ok')
+  }
+  const data: { boards: UserBoard[] } = await response.json()
+  return data.boards
+}
+
+export interface PoWChallengeResponse {
+  challenge: string
+  difficulty: number
+}
+
+// getPoWChallenge returns a proof-of-work challenge to solve, when the "pow" provider is configured.
+export const getPoWChallenge = async (): Promise<PoWChallengeResponse> => {
+  const response = await fetch(challengeUrl, { cache: 'no-store' })
+  if (!response.ok) {
+    throw new Error('Network response was not ok')
+  }
+  return response.json()
+}
+
+export interface SignedInUser {
+  id: string
+  nickname: string
+}
+
+// Only fetched once per page load. Sign in and sign out reload the page.
+let signedInUser: Promise<SignedInUser | null> | undefined
+
+// getSignedInUser returns the OIDC identity of this browser, or null when not signed in.
+export const getSignedInUser = (): Promise<SignedInUser | null> => {
+  signedInUser ??= fetch(authMeUrl, { cache: 'no-store' })
+    .then(response => (response.ok ? (response.json() as Promise<SignedInUser>) : null))
+    .catch(error => {
+      console.error('Error:', error)
+      return null
+    })
+  return signedInUser
+}
+
+export const loginUrl = (next: string): string => `/auth/login?${new URLSearchParams({ next })}`
+
+// downloadArchive fetches the gzipped archive of an expired board. Needs the creator or owner token.
+export const downloadArchive = async (boardId: string, token: string): Promise<Blob> => {
+  const response = await fetch(`/board/${encodeURIComponent(boardId)}/archive`, {
+    cache: 'no-store',
+    headers: { 'X-Owner-Token': token },
+  })
+  if (!response.ok) {
+    throw new Error('Network response was not ok')
+  }
+  return response.blob()
+}
+
+export const logout = async (): Promise<void> => {
+  await fetch(logoutUrl, { method: 'POST' })
+}
@@ -1,4 +1,4 @@
-import { computed } from 'vue'
+import { computed, inject, ref } from 'vue'
 import {
   assertMessageContentValidation,
   calculateContentBudget,
@@ -8,6 +8,7 @@ import {
 } from '../utils'
 import { useI18n } from 'vue-i18n'
 import { CONTENT_EDITABLE_INVALID_DEBOUNCE_MS } from '../utils/appConfig'
+import { boardE2eeKey, encryptedContentBudget } from '../utils/e2ee'
 
 interface UseLimiterOptions {
   nickname: () => string
@@ -20,11 +21,14 @@ interface UseLimiterOptions {
 export function useContentEditableLimiter(opts: UseLimiterOptions) {
   const { nickname, category, anon, isComment = false, onInvalid } = opts
   const { t } = useI18n()
+  const isE2ee = inject(boardE2eeKey, ref(false))
 
   // Precomputed byte budget: Only re-calculates if props change, not when the user types
-  const contentByteBudget = computed(() =>
-    calculateContentBudget(nickname(), category(), anon(), isComment)
-  )
+  // On end-to-end encrypted boards, the encrypted content has to fit.
+  const contentByteBudget = computed(() => {
+    const budget = calculateContentBudget(nickname(), category(), anon(), isComment)
+    return isE2ee.value ? encryptedContentBudget(budget) : budget
+  })
 
   // Wait *ms after the user stops hitting the limit to fire the toast
   const debouncedEmitInvalid = debounce((msg: string) => {
@@ -1,6 +1,24 @@
 import { CategoryDefinition } from '../models/CategoryDefinition'
+import { MAX_COLUMNS } from '../utils/appConfig'
 
-export const defaultCategories: CategoryDefinition[] = [
+export const MAX_COLUMN_DESCRIPTION_LENGTH = 280 // MaxColumnDescriptionLength in columns.go
+
+// Colors a column can have. Their classes are safelisted in index.css
+export const columnColors = [
+  'green',
+  'red',
+  'yellow',
+  'fuchsia',
+  'orange',
+  'sky',
+  'violet',
+  'teal',
+  'pink',
+  'lime',
+]
+
+// Columns with a translated default name (dashboard.columns.<id>)
+const namedCategories: CategoryDefinition[] = [
   { id: 'col01', text: '', color: 'green', colorClass: 'text-green-500', enabled: true, pos: 1 },
   { id: 'col02', text: '', color: 'red', colorClass: 'text-red-500', enabled: true, pos: 2 },
   { id: 'col03', text: '', color: 'yellow', colorClass: 'text-yellow-500', enabled: true, pos: 3 },
@@ -14,3 +32,24 @@ export const defaultCategories: CategoryDefinition[] = [
   },
   { id: 'col05', text: '', color: 'orange', colorClass: 'text-orange-500', enabled: false, pos: 5 },
 ]
+
+export const namedCategoryIds = new Set(namedCategories.map(c => c.id))
+
+// Boards allowing more columns get numbered ones, "col06" and up
+const numberedCategories: CategoryDefinition[] = Array.from(
+  { length: Math.max(0, MAX_COLUMNS - namedCategories.length) },
+  (_, i) => {
+    const pos = namedCategories.length + i + 1
+    const color = columnColors[(pos - 1) % columnColors.length]
+    return {
+      id: `col${String(pos).padStart(2, '0')}`,
+      text: '',
+      color,
+      colorClass: `text-${color}-500`,
+      enabled: false,
+      pos,
+    }
+  },
+)
+
+export const defaultCategories: CategoryDefinition[] = [...namedCategories, ...numberedCategories]
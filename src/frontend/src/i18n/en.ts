@@ -15,6 +15,15 @@ export default {
     contentOverloadError: 'Content more than allowed limit.',
     contentStrippingError: 'Content more than allowed limit. Extra text is stripped from the end.',
     invalidColumnSelection: 'Please select column(s)',
+    tooManyColumns: 'Select at most {max} columns',
+    changeColumnColor: 'Change color',
+    columnRules: {
+      label: 'Column rules',
+      description: 'Description or prompt (optional)',
+      maxCards: 'Max cards per person (0 is unlimited)',
+      mask: 'Mask cards',
+      lock: 'Lock: no new cards, edits or moves',
+    },
     typing: '{name} is typing',
     share: {
       linkCopied: 'Link copied!',
@@ -32,6 +41,16 @@ export default {
     namePlaceholder: 'Type your name here!',
     nameRequired: 'Please enter your name',
     button: 'Join',
+    signIn: 'Sign in',
+    signOut: 'Sign out',
+    signedIn: 'Your name comes from your sign-in',
+    myBoards: 'My boards',
+    deletesAt: 'Deletes {date}',
+    roles: {
+      owner: 'Owner',
+      creator: 'Creator',
+      participant: 'Participant',
+    },
   },
   createBoard: {
     label: 'Create Board',
@@ -41,8 +60,40 @@ export default {
     button: 'Create',
     buttonProgress: 'Creating..',
     captchaInfo: 'Please complete the CAPTCHA to continue',
+    challengeSolving: 'Checking your browser...',
+    challengeSolved: 'Browser check complete',
     boardCreationError: 'Error when creating board',
     columns: 'Columns',
+    passcodePlaceholder: 'Passcode to join (optional)',
+    maxContentLengthPlaceholder: 'Max characters per card (optional)',
+    retentionDefault: 'Delete after the default time',
+    retentionHours: 'Delete after {n} hour | Delete after {n} hours',
+    retentionDays: 'Delete after {n} day | Delete after {n} days',
+    e2ee: 'End-to-end encrypt cards',
+    e2eeHint:
+      'Cards are encrypted in the browser. The key is part of the board link, keep it safe. Without it, cards can never be read.',
+    teamNotFound: 'Team space not found. The board will be created without it.',
+    createTeamSpace: 'Create a team space to keep your retros together',
+  },
+  team: {
+    label: 'Create Team Space',
+    hint:
+      'Keep the retros of your team together. Anyone with the team link sees its past boards and can create new ones.',
+    namePlaceholder: 'Type team name here!',
+    button: 'Create',
+    buttonProgress: 'Creating..',
+    creationError: 'Error when creating team space',
+    notFound:
+      'Team space not found. It may have been deleted after a long time without new boards.',
+    createBoard: 'New board',
+    copyLink: 'Copy team link',
+    linkCopied: 'Team link copied. Share it with your team!',
+    noBoards: 'No boards yet. Create the first one!',
+    createdAt: 'Created {date}',
+    deletedAt: 'Deleted {date}',
+    deletesAt: 'Deletes {date}',
+    participants: 'No participants | {n} participant | {n} participants',
+    active: 'Active',
   },
   dashboard: {
     timer: {
@@ -118,9 +169,11 @@ export default {
       col03: 'Action Items',
       col04: 'Appreciations',
       col05: 'Improvements',
+      numbered: 'Column {n}',
       cannotDisable: 'Cannot disable column(s) with cards',
       update: 'Update',
-      discardNewMessages: 'Your draft was discarded because the column was disabled.',
+      discardNewMessages: 'Your draft was discarded because the column was disabled or locked.',
+      lockedHint: 'This column is closed to new cards',
     },
     printFooter: 'Created with',
     offline: 'You seem to be offline.',
@@ -133,10 +186,59 @@ export default {
       maskOffLabel: 'Card Masking is OFF',
       ok: 'Got it!',
     },
+    expiry: {
+      tooltip: 'Change auto-delete date',
+      title: 'Auto-delete date',
+      text: 'Choose when this board and all its cards are deleted. The latest possible date is {date}.',
+      save: 'Save',
+    },
+    passcode: {
+      joinTitle: 'Passcode required',
+      joinText: 'This board is protected. Enter the passcode shared by the board owner.',
+      invalid: 'Incorrect passcode. Please try again.',
+      locked: 'Too many incorrect attempts. Please try again later.',
+      placeholder: 'Passcode',
+      join: 'Join',
+      tooltip: 'Set or remove the board passcode',
+      shortText: 'Passcode',
+      settingsTitle: 'Board passcode',
+      settingsText:
+        'New participants must enter the passcode to join. People already on the board stay connected.',
+      tooShort: 'Passcode must be at least {min} characters',
+      save: 'Save',
+      remove: 'Remove passcode',
+    },
+    invites: {
+      tooltip: 'Create and revoke invite links',
+      shortText: 'Invites',
+      title: 'Invite links',
+      text:
+        'Invite links let people join without the passcode. Each link expires, and can be limited to a number of people.',
+      inviteOnly: 'Only people with an invite link can join',
+      expiresIn: 'Expires in',
+      oneHour: '1 hour',
+      oneDay: '1 day',
+      oneWeek: '1 week',
+      maxUses: 'Max people (0 = no limit)',
+      create: 'Create link',
+      none: 'No active invite links',
+      uses: 'Used by {uses}',
+      usesOf: 'Used by {uses} of {max}',
+      expires: 'Expires {time}',
+      copy: 'Copy',
+      revoke: 'Revoke',
+      linkCopied: 'Invite link copied. Share it with the people you want to invite!',
+      joinTitle: 'Invite required',
+      required: 'This board is invite only. Ask the board owner for an invite link.',
+      invalid:
+        'This invite link has expired, was revoked or has already been used by the maximum number of people.',
+    },
     notFound: {
       title: 'Board not found',
       text: 'The board you are looking for was either auto-deleted, or manually deleted by its owner.',
       createNewBoard: 'Create new board',
+      downloadArchive: 'Download archive',
+      archiveFailed: 'Could not download the archive.',
       supportText: 'Support us with a star on GitHub! ⭐',
     },
     help: {
@@ -173,4 +275,47 @@ export default {
       shortText: 'Reclaim',
     },
   },
+  moderators: {
+    tooltip: 'Manage moderators',
+    shortText: 'Mods',
+    title: 'Moderators',
+    text: 'Moderators can run the timer, pin, move and delete cards, and mask or lock the board.',
+    badge: 'Moderator',
+    promotedNotification: 'You are now a moderator of this board.',
+    demotedNotification: 'You are no longer a moderator of this board.',
+  },
+  contentRejected: {
+    TOOLONG: 'Your card is too long for this board and was not saved.',
+    DENYWORD: 'Your card contains words not allowed on this board and was not saved.',
+    NOTENCRYPTED: 'Your card was not encrypted and was not saved. Reload the board.',
+    COLLOCKED: 'This column is locked. Cards can not be added, edited or moved.',
+    COLFULL: 'You have added as many cards as this column allows.',
+    default: 'Your card was not saved.',
+  },
+  e2ee: {
+    keyMissingTitle: 'Cards are encrypted',
+    keyMissingDescription:
+      'This board is end-to-end encrypted. Open it with the full link shared by the owner, including the part after "#", to read and add cards.',
+    keyMissing: 'Open the board with its full link to add cards.',
+    undecryptable: '🔒 Encrypted',
+  },
+  rateLimit: {
+    disconnected: 'You were disconnected for sending too many updates too quickly. Reload to join again.',
+    ownerNotification: '{name} was disconnected for sending too many updates too quickly.',
+  },
+  kick: {
+    button: 'Remove',
+    tooltip: 'Remove from the board',
+    title: 'Remove {name}?',
+    text: 'Their open tabs are disconnected. Without a ban they can join again.',
+    ban: "Ban, don't let them join again",
+    deleteCards: 'Delete their cards and comments',
+    cancel: 'Cancel',
+    confirm: 'Remove',
+    kickedNotification: '{name} was removed from the board.',
+    bannedNotification: '{name} was removed and banned from the board.',
+    removedTitle: 'Removed from the board',
+    kicked: 'The board owner removed you from this board.',
+    banned: 'The board owner removed you from this board. You can no longer join it.',
+  },
 }
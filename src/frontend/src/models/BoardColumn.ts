@@ -4,4 +4,8 @@ export interface BoardColumn {
   isDefault: boolean // Used to identify if Board creator entered custom value for "text". Useful during multi-lang translation.
   color: string
   pos: number
+  maxCards?: number // Cards per user. 0 is unlimited.
+  mask?: boolean // Masks the cards of this column, like the board's mask does for all
+  lock?: boolean // No new cards, edits or moves in or out
+  description?: string // Prompt shown under the column name
 }
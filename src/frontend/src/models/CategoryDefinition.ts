@@ -5,4 +5,9 @@ export interface CategoryDefinition {
   pos: number
   color: string
   colorClass: string
+  // Column rules. See BoardColumn
+  maxCards?: number
+  mask?: boolean
+  lock?: boolean
+  description?: string
 }
@@ -3,4 +3,5 @@ export interface OnlineUser {
   xid: string
   active: boolean
   isOwner: boolean
+  isModerator: boolean
 }
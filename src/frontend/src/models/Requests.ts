@@ -12,6 +12,14 @@ export interface SettingsEvent {
   ownerXid?: string
   mask?: boolean
   lock?: boolean
+  passcode?: string // Empty string removes the passcode
+  inviteOnly?: boolean
+  moderator?: ModeratorChange
+}
+
+export interface ModeratorChange {
+  xid: string
+  moderator: boolean
 }
 
 export interface SaveMessageEvent {
@@ -48,6 +56,13 @@ export interface CategoryChangeEvent {
   oldcat: string
 }
 
+// Only the board owner can kick
+export interface KickEvent {
+  xid: string
+  ban: boolean // Keeps the user out for the board's lifetime
+  deleteCards: boolean
+}
+
 export interface TimerEvent {
   expiryDurationInSeconds: number
   stop: boolean
@@ -57,8 +72,33 @@ export interface ColumnsChangeEvent {
   columns: BoardColumn[]
 }
 
+export interface ColumnMoveEvent {
+  order: string[] // Ids of all enabled columns
+}
+
 export type TypedEvent = Record<string, never>
 
+export interface InviteCreateEvent {
+  expiresInSeconds: number
+  maxUses: number // 0 is unlimited
+}
+
+export interface InviteRevokeEvent {
+  id: string
+}
+
+export type InviteListEvent = Record<string, never>
+
+// Sent once the user token from RegisterResponse is saved. Until then, every connection gets it again.
+export interface UserTokenAckEvent {
+  token: string
+}
+
+// Owner only. See expiry.go for the allowed range.
+export interface BoardExpiryEvent {
+  expiresAtUtc: number // Unix Timestamp Seconds
+}
+
 export interface RegisterResponse {
   typ: 'reg'
   boardName: string
@@ -68,8 +108,13 @@ export interface RegisterResponse {
   xid: string
   boardMasking: boolean
   boardLock: boolean
+  boardPasscode: boolean
+  boardInviteOnly: boolean
+  boardE2ee: boolean // Card content is end-to-end encrypted. See utils/e2ee.ts
   isBoardOwner: boolean
   isBoardCreator: boolean
+  isBoardModerator: boolean
+  userToken?: string // Until acknowledged with a UserTokenAckEvent. Needed to list "my boards"
   mine: boolean
   users: OnlineUser[]
   messages: MessageResponse[]
@@ -77,6 +122,7 @@ export interface RegisterResponse {
   pins: string[]
   timerExpiresInSeconds: number
   boardExpiryUtcSeconds: number // Unix Timestamp Seconds
+  boardMaxExpiryUtcSeconds: number // Latest expiry the owner can set. Unix Timestamp Seconds
   boardCreatedAtUtcSeconds: number // Unix Timestamp Seconds
   showWelcomePopup: boolean
 }
@@ -97,6 +143,11 @@ export interface SettingsResponse {
   ownerXid: string
   mask: boolean
   lock: boolean
+  passcode: boolean
+  inviteOnly: boolean
+  moderator?: ModeratorChange // Only sent when a moderator was added or removed
+  ownerToken?: string // Only sent to the new owner after a transfer
+  moderatorToken?: string // Only sent to a user who was made a moderator
 }
 
 export interface MessageResponse {
@@ -153,11 +204,55 @@ export interface ColumnsChangeResponse {
   columns: BoardColumn[]
 }
 
+export interface ColumnMoveResponse {
+  typ: 'colmove'
+  order: string[] // Column ids. Positions follow the order, starting from 1.
+}
+
 export interface TypedResponse {
   typ: 't'
   xid: string
 }
 
+export interface Invite {
+  id: string
+  createdAtUtc: number // Unix Timestamp Seconds
+  expiresAtUtc: number // Unix Timestamp Seconds
+  maxUses: number // 0 is unlimited
+  uses: number
+}
+
+// Only sent to the board owner
+export interface InvitesResponse {
+  typ: 'invites'
+  invites: Invite[]
+}
+
+export interface KickResponse {
+  typ: 'kick'
+  xid: string
+  ban: boolean
+  messageIds?: string[] // Deleted cards and comments
+}
+
+export interface BoardExpiryResponse {
+  typ: 'expiry'
+  boardExpiryUtcSeconds: number // Unix Timestamp Seconds
+}
+
+// Only sent to the author of the rejected card or comment
+export interface MessageRejectedResponse {
+  typ: 'msgrej'
+  id: string
+  reason: string // TOOLONG or DENYWORD
+}
+
+// Only sent to the board owner
+export interface RateLimitedResponse {
+  typ: 'ratelimited'
+  xid: string
+}
+
 export type SocketResponse =
   | RegisterResponse
   | SettingsResponse
@@ -171,7 +266,13 @@ export type SocketResponse =
   | UserClosingResponse
   | TimerResponse
   | ColumnsChangeResponse
+  | ColumnMoveResponse
   | TypedResponse
+  | InvitesResponse
+  | KickResponse
+  | RateLimitedResponse
+  | MessageRejectedResponse
+  | BoardExpiryResponse
 
 export function toSocketResponse(json: unknown): SocketResponse | null {
   const obj = json as Record<string, unknown>
@@ -201,8 +302,20 @@ export function toSocketResponse(json: unknown): SocketResponse | null {
         return obj as unknown as TimerResponse
       case 'colreset':
         return obj as unknown as ColumnsChangeResponse
+      case 'colmove':
+        return obj as unknown as ColumnMoveResponse
       case 't':
         return obj as unknown as TypedResponse
+      case 'invites':
+        return obj as unknown as InvitesResponse
+      case 'kick':
+        return obj as unknown as KickResponse
+      case 'ratelimited':
+        return obj as unknown as RateLimitedResponse
+      case 'msgrej':
+        return obj as unknown as MessageRejectedResponse
+      case 'expiry':
+        return obj as unknown as BoardExpiryResponse
       // const data: MaskResponse = json
       // return data
 
@@ -1,7 +1,31 @@
-import { createRouter, createWebHistory } from 'vue-router'
+import { RouteLocationNormalized, createRouter, createWebHistory } from 'vue-router'
 import Dashboard from './components/Dashboard.vue'
 import Join from './components/Join.vue'
 import CreateBoard from './components/CreateBoard.vue'
+import TeamSpace from './components/TeamSpace.vue'
+import { getSignedInUser, loginUrl } from './api'
+import { AUTH_ENABLED, AUTH_REQUIRED_TO_CREATE, AUTH_REQUIRED_TO_JOIN } from './utils/appConfig'
+
+// With OIDC sign-in, the signed-in identity replaces the random per-browser user id and nickname.
+// Returns false after sending the browser to the login page.
+const syncSignedInUser = async (to: RouteLocationNormalized, required: boolean) => {
+  if (!AUTH_ENABLED) return true
+  const me = await getSignedInUser()
+  if (me) {
+    localStorage.setItem('user', me.id)
+    localStorage.setItem('nickname', me.nickname)
+    return true
+  }
+  // Identity user ids are rejected without a session. Go back to an anonymous one.
+  if (localStorage.getItem('user')?.startsWith('oidc-')) {
+    localStorage.setItem('user', crypto.randomUUID())
+  }
+  if (required) {
+    window.location.assign(loginUrl(to.fullPath))
+    return false
+  }
+  return true
+}
 
 export default createRouter({
   history: createWebHistory(),
@@ -10,24 +34,33 @@ export default createRouter({
       path: '/',
       name: 'start',
       component: Join,
+      beforeEnter: to => syncSignedInUser(to, AUTH_REQUIRED_TO_CREATE),
     },
     {
       path: '/create',
       name: 'create',
       component: CreateBoard,
-      beforeEnter: to => {
+      beforeEnter: async to => {
+        if (!(await syncSignedInUser(to, AUTH_REQUIRED_TO_CREATE))) return false
         if (!localStorage.getItem('user') || !localStorage.getItem('nickname')) {
           return { path: '/', query: to.query }
         }
       },
     },
+    {
+      path: '/team/:team?',
+      name: 'team',
+      component: TeamSpace,
+    },
     {
       path: '/board/:board',
       name: 'dashboard',
       component: Dashboard,
-      beforeEnter: to => {
+      beforeEnter: async to => {
+        if (!(await syncSignedInUser(to, AUTH_REQUIRED_TO_JOIN))) return false
         if (!localStorage.getItem('user') || !localStorage.getItem('nickname')) {
-          return `/board/${to.params.board}/join`
+          // The hash keeps the key of end-to-end encrypted boards
+          return { path: `/board/${to.params.board}/join`, hash: to.hash }
         }
       },
     },
@@ -35,6 +68,7 @@ export default createRouter({
       path: '/board/:board/join',
       name: 'join',
       component: Join,
+      beforeEnter: to => syncSignedInUser(to, AUTH_REQUIRED_TO_JOIN),
     },
   ],
 })
@@ -1,25 +1,31 @@
-declare namespace Turnstile {
-  interface RenderParameters {
-    sitekey: string
-    callback?: (token: string) => void
-    'error-callback'?: () => void
-    'expired-callback'?: () => void
-    theme?: 'auto' | 'light' | 'dark'
-    size?: 'normal' | 'flexible' | 'compact'
-    language?: string
-  }
+// Widget API shared by Turnstile, hCaptcha and reCAPTCHA. See components/ChallengeWidget.vue
+type ChallengeWidgetId = string | number
+
+interface ChallengeRenderParameters {
+  sitekey: string
+  callback?: (token: string) => void
+  'error-callback'?: () => void
+  'expired-callback'?: () => void
+  theme?: 'auto' | 'light' | 'dark'
+  size?: 'normal' | 'flexible' | 'compact'
+  language?: string // Turnstile
+  hl?: string // hCaptcha
+}
 
-  function remove(widgetId: string): void
-  function reset(widgetId: string): void
-  function render(container: string | HTMLElement, params: RenderParameters): string
+interface ChallengeWidgetApi {
+  render(container: string | HTMLElement, params: ChallengeRenderParameters): ChallengeWidgetId
+  reset(widgetId: ChallengeWidgetId): void
+  remove?(widgetId: ChallengeWidgetId): void // reCAPTCHA has none
+  ready?(callback: () => void): void // reCAPTCHA only
 }
 
 // Runtime config injected by backend via /config.js
 declare interface AppConfig {
   version: string
-  turnstile: {
-    enabled: boolean
+  challenge: {
+    provider: 'none' | 'turnstile' | 'hcaptcha' | 'recaptcha' | 'pow'
     siteKey: string
+    scriptUrl: string
   }
   websocket: {
     maxMessageSizeBytes: number
@@ -27,6 +33,9 @@ declare interface AppConfig {
   data: {
     maxCategoryTextLength: number
     maxTextLength: number
+    minRetentionSeconds: number
+    maxRetentionSeconds: number
+    maxColumns: number
   }
   frontend: {
     contentEditableInvalidDebounceMs: number
@@ -41,9 +50,22 @@ declare interface AppConfig {
     panelEnabled: boolean
     maxCount: number
   }
+  auth: {
+    enabled: boolean
+    requireLoginToCreate: boolean
+    requireLoginToJoin: boolean
+  }
+  archive: {
+    enabled: boolean
+  }
+  teams: {
+    enabled: boolean
+  }
 }
 
 declare interface Window {
   APP_CONFIG?: AppConfig
-  turnstile: typeof Turnstile
+  turnstile?: ChallengeWidgetApi
+  hcaptcha?: ChallengeWidgetApi
+  grecaptcha?: ChallengeWidgetApi
 }
@@ -1,10 +1,14 @@
 export const appConfig = window.APP_CONFIG
 export const APP_VERSION = appConfig?.version ?? ''
-export const TURNSTILE_ENABLED = appConfig?.turnstile.enabled ?? false
-export const TURNSTILE_SITEKEY = appConfig?.turnstile.siteKey ?? ''
+export const CHALLENGE_PROVIDER = appConfig?.challenge.provider ?? 'none'
+export const CHALLENGE_SITEKEY = appConfig?.challenge.siteKey ?? ''
+export const CHALLENGE_SCRIPT_URL = appConfig?.challenge.scriptUrl ?? ''
 export const MAX_WEBSOCKET_MESSAGE_SIZE_BYTES = appConfig?.websocket.maxMessageSizeBytes ?? 1024
 export const MAX_CATEGORY_TEXT_LENGTH = appConfig?.data.maxCategoryTextLength ?? 80
 export const MAX_TEXT_LENGTH = appConfig?.data.maxTextLength ?? 80
+export const MIN_RETENTION_SECONDS = appConfig?.data.minRetentionSeconds ?? 0
+export const MAX_RETENTION_SECONDS = appConfig?.data.maxRetentionSeconds ?? 0
+export const MAX_COLUMNS = appConfig?.data.maxColumns ?? 5
 export const CONTENT_EDITABLE_INVALID_DEBOUNCE_MS =
   appConfig?.frontend.contentEditableInvalidDebounceMs ?? 500
 export const TYPING_ACTIVITY_ENABLED = appConfig?.typingActivity.enabled ?? false
@@ -14,3 +18,11 @@ export const TYPING_ACTIVITY_EMIT_THROTTLE_MS = appConfig?.typingActivity.emitTh
 export const TYPING_ACTIVITY_DISPLAY_TIMEOUT_MS = appConfig?.typingActivity.displayTimeoutMs ?? 2500
 export const OFFLINE_LIKES_PANEL_ENABLED = appConfig?.offlineLikes.panelEnabled ?? false
 export const OFFLINE_LIKES_MAX_COUNT = appConfig?.offlineLikes.maxCount ?? 50
+export const AUTH_ENABLED = appConfig?.auth.enabled ?? false
+export const AUTH_REQUIRED_TO_CREATE = appConfig?.auth.requireLoginToCreate ?? false
+export const AUTH_REQUIRED_TO_JOIN = appConfig?.auth.requireLoginToJoin ?? false
+export const ARCHIVE_ENABLED = appConfig?.archive.enabled ?? false
+export const TEAMS_ENABLED = appConfig?.teams.enabled ?? false
+// Mirrors MinPasscodeLength and MaxPasscodeLength in passcode.go
+export const MIN_PASSCODE_LENGTH = 4
+export const MAX_PASSCODE_LENGTH = 64
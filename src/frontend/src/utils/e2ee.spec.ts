@@ -0,0 +1,49 @@
+// @vitest-environment happy-dom
+import { describe, it, expect } from 'vitest'
+import {
+  boardKeyFromHash,
+  boardKeyHash,
+  decryptContent,
+  encryptContent,
+  encryptedContentBudget,
+  generateBoardKey,
+  importBoardKey,
+} from './e2ee'
+
+describe('End-to-end encryption utils', () => {
+  it('should round trip content', async () => {
+    const key = (await importBoardKey(generateBoardKey()))!
+    const blob = await encryptContent(key, 'Ship it 🚀', 'board1', 'm1')
+    expect(blob).not.toContain('Ship')
+    expect(await decryptContent(key, blob, 'board1', 'm1')).toBe('Ship it 🚀')
+  })
+
+  it('should not decrypt with another key or on another card', async () => {
+    const key = (await importBoardKey(generateBoardKey()))!
+    const other = (await importBoardKey(generateBoardKey()))!
+    const blob = await encryptContent(key, 'secret', 'board1', 'm1')
+    expect(await decryptContent(other, blob, 'board1', 'm1')).toBeNull()
+    expect(await decryptContent(key, blob, 'board1', 'm2')).toBeNull()
+    expect(await decryptContent(key, 'not encrypted', 'board1', 'm1')).toBeNull()
+  })
+
+  it('should reject invalid keys', async () => {
+    expect(await importBoardKey('')).toBeNull()
+    expect(await importBoardKey('c2hvcnQ')).toBeNull()
+  })
+
+  it('should carry the key in the fragment', () => {
+    const key = generateBoardKey()
+    expect(key).toMatch(/^[A-Za-z0-9\-_]{43}$/)
+    expect(boardKeyFromHash(boardKeyHash(key))).toBe(key)
+    expect(boardKeyHash('')).toBe('')
+    expect(boardKeyFromHash('')).toBe('')
+  })
+
+  it('should fit encrypted content in the budget', async () => {
+    const key = (await importBoardKey(generateBoardKey()))!
+    const budget = encryptedContentBudget(100)
+    const blob = await encryptContent(key, 'x'.repeat(budget), 'board1', 'm1')
+    expect(blob.length).toBeLessThanOrEqual(100)
+  })
+})
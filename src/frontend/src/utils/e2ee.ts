@@ -0,0 +1,110 @@
+import { InjectionKey, Ref } from 'vue'
+
+// End-to-end encrypted boards.
+// Card and comment content is encrypted in the browser with AES-GCM. The board key is only carried in the URL fragment
+// ("#key=..."), which browsers never send to the server. The server stores and relays an opaque blob:
+//   base64(nonce (12 bytes) + ciphertext + tag (16 bytes))
+// The board id and card id are authenticated with the content, so the server can't move content to another card.
+
+const KEY_BYTES = 32
+const NONCE_BYTES = 12
+const TAG_BYTES = 16
+
+// Provided by the dashboard to the card editors, which leave room for the encryption overhead.
+export const boardE2eeKey: InjectionKey<Ref<boolean>> = Symbol('boardE2ee')
+
+// Board keys are kept per board, like owner tokens, so the board opens without the full link later.
+const boardKeyStorageKey = (boardId: string): string => `boardKey:${boardId}`
+export const getBoardKey = (boardId: string): string =>
+  localStorage.getItem(boardKeyStorageKey(boardId)) || ''
+export const saveBoardKey = (boardId: string, key: string): void => {
+  localStorage.setItem(boardKeyStorageKey(boardId), key)
+}
+
+// boardKeyFromHash returns the key of a "#key=..." fragment, or ''.
+export const boardKeyFromHash = (hash: string): string =>
+  new URLSearchParams(hash.replace(/^#/, '')).get('key') || ''
+
+// boardKeyHash is the fragment to add to board links. Empty without a key.
+export const boardKeyHash = (key: string): string =>
+  key ? `#${new URLSearchParams({ key })}` : ''
+
+const toBase64 = (bytes: Uint8Array): string => {
+  let binary = ''
+  bytes.forEach(b => (binary += String.fromCharCode(b)))
+  return btoa(binary)
+}
+const fromBase64 = (encoded: string): Uint8Array =>
+  Uint8Array.from(atob(encoded), c => c.charCodeAt(0))
+
+const toBase64Url = (bytes: Uint8Array): string =>
+  toBase64(bytes).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+const fromBase64Url = (encoded: string): Uint8Array =>
+  fromBase64(encoded.replace(/-/g, '+').replace(/_/g, '/'))
+
+export const generateBoardKey = (): string =>
+  toBase64Url(crypto.getRandomValues(new Uint8Array(KEY_BYTES)))
+
+// importBoardKey returns null when the key isn't valid, e.g. a link that was cut short.
+export const importBoardKey = async (encoded: string): Promise<CryptoKey | null> => {
+  try {
+    const raw = fromBase64Url(encoded)
+    if (raw.length !== KEY_BYTES) return null
+    return await crypto.subtle.importKey('raw', raw, 'AES-GCM', false, ['encrypt', 'decrypt'])
+  } catch {
+    return null
+  }
+}
+
+const encoder = new TextEncoder()
+const decoder = new TextDecoder()
+
+const additionalData = (boardId: string, msgId: string): Uint8Array =>
+  encoder.encode(`${boardId}\u0000${msgId}`)
+
+export const encryptContent = async (
+  key: CryptoKey,
+  text: string,
+  boardId: string,
+  msgId: string
+): Promise<string> => {
+  const nonce = crypto.getRandomValues(new Uint8Array(NONCE_BYTES))
+  const ciphertext = await crypto.subtle.encrypt(
+    { name: 'AES-GCM', iv: nonce, additionalData: additionalData(boardId, msgId) },
+    key,
+    encoder.encode(text)
+  )
+  const blob = new Uint8Array(NONCE_BYTES + ciphertext.byteLength)
+  blob.set(nonce)
+  blob.set(new Uint8Array(ciphertext), NONCE_BYTES)
+  return toBase64(blob)
+}
+
+// decryptContent returns null when the content can't be decrypted, e.g. with the wrong key.
+export const decryptContent = async (
+  key: CryptoKey,
+  blob: string,
+  boardId: string,
+  msgId: string
+): Promise<string | null> => {
+  if (!blob) return ''
+  try {
+    const bytes = fromBase64(blob)
+    const plaintext = await crypto.subtle.decrypt(
+      {
+        name: 'AES-GCM',
+        iv: bytes.subarray(0, NONCE_BYTES),
+        additionalData: additionalData(boardId, msgId),
+      },
+      key,
+      bytes.subarray(NONCE_BYTES)
+    )
+    return decoder.decode(plaintext)
+  } catch {
+    return null
+  }
+}
+
+// encryptedContentBudget is the most text bytes that fit in maxBytes once encrypted and base64 encoded.
+export const encryptedContentBudget = (maxBytes: number): number =>
+  Math.max(0, Math.floor(maxBytes / 4) * 3 - NONCE_BYTES - TAG_BYTES)
@@ -1,5 +1,8 @@
+import type { ComposerTranslation } from 'vue-i18n'
 import { env } from '../env'
+import { namedCategoryIds } from '../constants/defaultCategories'
 import { BoardColumn } from '../models/BoardColumn'
+import { CategoryDefinition } from '../models/CategoryDefinition'
 import { EventRequest, SaveMessageEvent } from '../models/Requests'
 import { MAX_WEBSOCKET_MESSAGE_SIZE_BYTES } from './appConfig'
 
@@ -9,6 +12,41 @@ export const logMessage = (...args: unknown[]): void => {
   }
 }
 
+// Owner tokens are kept per board. The server only honours owner actions from connections that present one.
+const ownerTokenKey = (boardId: string): string => `ownerToken:${boardId}`
+export const getOwnerToken = (boardId: string): string => localStorage.getItem(ownerTokenKey(boardId)) || ''
+export const saveOwnerToken = (boardId: string, token: string): void => {
+  localStorage.setItem(ownerTokenKey(boardId), token)
+}
+
+// The user token lists the user's boards. It is issued only once per user id, see getUserBoards.
+const userTokenKey = (userId: string): string => `userToken:${userId}`
+export const getUserToken = (userId: string): string =>
+  localStorage.getItem(userTokenKey(userId)) || ''
+export const saveUserToken = (userId: string, token: string): void => {
+  localStorage.setItem(userTokenKey(userId), token)
+}
+
+// Moderator tokens are kept apart, so a creator who is made a moderator keeps the creator token.
+const moderatorTokenKey = (boardId: string): string => `moderatorToken:${boardId}`
+export const getModeratorToken = (boardId: string): string =>
+  localStorage.getItem(moderatorTokenKey(boardId)) || ''
+export const saveModeratorToken = (boardId: string, token: string): void => {
+  if (token) {
+    localStorage.setItem(moderatorTokenKey(boardId), token)
+  } else {
+    localStorage.removeItem(moderatorTokenKey(boardId))
+  }
+}
+
+// Invites from /board/{id}/join?invite=... are kept for the tab, so reconnects can send them again.
+const inviteKey = (boardId: string): string => `invite:${boardId}`
+export const getInvite = (boardId: string): string =>
+  sessionStorage.getItem(inviteKey(boardId)) || ''
+export const saveInvite = (boardId: string, invite: string): void => {
+  sessionStorage.setItem(inviteKey(boardId), invite)
+}
+
 // Show Unix timestamp as local date
 export const formatDate = (timestamp: number): string => {
   if (!timestamp) return ''
@@ -17,6 +55,18 @@ export const formatDate = (timestamp: number): string => {
   return date.toLocaleString()
 }
 
+// Values of <input type="datetime-local">, in local time
+const pad = (n: number): string => String(n).padStart(2, '0')
+export const toDateTimeLocal = (timestamp: number): string => {
+  const d = new Date(timestamp * 1000)
+  return (
+    `${d.getFullYear()}-${pad(d.getMonth() + 1)}-${pad(d.getDate())}` +
+    `T${pad(d.getHours())}:${pad(d.getMinutes())}`
+  )
+}
+export const fromDateTimeLocal = (value: string): number =>
+  Math.floor(new Date(value).getTime() / 1000)
+
 // export const getByteLength = (text: string) => new Blob([text]).size
 // export const getByteLength = (text: string): number => new TextEncoder().encode(text).length
 const encoder = new TextEncoder() // Cache the encoder. Check impact.
@@ -152,12 +202,54 @@ export const areBoardColumnsVisuallySame = (a: BoardColumn[], b: BoardColumn[]):
       col.id === other.id &&
       col.text === other.text &&
       col.isDefault === other.isDefault &&
-      col.color === other.color
+      col.color === other.color &&
+      sameColumnRules(col, other)
       // col.pos === other.pos
     )
   })
 }
 
+// Same columns with the same content, only in another order. Moving them is enough, see "colmove".
+export const areBoardColumnsReordered = (a: BoardColumn[], b: BoardColumn[]): boolean => {
+  if (a.length !== b.length) return false
+  const byId = new Map(b.map(col => [col.id, col]))
+  return a.every(col => {
+    const other = byId.get(col.id)
+    return (
+      other !== undefined &&
+      col.text === other.text &&
+      col.isDefault === other.isDefault &&
+      col.color === other.color &&
+      sameColumnRules(col, other)
+    )
+  })
+}
+
+// Column rules of an edited column, with the defaults filled in. See CategoryEditor.vue
+export const columnRules = (col: CategoryDefinition | BoardColumn) => ({
+  maxCards: col.maxCards ?? 0,
+  mask: col.mask ?? false,
+  lock: col.lock ?? false,
+  description: (col.description ?? '').trim(),
+})
+
+const sameColumnRules = (a: BoardColumn, b: BoardColumn): boolean => {
+  const x = columnRules(a)
+  const y = columnRules(b)
+  return (
+    x.maxCards === y.maxCards &&
+    x.mask === y.mask &&
+    x.lock === y.lock &&
+    x.description === y.description
+  )
+}
+
+// Default name of a column. Columns after the named ones are numbered.
+export const defaultColumnName = (t: ComposerTranslation, id: string): string =>
+  namedCategoryIds.has(id)
+    ? t(`dashboard.columns.${id}`)
+    : t('dashboard.columns.numbered', { n: Number(id.slice(3)) })
+
 export const exceedsEventRequestMaxSize = <T>(eventType: string, payload: T) => {
   const event: EventRequest<T> = {
     typ: eventType,
@@ -200,6 +292,16 @@ export function throttleRAF<T extends (...args: never[]) => unknown>(fn: T) {
   }
 }
 
+/**
+ * Websocket subprotocol carrying a credential, e.g. the owner token.
+ * Keeps credentials out of URLs and logs. See handshakeCredential in codec.go
+ */
+export const credentialSubprotocol = (name: string, value: string): string => {
+  const binString = Array.from(encoder.encode(value), byte => String.fromCharCode(byte)).join('')
+  const base64 = btoa(binString).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+  return `quickretro.${name}.${base64}`
+}
+
 /**
  * Encodes the passed data to URL-safe string.
  * Safe for Non-ASCII chars.
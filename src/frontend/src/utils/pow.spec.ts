@@ -0,0 +1,22 @@
+// @vitest-environment happy-dom
+import { describe, it, expect } from 'vitest'
+import { leadingZeroBits, solveChallenge } from './pow'
+
+describe('Proof-of-work utils', () => {
+  it('should count leading zero bits', () => {
+    expect(leadingZeroBits(new Uint8Array([0xff]))).toBe(0)
+    expect(leadingZeroBits(new Uint8Array([0x00, 0x10]))).toBe(11)
+    expect(leadingZeroBits(new Uint8Array([0x00, 0x00]))).toBe(16)
+  })
+
+  it('should find a response with enough zero bits', async () => {
+    const response = await solveChallenge('8.1700000000.NONCE.sig', 8)
+    expect(response).toMatch(/^8\.1700000000\.NONCE\.sig\.\d+$/)
+    const digest = await crypto.subtle.digest('SHA-256', new TextEncoder().encode(response!))
+    expect(leadingZeroBits(new Uint8Array(digest))).toBeGreaterThanOrEqual(8)
+  })
+
+  it('should stop when cancelled', async () => {
+    expect(await solveChallenge('challenge', 32, () => true)).toBeNull()
+  })
+})
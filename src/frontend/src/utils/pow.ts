@@ -0,0 +1,31 @@
+// Proof-of-work bot protection, when the "pow" challenge provider is configured. See challenge.go
+// The response is "<challenge>.<counter>", where SHA-256 of the response starts with difficulty zero bits.
+
+const encoder = new TextEncoder()
+const BATCH_SIZE = 256 // Hashes computed in parallel
+
+export const leadingZeroBits = (digest: Uint8Array): number => {
+  let bits = 0
+  for (const byte of digest) {
+    if (byte !== 0) return bits + Math.clz32(byte) - 24
+    bits += 8
+  }
+  return bits
+}
+
+// solveChallenge finds the response. Takes about 2^difficulty hashes on average.
+export const solveChallenge = async (
+  challenge: string,
+  difficulty: number,
+  isCancelled: () => boolean = () => false
+): Promise<string | null> => {
+  for (let start = 0; !isCancelled(); start += BATCH_SIZE) {
+    const candidates = Array.from({ length: BATCH_SIZE }, (_, i) => `${challenge}.${start + i}`)
+    const digests = await Promise.all(
+      candidates.map(c => crypto.subtle.digest('SHA-256', encoder.encode(c)))
+    )
+    const index = digests.findIndex(d => leadingZeroBits(new Uint8Array(d)) >= difficulty)
+    if (index !== -1) return candidates[index]
+  }
+  return null
+}
@@ -3,7 +3,6 @@
 interface ImportMetaEnv {
   readonly VITE_WS_PROTOCOL: 'ws' | 'wss'
   readonly VITE_SHOW_CONSOLE_LOGS: 'true' | 'false'
-  readonly VITE_TURNSTILE_SCRIPT_URL: string
   readonly VITE_API_BASE_URL: string
 }
 
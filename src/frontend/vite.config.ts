@@ -19,6 +19,10 @@ export default defineConfig(({ mode }) => {
           target: apiBase,
           changeOrigin: true,
         },
+        '^/(auth)': {
+          target: apiBase,
+          changeOrigin: true,
+        },
         '/config.js': {
           target: apiBase,
           changeOrigin: true,
@@ -71,6 +71,11 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any) error {
 	return nil
 }
 
+// isHTTPS reports if the client connected over HTTPS, directly or through a proxy that terminates TLS.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
 func parseDuration(s string) (time.Duration, error) {
 	var multiplier time.Duration = 1
 	switch {
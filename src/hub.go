@@ -9,15 +9,15 @@ type Hub struct {
 	clients    map[string]map[*Client]bool // Board-wise clients. Board is like a typical "room".
 	register   chan *Client
 	unregister chan *Client
-	redis      *RedisConnector
+	store      Store
 }
 
-func newHub(r *RedisConnector) *Hub {
+func newHub(s Store) *Hub {
 	return &Hub{
 		clients:    make(map[string]map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		redis:      r,
+		store:      s,
 	}
 }
 
@@ -62,21 +62,27 @@ func (hub *Hub) run() {
 					// delete(connections, client) modified the actual map. Because connections and hub.clients[client.group] point to the same thing, the change is "live."
 					if len(connections) == 0 {
 						delete(hub.clients, client.group)
-						hub.redis.Unsubscribe(client.group)
-						slog.Info("Board empty. Unsubscribed from Redis.", "group", client.group)
+						hub.store.Unsubscribe(client.group)
+						slog.Info("Board empty. Unsubscribed from pub/sub.", "group", client.group)
 					}
 				}
 			}
-		case broadcast := <-hub.redis.subscriber.Channel():
-			var args BroadcastArgs
-			if err := json.Unmarshal([]byte(broadcast.Payload), &args); err != nil {
-				slog.Error("Error unmarshalling to BroadcastArgs from redis channel in hub", "details", err.Error(), "payload", broadcast.Payload)
-			}
-			args.Event.Broadcast(args.Message, hub)
+		case payload := <-hub.store.Broadcasts():
+			hub.dispatch(payload)
 		}
 	}
 }
 
+// dispatch decodes a payload received from the store's pub/sub and broadcasts it to the board's local clients.
+func (hub *Hub) dispatch(payload string) {
+	var args BroadcastArgs
+	if err := json.Unmarshal([]byte(payload), &args); err != nil {
+		slog.Error("Error unmarshalling to BroadcastArgs from pub/sub channel in hub", "details", err.Error(), "payload", payload)
+		return
+	}
+	args.Event.Broadcast(args.Message, hub)
+}
+
 func (hub *Hub) broadcastUserLeft(c *Client) {
 	userClosingEvent := &UserClosingEvent{
 		By:    c.id,
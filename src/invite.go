@@ -0,0 +1,74 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Invite links.
+// The owner issues invites through the "invcreate" event, each with an expiry and an optional limit on how many users may use it.
+// Joining users send the invite id as the "invite" query param of the websocket URL. The frontend picks it up from
+// /board/{id}/join?invite=<id>.
+//
+// A valid invite lets the user in without the passcode, and is required to join boards marked Board.InviteOnly,
+// except for users who already joined before. Uses are counted once per user, atomically in the store,
+// so reconnects with the same invite don't use it up. Joins that fail (e.g. a refused handshake) don't count.
+
+const (
+	MaxInvitesPerBoard   = 20
+	MaxInviteUses        = 1000
+	MinInviteExpiry      = time.Minute
+	defaultInviteExpiry  = 24 * time.Hour
+	inviteRandomIdLength = 26 // rand.Text()
+)
+
+// Close reasons sent when an invite is required or rejected. See handleWebSocket.
+const (
+	CloseInviteRequired = "INVITEREQUIRED"
+	CloseInviteInvalid  = "INVITEINVALID"
+)
+
+// Invite is stored per board and expires at ExpiresAtUtc, or with the board, whichever comes first.
+type Invite struct {
+	Id           string `redis:"id" json:"id"`
+	CreatedAtUtc int64  `redis:"createdAtUtc" json:"createdAtUtc"`
+	ExpiresAtUtc int64  `redis:"expiresAtUtc" json:"expiresAtUtc"`
+	MaxUses      int64  `redis:"maxUses" json:"maxUses"` // 0 is unlimited
+	Uses         int64  `redis:"-" json:"uses"`          // Distinct users who joined with the invite
+}
+
+func newInviteId() string {
+	return rand.Text()
+}
+
+func validInviteId(id string) bool {
+	return len(id) == inviteRandomIdLength
+}
+
+// newInvite caps the expiry at the board's auto delete time, an invite can't outlive its board.
+func newInvite(b *Board, expiresIn time.Duration, maxUses int64, now time.Time) *Invite {
+	expiresAt := now.UTC().Add(expiresIn).Unix()
+	if b.AutoDeleteAtUtc > 0 && expiresAt > b.AutoDeleteAtUtc {
+		expiresAt = b.AutoDeleteAtUtc
+	}
+	return &Invite{
+		Id:           newInviteId(),
+		CreatedAtUtc: now.UTC().Unix(),
+		ExpiresAtUtc: expiresAt,
+		MaxUses:      maxUses,
+	}
+}
+
+func (i *Invite) usedUp() bool {
+	return i.MaxUses > 0 && i.Uses >= i.MaxUses
+}
+
+// sortInvites orders oldest first, which is the order the owner created them.
+func sortInvites(invites []*Invite) {
+	slices.SortFunc(invites, func(a, b *Invite) int {
+		return cmp.Or(cmp.Compare(a.CreatedAtUtc, b.CreatedAtUtc), strings.Compare(a.Id, b.Id))
+	})
+}
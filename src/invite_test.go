@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebsocket_Invite(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+	b, _ := ps.store.GetBoard("board1")
+	inv := newInvite(b, time.Hour, 1, time.Now())
+	ps.store.CreateInvite(b, inv)
+
+	// An invite admits the user without the passcode
+	if reason, _ := ps.join(t, "bob", "&invite="+inv.Id); reason != "" {
+		t.Fatalf("invite rejected with %q", reason)
+	}
+	// Reconnecting with the same invite doesn't use it up
+	if reason, _ := ps.join(t, "bob", "&invite="+inv.Id); reason != "" {
+		t.Errorf("invite rejected on reconnect with %q", reason)
+	}
+	if reason, _ := ps.join(t, "carol", "&invite="+inv.Id); reason != CloseInviteInvalid {
+		t.Errorf("used up invite: close reason %q", reason)
+	}
+	if reason, _ := ps.join(t, "carol", "&invite=forged"); reason != CloseInviteInvalid {
+		t.Errorf("unknown invite: close reason %q", reason)
+	}
+}
+
+func TestWebsocket_InviteNotUsedByFailedJoins(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+	b, _ := ps.store.GetBoard("board1")
+	inv := newInvite(b, time.Hour, 1, time.Now())
+	ps.store.CreateInvite(b, inv)
+
+	// Refused handshakes, retried
+	u := ps.url + "/ws/board/board1/user/mallory/meet?nickname=mallory&invite=" + inv.Id
+	for range 3 {
+		if _, _, err := websocket.DefaultDialer.Dial(u, http.Header{"Origin": []string{"https://evil.example"}}); err == nil {
+			t.Fatal("handshake from a disallowed origin accepted")
+		}
+	}
+	if invites, _ := ps.store.GetInvites(b.Id); invites[0].Uses != 0 {
+		t.Fatalf("failed joins used the invite: %d uses", invites[0].Uses)
+	}
+	if _, known := ps.store.GetUser(b.Id, "mallory"); known {
+		t.Error("user of a failed join saved")
+	}
+
+	if reason, _ := ps.join(t, "bob", "&invite="+inv.Id); reason != "" {
+		t.Fatalf("invite rejected with %q", reason)
+	}
+	if reason, _ := ps.join(t, "bob", "&invite="+inv.Id); reason != "" {
+		t.Errorf("invite rejected on reconnect with %q", reason)
+	}
+	if invites, _ := ps.store.GetInvites(b.Id); invites[0].Uses != 1 {
+		t.Errorf("invite uses = %d, want 1", invites[0].Uses)
+	}
+}
+
+func TestWebsocket_InviteOnly(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+	b, _ := ps.store.GetBoard("board1")
+	ps.store.UpdateBoardPasscode(b, "")
+	ps.store.UpdateBoardInviteOnly(b, true)
+	ps.store.EnsureUser("board1", "bob", "bob")
+	inv := newInvite(b, time.Hour, 0, time.Now())
+	ps.store.CreateInvite(b, inv)
+
+	if reason, _ := ps.join(t, "carol", ""); reason != CloseInviteRequired {
+		t.Errorf("no invite: close reason %q", reason)
+	}
+	if reason, _ := ps.join(t, "carol", "&invite="+inv.Id); reason != "" {
+		t.Errorf("invite rejected with %q", reason)
+	}
+	// Users who joined before don't need an invite, nor does the owner
+	if reason, _ := ps.join(t, "bob", ""); reason != "" {
+		t.Errorf("returning user rejected with %q", reason)
+	}
+	if reason, _ := ps.joinWithCredential(t, "alice", CredentialOwnerToken, creatorToken("board1", "alice")); reason != "" {
+		t.Errorf("owner rejected with %q", reason)
+	}
+}
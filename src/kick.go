@@ -0,0 +1,62 @@
+package main
+
+import "log/slog"
+
+// Kick and ban.
+// The owner removes a participant with the "kick" event. Every instance closes the user's connections with the KICKED close reason,
+// and tells the others to drop the user from their presence lists. A kicked user can join again, unless they were also banned.
+// Banned users are rejected with the BANNED close reason until the board is deleted.
+//
+// The owner can't be kicked. A ban doesn't stop the creator from coming back with their creator token, the creator can always reclaim the board.
+// Optionally the user's cards, with the comments on them, and their comments are deleted. Anonymous cards are kept, deleting them
+// would reveal who wrote them.
+
+// Close reasons sent to removed users. See handleWebSocket.
+const (
+	CloseKicked = "KICKED"
+	CloseBanned = "BANNED"
+)
+
+// closeFrame asks the client's write goroutine to close the connection with a reason, after the messages already queued.
+type closeFrame struct {
+	reason string
+}
+
+// deleteUserCards deletes the user's non-anonymous cards, with all comments on them, and the user's comments on other cards.
+// Returns the ids of what was deleted.
+func deleteUserCards(s Store, boardId, userId string) []string {
+	data, ok := s.GetBoardAggregatedData(boardId)
+	if !ok {
+		return nil
+	}
+
+	var deleted []string
+	cardIds := make(map[string]struct{})
+	for _, m := range data.Messages {
+		if m.By != userId || m.Anonymous {
+			continue
+		}
+		var commentIds []string
+		for _, c := range data.Comments {
+			if c.ParentId == m.Id {
+				commentIds = append(commentIds, c.Id)
+			}
+		}
+		if !s.DeleteMessage(boardId, m.Id, commentIds) {
+			slog.Warn("Failed to delete card of kicked user", "board", boardId, "msgId", m.Id)
+			continue
+		}
+		cardIds[m.Id] = struct{}{}
+		deleted = append(deleted, m.Id)
+		deleted = append(deleted, commentIds...)
+	}
+	for _, c := range data.Comments {
+		if _, onDeletedCard := cardIds[c.ParentId]; onDeletedCard || c.By != userId {
+			continue
+		}
+		if s.DeleteComment(boardId, c.Id) {
+			deleted = append(deleted, c.Id)
+		}
+	}
+	return deleted
+}
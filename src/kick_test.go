@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestKickEvent(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	bobSecondTab := tb.join("bob", "Bob")
+	carol := tb.join("carol", "Carol")
+	tb.send(bob, "msg", MessageEvent{Id: "m1", Content: "spam", Category: "col01"})
+	tb.send(bob, "msg", MessageEvent{Id: "m2", Content: "anon", Category: "col01", Anonymous: true})
+	tb.send(carol, "msg", MessageEvent{Id: "m3", Content: "hello", Category: "col01"})
+	tb.send(carol, "msg", MessageEvent{Id: "c1", Content: "reply", ParentId: "m1"})
+	tb.send(bob, "msg", MessageEvent{Id: "c2", Content: "reply", ParentId: "m3"})
+	for _, c := range []*Client{owner, bob, bobSecondTab, carol} {
+		for len(c.send) > 0 {
+			receive(c)
+		}
+	}
+
+	// Only the owner can kick, and the owner can't be kicked
+	tb.send(carol, "kick", KickEvent{Xid: bob.xid, Ban: true})
+	tb.send(owner, "kick", KickEvent{Xid: owner.xid})
+	if r := receive(bob); r != nil || tb.store.IsBanned(tb.board.Id, "bob") {
+		t.Fatalf("kick by non-owner or of the owner went through: %+v", r)
+	}
+
+	// The user id in the payload is never trusted
+	tb.send(owner, "kick", KickEvent{Xid: bob.xid, Ban: true, DeleteCards: true, UserId: "carol"})
+	for _, c := range []*Client{bob, bobSecondTab} {
+		if f, ok := receive(c).(closeFrame); !ok || f.reason != CloseKicked {
+			t.Errorf("kicked connection received %+v", f)
+		}
+	}
+	res, ok := receive(carol).(*KickResponse)
+	if !ok || res.Xid != bob.xid || !res.Ban || len(res.MessageIds) != 3 {
+		t.Fatalf("other user received %+v", res)
+	}
+	if !tb.store.IsBanned(tb.board.Id, "bob") {
+		t.Error("user not banned")
+	}
+	// Bob's card with its comments, and Bob's comment are gone. The anonymous card stays.
+	for id, want := range map[string]bool{"m1": false, "c1": false, "c2": false, "m2": true, "m3": true} {
+		if _, ok := tb.store.GetMessage(tb.board.Id, id); ok != want {
+			t.Errorf("%s exists = %v, want %v", id, ok, want)
+		}
+	}
+}
+
+func TestWebsocket_Banned(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+	b, _ := ps.store.GetBoard("board1")
+	ps.store.UpdateBoardPasscode(b, "")
+	ps.store.BanUser(b, "bob")
+	ps.store.BanUser(b, "alice")
+
+	if reason, _ := ps.join(t, "bob", ""); reason != CloseBanned {
+		t.Errorf("banned user: close reason %q", reason)
+	}
+	if reason, _ := ps.join(t, "carol", ""); reason != "" {
+		t.Errorf("other user rejected with %q", reason)
+	}
+	// The creator can always come back
+	if reason, _ := ps.joinWithCredential(t, "alice", CredentialOwnerToken, creatorToken("board1", "alice")); reason != "" {
+		t.Errorf("creator rejected with %q", reason)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import "time"
+
+// memoryDocs keeps board documents in a map. Data is lost on restart.
+type memoryDocs struct {
+	boards     map[string]*boardDoc
+	teams      map[string]*teamDoc
+	userBoards map[string]map[string]struct{} // userId -> boardIds
+	userTokens map[string]struct{}            // userIds that acknowledged their user token
+}
+
+// NewMemoryStore creates a DocStore that keeps everything in-process, for single-binary or demo deployments and for tests.
+func NewMemoryStore(timeToLive time.Duration) *DocStore {
+	docs := &memoryDocs{boards: make(map[string]*boardDoc), teams: make(map[string]*teamDoc), userBoards: make(map[string]map[string]struct{}), userTokens: make(map[string]struct{})}
+	return newDocStore(docs, timeToLive)
+}
+
+// get returns the stored document itself. DocStore only modifies it inside update, which calls put afterwards anyway.
+func (m *memoryDocs) get(boardId string) (*boardDoc, bool) {
+	d, ok := m.boards[boardId]
+	return d, ok
+}
+
+func (m *memoryDocs) put(d *boardDoc) bool {
+	m.boards[d.Board.Id] = d
+	return true
+}
+
+func (m *memoryDocs) del(boardId string) bool {
+	delete(m.boards, boardId)
+	return true
+}
+
+func (m *memoryDocs) expired(nowUtcSeconds int64) []string {
+	var ids []string
+	for id, d := range m.boards {
+		if d.Board.AutoDeleteAtUtc <= nowUtcSeconds {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *memoryDocs) getTeam(teamId string) (*teamDoc, bool) {
+	d, ok := m.teams[teamId]
+	return d, ok
+}
+
+func (m *memoryDocs) putTeam(d *teamDoc) bool {
+	m.teams[d.Team.Id] = d
+	return true
+}
+
+func (m *memoryDocs) delTeam(teamId string) bool {
+	delete(m.teams, teamId)
+	return true
+}
+
+func (m *memoryDocs) expiredTeams(nowUtcSeconds int64) []string {
+	var ids []string
+	for id, d := range m.teams {
+		if d.Team.AutoDeleteAtUtc <= nowUtcSeconds {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *memoryDocs) boardsOfUser(userId string) []string {
+	ids := make([]string, 0, len(m.userBoards[userId]))
+	for id := range m.userBoards[userId] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (m *memoryDocs) addUserBoard(userId, boardId string) bool {
+	if m.userBoards[userId] == nil {
+		m.userBoards[userId] = make(map[string]struct{})
+	}
+	m.userBoards[userId][boardId] = struct{}{}
+	return true
+}
+
+func (m *memoryDocs) delUserBoard(userId, boardId string) bool {
+	delete(m.userBoards[userId], boardId)
+	if len(m.userBoards[userId]) == 0 {
+		delete(m.userBoards, userId)
+		delete(m.userTokens, userId)
+	}
+	return true
+}
+
+func (m *memoryDocs) userTokenAcked(userId string) bool {
+	_, acked := m.userTokens[userId]
+	return acked
+}
+
+func (m *memoryDocs) ackUserToken(userId string) bool {
+	m.userTokens[userId] = struct{}{}
+	return true
+}
+
+func (m *memoryDocs) close() {}
@@ -0,0 +1,27 @@
+package main
+
+// Co-facilitators.
+// The board owner can make other users moderators through the "set" event (SettingsEvent.Moderator), so the meeting
+// can go on when the owner drops out. Moderators can run the timer, pin, move and delete cards, and mask or lock the board.
+// Transferring ownership, deleting all data, columns, passcodes, invites and offline likes stay with the owner.
+//
+// The flag is kept on the board's User. Knowing a moderator's user id isn't enough to act as one: moderators get a signed
+// token when they are added, and present it when joining, like owners do. See owner_token.go
+
+// ModeratorChange adds or removes a moderator, identified by xid.
+type ModeratorChange struct {
+	Xid       string `json:"xid"`
+	Moderator bool   `json:"moderator"`
+}
+
+// canModerate checks that the user is the verified owner or a verified moderator of the board.
+func canModerate(s Store, b *Board, e *Event) bool {
+	if isVerifiedOwner(b, e.By, e.Token) {
+		return true
+	}
+	if e.ModeratorToken == "" {
+		return false
+	}
+	u, ok := s.GetUser(b.Id, e.By)
+	return ok && isVerifiedModerator(b.Id, u, e.ModeratorToken)
+}
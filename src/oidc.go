@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OpenID Connect sign-in. Optional, disabled by default, when everyone is anonymous with a random per-browser user id.
+//
+// When enabled ([oidc] in config.toml, provider settings in OIDC_* ENV vars), the server runs the authorization code flow
+// with PKCE: /auth/login redirects to the provider, /auth/callback verifies the ID token and sets a session cookie.
+// The session carries a user id derived from the issuer and "sub" claim, and the nickname from the identity.
+// Signed-in users always connect and create boards with that user id and nickname.
+// Sessions are stateless, signed with TOKEN_SECRET like owner tokens, so they work across instances.
+
+const (
+	sessionCookieName    = "qr_session"
+	loginStateCookieName = "qr_login"
+	loginStateTTL        = 10 * time.Minute
+	defaultSessionTTL    = 12 * time.Hour
+
+	// User ids of signed-in users. Anonymous user ids are UUIDs, so they never start with it.
+	identityUserIdPrefix = "oidc-"
+
+	tokenPurposeSession    = "session"
+	tokenPurposeLoginState = "login"
+)
+
+// Close reasons sent when joining needs a signed-in user. See handleWebSocket.
+const (
+	CloseLoginRequired = "LOGINREQUIRED"
+	CloseLoginMismatch = "LOGINMISMATCH"
+)
+
+// sso is nil when OIDC sign-in is disabled.
+var sso *oidcLogin
+
+type oidcLogin struct {
+	issuer   string
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// session is the payload of the session cookie.
+type session struct {
+	UserId     string `json:"uid"`
+	Nickname   string `json:"nick"`
+	ExpiresUtc int64  `json:"exp"`
+}
+
+// loginState is kept in a short-lived cookie between /auth/login and /auth/callback.
+type loginState struct {
+	State      string `json:"state"`
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"verifier"`
+	Next       string `json:"next"`
+	ExpiresUtc int64  `json:"exp"`
+}
+
+// newOIDCLogin discovers the provider configuration from the issuer.
+func newOIDCLogin(ctx context.Context, env EnvironmentConfig) (*oidcLogin, error) {
+	if env.OIDCIssuerURL == "" || env.OIDCClientID == "" || env.OIDCRedirectURL == "" {
+		return nil, errors.New("OIDC_ISSUER_URL, OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC is enabled")
+	}
+	provider, err := oidc.NewProvider(ctx, env.OIDCIssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	scopes := config.OIDC.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &oidcLogin{
+		issuer: env.OIDCIssuerURL,
+		oauth: oauth2.Config{
+			ClientID:     env.OIDCClientID,
+			ClientSecret: env.OIDCClientSecret,
+			RedirectURL:  env.OIDCRedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: env.OIDCClientID}),
+	}, nil
+}
+
+// handleLogin starts the authorization code flow. "next" is where to go after signing in, a path on this site.
+func (l *oidcLogin) handleLogin(w http.ResponseWriter, r *http.Request) {
+	st := loginState{
+		State:      rand.Text(),
+		Nonce:      rand.Text(),
+		Verifier:   oauth2.GenerateVerifier(),
+		Next:       safeRedirectPath(r.URL.Query().Get("next")),
+		ExpiresUtc: time.Now().UTC().Add(loginStateTTL).Unix(),
+	}
+	value, err := signCookieValue(tokenPurposeLoginState, st)
+	if err != nil {
+		slog.Error("Error signing login state", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookieName,
+		Value:    value,
+		Path:     "/auth/",
+		MaxAge:   int(loginStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode, // Sent on the redirect back from the provider
+	})
+	http.Redirect(w, r, l.oauth.AuthCodeURL(st.State, oidc.Nonce(st.Nonce), oauth2.S256ChallengeOption(st.Verifier)), http.StatusFound)
+}
+
+// handleCallback completes the flow, verifies the ID token and starts the session.
+func (l *oidcLogin) handleCallback(w http.ResponseWriter, r *http.Request) {
+	var st loginState
+	c, err := r.Cookie(loginStateCookieName)
+	if err != nil || !openCookieValue(tokenPurposeLoginState, c.Value, &st) || st.ExpiresUtc <= time.Now().UTC().Unix() {
+		slog.Warn("Missing or expired login state", "remote", remoteIP(r))
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookieName, Path: "/auth/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r)})
+
+	query := r.URL.Query()
+	if !tokenMatches(query.Get("state"), st.State) {
+		slog.Warn("Login state mismatch", "remote", remoteIP(r))
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		slog.Warn("Login rejected by provider", "error", e, "description", query.Get("error_description"))
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := l.oauth.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		slog.Warn("OIDC code exchange failed", "err", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		slog.Warn("OIDC token response has no id_token")
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := l.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil || !tokenMatches(idToken.Nonce, st.Nonce) {
+		slog.Warn("Invalid OIDC ID token", "err", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		slog.Warn("Cannot read OIDC claims", "err", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	s := session{
+		UserId:     identityUserId(l.issuer, idToken.Subject),
+		Nickname:   nicknameFromClaims(claims),
+		ExpiresUtc: time.Now().UTC().Add(sessionDuration()).Unix(),
+	}
+	value, err := signCookieValue(tokenPurposeSession, s)
+	if err != nil {
+		slog.Error("Error signing session", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(sessionDuration().Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.Info("Login", "user", s.UserId)
+	http.Redirect(w, r, st.Next, http.StatusFound)
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !isOriginAllowed(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Path: "/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAuthMe returns the signed-in user, so the frontend can use the same user id and nickname.
+func handleAuthMe(w http.ResponseWriter, r *http.Request) {
+	s, ok := sessionFromRequest(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"id": s.UserId, "nickname": s.Nickname})
+}
+
+// sessionFromRequest returns the signed-in user. Session cookies are ignored while OIDC is disabled.
+func sessionFromRequest(r *http.Request) (*session, bool) {
+	if sso == nil {
+		return nil, false
+	}
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, false
+	}
+	var s session
+	if !openCookieValue(tokenPurposeSession, c.Value, &s) || s.ExpiresUtc <= time.Now().UTC().Unix() || s.UserId == "" {
+		return nil, false
+	}
+	return &s, true
+}
+
+func loginRequiredToCreate() bool {
+	return sso != nil && config.OIDC.RequireLoginToCreate
+}
+
+func loginRequiredToJoin() bool {
+	return sso != nil && config.OIDC.RequireLoginToJoin
+}
+
+// checkUserCredential checks that the request can act as userId. Websocket connections and user APIs share it.
+// Signed-in users can only be themselves. Without a session, identity user ids are refused, and so is everyone when
+// login is required to join. Anonymous user ids are random and only known to their browser.
+// Returns the session when signed in, and the reason when refused.
+func checkUserCredential(r *http.Request, userId string) (*session, string) {
+	s, signedIn := sessionFromRequest(r)
+	if signedIn {
+		if userId != s.UserId {
+			return nil, CloseLoginMismatch
+		}
+		return s, ""
+	}
+	if loginRequiredToJoin() || isIdentityUserId(userId) {
+		return nil, CloseLoginRequired
+	}
+	return nil, ""
+}
+
+// identityUserId maps an identity to a user id that fits MaxIdSizeBytes. "sub" is only unique per issuer.
+func identityUserId(issuer, subject string) string {
+	sum := sha256.Sum256([]byte(issuer + "\x00" + subject))
+	return identityUserIdPrefix + base64.RawURLEncoding.EncodeToString(sum[:21])
+}
+
+// isIdentityUserId reports if the user id belongs to a signed-in identity. Anonymous users can't use those.
+func isIdentityUserId(userId string) bool {
+	return strings.HasPrefix(userId, identityUserIdPrefix)
+}
+
+// nicknameFromClaims uses the configured claim, then common profile claims.
+func nicknameFromClaims(claims map[string]any) string {
+	for _, name := range []string{config.OIDC.NicknameClaim, "name", "preferred_username", "email"} {
+		if v, ok := claims[name].(string); ok && strings.TrimSpace(v) != "" {
+			return truncateRunes(strings.TrimSpace(v), config.Data.MaxTextLength)
+		}
+	}
+	return "User"
+}
+
+func truncateRunes(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+func sessionDuration() time.Duration {
+	if config.OIDC.SessionDuration == "" {
+		return defaultSessionTTL
+	}
+	d, err := parseDuration(config.OIDC.SessionDuration)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid OIDC session duration in config. Using default.", "value", config.OIDC.SessionDuration, "default", defaultSessionTTL)
+		return defaultSessionTTL
+	}
+	return d
+}
+
+// safeRedirectPath only allows paths on this site, to avoid redirecting to other sites after login.
+func safeRedirectPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// signCookieValue encodes v as "<base64 json>.<signature>".
+func signCookieValue(purpose string, v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + cookieSignature(purpose, payload), nil
+}
+
+// openCookieValue checks the signature and decodes the payload into v.
+func openCookieValue(purpose, value string, v any) bool {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok || !tokenMatches(sig, cookieSignature(purpose, payload)) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func cookieSignature(purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(tokenSecret))
+	mac.Write([]byte(purpose + "\x00" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider. Every authorization request signs in as subject.
+type mockOIDCProvider struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	subject string
+	name    string
+
+	mu    sync.Mutex
+	codes map[string]mockAuthRequest
+}
+
+type mockAuthRequest struct {
+	nonce     string
+	challenge string
+	clientId  string
+}
+
+func newMockOIDCProvider(t *testing.T, subject, name string) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockOIDCProvider{key: key, subject: subject, name: name, codes: make(map[string]mockAuthRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "test",
+			"n": enc.EncodeToString(key.N.Bytes()),
+			"e": enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "PKCE required", http.StatusBadRequest)
+			return
+		}
+		code := rand.Text()
+		p.mu.Lock()
+		p.codes[code] = mockAuthRequest{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), clientId: q.Get("client_id")}
+		p.mu.Unlock()
+		redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.mu.Lock()
+		req, ok := p.codes[r.PostForm.Get("code")]
+		delete(p.codes, r.PostForm.Get("code"))
+		p.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     p.idToken(t, req),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *mockOIDCProvider) idToken(t *testing.T, req mockAuthRequest) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	now := time.Now().Unix()
+	claims, _ := json.Marshal(map[string]any{
+		"iss": p.URL, "sub": p.subject, "aud": req.clientId, "nonce": req.nonce,
+		"iat": now, "exp": now + 300, "name": p.name,
+	})
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Error(err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig)
+}
+
+type oidcTestApp struct {
+	url      string
+	provider *mockOIDCProvider
+	store    *DocStore
+}
+
+// newOIDCTestApp runs the app routes with OIDC sign-in against the mock provider.
+func newOIDCTestApp(t *testing.T) *oidcTestApp {
+	t.Helper()
+	prevConfig, prevSSO := config, sso
+	config.Server.AllowedOrigins = []string{"https://localhost"}
+	config.Data.MaxTextLength = 80
+	config.Data.MaxCategoryTextLength = 80
+	config.OIDC.Enabled = true
+	t.Cleanup(func() { config, sso = prevConfig, prevSSO })
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	hub := newHub(store)
+	go hub.run()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/auth/login", func(w http.ResponseWriter, r *http.Request) { sso.handleLogin(w, r) })
+	router.HandleFunc("/auth/callback", func(w http.ResponseWriter, r *http.Request) { sso.handleCallback(w, r) })
+	router.HandleFunc("/api/auth/me", handleAuthMe)
+	router.HandleFunc("/api/board/create", func(w http.ResponseWriter, r *http.Request) { HandleCreateBoard(store, w, r) })
+	router.HandleFunc("/api/user/{id}/boards", func(w http.ResponseWriter, r *http.Request) { HandleGetUserBoards(store, w, r) })
+	router.HandleFunc("/ws/board/{board}/user/{user}/meet", func(w http.ResponseWriter, r *http.Request) { handleWebSocket(hub, w, r) })
+	router.HandleFunc("/board/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+
+	provider := newMockOIDCProvider(t, "alice@example", "Alice Example")
+	login, err := newOIDCLogin(context.Background(), EnvironmentConfig{
+		OIDCIssuerURL:   provider.URL,
+		OIDCClientID:    "quickretro",
+		OIDCRedirectURL: app.URL + "/auth/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sso = login
+	return &oidcTestApp{url: app.URL, provider: provider, store: store}
+}
+
+// signIn runs the login flow in a fresh client and returns it with the session cookie in its jar.
+func (a *oidcTestApp) signIn(t *testing.T) *http.Client {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(a.url + "/auth/login?next=" + url.QueryEscape("/board/abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/board/abc" {
+		t.Fatalf("login ended at %s with %d", resp.Request.URL, resp.StatusCode)
+	}
+	return client
+}
+
+func (a *oidcTestApp) createBoard(t *testing.T, client *http.Client, owner string) (*http.Response, string) {
+	t.Helper()
+	body := `{"name":"Retro","team":"Team","owner":"` + owner + `","columns":[{"id":"col01","text":"Good","color":"green","pos":1}]}`
+	req, _ := http.NewRequest(http.MethodPost, a.url+"/api/board/create", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://localhost")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var res CreateBoardRes
+	json.NewDecoder(resp.Body).Decode(&res)
+	return resp, res.Id
+}
+
+// join returns the close reason if the server rejected the websocket.
+func (a *oidcTestApp) join(t *testing.T, client *http.Client, board, user, nickname string) string {
+	t.Helper()
+	header := http.Header{"Origin": []string{"https://localhost"}}
+	u := "ws" + strings.TrimPrefix(a.url, "http") + "/ws/board/" + board + "/user/" + user + "/meet?nickname=" + url.QueryEscape(nickname)
+	dialer := websocket.Dialer{Jar: client.Jar}
+	conn, _, err := dialer.Dial(u, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		if ce, ok := err.(*websocket.CloseError); ok {
+			return ce.Text
+		}
+	}
+	return ""
+}
+
+func TestOIDCLogin_SessionIdentity(t *testing.T) {
+	app := newOIDCTestApp(t)
+	client := app.signIn(t)
+
+	resp, err := client.Get(app.url + "/api/auth/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var me struct{ Id, Nickname string }
+	json.NewDecoder(resp.Body).Decode(&me)
+	resp.Body.Close()
+	if me.Id != identityUserId(app.provider.URL, "alice@example") || me.Nickname != "Alice Example" {
+		t.Fatalf("me = %+v", me)
+	}
+	if len(me.Id) > MaxIdSizeBytes {
+		t.Errorf("user id %q longer than %d bytes", me.Id, MaxIdSizeBytes)
+	}
+
+	// The board is created for the signed-in user, whatever the request says
+	resp, boardId := app.createBoard(t, client, "someone-else")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create board: %d", resp.StatusCode)
+	}
+	if b, _ := app.store.GetBoard(boardId); b.Owner != me.Id || b.Creator != me.Id {
+		t.Errorf("board owner %q, creator %q, want %q", b.Owner, b.Creator, me.Id)
+	}
+
+	// Joins use the identity, and the nickname is locked to it
+	if reason := app.join(t, client, boardId, "someone-else", "Alice"); reason != CloseLoginMismatch {
+		t.Errorf("join with another user id: close reason %q", reason)
+	}
+	if reason := app.join(t, client, boardId, me.Id, "Not Alice"); reason != "" {
+		t.Fatalf("join rejected with %q", reason)
+	}
+	if u, _ := app.store.GetUser(boardId, me.Id); u == nil || u.Nickname != "Alice Example" {
+		t.Errorf("user = %+v", u)
+	}
+
+	// Anonymous users can't take over the identity
+	anonymous := &http.Client{}
+	if reason := app.join(t, anonymous, boardId, me.Id, "Mallory"); reason != CloseLoginRequired {
+		t.Errorf("anonymous join as identity: close reason %q", reason)
+	}
+	if resp, _ := app.createBoard(t, anonymous, me.Id); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous create as identity: %d", resp.StatusCode)
+	}
+}
+
+func TestOIDCLogin_RequireLogin(t *testing.T) {
+	app := newOIDCTestApp(t)
+	anonymous := &http.Client{}
+
+	resp, boardId := app.createBoard(t, anonymous, "anon-user")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("anonymous create without require_login_to_create: %d", resp.StatusCode)
+	}
+	if reason := app.join(t, anonymous, boardId, "anon-user", "Anon"); reason != "" {
+		t.Errorf("anonymous join without require_login_to_join rejected with %q", reason)
+	}
+
+	config.OIDC.RequireLoginToCreate = true
+	config.OIDC.RequireLoginToJoin = true
+	if resp, _ := app.createBoard(t, anonymous, "anon-user"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous create: %d", resp.StatusCode)
+	}
+	if reason := app.join(t, anonymous, boardId, "anon-user", "Anon"); reason != CloseLoginRequired {
+		t.Errorf("anonymous join: close reason %q", reason)
+	}
+
+	client := app.signIn(t)
+	if resp, _ := app.createBoard(t, client, ""); resp.StatusCode != http.StatusCreated {
+		t.Errorf("signed-in create: %d", resp.StatusCode)
+	}
+}
+
+func TestOIDCCallback_RejectsForgedState(t *testing.T) {
+	app := newOIDCTestApp(t)
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	// Start a login to get the state cookie, then come back with another state
+	resp, err := client.Get(app.url + "/auth/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get(app.url + "/auth/callback?code=x&state=forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("forged state: %d", resp.StatusCode)
+	}
+	if resp, _ := client.Get(app.url + "/api/auth/me"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("session after forged state: %d", resp.StatusCode)
+	}
+}
+
+func TestSafeRedirectPath(t *testing.T) {
+	tests := map[string]string{
+		"/board/abc":         "/board/abc",
+		"/create?x=1":        "/create?x=1",
+		"":                   "/",
+		"https://evil.test/": "/",
+		"//evil.test/":       "/",
+		"/\\evil.test/":      "/",
+	}
+	for in, want := range tests {
+		if got := safeRedirectPath(in); got != want {
+			t.Errorf("safeRedirectPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
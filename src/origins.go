@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Allowed origins.
+// Board creation, sign-in and websocket handshakes are only accepted from the origins in allowed_origins in [server]
+// of config.toml, or the comma separated ALLOWED_ORIGINS env var, which replaces them.
+//
+// Entries are exact origins ("https://retro.example.com") or patterns:
+//   - "https://*.retro.example.com" matches a single subdomain label, like "https://pr-12.retro.example.com".
+//     Not "https://retro.example.com" itself, nor "https://a.b.retro.example.com".
+//   - "http://localhost:*" matches any port, including none.
+//
+// The scheme always has to match. Origins are parsed strictly, so userinfo, paths and look-alike hosts never match.
+
+type originPattern struct {
+	scheme   string
+	host     string // Without "*." for wildcards
+	port     string // Empty for the default port of the scheme. "*" for any.
+	wildcard bool
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// parseOriginPattern parses an allowed_origins entry.
+func parseOriginPattern(s string) (originPattern, error) {
+	scheme, hostPort, ok := strings.Cut(strings.TrimSpace(s), "://")
+	if !ok {
+		return originPattern{}, fmt.Errorf("origin %q: missing scheme", s)
+	}
+	if _, known := defaultPorts[scheme]; !known {
+		return originPattern{}, fmt.Errorf("origin %q: scheme must be http or https", s)
+	}
+	if strings.ContainsAny(hostPort, "/?#@\\") {
+		return originPattern{}, fmt.Errorf("origin %q: only scheme, host and port are allowed", s)
+	}
+
+	p := originPattern{scheme: scheme}
+	host := hostPort
+	if i := strings.LastIndex(hostPort, ":"); i != -1 && !strings.HasSuffix(hostPort, "]") {
+		host, p.port = hostPort[:i], hostPort[i+1:]
+		if p.port != "*" && !isValidPort(p.port) {
+			return originPattern{}, fmt.Errorf("origin %q: invalid port", s)
+		}
+		if p.port == defaultPorts[scheme] {
+			p.port = ""
+		}
+	}
+
+	if rest, isWildcard := strings.CutPrefix(host, "*."); isWildcard {
+		// At least a registrable domain after the wildcard, e.g. not "*.com"
+		if strings.Count(rest, ".") < 1 {
+			return originPattern{}, fmt.Errorf("origin %q: wildcard needs a domain with at least two labels", s)
+		}
+		p.wildcard, host = true, rest
+	}
+	host = strings.ToLower(host)
+	if ipv6, isIPv6 := strings.CutPrefix(host, "["); isIPv6 && !p.wildcard {
+		addr, err := netip.ParseAddr(strings.TrimSuffix(ipv6, "]"))
+		if err != nil || !addr.Is6() || !strings.HasSuffix(ipv6, "]") {
+			return originPattern{}, fmt.Errorf("origin %q: invalid host", s)
+		}
+		p.host = "[" + addr.String() + "]"
+		return p, nil
+	}
+	if !isValidHostname(host) {
+		return originPattern{}, fmt.Errorf("origin %q: invalid host", s)
+	}
+	p.host = host
+	return p, nil
+}
+
+// matches reports whether an origin sent by a browser matches the pattern.
+// The origin is parsed as strictly as patterns, without wildcards.
+func (p originPattern) matches(origin string) bool {
+	o, err := parseOriginPattern(origin)
+	if err != nil || o.wildcard || o.port == "*" || o.scheme != p.scheme {
+		return false
+	}
+	if p.port != "*" && o.port != p.port {
+		return false
+	}
+	if !p.wildcard {
+		return o.host == p.host
+	}
+	label, found := strings.CutSuffix(o.host, "."+p.host)
+	return found && label != "" && !strings.Contains(label, ".")
+}
+
+// isOriginAllowedValue checks an Origin header value against allowed_origins. Invalid entries never match.
+func isOriginAllowedValue(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, entry := range config.Server.AllowedOrigins {
+		p, err := parseOriginPattern(entry)
+		if err == nil && p.matches(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAllowedOrigins reports invalid allowed_origins entries, so they fail at startup instead of silently never matching.
+func validateAllowedOrigins(entries []string) error {
+	var errs []error
+	for _, entry := range entries {
+		if _, err := parseOriginPattern(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// splitAllowedOrigins splits the ALLOWED_ORIGINS env var.
+func splitAllowedOrigins(s string) []string {
+	var origins []string
+	for _, origin := range strings.Split(s, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+func isValidPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && n <= 65535 && s[0] != '0' && s[0] != '+'
+}
+
+// isValidHostname allows DNS names and IPv4 addresses. Labels are letters, digits and hyphens, not at the ends.
+func isValidHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strconv"
+)
+
+// Owner tokens prove that a websocket connection belongs to the board owner.
+// Knowing the owner's user id (e.g. from a shared URL or logs) is not enough to act as owner.
+//
+// Tokens are HMAC-SHA256 over the board id, user id and a purpose, keyed with the server secret (TOKEN_SECRET):
+//   - "creator" tokens are returned by HandleCreateBoard. They never change, so the creator can always reclaim the board.
+//   - "owner" tokens also cover Board.OwnerEpoch. A transfer bumps the epoch, so tokens of previous owners stop working.
+//     The new owner receives theirs in the SettingsResponse.
+//   - "user" tokens aren't tied to a board. They let anonymous users list their boards, see user_boards.go
+//   - "moderator" tokens cover User.ModeratorEpoch. Every moderator change bumps it, so removing a moderator invalidates
+//     their token, and adding them again issues a new one. Moderators receive theirs in the SettingsResponse too.
+const (
+	tokenPurposeCreator   = "creator"
+	tokenPurposeOwner     = "owner"
+	tokenPurposeModerator = "moderator"
+	tokenPurposeUser      = "user"
+)
+
+// tokenSecret is random until initTokenSecret is called with the configured secret.
+var tokenSecret = rand.Text()
+
+// initTokenSecret sets the HMAC key. Without a configured secret the random one is kept,
+// which invalidates all tokens on restart and doesn't work across multiple instances.
+// That is refused with a shared store (Redis), whose boards outlive the process and are served by every instance.
+func initTokenSecret(secret string, sharedStore bool) error {
+	if secret == "" {
+		if sharedStore {
+			return errors.New("TOKEN_SECRET must be set when using the redis store backend")
+		}
+		slog.Warn("TOKEN_SECRET not set. Using a random secret. Tokens won't survive restarts.")
+		return nil
+	}
+	tokenSecret = secret
+	return nil
+}
+
+func signBoardToken(purpose, boardId, userId string, epoch int64) string {
+	mac := hmac.New(sha256.New, []byte(tokenSecret))
+	// Null separated, ids never contain null bytes
+	mac.Write([]byte(purpose + "\x00" + boardId + "\x00" + userId + "\x00" + strconv.FormatInt(epoch, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func creatorToken(boardId, userId string) string {
+	return signBoardToken(tokenPurposeCreator, boardId, userId, 0)
+}
+
+func ownerToken(b *Board) string {
+	return signBoardToken(tokenPurposeOwner, b.Id, b.Owner, b.OwnerEpoch)
+}
+
+func moderatorToken(boardId string, u *User) string {
+	return signBoardToken(tokenPurposeModerator, boardId, u.Id, u.ModeratorEpoch)
+}
+
+func userToken(userId string) string {
+	return signBoardToken(tokenPurposeUser, "", userId, 0)
+}
+
+func tokenMatches(token, expected string) bool {
+	return token != "" && hmac.Equal([]byte(token), []byte(expected))
+}
+
+// isCreatorToken checks if the token was issued to the user when they created the board.
+func isCreatorToken(b *Board, userId, token string) bool {
+	return userId == b.Creator && tokenMatches(token, creatorToken(b.Id, userId))
+}
+
+// isVerifiedOwner checks that the user is the current board owner and holds a valid credential for it.
+func isVerifiedOwner(b *Board, userId, token string) bool {
+	if b.Owner != userId {
+		return false
+	}
+	return isCreatorToken(b, userId, token) || tokenMatches(token, ownerToken(b))
+}
+
+// isVerifiedModerator checks that the user is a moderator of the board and holds the token issued with it.
+func isVerifiedModerator(boardId string, u *User, token string) bool {
+	return u != nil && u.Moderator && tokenMatches(token, moderatorToken(boardId, u))
+}
+
+// isUserToken checks that the token was issued to the user.
+func isUserToken(userId, token string) bool {
+	return tokenMatches(token, userToken(userId))
+}
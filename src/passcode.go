@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Board passcodes.
+// The owner can protect a board with a passcode at creation (CreateBoardReq.Passcode) or later through SettingsEvent.
+// Only a salted PBKDF2 hash is kept, in Board.PasscodeHash, and it is never sent to clients.
+//
+// Joining users send the passcode once, as a credential subprotocol of the websocket handshake (see handshakeCredential).
+// A successful join sets a short-lived, signed join cookie so reconnects don't need the passcode again.
+// Attempts are counted per board and IP in the store before the passcode is hashed, and taken back when it was correct.
+// Too many lock the IP out of that board for a while. Checks per IP are also limited across boards, as each one is a PBKDF2 run.
+
+const (
+	MinPasscodeLength = 4
+	MaxPasscodeLength = 64
+
+	passcodeSaltBytes = 16
+	passcodeKeyBytes  = 32
+
+	tokenPurposeJoin   = "join"
+	joinCookiePrefix   = "qr_join_"
+	defaultJoinTTL     = 4 * time.Hour
+	defaultLockout     = 15 * time.Minute
+	defaultMaxFailure  = 5
+	defaultCheckLimit  = 20
+	defaultCheckWindow = time.Minute
+)
+
+// Close reasons sent when a passcode protected board rejects the websocket. See handleWebSocket.
+const (
+	ClosePasscodeRequired = "PASSCODEREQUIRED"
+	ClosePasscodeInvalid  = "PASSCODEINVALID"
+	ClosePasscodeLocked   = "PASSCODELOCKED"
+)
+
+// Iterations for new hashes. The count is stored with each hash, so changing it doesn't break existing boards.
+var passcodeIterations = 600_000
+
+func validPasscodeLength(passcode string) bool {
+	n := utf8.RuneCountInString(passcode)
+	return n >= MinPasscodeLength && n <= MaxPasscodeLength
+}
+
+// hashPasscode returns "pbkdf2-sha256$<iterations>$<salt>$<key>", salt and key base64url encoded.
+func hashPasscode(passcode string) (string, error) {
+	salt := make([]byte, passcodeSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, passcode, salt, passcodeIterations, passcodeKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passcodeIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func verifyPasscode(encoded, passcode string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawURLEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, passcode, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// joinToken is "<expiresAtUtcSeconds>.<signature>". The signature covers the passcode hash,
+// so changing or removing the passcode invalidates all issued join cookies.
+func joinToken(b *Board, userId string, expiresAt int64) string {
+	sig := signBoardToken(tokenPurposeJoin, b.Id, userId+"\x00"+b.PasscodeHash, expiresAt)
+	return strconv.FormatInt(expiresAt, 10) + "." + sig
+}
+
+func validJoinToken(b *Board, userId, token string, now time.Time) bool {
+	exp, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || expiresAt <= now.UTC().Unix() {
+		return false
+	}
+	return tokenMatches(token, joinToken(b, userId, expiresAt))
+}
+
+func joinCookieName(boardId string) string {
+	return joinCookiePrefix + boardId
+}
+
+// joinCookie is scoped to the board's websocket path.
+func joinCookie(r *http.Request, b *Board, userId string) *http.Cookie {
+	ttl := passcodeDuration(config.Passcode.JoinTokenDuration, defaultJoinTTL)
+	expiresAt := time.Now().UTC().Add(ttl)
+	return &http.Cookie{
+		Name:     joinCookieName(b.Id),
+		Value:    joinToken(b, userId, expiresAt.Unix()),
+		Path:     "/ws/board/" + b.Id + "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// checkJoinPasscode decides if a user may join a passcode protected board.
+// Returns the close reason when rejected, or the join cookie to set when the passcode was accepted.
+// A valid join cookie is accepted without a passcode, and then no new cookie is issued.
+func checkJoinPasscode(s Store, r *http.Request, b *Board, userId string) (reason string, cookie *http.Cookie) {
+	if c, err := r.Cookie(joinCookieName(b.Id)); err == nil && validJoinToken(b, userId, c.Value, time.Now()) {
+		return "", nil
+	}
+
+	passcode := handshakeCredential(r, CredentialPasscode)
+	if passcode == "" {
+		return ClosePasscodeRequired, nil
+	}
+
+	// Checks from one IP, on any board. Counted in the store like the request limits, see ratelimiter.go
+	ip := remoteIP(r)
+	checkLimit := config.Passcode.CheckLimit
+	if checkLimit <= 0 {
+		checkLimit = defaultCheckLimit
+	}
+	checkWindow := passcodeDuration(config.Passcode.CheckWindow, defaultCheckWindow)
+	if checks, _, ok := s.CountRequest("passcode", ip, checkWindow); ok && checks > checkLimit {
+		slog.Warn("Passcode checks rate limited", "board", b.Id, "ip", ip, "checks", checks)
+		return ClosePasscodeLocked, nil
+	}
+
+	// The attempt is counted before the passcode is checked, so parallel attempts can't all get in under the limit
+	maxFailures := config.Passcode.MaxAttempts
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailure
+	}
+	attempts, ok := s.ReservePasscodeAttempt(b.Id, ip, passcodeDuration(config.Passcode.LockoutDuration, defaultLockout))
+	if !ok || attempts > int64(maxFailures) {
+		slog.Warn("Passcode attempts locked out", "board", b.Id, "ip", ip)
+		return ClosePasscodeLocked, nil
+	}
+
+	if !validPasscodeLength(passcode) || !verifyPasscode(b.PasscodeHash, passcode) {
+		slog.Warn("Invalid board passcode", "board", b.Id, "ip", ip, "failures", attempts)
+		if attempts >= int64(maxFailures) {
+			return ClosePasscodeLocked, nil
+		}
+		return ClosePasscodeInvalid, nil
+	}
+
+	s.ReleasePasscodeAttempt(b.Id, ip)
+	return "", joinCookie(r, b, userId)
+}
+
+func passcodeDuration(s string, fallback time.Duration) time.Duration {
+	if s == "" {
+		return fallback
+	}
+	d, err := parseDuration(s)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid passcode duration in config. Using default.", "value", s, "default", fallback)
+		return fallback
+	}
+	return d
+}
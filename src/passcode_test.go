@@ -0,0 +1,258 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// cheapPasscodes lowers the hashing cost, the default makes each hash take a noticeable time.
+func cheapPasscodes(t *testing.T) {
+	prev := passcodeIterations
+	passcodeIterations = 1000
+	t.Cleanup(func() { passcodeIterations = prev })
+}
+
+func TestPasscodeHash(t *testing.T) {
+	cheapPasscodes(t)
+	hash, err := hashPasscode("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(hash, "s3cret") {
+		t.Fatalf("hash contains the passcode: %s", hash)
+	}
+	if !verifyPasscode(hash, "s3cret") {
+		t.Error("correct passcode rejected")
+	}
+	if verifyPasscode(hash, "s3cret!") || verifyPasscode(hash, "") || verifyPasscode("", "s3cret") {
+		t.Error("wrong passcode accepted")
+	}
+
+	other, _ := hashPasscode("s3cret")
+	if other == hash {
+		t.Error("same passcode hashed twice gives the same hash, salt missing")
+	}
+}
+
+func TestJoinToken(t *testing.T) {
+	b := &Board{Id: "board1", PasscodeHash: "hash-1"}
+	now := time.Now()
+	token := joinToken(b, "alice", now.Add(time.Hour).Unix())
+
+	if !validJoinToken(b, "alice", token, now) {
+		t.Error("valid token rejected")
+	}
+	if validJoinToken(b, "bob", token, now) {
+		t.Error("token accepted for another user")
+	}
+	if validJoinToken(b, "alice", token, now.Add(2*time.Hour)) {
+		t.Error("expired token accepted")
+	}
+	if validJoinToken(&Board{Id: "board2", PasscodeHash: "hash-1"}, "alice", token, now) {
+		t.Error("token accepted for another board")
+	}
+	if validJoinToken(&Board{Id: "board1", PasscodeHash: "hash-2"}, "alice", token, now) {
+		t.Error("token accepted after the passcode changed")
+	}
+	// Moving the expiry invalidates the signature
+	_, sig, _ := strings.Cut(token, ".")
+	if validJoinToken(b, "alice", "9999999999."+sig, now) {
+		t.Error("token with a tampered expiry accepted")
+	}
+}
+
+type passcodeServer struct {
+	url   string
+	store *DocStore
+}
+
+func newPasscodeServer(t *testing.T, passcode string) *passcodeServer {
+	t.Helper()
+	cheapPasscodes(t)
+	prevOrigins, prevMaxText, prevPasscode := config.Server.AllowedOrigins, config.Data.MaxTextLength, config.Passcode
+	config.Server.AllowedOrigins = []string{"https://localhost"}
+	config.Data.MaxTextLength = 80
+	config.Passcode.MaxAttempts = 3
+	t.Cleanup(func() {
+		config.Server.AllowedOrigins, config.Data.MaxTextLength, config.Passcode = prevOrigins, prevMaxText, prevPasscode
+	})
+
+	hash, err := hashPasscode(passcode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	store.CreateBoard(&Board{Id: "board1", Name: "Retro", Owner: "alice", Creator: "alice", PasscodeHash: hash}, []*BoardColumn{{Id: "col01", Text: "Good", Position: 1}}, 0)
+	hub := newHub(store)
+	go hub.run()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/board/{board}/user/{user}/meet", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(hub, w, r)
+	})
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return &passcodeServer{url: "ws" + strings.TrimPrefix(srv.URL, "http"), store: store}
+}
+
+// join connects as the user and returns the close reason if the server rejected the join, and the handshake response.
+func (ps *passcodeServer) join(t *testing.T, user, query string, cookies ...*http.Cookie) (string, *http.Response) {
+	t.Helper()
+	return ps.dial(t, user, query, nil, cookies...)
+}
+
+// joinWithCredential joins with a credential offered in the handshake, e.g. the owner token.
+func (ps *passcodeServer) joinWithCredential(t *testing.T, user, name, value string) (string, *http.Response) {
+	t.Helper()
+	return ps.dial(t, user, "", []string{SubprotocolJSON, credentialSubprotocol(name, value)})
+}
+
+func (ps *passcodeServer) dial(t *testing.T, user, query string, protocols []string, cookies ...*http.Cookie) (string, *http.Response) {
+	t.Helper()
+	header := http.Header{"Origin": []string{"https://localhost"}}
+	for _, c := range cookies {
+		header.Add("Cookie", c.String())
+	}
+	u := ps.url + "/ws/board/board1/user/" + user + "/meet?nickname=" + url.QueryEscape(user) + query
+	dialer := websocket.Dialer{Subprotocols: protocols}
+	conn, resp, err := dialer.Dial(u, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = conn.ReadMessage()
+	if ce, ok := err.(*websocket.CloseError); ok {
+		return ce.Text, resp
+	}
+	return "", resp
+}
+
+func TestWebsocket_PasscodeRequired(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+
+	if reason, _ := ps.join(t, "bob", ""); reason != ClosePasscodeRequired {
+		t.Errorf("no passcode: close reason %q", reason)
+	}
+	if reason, _ := ps.joinWithCredential(t, "bob", CredentialPasscode, "wrong"); reason != ClosePasscodeInvalid {
+		t.Errorf("wrong passcode: close reason %q", reason)
+	}
+
+	reason, resp := ps.joinWithCredential(t, "bob", CredentialPasscode, "s3cret")
+	if reason != "" {
+		t.Fatalf("correct passcode rejected with %q", reason)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == joinCookieName("board1") {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("join cookie not set: %v", resp.Header["Set-Cookie"])
+	}
+
+	// Reconnects use the cookie
+	if reason, _ := ps.join(t, "bob", "", cookie); reason != "" {
+		t.Errorf("join cookie rejected with %q", reason)
+	}
+	// Cookies are per user
+	if reason, _ := ps.join(t, "carol", "", cookie); reason != ClosePasscodeRequired {
+		t.Errorf("another user's cookie: close reason %q", reason)
+	}
+	// The owner doesn't need the passcode
+	if reason, _ := ps.joinWithCredential(t, "alice", CredentialOwnerToken, creatorToken("board1", "alice")); reason != "" {
+		t.Errorf("owner rejected with %q", reason)
+	}
+}
+
+func TestWebsocket_PasscodeLockout(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+
+	for range 2 {
+		if reason, _ := ps.joinWithCredential(t, "mallory", CredentialPasscode, "guess"); reason != ClosePasscodeInvalid {
+			t.Fatalf("close reason %q, want %q", reason, ClosePasscodeInvalid)
+		}
+	}
+	if reason, _ := ps.joinWithCredential(t, "mallory", CredentialPasscode, "guess"); reason != ClosePasscodeLocked {
+		t.Errorf("third failure: close reason %q, want %q", reason, ClosePasscodeLocked)
+	}
+	// Locked out even with the right passcode, and with another user id
+	if reason, _ := ps.joinWithCredential(t, "bob", CredentialPasscode, "s3cret"); reason != ClosePasscodeLocked {
+		t.Errorf("after lockout: close reason %q, want %q", reason, ClosePasscodeLocked)
+	}
+}
+
+func TestWebsocket_PasscodeNotReadFromQuery(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+
+	if reason, _ := ps.join(t, "bob", "&passcode=s3cret"); reason != ClosePasscodeRequired {
+		t.Errorf("passcode in the URL: close reason %q, want %q", reason, ClosePasscodeRequired)
+	}
+}
+
+// Parallel attempts must not all get under max_attempts before any of them is counted
+func TestCheckJoinPasscode_ParallelAttempts(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+	b, _ := ps.store.GetBoard("board1")
+
+	results := make(chan string, 20)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/ws/board/board1/user/mallory/meet", nil)
+			r.Header.Set("Sec-WebSocket-Protocol", credentialSubprotocol(CredentialPasscode, "guess"))
+			reason, _ := checkJoinPasscode(ps.store, r, b, "mallory")
+			results <- reason
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	invalid := 0
+	for reason := range results {
+		if reason == ClosePasscodeInvalid {
+			invalid++
+		}
+	}
+	// max_attempts is 3. The third failure locks.
+	if invalid != 2 {
+		t.Errorf("%d attempts checked before the lockout, want 2", invalid)
+	}
+}
+
+func TestCheckJoinPasscode_CheckLimitPerIP(t *testing.T) {
+	ps := newPasscodeServer(t, "s3cret")
+	config.Passcode.MaxAttempts = 100
+	config.Passcode.CheckLimit = 2
+	b, _ := ps.store.GetBoard("board1")
+
+	check := func(passcode string) string {
+		r := httptest.NewRequest(http.MethodGet, "/ws/board/board1/user/bob/meet", nil)
+		r.Header.Set("Sec-WebSocket-Protocol", credentialSubprotocol(CredentialPasscode, passcode))
+		reason, _ := checkJoinPasscode(ps.store, r, b, "bob")
+		return reason
+	}
+	if reason := check("s3cret"); reason != "" {
+		t.Fatalf("first check: close reason %q", reason)
+	}
+	if reason := check("guess"); reason != ClosePasscodeInvalid {
+		t.Fatalf("second check: close reason %q", reason)
+	}
+	// Over the limit, the passcode isn't checked at all
+	if reason := check("s3cret"); reason != ClosePasscodeLocked {
+		t.Errorf("third check: close reason %q, want %q", reason, ClosePasscodeLocked)
+	}
+}
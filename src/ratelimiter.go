@@ -1,6 +1,10 @@
 package main
 
 import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -25,6 +29,11 @@ func NewClientRateLimiter(burst int, refillInterval time.Duration) *ClientRateLi
 
 // Allow checks if the client is within the rate limit. NOT thread-safe.
 func (cl *ClientRateLimiter) Allow() bool {
+	return cl.AllowN(1)
+}
+
+// AllowN takes n tokens if the client has them. NOT thread-safe.
+func (cl *ClientRateLimiter) AllowN(n int) bool {
 	now := time.Now()
 
 	// Refill tokens based on elapsed time
@@ -35,10 +44,146 @@ func (cl *ClientRateLimiter) Allow() bool {
 		cl.lastRefill = now
 	}
 
-	if cl.tokens > 0 {
-		cl.tokens--
+	if cl.tokens >= n {
+		cl.tokens -= n
 		return true
 	}
 
 	return false
 }
+
+// CloseRateLimited is the close reason for clients disconnected after too many strikes.
+const CloseRateLimited = "RATE_LIMITED"
+
+// ClientLimits is the rate limiting of one websocket connection.
+// Each event is charged its configured cost (default 1) against its bucket. Typing events have their own bucket,
+// so typing can't use up the tokens for messages, and a flood of typing events doesn't need to be tolerated.
+// Every dropped event is a strike. Too many strikes within the strike window disconnects the client.
+// NOT thread-safe - used by the client's read loop only.
+type ClientLimits struct {
+	now          func() time.Time
+	events       *ClientRateLimiter
+	typing       *ClientRateLimiter // nil when typing shares the events bucket
+	costs        map[string]int
+	firstStrike  time.Time
+	strikeWindow time.Duration
+	strikes      int
+	maxStrikes   int // 0 never disconnects
+}
+
+// newClientLimits creates the limits for a connection from config. Returns nil if websocket rate limiting is disabled.
+func newClientLimits() *ClientLimits {
+	cfg := config.Websocket.RateLimit
+	if !cfg.Enabled {
+		return nil
+	}
+	refill, err := parseDuration(cfg.RefillInterval)
+	if err != nil || refill <= 0 || cfg.Burst <= 0 {
+		return nil
+	}
+	l := &ClientLimits{
+		now:        time.Now,
+		events:     NewClientRateLimiter(cfg.Burst, refill),
+		costs:      cfg.Costs,
+		maxStrikes: cfg.MaxStrikes,
+	}
+	if typingRefill, err := parseDuration(cfg.TypingRefillInterval); err == nil && typingRefill > 0 && cfg.TypingBurst > 0 {
+		l.typing = NewClientRateLimiter(cfg.TypingBurst, typingRefill)
+	}
+	if l.strikeWindow, err = parseDuration(cfg.StrikeWindow); err != nil || l.strikeWindow <= 0 {
+		l.strikeWindow = time.Minute
+	}
+	return l
+}
+
+// clampEventCosts lowers configured costs that are above the burst of their bucket. Such events could never pass,
+// every send would be dropped as a strike until the client is disconnected.
+func clampEventCosts() {
+	cfg := &config.Websocket.RateLimit
+	typingBurst := 0
+	if typingRefill, err := parseDuration(cfg.TypingRefillInterval); err == nil && typingRefill > 0 {
+		typingBurst = cfg.TypingBurst
+	}
+	for eventType, cost := range cfg.Costs {
+		burst := cfg.Burst
+		if eventType == "t" && typingBurst > 0 {
+			burst = typingBurst
+		}
+		if cost > burst {
+			slog.Error("Websocket rate limit cost is above the burst, the event could never pass. Using the burst instead", "event", eventType, "cost", cost, "burst", burst)
+			cfg.Costs[eventType] = burst
+		}
+	}
+}
+
+// Allow charges the event. It returns false if the event must be dropped,
+// and disconnect when the client has run out of strikes.
+func (l *ClientLimits) Allow(eventType string) (allowed, disconnect bool) {
+	cost := 1
+	if c, ok := l.costs[eventType]; ok && c >= 0 {
+		cost = c
+	}
+	bucket := l.events
+	if eventType == "t" && l.typing != nil {
+		bucket = l.typing
+	}
+	if bucket.AllowN(cost) {
+		return true, false
+	}
+
+	now := l.now()
+	if l.strikes == 0 || now.Sub(l.firstStrike) > l.strikeWindow {
+		l.strikes, l.firstStrike = 0, now
+	}
+	l.strikes++
+	return false, l.maxStrikes > 0 && l.strikes >= l.maxStrikes
+}
+
+// RequestLimitConfig is the number of requests allowed from one IP in a window.
+type RequestLimitConfig struct {
+	Window   string `toml:"window"`
+	Requests int64  `toml:"requests"`
+}
+
+// requestLimiter limits HTTP requests per client IP, for board creation and websocket handshakes.
+// Counts are kept in the store, so the limit holds across all instances sharing a Redis.
+// ClientRateLimiter takes over once the websocket is open.
+type requestLimiter struct {
+	store    Store
+	scope    string
+	requests int64
+	window   time.Duration
+}
+
+// newRequestLimiter returns nil when HTTP rate limiting is disabled or the limit is not set. A nil limiter allows everything.
+func newRequestLimiter(store Store, scope string, cfg RequestLimitConfig) *requestLimiter {
+	if !config.HTTPRateLimit.Enabled || cfg.Requests <= 0 {
+		return nil
+	}
+	window, err := parseDuration(cfg.Window)
+	if err != nil || window <= 0 {
+		slog.Warn("Invalid rate limit window in config. Rate limit disabled.", "scope", scope, "window", cfg.Window)
+		return nil
+	}
+	slog.Info("HTTP rate limiting enabled", "scope", scope, "requests", cfg.Requests, "window", window)
+	return &requestLimiter{store: store, scope: scope, requests: cfg.Requests, window: window}
+}
+
+// wrap rejects requests over the limit with 429 Too Many Requests and a Retry-After in seconds.
+// If the store can't count the request, it is let through. A store outage shouldn't lock everyone out.
+func (l *requestLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := remoteIP(r)
+		count, retryAfter, ok := l.store.CountRequest(l.scope, ip, l.window)
+		if ok && count > l.requests {
+			slog.Warn("Rate limit exceeded", "scope", l.scope, "ip", ip, "requests", count)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	prev := config.HTTPRateLimit
+	t.Cleanup(func() { config.HTTPRateLimit = prev })
+	config.HTTPRateLimit.Enabled = true
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	l := newRequestLimiter(store, "create", RequestLimitConfig{Requests: 2, Window: "1m"})
+	handler := l.wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	do := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/board/create", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do("10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+	}
+	w := do("10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	// Other IPs are not affected
+	if w := do("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other ip: status %d", w.Code)
+	}
+}
+
+func TestRequestLimiter_BehindProxy(t *testing.T) {
+	prevLimit, prevProxies := config.HTTPRateLimit, trustedProxies
+	t.Cleanup(func() { config.HTTPRateLimit, trustedProxies = prevLimit, prevProxies })
+	config.HTTPRateLimit.Enabled = true
+	trustedProxies, _ = parseTrustedProxies([]string{"172.16.0.0/12"})
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	l := newRequestLimiter(store, "create", RequestLimitConfig{Requests: 1, Window: "1m"})
+	handler := l.wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	do := func(client string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/board/create", nil)
+		r.RemoteAddr = "172.18.0.5:40000"
+		r.Header.Set("X-Forwarded-For", client)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	// Clients behind the same proxy are limited on their own
+	if code := do("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first client: status %d", code)
+	}
+	if code := do("198.51.100.2"); code != http.StatusOK {
+		t.Errorf("second client: status %d", code)
+	}
+	if code := do("198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("first client again: status %d, want 429", code)
+	}
+}
+
+func TestRequestLimiter_Disabled(t *testing.T) {
+	prev := config.HTTPRateLimit
+	t.Cleanup(func() { config.HTTPRateLimit = prev })
+
+	config.HTTPRateLimit.Enabled = false
+	if l := newRequestLimiter(nil, "create", RequestLimitConfig{Requests: 1, Window: "1m"}); l != nil {
+		t.Error("limiter created while disabled")
+	}
+	config.HTTPRateLimit.Enabled = true
+	if l := newRequestLimiter(nil, "create", RequestLimitConfig{Requests: 1, Window: "soon"}); l != nil {
+		t.Error("limiter created with invalid window")
+	}
+}
+
+func TestClientLimits(t *testing.T) {
+	prev := config.Websocket.RateLimit
+	t.Cleanup(func() { config.Websocket.RateLimit = prev })
+	rl := &config.Websocket.RateLimit
+	rl.Enabled = true
+	rl.Burst, rl.RefillInterval = 10, "1h"
+	rl.TypingBurst, rl.TypingRefillInterval = 2, "1h"
+	rl.MaxStrikes, rl.StrikeWindow = 3, "1m"
+	rl.Costs = map[string]int{"delall": 10}
+
+	l := newClientLimits()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	// Typing has its own bucket
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("t"); !ok {
+			t.Fatalf("typing event %d dropped", i)
+		}
+	}
+	if ok, _ := l.Allow("t"); ok {
+		t.Fatal("typing event allowed over its burst")
+	}
+	// Costly events take their cost. One message uses the token delall would need.
+	if ok, _ := l.Allow("msg"); !ok {
+		t.Fatal("message dropped after typing burst")
+	}
+	if ok, _ := l.Allow("delall"); ok {
+		t.Fatal("delall allowed with 9 tokens left")
+	}
+	// Third strike disconnects
+	if _, disconnect := l.Allow("t"); !disconnect {
+		t.Fatal("not disconnected after max strikes")
+	}
+
+	// Strikes outside the window are forgotten
+	l.strikes = 0
+	l.Allow("t")
+	now = now.Add(2 * time.Minute)
+	l.Allow("t")
+	if _, disconnect := l.Allow("t"); disconnect {
+		t.Error("disconnected with strikes spread over two windows")
+	}
+}
+
+func TestClampEventCosts(t *testing.T) {
+	prev := config.Websocket.RateLimit
+	t.Cleanup(func() { config.Websocket.RateLimit = prev })
+	rl := &config.Websocket.RateLimit
+	rl.Enabled = true
+	rl.Burst, rl.RefillInterval = 5, "1h"
+	rl.TypingBurst, rl.TypingRefillInterval = 2, "1h"
+	rl.Costs = map[string]int{"delall": 10, "colreset": 5, "t": 3, "kick": 2}
+
+	clampEventCosts()
+	want := map[string]int{"delall": 5, "colreset": 5, "t": 2, "kick": 2}
+	for eventType, cost := range want {
+		if rl.Costs[eventType] != cost {
+			t.Errorf("cost of %s = %d, want %d", eventType, rl.Costs[eventType], cost)
+		}
+	}
+
+	// A clamped event passes with a full bucket
+	l := newClientLimits()
+	if ok, _ := l.Allow("delall"); !ok {
+		t.Error("delall dropped with a full bucket")
+	}
+}
+
+func TestClientLimits_Disabled(t *testing.T) {
+	prev := config.Websocket.RateLimit
+	t.Cleanup(func() { config.Websocket.RateLimit = prev })
+	config.Websocket.RateLimit.Enabled = false
+	if newClientLimits() != nil {
+		t.Error("limits created while disabled")
+	}
+}
+
+func TestRateLimitedEvent_NotifiesOwnerOnly(t *testing.T) {
+	tb := newTestBoard(t)
+	owner := tb.joinAsCreator("owner", "Owner")
+	bob := tb.join("bob", "Bob")
+	carol := tb.join("carol", "Carol")
+
+	// Clients can't raise it
+	tb.send(carol, "ratelimited", RateLimitedEvent{Group: tb.board.Id, Xid: bob.xid})
+	if r := receive(owner); r != nil {
+		t.Fatalf("client-sent event reached the owner: %+v", r)
+	}
+
+	(&RateLimitedEvent{Group: tb.board.Id, Xid: bob.xid}).Handle(nil, tb.hub)
+	tb.hub.dispatch(<-tb.store.Broadcasts())
+	if res, ok := receive(owner).(*RateLimitedResponse); !ok || res.Xid != bob.xid {
+		t.Errorf("owner received %+v", res)
+	}
+	if r := receive(carol); r != nil {
+		t.Errorf("other user received %+v", r)
+	}
+}
@@ -1,55 +1,55 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
-	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisConnector struct {
-	ctx        context.Context
-	client     *redis.Client
-	subscriber *redis.PubSub
-	timeToLive time.Duration
+	ctx            context.Context
+	client         redis.UniversalClient
+	subscriber     *redis.PubSub
+	broadcasts     chan string
+	broadcastsOnce sync.Once
+	timeToLive     time.Duration
+	crypt          *fieldCipher // Encryption at rest, nil when disabled. See encryption.go
 }
 
 func NewRedisConnector(ctx context.Context, timeToLive time.Duration) *RedisConnector {
-
-	// rdb := redis.NewClient(&redis.Options{
-	// 	Addr:     "localhost:6379",
-	// 	Password: "", // no password set
-	// 	DB:       0,  // use default DB
-	// })
-
-	opt, err := redis.ParseURL(envConfig.RedisConnStr)
+	// Standalone, Sentinel or Cluster client. Chosen with REDIS_MODE.
+	rdb, err := newRedisUniversalClient(envConfig)
 	if err != nil {
-		slog.Error("Cannot parse Redis connection string", "err", err)
+		slog.Error("Cannot parse Redis connection settings", "err", err, "mode", envConfig.RedisMode)
+		os.Exit(1)
 	}
 
-	// Todo: Add auth and pull from config
-	rdb := redis.NewUniversalClient(&redis.UniversalOptions{
-		// Addrs:    []string{":6379"},
-		// Addrs:    []string{"my-redis:6379"},
-		Addrs:    []string{opt.Addr},
-		Username: opt.Username,
-		Password: opt.Password,
-		DB:       opt.DB,
-	})
-
 	_, err = rdb.Ping(ctx).Result()
 	if err != nil {
 		slog.Error("Cannot connect to Redis", "err", err)
 		os.Exit(1)
 	}
 
-	// return rdb.(*redis.Client), rdb.(*redis.Client).Subscribe(ctx)
-	return &RedisConnector{client: rdb.(*redis.Client), subscriber: rdb.(*redis.Client).Subscribe(ctx), timeToLive: timeToLive, ctx: ctx}
+	c := newRedisConnector(ctx, rdb, timeToLive)
+	if c.crypt, err = newFieldCipher(envConfig.EncryptionKeys); err != nil {
+		slog.Error("Invalid ENCRYPTION_KEYS", "err", err)
+		os.Exit(1)
+	}
+	if c.crypt != nil {
+		slog.Info("Encryption at rest enabled", "activeKeyId", c.crypt.activeId)
+	}
+	return c
+}
+
+func newRedisConnector(ctx context.Context, rdb redis.UniversalClient, timeToLive time.Duration) *RedisConnector {
+	return &RedisConnector{client: rdb, subscriber: rdb.Subscribe(ctx), timeToLive: timeToLive, ctx: ctx}
 }
 
 func (c *RedisConnector) Subscribe(redisChannel ...string) {
@@ -74,19 +74,33 @@ func (c *RedisConnector) Publish(redisChannel string, payload any) {
 	}
 }
 
-func (c *RedisConnector) CreateBoard(b *Board, cols []*BoardColumn) bool {
+// Broadcasts relays payloads received on subscribed Redis channels.
+func (c *RedisConnector) Broadcasts() <-chan string {
+	c.broadcastsOnce.Do(func() {
+		c.broadcasts = make(chan string, 100)
+		go func() {
+			defer close(c.broadcasts)
+			for msg := range c.subscriber.Channel() {
+				c.broadcasts <- msg.Payload
+			}
+		}()
+	})
+	return c.broadcasts
+}
+
+func (c *RedisConnector) CreateBoard(b *Board, cols []*BoardColumn, retention time.Duration) bool {
 	key := boardKey(b.Id)
 	boardColsKey := boardColsKey(b.Id) // Boardwise-ColIds
 
 	currentTime := time.Now().UTC()
 	currentTimeUtcSeconds := currentTime.Unix()
-	autoDeleteTime := currentTime.Add(c.timeToLive)
+	autoDeleteTime := currentTime.Add(cmp.Or(retention, c.timeToLive))
 	autoDeleteTimeUtcSeconds := autoDeleteTime.Unix()
 
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		pipe.HSet(c.ctx, key,
 			"id", b.Id,
-			"name", b.Name,
+			"name", c.crypt.seal(b.Name, b.Id, boardRecord(), "name"),
 			"team", b.Team,
 			"owner", b.Owner,
 			"creator", b.Creator,
@@ -95,22 +109,31 @@ func (c *RedisConnector) CreateBoard(b *Board, cols []*BoardColumn) bool {
 			"lock", b.Lock,
 			"createdAtUtc", currentTimeUtcSeconds,
 			"autoDeleteAtUtc", autoDeleteTimeUtcSeconds,
+			"passcodeHash", b.PasscodeHash,
+			"maxContentLength", b.MaxContentLength,
+			"e2ee", b.E2EE,
+			"teamId", b.TeamId,
 		)
 		// Columns
 		for _, col := range cols {
 			colKey := boardColKey(b.Id, col.Id)
 			pipe.HSet(c.ctx, colKey,
 				"id", col.Id,
-				"text", col.Text,
+				"text", c.crypt.seal(col.Text, b.Id, columnRecord(col.Id), "text"),
 				"isDefault", col.IsDefault,
 				"color", col.Color,
 				"pos", col.Position,
+				"maxCards", col.MaxCards,
+				"mask", col.Mask,
+				"lock", col.Lock,
+				"desc", c.crypt.seal(col.Description, b.Id, columnRecord(col.Id), "desc"),
 			)
 			pipe.ExpireAt(c.ctx, colKey, autoDeleteTime)
 			pipe.SAdd(c.ctx, boardColsKey, col.Id)
 		}
 		pipe.ExpireAt(c.ctx, boardColsKey, autoDeleteTime)
 		pipe.ExpireAt(c.ctx, key, autoDeleteTime)
+		pipe.ZAdd(c.ctx, boardsExpiryKey(), redis.Z{Score: float64(autoDeleteTimeUtcSeconds), Member: b.Id})
 		return nil
 	})
 
@@ -118,54 +141,11 @@ func (c *RedisConnector) CreateBoard(b *Board, cols []*BoardColumn) bool {
 		slog.Error("Failed to create board in Redis", "err", err, "board", b, "cols", cols)
 		return false
 	}
+	c.addUserBoard(b.Id, autoDeleteTime, b.Creator, b.Owner)
 
 	return true
 }
 
-func (c *RedisConnector) HasMessagesForColumnsMarkedForRemoval(boardId string, oldCols []*BoardColumn, newCols []*BoardColumn) (bool, error) {
-	// Compare oldCols vs newCols to find colIds that existed before but no longer exist.
-	oldMap := make(map[string]struct{}, len(oldCols))
-	newMap := make(map[string]struct{}, len(newCols))
-	for _, c := range oldCols {
-		oldMap[c.Id] = struct{}{}
-	}
-	for _, c := range newCols {
-		newMap[c.Id] = struct{}{}
-	}
-
-	var missingIds []string // missingIds are ids of columns marked for removal
-	for oldId := range oldMap {
-		if _, stillExists := newMap[oldId]; !stillExists {
-			missingIds = append(missingIds, oldId)
-		}
-	}
-
-	if len(missingIds) == 0 {
-		return false, nil
-	}
-
-	// Fetch messages
-	messages, ok := c.GetMessages(boardId)
-	if !ok {
-		return false, fmt.Errorf("unable to fetch messages for board %s", boardId)
-	}
-
-	// Convert list to a set for quick lookup
-	disableSet := make(map[string]struct{}, len(missingIds))
-	for _, id := range missingIds {
-		disableSet[id] = struct{}{}
-	}
-
-	// Check messages: any message belonging to a disabled column?
-	for _, m := range messages {
-		if _, exists := disableSet[m.Category]; exists {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
-
 func (c *RedisConnector) ResetBoardColumns(b *Board, oldCols []*BoardColumn, newCols []*BoardColumn) bool {
 	boardColsKey := boardColsKey(b.Id)
 	autoDeleteTime := time.Unix(b.AutoDeleteAtUtc, 0).UTC()
@@ -200,10 +180,14 @@ func (c *RedisConnector) ResetBoardColumns(b *Board, oldCols []*BoardColumn, new
 			if !existed {
 				pipe.HSet(c.ctx, colKey,
 					"id", newCol.Id,
-					"text", newCol.Text,
+					"text", c.crypt.seal(newCol.Text, b.Id, columnRecord(newCol.Id), "text"),
 					"isDefault", newCol.IsDefault,
 					"color", newCol.Color,
 					"pos", newCol.Position,
+					"maxCards", newCol.MaxCards,
+					"mask", newCol.Mask,
+					"lock", newCol.Lock,
+					"desc", c.crypt.seal(newCol.Description, b.Id, columnRecord(newCol.Id), "desc"),
 				)
 				pipe.ExpireAt(c.ctx, colKey, autoDeleteTime)
 				continue
@@ -213,7 +197,7 @@ func (c *RedisConnector) ResetBoardColumns(b *Board, oldCols []*BoardColumn, new
 			var changes []any
 
 			if oldCol.Text != newCol.Text {
-				changes = append(changes, "text", newCol.Text)
+				changes = append(changes, "text", c.crypt.seal(newCol.Text, b.Id, columnRecord(newCol.Id), "text"))
 			}
 			if oldCol.Color != newCol.Color {
 				changes = append(changes, "color", newCol.Color)
@@ -224,6 +208,18 @@ func (c *RedisConnector) ResetBoardColumns(b *Board, oldCols []*BoardColumn, new
 			if oldCol.Position != newCol.Position {
 				changes = append(changes, "pos", newCol.Position)
 			}
+			if oldCol.MaxCards != newCol.MaxCards {
+				changes = append(changes, "maxCards", newCol.MaxCards)
+			}
+			if oldCol.Mask != newCol.Mask {
+				changes = append(changes, "mask", newCol.Mask)
+			}
+			if oldCol.Lock != newCol.Lock {
+				changes = append(changes, "lock", newCol.Lock)
+			}
+			if oldCol.Description != newCol.Description {
+				changes = append(changes, "desc", c.crypt.seal(newCol.Description, b.Id, columnRecord(newCol.Id), "desc"))
+			}
 
 			if len(changes) > 0 {
 				pipe.HSet(c.ctx, colKey, changes...)
@@ -250,6 +246,45 @@ func (c *RedisConnector) ResetBoardColumns(b *Board, oldCols []*BoardColumn, new
 	return true
 }
 
+// MoveBoardColumns sets the positions of the columns to their index in order.
+// The column set is watched, so a concurrent column reset makes the transaction retry, and the order is checked again.
+func (c *RedisConnector) MoveBoardColumns(b *Board, order []string) bool {
+	boardColsKey := boardColsKey(b.Id)
+	moved := false
+
+	update := func(tx *redis.Tx) error {
+		colIds, err := tx.SMembers(c.ctx, boardColsKey).Result()
+		if err != nil {
+			return err
+		}
+		if !sameColumnIds(colIds, order) {
+			moved = false
+			return nil
+		}
+		_, err = tx.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			for i, colId := range order {
+				pipe.HSet(c.ctx, boardColKey(b.Id, colId), "pos", i+1)
+			}
+			return nil
+		})
+		moved = err == nil
+		return err
+	}
+
+	for range 3 {
+		err := c.client.Watch(c.ctx, update, boardColsKey)
+		if err == nil {
+			return moved
+		}
+		if err != redis.TxFailedErr {
+			slog.Error("Failed to move columns", "err", err, "board", b.Id)
+			return false
+		}
+	}
+	slog.Error("Failed to move columns, the columns kept changing", "board", b.Id)
+	return false
+}
+
 func (c *RedisConnector) UpdateMasking(b *Board, mask bool) bool {
 	// Todo: Deduplicate with UpdateBoardLock() & UpdateTimer()
 	key := boardKey(b.Id)
@@ -262,10 +297,27 @@ func (c *RedisConnector) UpdateMasking(b *Board, mask bool) bool {
 
 func (c *RedisConnector) UpdateBoardOwner(b *Board, owner string) bool {
 	key := boardKey(b.Id)
-	if _, err := c.client.HSet(c.ctx, key, "owner", owner).Result(); err != nil {
+	var epoch *redis.IntCmd
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, key, "owner", owner)
+		epoch = pipe.HIncrBy(c.ctx, key, "ownerEpoch", 1)
+		return nil
+	})
+	if err != nil {
 		slog.Error("Failed to update board owner", "err", err, "board", b)
 		return false
 	}
+	b.OwnerEpoch = epoch.Val()
+	c.addUserBoard(b.Id, c.boardExpireAt(b.Id), owner)
+	return true
+}
+
+func (c *RedisConnector) UpdateBoardPasscode(b *Board, passcodeHash string) bool {
+	key := boardKey(b.Id)
+	if _, err := c.client.HSet(c.ctx, key, "passcodeHash", passcodeHash).Result(); err != nil {
+		slog.Error("Failed to update board passcode", "err", err, "board", b.Id)
+		return false
+	}
 	return true
 }
 
@@ -306,6 +358,238 @@ func (c *RedisConnector) StopTimer(b *Board) bool {
 	return true
 }
 
+// UpdateBoardExpiry moves the expiry of every key of the board to expiresAtUtc.
+// The index sets are watched, so keys added concurrently (e.g. a new card) make the transaction retry instead of keeping their old TTL.
+// Invites keep their own expiry when it is earlier. Passcode failures aren't indexed, and expire with their short lockout window anyway.
+func (c *RedisConnector) UpdateBoardExpiry(b *Board, expiresAtUtc int64) bool {
+	boardId := b.Id
+	expireAt := time.Unix(expiresAtUtc, 0)
+	indexKeys := []string{
+		boardKey(boardId),
+		boardMsgsKey(boardId),
+		boardCmtsKey(boardId),
+		boardAllUsersKey(boardId),
+		boardColsKey(boardId),
+		boardInvitesKey(boardId),
+	}
+	var userIds []string
+
+	update := func(tx *redis.Tx) error {
+		// Read phase
+		readPipe := tx.Pipeline()
+		msgsCmd := readPipe.SMembers(c.ctx, boardMsgsKey(boardId))
+		cmtsCmd := readPipe.SMembers(c.ctx, boardCmtsKey(boardId))
+		usrsCmd := readPipe.SMembers(c.ctx, boardAllUsersKey(boardId))
+		colsCmd := readPipe.SMembers(c.ctx, boardColsKey(boardId))
+		invsCmd := readPipe.SMembers(c.ctx, boardInvitesKey(boardId))
+		if _, err := readPipe.Exec(c.ctx); err != nil {
+			return err
+		}
+		inviteIds := invsCmd.Val()
+		userIds = usrsCmd.Val()
+		invExpiryCmds := make([]*redis.StringCmd, len(inviteIds))
+		if len(inviteIds) > 0 {
+			invPipe := tx.Pipeline()
+			for i, inviteId := range inviteIds {
+				invExpiryCmds[i] = invPipe.HGet(c.ctx, boardInviteKey(boardId, inviteId), "expiresAtUtc")
+			}
+			if _, err := invPipe.Exec(c.ctx); err != nil && err != redis.Nil {
+				return err
+			}
+		}
+
+		// Write phase, atomically
+		_, err := tx.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(c.ctx, boardKey(boardId), "autoDeleteAtUtc", expiresAtUtc)
+
+			keys := []string{
+				boardKey(boardId),
+				boardMsgsKey(boardId),
+				boardPinnedMsgsKey(boardId),
+				boardCmtsKey(boardId),
+				boardUsersPresenceKey(boardId),
+				boardAllUsersKey(boardId),
+				boardUserXidKey(boardId),
+				boardColsKey(boardId),
+				boardInvitesKey(boardId),
+				boardBannedKey(boardId),
+			}
+			for _, msgId := range msgsCmd.Val() {
+				keys = append(keys, msgKey(boardId, msgId), msgLikesKey(boardId, msgId))
+			}
+			for _, cmtId := range cmtsCmd.Val() {
+				keys = append(keys, msgKey(boardId, cmtId))
+			}
+			for _, userId := range usrsCmd.Val() {
+				keys = append(keys, boardUserKey(boardId, userId))
+			}
+			for _, colId := range colsCmd.Val() {
+				keys = append(keys, boardColKey(boardId, colId))
+			}
+			for _, key := range keys {
+				pipe.ExpireAt(c.ctx, key, expireAt)
+			}
+
+			for i, inviteId := range inviteIds {
+				inviteExpireAt := expireAt
+				if inviteExpiry, err := invExpiryCmds[i].Int64(); err == nil && inviteExpiry < expiresAtUtc {
+					inviteExpireAt = time.Unix(inviteExpiry, 0)
+				}
+				pipe.ExpireAt(c.ctx, boardInviteKey(boardId, inviteId), inviteExpireAt)
+				pipe.ExpireAt(c.ctx, boardInviteUsersKey(boardId, inviteId), inviteExpireAt)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for range 3 {
+		err := c.client.Watch(c.ctx, update, indexKeys...)
+		if err == nil {
+			// Outside of the transaction, the index isn't in the board's cluster slot
+			if err := c.client.ZAdd(c.ctx, boardsExpiryKey(), redis.Z{Score: float64(expiresAtUtc), Member: boardId}).Err(); err != nil {
+				slog.Error("Failed to update board in expiry index", "err", err, "board", boardId)
+			}
+			c.addUserBoard(boardId, expireAt, append(userIds, b.Creator, b.Owner)...)
+			return true
+		}
+		if err != redis.TxFailedErr {
+			slog.Error("Failed to update board expiry", "err", err, "board", boardId)
+			return false
+		}
+	}
+	slog.Error("Failed to update board expiry, the board kept changing", "board", boardId)
+	return false
+}
+
+// addUserBoard adds the board to the boards index of the users, or moves it to the board's new expiry.
+// The index keys expire with the latest board in them. Best-effort: the index is only used for listing.
+func (c *RedisConnector) addUserBoard(boardId string, expireAt time.Time, userIds ...string) {
+	added := make(map[string]bool, len(userIds))
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for _, userId := range userIds {
+			if userId == "" || added[userId] {
+				continue
+			}
+			added[userId] = true
+			key := userBoardsKey(userId)
+			pipe.ZAdd(c.ctx, key, redis.Z{Score: float64(expireAt.Unix()), Member: boardId})
+			// GT alone never sets an expiry on a key without one
+			pipe.ExpireNX(c.ctx, key, time.Until(expireAt))
+			pipe.ExpireGT(c.ctx, key, time.Until(expireAt))
+			// The user token mark always has an expiry, see AckUserToken
+			pipe.ExpireGT(c.ctx, userTokenKey(userId), time.Until(expireAt))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to add board to user boards index", "err", err, "board", boardId)
+	}
+}
+
+// GetUserBoards lists boards from the user's index. Expired and deleted boards are removed from it.
+func (c *RedisConnector) GetUserBoards(userId string) ([]*Board, bool) {
+	key := userBoardsKey(userId)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := c.client.ZRemRangeByScore(c.ctx, key, "-inf", "("+now).Err(); err != nil {
+		slog.Error("Failed to prune user boards index", "err", err, "user", userId)
+	}
+	boardIds, err := c.client.ZRange(c.ctx, key, 0, -1).Result()
+	if err != nil {
+		slog.Error("Failed to get user boards", "err", err, "user", userId)
+		return nil, false
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(boardIds))
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, boardId := range boardIds {
+			cmds[i] = pipe.HGetAll(c.ctx, boardKey(boardId))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to get user boards", "err", err, "user", userId)
+		return nil, false
+	}
+
+	boards := make([]*Board, 0, len(boardIds))
+	var gone []any
+	for i, boardId := range boardIds {
+		var b Board
+		if err := cmds[i].Scan(&b); err != nil || b.Id == "" {
+			gone = append(gone, boardId)
+			continue
+		}
+		c.decryptBoard(&b)
+		boards = append(boards, &b)
+	}
+	if len(gone) > 0 {
+		if err := c.client.ZRem(c.ctx, key, gone...).Err(); err != nil {
+			slog.Error("Failed to prune user boards index", "err", err, "user", userId)
+		}
+	}
+	sortUserBoards(boards)
+	return boards, true
+}
+
+// ackUserTokenScript marks the user token as received, expiring with the boards index (or after ARGV[1] ms without one).
+// KEYS[1] user token mark, KEYS[2] user boards index. Returns 1 when the mark was set.
+var ackUserTokenScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl <= 0 then
+	ttl = tonumber(ARGV[1])
+end
+redis.call('SET', KEYS[1], '1', 'PX', ttl)
+return 1
+`)
+
+func (c *RedisConnector) UserTokenPending(userId string) bool {
+	acked, err := c.client.Exists(c.ctx, userTokenKey(userId)).Result()
+	if err != nil {
+		slog.Error("Failed to get user token mark", "err", err, "user", userId)
+		return false
+	}
+	return acked == 0
+}
+
+func (c *RedisConnector) AckUserToken(userId string) bool {
+	keys := []string{userTokenKey(userId), userBoardsKey(userId)}
+	if err := ackUserTokenScript.Run(c.ctx, c.client, keys, c.timeToLive.Milliseconds()).Err(); err != nil {
+		slog.Error("Failed to acknowledge user token", "err", err, "user", userId)
+		return false
+	}
+	return true
+}
+
+// BoardsExpiringBefore lists boards from the expiry index. Boards that have already expired are removed from it.
+func (c *RedisConnector) BoardsExpiringBefore(t time.Time) ([]string, bool) {
+	key := boardsExpiryKey()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := c.client.ZRemRangeByScore(c.ctx, key, "-inf", "("+now).Err(); err != nil {
+		slog.Error("Failed to prune board expiry index", "err", err)
+	}
+	ids, err := c.client.ZRangeByScore(c.ctx, key, &redis.ZRangeBy{Min: now, Max: strconv.FormatInt(t.Unix(), 10)}).Result()
+	if err != nil {
+		slog.Error("Failed to list expiring boards", "err", err)
+		return nil, false
+	}
+	return ids, true
+}
+
+// boardExpireAt is when keys of the board expire, at Board.AutoDeleteAtUtc.
+// Keys written after UpdateBoardExpiry follow the new time, not timeToLive from now.
+// Falls back to timeToLive from now for boards without it.
+func (c *RedisConnector) boardExpireAt(boardId string) time.Time {
+	autoDeleteAtUtc, err := c.client.HGet(c.ctx, boardKey(boardId), "autoDeleteAtUtc").Int64()
+	if err != nil || autoDeleteAtUtc <= 0 {
+		return time.Now().Add(c.timeToLive)
+	}
+	return time.Unix(autoDeleteAtUtc, 0)
+}
+
 func (c *RedisConnector) BoardExists(boardId string) bool {
 	key := boardKey(boardId)
 
@@ -335,6 +619,7 @@ func (c *RedisConnector) GetBoard(boardId string) (*Board, bool) {
 	if b.Id == "" {
 		return nil, false
 	}
+	c.decryptBoard(&b)
 
 	return &b, true
 }
@@ -414,6 +699,9 @@ func (c *RedisConnector) GetBoardColumns(boardId string) ([]*BoardColumn, bool)
 		}
 		cols = append(cols, &c)
 	}
+	for _, col := range cols {
+		c.decryptColumn(boardId, col)
+	}
 
 	return cols, true
 }
@@ -445,6 +733,7 @@ func (c *RedisConnector) EnsureUser(boardId, userId, nickname string) (*User, bo
 		if err := c.client.SAdd(c.ctx, allUsersKey, userId).Err(); err != nil {
 			slog.Error("Failed adding returning user to all-users set", "err", err, "boardId", boardId, "userId", userId)
 		}
+		c.addUserBoard(boardId, c.boardExpireAt(boardId), userId)
 
 		return &user, true
 	}
@@ -454,19 +743,21 @@ func (c *RedisConnector) EnsureUser(boardId, userId, nickname string) (*User, bo
 
 	allUsersKey := boardAllUsersKey(boardId)
 
+	expireAt := c.boardExpireAt(boardId)
+
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		xidCmd = pipe.Incr(c.ctx, xidSeqKey)
-		pipe.Expire(c.ctx, xidSeqKey, c.timeToLive) // Todo: We can try to expire this earlier by looking at Board.AutoDeleteAtUtc. But the requires a call to get board details. Skipping it for now.
+		pipe.ExpireAt(c.ctx, xidSeqKey, expireAt)
 
 		pipe.HSet(c.ctx, userKey,
 			"id", userId,
 			"nickname", nickname,
 		)
-		pipe.Expire(c.ctx, userKey, c.timeToLive)
+		pipe.ExpireAt(c.ctx, userKey, expireAt)
 
 		// Track in all-users set
 		pipe.SAdd(c.ctx, allUsersKey, userId)
-		pipe.Expire(c.ctx, allUsersKey, c.timeToLive)
+		pipe.ExpireAt(c.ctx, allUsersKey, expireAt)
 		return nil
 	})
 
@@ -488,19 +779,361 @@ func (c *RedisConnector) EnsureUser(boardId, userId, nickname string) (*User, bo
 		Xid:      xid,
 		Nickname: nickname,
 	}
+	c.addUserBoard(boardId, expireAt, userId)
 
 	return &user, true
 }
 
+func (c *RedisConnector) ReservePasscodeAttempt(boardId, ip string, window time.Duration) (int64, bool) {
+	key := boardPassFailKey(boardId, ip)
+	var incr *redis.IntCmd
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(c.ctx, key)
+		// Window starts with the first attempt
+		pipe.ExpireNX(c.ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to reserve passcode attempt", "err", err, "board", boardId)
+		return 0, false
+	}
+	return incr.Val(), true
+}
+
+// releasePasscodeAttemptScript decrements the attempts, unless the window has already ended. DECR would recreate the key without expiry.
+var releasePasscodeAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+func (c *RedisConnector) ReleasePasscodeAttempt(boardId, ip string) bool {
+	if err := releasePasscodeAttemptScript.Run(c.ctx, c.client, []string{boardPassFailKey(boardId, ip)}).Err(); err != nil {
+		slog.Error("Failed to release passcode attempt", "err", err, "board", boardId)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) CountRequest(scope, ip string, window time.Duration) (int64, time.Duration, bool) {
+	key := rateLimitKey(scope, ip)
+	var incr *redis.IntCmd
+	var ttl *redis.DurationCmd
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(c.ctx, key)
+		// Window starts with the first request
+		pipe.ExpireNX(c.ctx, key, window)
+		ttl = pipe.PTTL(c.ctx, key)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to count request", "err", err, "scope", scope)
+		return 0, 0, false
+	}
+	return incr.Val(), max(ttl.Val(), 0), true
+}
+
+// useInviteScript counts a user as a use of an invite. Checking the limit and adding the user must be atomic,
+// otherwise concurrent joins could go over MaxUses.
+// KEYS[1] invite hash, KEYS[2] invite users set, ARGV[1] userId. Returns 1 when the user may join.
+var useInviteScript = redis.NewScript(`
+local maxUses = redis.call('HGET', KEYS[1], 'maxUses')
+if not maxUses then
+	return 0
+end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+	return 1
+end
+maxUses = tonumber(maxUses)
+if maxUses > 0 and redis.call('SCARD', KEYS[2]) >= maxUses then
+	return 0
+end
+redis.call('SADD', KEYS[2], ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+func (c *RedisConnector) UpdateBoardInviteOnly(b *Board, inviteOnly bool) bool {
+	key := boardKey(b.Id)
+	if _, err := c.client.HSet(c.ctx, key, "inviteOnly", inviteOnly).Result(); err != nil {
+		slog.Error("Failed to update board invite only", "err", err, "board", b.Id)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) CreateInvite(b *Board, inv *Invite) bool {
+	key := boardInviteKey(b.Id, inv.Id)
+	invitesKey := boardInvitesKey(b.Id)
+
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, key,
+			"id", inv.Id,
+			"createdAtUtc", inv.CreatedAtUtc,
+			"expiresAtUtc", inv.ExpiresAtUtc,
+			"maxUses", inv.MaxUses,
+		)
+		pipe.ExpireAt(c.ctx, key, time.Unix(inv.ExpiresAtUtc, 0))
+		pipe.SAdd(c.ctx, invitesKey, inv.Id)
+		pipe.ExpireAt(c.ctx, invitesKey, time.Unix(b.AutoDeleteAtUtc, 0))
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to create invite", "err", err, "board", b.Id)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) GetInvites(boardId string) ([]*Invite, bool) {
+	invitesKey := boardInvitesKey(boardId)
+	ids, err := c.client.SMembers(c.ctx, invitesKey).Result()
+	if err != nil {
+		slog.Error("Failed to get invite ids", "err", err, "board", boardId)
+		return nil, false
+	}
+
+	invCmds := make([]*redis.MapStringStringCmd, len(ids))
+	usesCmds := make([]*redis.IntCmd, len(ids))
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			invCmds[i] = pipe.HGetAll(c.ctx, boardInviteKey(boardId, id))
+			usesCmds[i] = pipe.SCard(c.ctx, boardInviteUsersKey(boardId, id))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to get invites", "err", err, "board", boardId)
+		return nil, false
+	}
+
+	invites := make([]*Invite, 0, len(ids))
+	var expired []any
+	for i, id := range ids {
+		var inv Invite
+		if err := invCmds[i].Scan(&inv); err != nil || inv.Id == "" {
+			// The invite hash expired, drop it from the index
+			expired = append(expired, id)
+			continue
+		}
+		inv.Uses = usesCmds[i].Val()
+		invites = append(invites, &inv)
+	}
+	if len(expired) > 0 {
+		if err := c.client.SRem(c.ctx, invitesKey, expired...).Err(); err != nil {
+			slog.Warn("Failed to remove expired invites", "err", err, "board", boardId)
+		}
+	}
+
+	sortInvites(invites)
+	return invites, true
+}
+
+func (c *RedisConnector) RevokeInvite(boardId, inviteId string) bool {
+	var del *redis.IntCmd
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(c.ctx, boardInviteKey(boardId, inviteId), boardInviteUsersKey(boardId, inviteId))
+		pipe.SRem(c.ctx, boardInvitesKey(boardId), inviteId)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to revoke invite", "err", err, "board", boardId)
+		return false
+	}
+	return del.Val() > 0
+}
+
+func (c *RedisConnector) CheckInvite(boardId, inviteId, userId string) bool {
+	usersKey := boardInviteUsersKey(boardId, inviteId)
+	var maxUsesCmd *redis.StringCmd
+	var usedCmd *redis.BoolCmd
+	var usesCmd *redis.IntCmd
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		maxUsesCmd = pipe.HGet(c.ctx, boardInviteKey(boardId, inviteId), "maxUses")
+		usedCmd = pipe.SIsMember(c.ctx, usersKey, userId)
+		usesCmd = pipe.SCard(c.ctx, usersKey)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		slog.Error("Failed to check invite", "err", err, "board", boardId)
+		return false
+	}
+	maxUses, err := maxUsesCmd.Int64()
+	if err != nil {
+		return false // Expired, revoked or never existed
+	}
+	return usedCmd.Val() || maxUses == 0 || usesCmd.Val() < maxUses
+}
+
+func (c *RedisConnector) UseInvite(boardId, inviteId, userId string) bool {
+	keys := []string{boardInviteKey(boardId, inviteId), boardInviteUsersKey(boardId, inviteId)}
+	ok, err := useInviteScript.Run(c.ctx, c.client, keys, userId).Int()
+	if err != nil {
+		slog.Error("Failed to use invite", "err", err, "board", boardId)
+		return false
+	}
+	return ok == 1
+}
+
+func (c *RedisConnector) BanUser(b *Board, userId string) bool {
+	key := boardBannedKey(b.Id)
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(c.ctx, key, userId)
+		pipe.ExpireAt(c.ctx, key, time.Unix(b.AutoDeleteAtUtc, 0))
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to ban user", "err", err, "board", b.Id, "user", userId)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) IsBanned(boardId, userId string) bool {
+	banned, err := c.client.SIsMember(c.ctx, boardBannedKey(boardId), userId).Result()
+	if err != nil {
+		slog.Error("Failed to check ban", "err", err, "board", boardId, "user", userId)
+		return false
+	}
+	return banned
+}
+
+func (c *RedisConnector) CreateTeam(t *Team, retention time.Duration) bool {
+	key := teamKey(t.Id)
+	now := time.Now().UTC()
+	expireAt := now.Add(retention)
+
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, key,
+			"id", t.Id,
+			"name", c.crypt.seal(t.Name, t.Id, teamRecord(), "name"),
+			"createdAtUtc", now.Unix(),
+			"autoDeleteAtUtc", expireAt.Unix(),
+		)
+		pipe.ExpireAt(c.ctx, key, expireAt)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to create team", "err", err, "team", t.Id)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) GetTeam(teamId string) (*Team, bool) {
+	var t Team
+	if err := c.client.HGetAll(c.ctx, teamKey(teamId)).Scan(&t); err != nil {
+		slog.Error("Failed to get team", "err", err, "team", teamId)
+		return nil, false
+	}
+	if t.Id == "" {
+		return nil, false
+	}
+	t.Name = c.decrypt(t.Name, teamId, teamRecord(), "name")
+	return &t, true
+}
+
+// AddTeamBoard adds the board's summary to the team, and moves the expiry of all keys of the team to retention from now.
+func (c *RedisConnector) AddTeamBoard(teamId string, b *Board, retention time.Duration) bool {
+	boardIds, err := c.client.ZRange(c.ctx, teamBoardsKey(teamId), 0, -1).Result()
+	if err != nil {
+		slog.Error("Failed to get team boards", "err", err, "team", teamId)
+		return false
+	}
+	expireAt := time.Now().UTC().Add(retention)
+
+	_, err = c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, teamBoardKey(teamId, b.Id),
+			"id", b.Id,
+			"name", c.crypt.seal(b.Name, b.Id, boardRecord(), "name"),
+			"createdAtUtc", b.CreatedAtUtc,
+			"autoDeleteAtUtc", b.AutoDeleteAtUtc,
+		)
+		pipe.ZAdd(c.ctx, teamBoardsKey(teamId), redis.Z{Score: float64(b.CreatedAtUtc), Member: b.Id})
+		pipe.HSet(c.ctx, teamKey(teamId), "autoDeleteAtUtc", expireAt.Unix())
+
+		pipe.ExpireAt(c.ctx, teamKey(teamId), expireAt)
+		pipe.ExpireAt(c.ctx, teamBoardsKey(teamId), expireAt)
+		for _, boardId := range append(boardIds, b.Id) {
+			pipe.ExpireAt(c.ctx, teamBoardKey(teamId, boardId), expireAt)
+			pipe.ExpireAt(c.ctx, teamBoardUsersKey(teamId, boardId), expireAt)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to add board to team", "err", err, "team", teamId, "board", b.Id)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) AddTeamBoardParticipant(teamId, boardId, userId string) bool {
+	autoDeleteAtUtc, err := c.client.HGet(c.ctx, teamKey(teamId), "autoDeleteAtUtc").Int64()
+	if err != nil {
+		// The team is gone, don't bring back its keys
+		return false
+	}
+	key := teamBoardUsersKey(teamId, boardId)
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(c.ctx, key, userId)
+		pipe.ExpireAt(c.ctx, key, time.Unix(autoDeleteAtUtc, 0))
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to add team board participant", "err", err, "team", teamId, "board", boardId)
+		return false
+	}
+	return true
+}
+
+func (c *RedisConnector) GetTeamBoards(teamId string) ([]*TeamBoard, bool) {
+	boardIds, err := c.client.ZRevRange(c.ctx, teamBoardsKey(teamId), 0, -1).Result()
+	if err != nil {
+		slog.Error("Failed to get team boards", "err", err, "team", teamId)
+		return nil, false
+	}
+
+	summaryCmds := make([]*redis.MapStringStringCmd, len(boardIds))
+	usersCmds := make([]*redis.IntCmd, len(boardIds))
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, boardId := range boardIds {
+			summaryCmds[i] = pipe.HGetAll(c.ctx, teamBoardKey(teamId, boardId))
+			usersCmds[i] = pipe.SCard(c.ctx, teamBoardUsersKey(teamId, boardId))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to get team boards", "err", err, "team", teamId)
+		return nil, false
+	}
+
+	boards := make([]*TeamBoard, 0, len(boardIds))
+	for i := range boardIds {
+		var tb TeamBoard
+		if err := summaryCmds[i].Scan(&tb); err != nil || tb.Id == "" {
+			continue
+		}
+		tb.Name = c.decrypt(tb.Name, tb.Id, boardRecord(), "name")
+		tb.Participants = usersCmds[i].Val()
+		boards = append(boards, &tb)
+	}
+	return boards, true
+}
+
 func (c *RedisConnector) UpdateMessagePin(boardId, msgId string, pin bool) bool {
 	boardPinsKey := boardPinnedMsgsKey(boardId)
 
 	var err error
 
 	if pin {
+		expireAt := c.boardExpireAt(boardId)
 		_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 			pipe.SAdd(c.ctx, boardPinsKey, msgId)
-			pipe.Expire(c.ctx, boardPinsKey, c.timeToLive)
+			pipe.ExpireAt(c.ctx, boardPinsKey, expireAt)
 			return nil
 		})
 	} else {
@@ -523,11 +1156,12 @@ func (c *RedisConnector) UpdateMessagePin(boardId, msgId string, pin bool) bool
 
 func (c *RedisConnector) CommitUserPresence(boardId string, userId string) bool {
 	boardUsersKey := boardUsersPresenceKey(boardId)
+	expireAt := c.boardExpireAt(boardId)
 
 	// Todo: Remove pipeline?
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		pipe.SAdd(c.ctx, boardUsersKey, userId)
-		pipe.Expire(c.ctx, boardUsersKey, c.timeToLive) // Todo: We can try to expire this earlier by looking at Board.AutoDeleteAtUtc. But the requires a call to get board details. Skipping it for now.
+		pipe.ExpireAt(c.ctx, boardUsersKey, expireAt)
 		return nil
 	})
 
@@ -607,18 +1241,19 @@ func (c *RedisConnector) GetPresentUserIds(boardId string) ([]string, bool) {
 	return ids, true
 }
 
-func (c *RedisConnector) GetMessage(msgId string) (*Message, bool) {
+func (c *RedisConnector) GetMessage(boardId, msgId string) (*Message, bool) {
 	var message Message
-	key := msgKey(msgId)
+	key := msgKey(boardId, msgId)
 
 	if err := c.client.HGetAll(c.ctx, key).Scan(&message); err != nil {
-		slog.Error("Failed getting/mapping message from Redis", "err", err, "msgId", msgId)
+		slog.Error("Failed getting/mapping message from Redis", "err", err, "boardId", boardId, "msgId", msgId)
 		return nil, false
 	}
 	// Assuming Id as empty to decide the key doesn't exist. This is done to avoid an additional EXISTS call to Redis.
 	if message.Id == "" {
 		return nil, false
 	}
+	c.decryptMessage(boardId, &message)
 
 	return &message, true
 }
@@ -653,7 +1288,7 @@ func (c *RedisConnector) GetMessagesByIds(ids []string, boardId string) ([]*Mess
 
 	cmds, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		for _, id := range ids {
-			key := msgKey(id)
+			key := msgKey(boardId, id)
 			pipe.HGetAll(c.ctx, key)
 		}
 		return nil
@@ -671,14 +1306,15 @@ func (c *RedisConnector) GetMessagesByIds(ids []string, boardId string) ([]*Mess
 		if m.Id == "" {
 			continue // Skip expired or non-existent message
 		}
+		c.decryptMessage(boardId, &m)
 		messages = append(messages, &m)
 	}
 
 	return messages, true
 }
 
-func (c *RedisConnector) GetLikesCount(msgId string) int64 {
-	key := msgLikesKey(msgId)
+func (c *RedisConnector) GetLikesCount(boardId, msgId string) int64 {
+	key := msgLikesKey(boardId, msgId)
 
 	count, err := c.client.SCard(c.ctx, key).Result()
 	if err != nil {
@@ -688,8 +1324,8 @@ func (c *RedisConnector) GetLikesCount(msgId string) int64 {
 	return count
 }
 
-func (c *RedisConnector) HasLiked(msgId string, users []string) []bool {
-	key := msgLikesKey(msgId)
+func (c *RedisConnector) HasLiked(boardId, msgId string, users []string) []bool {
+	key := msgLikesKey(boardId, msgId)
 
 	results, err := c.client.SMIsMember(c.ctx, key, users).Result()
 	if err != nil {
@@ -706,7 +1342,7 @@ type LikeInfo struct {
 	Liked bool
 }
 
-func (c *RedisConnector) GetLikesInfo(by string, msgIds ...string) (map[string]LikeInfo, bool) {
+func (c *RedisConnector) GetLikesInfo(boardId, by string, msgIds ...string) (map[string]LikeInfo, bool) {
 	result := make(map[string]LikeInfo, len(msgIds))
 	if len(msgIds) == 0 {
 		return result, true
@@ -721,7 +1357,7 @@ func (c *RedisConnector) GetLikesInfo(by string, msgIds ...string) (map[string]L
 
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		for _, id := range msgIds {
-			key := msgLikesKey(id)
+			key := msgLikesKey(boardId, id)
 			countCmd := pipe.SCard(c.ctx, key)
 			likedCmd := pipe.SIsMember(c.ctx, key, by)
 			cmds[id] = likeCmds{count: countCmd, liked: likedCmd}
@@ -754,9 +1390,10 @@ func (c *RedisConnector) GetLikesInfo(by string, msgIds ...string) (map[string]L
 }
 
 func (c *RedisConnector) Save(msg *Message, modes ...SaveMode) bool {
-	key := msgKey(msg.Id)
+	key := msgKey(msg.Group, msg.Id)
 	messagesKey := boardMsgsKey(msg.Group)
 	commentsKey := boardCmtsKey(msg.Group)
+	expireAt := c.boardExpireAt(msg.Group)
 
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		// Always save the message/comment to the Hash
@@ -764,15 +1401,15 @@ func (c *RedisConnector) Save(msg *Message, modes ...SaveMode) bool {
 			"id", msg.Id,
 			"by", msg.By,
 			"byxid", msg.ByXid,
-			"nickname", msg.ByNickname,
+			"nickname", c.crypt.seal(msg.ByNickname, msg.Group, messageRecord(msg.Id), "nickname"),
 			"group", msg.Group,
-			"content", msg.Content,
+			"content", c.crypt.seal(msg.Content, msg.Group, messageRecord(msg.Id), "content"),
 			"category", msg.Category,
 			"anon", msg.Anonymous,
 			"pid", msg.ParentId,
 			"offline_likes", msg.OfflineLikes,
 		)
-		pipe.Expire(c.ctx, key, c.timeToLive) // Todo: We can try to expire this earlier by looking at Board.AutoDeleteAtUtc. But requires a call to get board details. Skipping it for now.
+		pipe.ExpireAt(c.ctx, key, expireAt)
 
 		// Handle optional extra behavior
 		if len(modes) > 0 {
@@ -780,13 +1417,13 @@ func (c *RedisConnector) Save(msg *Message, modes ...SaveMode) bool {
 			switch modes[0] {
 			case AsNewMessage:
 				// Add Id to board:msg:{boardId} SET
-				pipe.SAdd(c.ctx, messagesKey, msg.Id)         // This is safe to be called multiple times too, without adding new entries to the Set.
-				pipe.Expire(c.ctx, messagesKey, c.timeToLive) // Todo: We can try to expire this earlier by looking at Board.AutoDeleteAtUtc. But requires a call to get board details. Skipping it for now.
+				pipe.SAdd(c.ctx, messagesKey, msg.Id) // This is safe to be called multiple times too, without adding new entries to the Set.
+				pipe.ExpireAt(c.ctx, messagesKey, expireAt)
 
 			case AsNewComment:
 				// Add Id to board:cmts:{boardId} SET
-				pipe.SAdd(c.ctx, commentsKey, msg.Id)         // This is safe to be called multiple times too, without adding new entries to the Set.
-				pipe.Expire(c.ctx, commentsKey, c.timeToLive) // Todo: We can try to expire this earlier by looking at Board.AutoDeleteAtUtc. But requires a call to get board details. Skipping it for now.
+				pipe.SAdd(c.ctx, commentsKey, msg.Id) // This is safe to be called multiple times too, without adding new entries to the Set.
+				pipe.ExpireAt(c.ctx, commentsKey, expireAt)
 			}
 		}
 
@@ -801,8 +1438,8 @@ func (c *RedisConnector) Save(msg *Message, modes ...SaveMode) bool {
 	return true
 }
 
-func (c *RedisConnector) SaveOfflineLikes(msgId string, offlineLikes int64) bool {
-	key := msgKey(msgId)
+func (c *RedisConnector) SaveOfflineLikes(boardId, msgId string, offlineLikes int64) bool {
+	key := msgKey(boardId, msgId)
 	if _, err := c.client.HSet(c.ctx, key, "offline_likes", offlineLikes).Result(); err != nil {
 		slog.Error("Failed to update offline likes in Redis", "err", err, "msgId", msgId)
 		return false
@@ -810,14 +1447,15 @@ func (c *RedisConnector) SaveOfflineLikes(msgId string, offlineLikes int64) bool
 	return true
 }
 
-func (c *RedisConnector) Like(msgId string, by string, like bool) bool {
-	key := msgLikesKey(msgId)
+func (c *RedisConnector) Like(boardId, msgId string, by string, like bool) bool {
+	key := msgLikesKey(boardId, msgId)
 
 	var affected int64
 	if like {
+		expireAt := c.boardExpireAt(boardId)
 		cmds, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 			pipe.SAdd(c.ctx, key, by)
-			pipe.Expire(c.ctx, key, c.timeToLive)
+			pipe.ExpireAt(c.ctx, key, expireAt)
 			return nil
 		})
 		if err != nil {
@@ -848,16 +1486,16 @@ func (c *RedisConnector) DeleteMessage(group string, msgId string, commentIds []
 	/*
 		DELETE SINGLE MESSAGE
 		---------------------
-		1. Delete HASH msg:{messageId}
-		2. Delete SET msg:likes:{messageId}
-		3. Remove messageId from SET board:msg:{boardId}
-		4. Remove messageId from SET board:pins:{boardId}
+		1. Delete HASH msg:{<boardId>}:<messageId>
+		2. Delete SET msg:likes:{<boardId>}:<messageId>
+		3. Remove messageId from SET board:msg:{<boardId>}
+		4. Remove messageId from SET board:pins:{<boardId>}
 		5. For each associated comment:
-			5.1. Delete HASH msg:{commentId}
-			5.2. Remove commentId from SET board:cmts:{boardId}
+			5.1. Delete HASH msg:{<boardId>}:<commentId>
+			5.2. Remove commentId from SET board:cmts:{<boardId>}
 	*/
-	key := msgKey(msgId)
-	likesKey := msgLikesKey(msgId)
+	key := msgKey(group, msgId)
+	likesKey := msgLikesKey(group, msgId)
 	pinsKey := boardPinnedMsgsKey(group)
 	messagesKey := boardMsgsKey(group)
 	commentsKey := boardCmtsKey(group)
@@ -868,7 +1506,7 @@ func (c *RedisConnector) DeleteMessage(group string, msgId string, commentIds []
 		pipe.SRem(c.ctx, pinsKey, msgId)
 		pipe.SRem(c.ctx, messagesKey, msgId)
 		for _, cid := range commentIds {
-			cKey := msgKey(cid)
+			cKey := msgKey(group, cid)
 			// Delete each attached comment
 			// Comments don't have likes right now
 			pipe.Del(c.ctx, cKey)
@@ -892,10 +1530,10 @@ func (c *RedisConnector) DeleteComment(group string, commentId string) bool {
 	/*
 		DELETE SINGLE COMMENT
 		---------------------
-		1. Delete HASH msg:{messageId}
-		2. Remove messageId entry from SET board:cmts:{boardId}
+		1. Delete HASH msg:{<boardId>}:<commentId>
+		2. Remove commentId entry from SET board:cmts:{<boardId>}
 	*/
-	key := msgKey(commentId)
+	key := msgKey(group, commentId)
 	commentsKey := boardCmtsKey(group)
 
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
@@ -919,25 +1557,35 @@ func (c *RedisConnector) DeleteComment(group string, commentId string) bool {
 
 func (c *RedisConnector) DeleteAll(boardId string) bool {
 	/*
+		All keys share the {<boardId>} hash tag, so they live in the same cluster slot.
+
 		Board
-		(KEY)board:{boardId}					(VALUE)board					Board - Redis Hash. The board details. Owned by single user.
+		(KEY)board:{<boardId>}					(VALUE)board					Board - Redis Hash. The board details. Owned by single user.
 
 		Messages, Comments, Likes, Pins
-		(KEY)board:msg:{boardId}				(VALUE)[messageIds] 			Board-wise Messages - Redis Set. Useful for fetching list of messages.
-		(KEY)board:pins:{boardId}				(VALUE)[messageIds] 			Board-wise pinned messages - Redis Set. Useful for fetching list of "pinned" messages.
-		(KEY)board:cmts:{boardId}      			(VALUE)[commentIds]      		Board-wise Comments - Redis Set. For fetching all comments.
-		(KEY)msg:{messageId}					(VALUE)message					Message - Redis Hash. Useful for fetch/add/update for an individual message.
-		(KEY)msg:likes:{messageId}				(VALUE)[userIds]				Likes - Redis Set. For recording likes/votes for a message
+		(KEY)board:msg:{<boardId>}				(VALUE)[messageIds] 			Board-wise Messages - Redis Set. Useful for fetching list of messages.
+		(KEY)board:pins:{<boardId>}				(VALUE)[messageIds] 			Board-wise pinned messages - Redis Set. Useful for fetching list of "pinned" messages.
+		(KEY)board:cmts:{<boardId>}      			(VALUE)[commentIds]      		Board-wise Comments - Redis Set. For fetching all comments.
+		(KEY)msg:{<boardId>}:<messageId>		(VALUE)message					Message - Redis Hash. Useful for fetch/add/update for an individual message.
+		(KEY)msg:likes:{<boardId>}:<messageId>	(VALUE)[userIds]				Likes - Redis Set. For recording likes/votes for a message
 
 		Users
-		(KEY)board:presence:{boardId}			(VALUE)[userIds]				Board-wise Live(Connected) Users - Redis Set.
-		(KEY)board:users:{boardId}				(VALUE)[userIds]				Board-wise All Users (ever connected) - Redis Set.
-		(KEY)board:user:{boardId}:{userId}	    (VALUE)User						User - Redis Hash. User master. Keeping as board specific.
-		(KEY)board:user:xid:seq:{boardId}		(VALUE)last_xid					Last generated sequential xid for Board - Redis INCR. Used to generate sequential Xids.
+		(KEY)board:presence:{<boardId>}			(VALUE)[userIds]				Board-wise Live(Connected) Users - Redis Set.
+		(KEY)board:users:{<boardId>}				(VALUE)[userIds]				Board-wise All Users (ever connected) - Redis Set.
+		(KEY)board:user:{<boardId>}:<userId>	    (VALUE)User						User - Redis Hash. User master. Keeping as board specific.
+		(KEY)board:user:xid:seq:{<boardId>}		(VALUE)last_xid					Last generated sequential xid for Board - Redis INCR. Used to generate sequential Xids.
 
 		Columns
-		(KEY)board:col:{boardId}				(Value)[colIds]					Board-wise columns - Redis Set. Just a list of colIds for a board.
-		(KEY)board:col:{boardId}:{colId}		(VALUE)column					Column - Redis Hash. Column definition for a Board.
+		(KEY)board:col:{<boardId>}				(Value)[colIds]					Board-wise columns - Redis Set. Just a list of colIds for a board.
+		(KEY)board:col:{<boardId>}:<colId>		(VALUE)column					Column - Redis Hash. Column definition for a Board.
+
+		Invites
+		(KEY)board:invites:{<boardId>}				(VALUE)[inviteIds]				Board-wise invites - Redis Set.
+		(KEY)board:invite:{<boardId>}:<inviteId>		(VALUE)Invite					Invite - Redis Hash.
+		(KEY)board:invite:users:{<boardId>}:<inviteId>	(VALUE)[userIds]			Users who joined with an invite - Redis Set.
+
+		Bans
+		(KEY)board:banned:{<boardId>}				(VALUE)[userIds]				Users banned from the board - Redis Set.
 	*/
 	ctx := c.ctx
 
@@ -948,15 +1596,17 @@ func (c *RedisConnector) DeleteAll(boardId string) bool {
 	boardAllUsersKey := boardAllUsersKey(boardId)
 	boardUserXidKey := boardUserXidKey(boardId)
 	boardColsKey := boardColsKey(boardId)
+	boardInvitesKey := boardInvitesKey(boardId)
 	boardKey := boardKey(boardId)
 
-	// Collect all message Ids, comment Ids, user Ids, and column Ids
+	// Collect all message Ids, comment Ids, user Ids, column Ids and invite Ids
 	// Pipeline SMEMBERS (read phase)
 	readPipe := c.client.Pipeline()
 	msgsCmd := readPipe.SMembers(ctx, boardMsgsKey)
 	cmtsCmd := readPipe.SMembers(ctx, boardCommsKey)
 	usrsCmd := readPipe.SMembers(ctx, boardAllUsersKey)
 	colsCmd := readPipe.SMembers(ctx, boardColsKey)
+	invsCmd := readPipe.SMembers(ctx, boardInvitesKey)
 
 	if _, err := readPipe.Exec(ctx); err != nil {
 		slog.Error("Redis SMEMBERS pipeline failed in DeleteAll", "boardId", boardId, "err", err)
@@ -967,14 +1617,15 @@ func (c *RedisConnector) DeleteAll(boardId string) bool {
 	commentIds := cmtsCmd.Val()
 	userIds := usrsCmd.Val()
 	colIds := colsCmd.Val()
+	inviteIds := invsCmd.Val()
 
 	// Pipeline Deletes (write phase)
 	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 
 		// Delete messages + likes, pinned messages
 		for _, msgId := range messageIds {
-			likesKey := msgLikesKey(msgId)
-			msgKey := msgKey(msgId)
+			likesKey := msgLikesKey(boardId, msgId)
+			msgKey := msgKey(boardId, msgId)
 			pipe.Del(ctx, likesKey, msgKey)
 		}
 		pipe.Del(ctx, boardMsgsKey)
@@ -982,7 +1633,7 @@ func (c *RedisConnector) DeleteAll(boardId string) bool {
 
 		// Delete comments
 		for _, cid := range commentIds {
-			commentKey := msgKey(cid)
+			commentKey := msgKey(boardId, cid)
 			pipe.Del(ctx, commentKey)
 		}
 		pipe.Del(ctx, boardCommsKey)
@@ -1003,6 +1654,15 @@ func (c *RedisConnector) DeleteAll(boardId string) bool {
 		}
 		pipe.Del(ctx, boardColsKey)
 
+		// Delete invites
+		for _, inviteId := range inviteIds {
+			pipe.Del(ctx, boardInviteKey(boardId, inviteId), boardInviteUsersKey(boardId, inviteId))
+		}
+		pipe.Del(ctx, boardInvitesKey)
+
+		// Delete bans
+		pipe.Del(ctx, boardBannedKey(boardId))
+
 		// Delete board hash
 		pipe.Del(ctx, boardKey)
 
@@ -1017,14 +1677,14 @@ func (c *RedisConnector) DeleteAll(boardId string) bool {
 	return true
 }
 
-func (c *RedisConnector) UpdateCategory(category string, msgId string, commentIds []string) bool {
+func (c *RedisConnector) UpdateCategory(boardId, category string, msgId string, commentIds []string) bool {
 	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
 		// Update main message
-		key := msgKey(msgId)
+		key := msgKey(boardId, msgId)
 		pipe.HSet(c.ctx, key, "category", category)
 		// Update associated comments (if any)
 		for _, cid := range commentIds {
-			ckey := msgKey(cid)
+			ckey := msgKey(boardId, cid)
 			pipe.HSet(c.ctx, ckey, "category", category)
 		}
 		return nil
@@ -1072,6 +1732,7 @@ func (c *RedisConnector) GetBoardAggregatedData(boardId string) (*BoardAggregate
 	if b.Id == "" {
 		return nil, false // Board not found
 	}
+	c.decryptBoard(&b)
 
 	colIds := colIdsCmd.Val()
 	allUserIds := allUserIdsCmd.Val()
@@ -1100,12 +1761,12 @@ func (c *RedisConnector) GetBoardAggregatedData(boardId string) (*BoardAggregate
 
 	msgCmds := make([]*redis.MapStringStringCmd, len(msgIds))
 	for i, id := range msgIds {
-		msgCmds[i] = pipe2.HGetAll(c.ctx, msgKey(id))
+		msgCmds[i] = pipe2.HGetAll(c.ctx, msgKey(boardId, id))
 	}
 
 	cmtCmds := make([]*redis.MapStringStringCmd, len(cmtIds))
 	for i, id := range cmtIds {
-		cmtCmds[i] = pipe2.HGetAll(c.ctx, msgKey(id))
+		cmtCmds[i] = pipe2.HGetAll(c.ctx, msgKey(boardId, id))
 	}
 
 	if _, err := pipe2.Exec(c.ctx); err != nil {
@@ -1125,9 +1786,10 @@ func (c *RedisConnector) GetBoardAggregatedData(boardId string) (*BoardAggregate
 	}
 
 	for _, cmd := range colCmds {
-		var c BoardColumn
-		if err := cmd.Scan(&c); err == nil && c.Id != "" {
-			data.Columns = append(data.Columns, &c)
+		var col BoardColumn
+		if err := cmd.Scan(&col); err == nil && col.Id != "" {
+			c.decryptColumn(boardId, &col)
+			data.Columns = append(data.Columns, &col)
 		}
 	}
 	for _, cmd := range userCmds {
@@ -1139,12 +1801,14 @@ func (c *RedisConnector) GetBoardAggregatedData(boardId string) (*BoardAggregate
 	for _, cmd := range msgCmds {
 		var m Message
 		if err := cmd.Scan(&m); err == nil && m.Id != "" {
+			c.decryptMessage(boardId, &m)
 			data.Messages = append(data.Messages, &m)
 		}
 	}
 	for _, cmd := range cmtCmds {
 		var m Message
 		if err := cmd.Scan(&m); err == nil && m.Id != "" {
+			c.decryptMessage(boardId, &m)
 			data.Comments = append(data.Comments, &m)
 		}
 	}
@@ -1216,6 +1880,26 @@ func (c *RedisConnector) GetUserByXid(boardId string, xid string) (*User, bool)
 	return nil, false
 }
 
+func (c *RedisConnector) UpdateUserModerator(boardId, userId string, moderator bool) bool {
+	key := boardUserKey(boardId, userId)
+	// Don't recreate an expired user hash without its TTL
+	n, err := c.client.Exists(c.ctx, key).Result()
+	if err != nil || n == 0 {
+		slog.Error("Failed to find user when updating moderator", "err", err, "boardId", boardId, "userId", userId)
+		return false
+	}
+	_, err = c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, key, "moderator", moderator)
+		pipe.HIncrBy(c.ctx, key, "modEpoch", 1)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to update user moderator", "err", err, "boardId", boardId, "userId", userId)
+		return false
+	}
+	return true
+}
+
 func (c *RedisConnector) Close() {
 	c.subscriber.Close()
 	c.client.Close()
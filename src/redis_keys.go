@@ -1,19 +1,40 @@
 package main
 
 // Store structure - Redis
+//
+// Every key that belongs to a board carries the boardId as a Redis Cluster hash tag, i.e. wrapped in curly braces.
+// Only the part inside {} is hashed, so all keys of a board land in the same slot and multi-key pipelines/commands
+// (DeleteAll, GetBoardAggregatedData, CreateBoard...) are cluster-safe.
+// In the table below, {<boardId>} is literal: the braces are part of the key.
 /*
-(KEY)board:{boardId}				(VALUE)board					Board - Redis Hash. The board details. Owned by single user.
-(KEY)msg:{messageId}				(VALUE)message					Message - Redis Hash. Useful for fetch/add/update for an individual message.
-(KEY)board:msg:{boardId}			(VALUE)[messageIds] 			Board-wise Messages - Redis Set. Useful for fetching list of messages.
-(KEY)board:pins:{boardId}			(VALUE)[messageIds] 			Board-wise pinned messages - Redis Set. Useful for fetching list of "pinned" messages.
-(KEY)board:cmts:{boardId}      		(VALUE)[commentIds]      		Board-wise Comments - Redis Set. For fetching all comments.
-(KEY)msg:likes:{messageId}			(VALUE)[userIds]				Likes - Redis Set. For recording likes/votes for a message.
-(KEY)board:user:{boardId}:{userId}	(VALUE)User						User - Redis Hash. User master. Keeping as board specific.
-(KEY)board:user:xid:seq:{boardId}	(VALUE)last_xid					Last generated sequential xid for Board - Redis INCR. Used to generate sequential Xids.
-(KEY)board:presence:{boardId}		(VALUE)[userIds]				Board-wise Live(Connected) Users - Redis Set.
-(KEY)board:users:{boardId}			(VALUE)[userIds]				Board-wise All Users (ever connected) - Redis Set.
-(KEY)board:col:{boardId}:{colId}	(VALUE)column					Column - Redis Hash. Column definition for a Board.
-(KEY)board:col:{boardId}			(VALUE)[colIds]					Board-wise columns - Redis Set. Just a list of colIds for a board.
+(KEY)board:{<boardId>}						(VALUE)board					Board - Redis Hash. The board details. Owned by single user.
+(KEY)msg:{<boardId>}:<messageId>			(VALUE)message					Message - Redis Hash. Useful for fetch/add/update for an individual message.
+(KEY)board:msg:{<boardId>}					(VALUE)[messageIds] 			Board-wise Messages - Redis Set. Useful for fetching list of messages.
+(KEY)board:pins:{<boardId>}					(VALUE)[messageIds] 			Board-wise pinned messages - Redis Set. Useful for fetching list of "pinned" messages.
+(KEY)board:cmts:{<boardId>}      			(VALUE)[commentIds]      		Board-wise Comments - Redis Set. For fetching all comments.
+(KEY)msg:likes:{<boardId>}:<messageId>		(VALUE)[userIds]				Likes - Redis Set. For recording likes/votes for a message.
+(KEY)board:user:{<boardId>}:<userId>		(VALUE)User						User - Redis Hash. User master. Keeping as board specific.
+(KEY)board:user:xid:seq:{<boardId>}			(VALUE)last_xid					Last generated sequential xid for Board - Redis INCR. Used to generate sequential Xids.
+(KEY)board:presence:{<boardId>}				(VALUE)[userIds]				Board-wise Live(Connected) Users - Redis Set.
+(KEY)board:users:{<boardId>}				(VALUE)[userIds]				Board-wise All Users (ever connected) - Redis Set.
+(KEY)board:col:{<boardId>}:<colId>			(VALUE)column					Column - Redis Hash. Column definition for a Board.
+(KEY)board:col:{<boardId>}					(VALUE)[colIds]					Board-wise columns - Redis Set. Just a list of colIds for a board.
+(KEY)board:passfail:{<boardId>}:<ip>		(VALUE)attempts					Passcode attempts from an IP, less the accepted ones - Redis INCR. Expires with the lockout window, not with the board.
+(KEY)board:invite:{<boardId>}:<inviteId>		(VALUE)Invite					Invite - Redis Hash. Expires with the invite, or the board if earlier.
+(KEY)board:invite:users:{<boardId>}:<inviteId>	(VALUE)[userIds]			Users who joined with an invite - Redis Set. Same expiry as the invite.
+(KEY)board:invites:{<boardId>}				(VALUE)[inviteIds]				Board-wise invites - Redis Set. Expired invites are removed from it when listing.
+(KEY)board:banned:{<boardId>}				(VALUE)[userIds]				Users banned from the board - Redis Set.
+
+Team spaces - Keys of a team carry the teamId as hash tag, like keys of a board. See team.go
+(KEY)team:{<teamId>}							(VALUE)team						Team - Redis Hash.
+(KEY)team:boards:{<teamId>}					(VALUE)[boardIds]				Boards of the team - Redis SORTED SET. Scored by creation time.
+(KEY)team:board:{<teamId>}:<boardId>			(VALUE)TeamBoard				Team's summary of a board - Redis Hash. Outlives the board.
+(KEY)team:board:users:{<teamId>}:<boardId>		(VALUE)[userIds]				Users who joined a board of the team - Redis Set.
+
+Keys that don't belong to a board
+(KEY)ratelimit:<scope>:<ip>					(VALUE)requests					HTTP requests from an IP - Redis INCR. Expires with the rate limit window.
+(KEY)boards:expiry							(VALUE)[boardIds]				Boards by AutoDeleteAtUtc - Redis SORTED SET. For archiving boards before they expire. See archive.go
+(KEY)user:boards:{<userId>}					(VALUE)[boardIds]				Boards a user created, owns or joined - Redis SORTED SET. Scored by AutoDeleteAtUtc. See user_boards.go
 */
 
 // Base prefixes
@@ -27,78 +48,175 @@ const (
 	keyBoardUser          = "board:user:"
 	keyBoardUserXid       = "board:user:xid:seq:"
 	keyBoardCols          = "board:col:"
+	keyBoardPassFail      = "board:passfail:"
+	keyBoardInvite        = "board:invite:"
+	keyBoardInviteUsers   = "board:invite:users:"
+	keyBoardInvites       = "board:invites:"
+	keyBoardBanned        = "board:banned:"
 	keyMsg                = "msg:"
 	keyMsgLikes           = "msg:likes:"
+	keyRateLimit          = "ratelimit:"
+	keyBoardsExpiry       = "boards:expiry"
+	keyTeam               = "team:"
+	keyTeamBoards         = "team:boards:"
+	keyTeamBoard          = "team:board:"
+	keyTeamBoardUsers     = "team:board:users:"
+	keyUserBoards         = "user:boards:"
+	keyUserToken          = "user:token:"
 )
 
-// board:{boardId}.
+// {<boardId>}.
+// Redis Cluster hash tag for a board.
+func boardTag(boardId string) string {
+	return "{" + boardId + "}"
+}
+
+// board:{<boardId>}.
 // Board - Redis HASH.
 func boardKey(boardId string) string {
-	return keyBoard + boardId
+	return keyBoard + boardTag(boardId)
 }
 
-// board:msg:{boardId}.
+// board:msg:{<boardId>}.
 // Board-wise Messages - Redis SET.
 func boardMsgsKey(boardId string) string {
-	return keyBoardMsgs + boardId
+	return keyBoardMsgs + boardTag(boardId)
 }
 
-// board:cmts:{boardId}
+// board:cmts:{<boardId>}
 // Board-wise Comments - Redis SET.
 func boardCmtsKey(boardId string) string {
-	return keyBoardCmts + boardId
+	return keyBoardCmts + boardTag(boardId)
 }
 
-// board:presence:{boardId}.
+// board:presence:{<boardId>}.
 // Board-wise Live(Connected) users - Redis SET.
 func boardUsersPresenceKey(boardId string) string {
-	return keyBoardUsersPresence + boardId
+	return keyBoardUsersPresence + boardTag(boardId)
 }
 
-// board:users:{boardId}.
+// board:users:{<boardId>}.
 // Board-wise All Users (ever connected) - Redis SET.
 func boardAllUsersKey(boardId string) string {
-	return keyBoardUsers + boardId
+	return keyBoardUsers + boardTag(boardId)
 }
 
-// board:user:xid:seq:{boardId}.
+// board:user:xid:seq:{<boardId>}.
 // Key for last generated sequential Xid - Redis INCR.
 func boardUserXidKey(boardId string) string {
-	return keyBoardUserXid + boardId
+	return keyBoardUserXid + boardTag(boardId)
 }
 
-// board:col:{boardId}.
+// board:col:{<boardId>}.
 // Board-wise columns - Redis SET.
 func boardColsKey(boardId string) string {
-	return keyBoardCols + boardId
+	return keyBoardCols + boardTag(boardId)
 }
 
-// msg:{messageId}.
+// msg:{<boardId>}:<messageId>.
 // Message - Redis HASH.
-func msgKey(msgId string) string {
-	return keyMsg + msgId
+func msgKey(boardId, msgId string) string {
+	return keyMsg + boardTag(boardId) + ":" + msgId
 }
 
-// msg:likes:{messageId}.
+// msg:likes:{<boardId>}:<messageId>.
 // Likes - Redis SET.
-func msgLikesKey(msgId string) string {
-	return keyMsgLikes + msgId
+func msgLikesKey(boardId, msgId string) string {
+	return keyMsgLikes + boardTag(boardId) + ":" + msgId
 }
 
-// board:user:{boardId}:{userId}.
+// board:user:{<boardId>}:<userId>.
 // User - Redis HASH.
 func boardUserKey(boardId, userId string) string {
-	return keyBoardUser + boardId + ":" + userId
+	return keyBoardUser + boardTag(boardId) + ":" + userId
 }
 
-// board:col:{boardId}:{colId}.
+// board:col:{<boardId>}:<colId>.
 // Column - Redis HASH.
 func boardColKey(boardId, colId string) string {
-	return keyBoardCols + boardId + ":" + colId
+	return keyBoardCols + boardTag(boardId) + ":" + colId
+}
+
+// board:passfail:{<boardId>}:<ip>.
+// Passcode attempts, less the accepted ones - Redis INCR.
+func boardPassFailKey(boardId, ip string) string {
+	return keyBoardPassFail + boardTag(boardId) + ":" + ip
+}
+
+// board:invite:{<boardId>}:<inviteId>.
+// Invite - Redis HASH.
+func boardInviteKey(boardId, inviteId string) string {
+	return keyBoardInvite + boardTag(boardId) + ":" + inviteId
 }
 
-// board:pins:{boardId}.
+// board:invite:users:{<boardId>}:<inviteId>.
+// Users who joined with an invite - Redis SET.
+func boardInviteUsersKey(boardId, inviteId string) string {
+	return keyBoardInviteUsers + boardTag(boardId) + ":" + inviteId
+}
+
+// board:invites:{<boardId>}.
+// Board-wise invites - Redis SET.
+func boardInvitesKey(boardId string) string {
+	return keyBoardInvites + boardTag(boardId)
+}
+
+// board:banned:{<boardId>}.
+// Users banned from the board - Redis SET.
+func boardBannedKey(boardId string) string {
+	return keyBoardBanned + boardTag(boardId)
+}
+
+// board:pins:{<boardId>}.
 // Board-wise "pinned" messages - Redis SET.
 func boardPinnedMsgsKey(boardId string) string {
-	return keyBoardPinnnedMsgs + boardId
+	return keyBoardPinnnedMsgs + boardTag(boardId)
+}
+
+// ratelimit:<scope>:<ip>.
+// HTTP requests from an IP - Redis INCR.
+func rateLimitKey(scope, ip string) string {
+	return keyRateLimit + scope + ":" + ip
+}
+
+// boards:expiry.
+// Boards by AutoDeleteAtUtc - Redis SORTED SET.
+func boardsExpiryKey() string {
+	return keyBoardsExpiry
+}
+
+// team:{<teamId>}.
+// Team - Redis HASH.
+func teamKey(teamId string) string {
+	return keyTeam + boardTag(teamId)
+}
+
+// team:boards:{<teamId>}.
+// Boards of the team - Redis SORTED SET.
+func teamBoardsKey(teamId string) string {
+	return keyTeamBoards + boardTag(teamId)
+}
+
+// team:board:{<teamId>}:<boardId>.
+// Team's summary of a board - Redis HASH.
+func teamBoardKey(teamId, boardId string) string {
+	return keyTeamBoard + boardTag(teamId) + ":" + boardId
+}
+
+// team:board:users:{<teamId>}:<boardId>.
+// Users who joined a board of the team - Redis SET.
+func teamBoardUsersKey(teamId, boardId string) string {
+	return keyTeamBoardUsers + boardTag(teamId) + ":" + boardId
+}
+
+// user:boards:{<userId>}.
+// Boards a user created, owns or joined - Redis SORTED SET.
+func userBoardsKey(userId string) string {
+	return keyUserBoards + boardTag(userId)
+}
+
+// user:token:{<userId>}.
+// Set once the user acknowledged their user token - Redis STRING. Expires with the user's boards index.
+func userTokenKey(userId string) string {
+	return keyUserToken + boardTag(userId)
 }
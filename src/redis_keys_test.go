@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// hashTag returns the part of the key Redis Cluster hashes to pick a slot.
+func hashTag(key string) string {
+	if s := strings.IndexByte(key, '{'); s >= 0 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			return key[s+1 : s+1+e]
+		}
+	}
+	return key
+}
+
+func TestBoardKeys_ShareHashSlot(t *testing.T) {
+	boardId := "f7Ks9xQ2mNpL4rT8vW3yZa"
+
+	keys := []string{
+		boardKey(boardId),
+		boardMsgsKey(boardId),
+		boardCmtsKey(boardId),
+		boardPinnedMsgsKey(boardId),
+		boardUsersPresenceKey(boardId),
+		boardAllUsersKey(boardId),
+		boardUserXidKey(boardId),
+		boardColsKey(boardId),
+		boardUserKey(boardId, "user-1"),
+		boardColKey(boardId, "col01"),
+		msgKey(boardId, "msg-1"),
+		msgLikesKey(boardId, "msg-1"),
+		boardInviteKey(boardId, "inv-1"),
+		boardInviteUsersKey(boardId, "inv-1"),
+		boardInvitesKey(boardId),
+		boardBannedKey(boardId),
+	}
+
+	for _, k := range keys {
+		if got := hashTag(k); got != boardId {
+			t.Errorf("key %q hashes on %q, want %q", k, got, boardId)
+		}
+	}
+}
+
+func TestBoardKeys_AreDistinct(t *testing.T) {
+	boardId := "abc"
+	seen := map[string]bool{}
+	for _, k := range []string{
+		boardKey(boardId), boardMsgsKey(boardId), boardCmtsKey(boardId), boardPinnedMsgsKey(boardId),
+		boardUsersPresenceKey(boardId), boardAllUsersKey(boardId), boardUserXidKey(boardId), boardColsKey(boardId),
+		boardUserKey(boardId, "u"), boardColKey(boardId, "c"), msgKey(boardId, "m"), msgLikesKey(boardId, "m"),
+		boardInviteKey(boardId, "i"), boardInviteUsersKey(boardId, "i"), boardInvitesKey(boardId),
+		boardBannedKey(boardId),
+	} {
+		if seen[k] {
+			t.Errorf("duplicate key %q", k)
+		}
+		seen[k] = true
+	}
+}
+
+func TestLegacyBoardId(t *testing.T) {
+	tests := []struct {
+		key    string
+		wantId string
+		wantOk bool
+	}{
+		{"board:abc123", "abc123", true},
+		{"board:{abc123}", "", false},        // Already migrated
+		{"board:msg:abc123", "", false},      // Board-wise set, not the board hash
+		{"board:user:abc:user-1", "", false}, // User hash
+		{"board:", "", false},
+		{"msg:abc123", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			id, ok := legacyBoardId(tt.key)
+			if id != tt.wantId || ok != tt.wantOk {
+				t.Errorf("legacyBoardId(%q) = (%q, %v), want (%q, %v)", tt.key, id, ok, tt.wantId, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Legacy (pre hash tag) key layout. Only used by MigrateKeys.
+/*
+(KEY)board:{boardId}				->	board:{<boardId>}
+(KEY)board:msg:{boardId}			->	board:msg:{<boardId>}
+(KEY)board:pins:{boardId}			->	board:pins:{<boardId>}
+(KEY)board:cmts:{boardId}      		->	board:cmts:{<boardId>}
+(KEY)board:presence:{boardId}		->	board:presence:{<boardId>}
+(KEY)board:users:{boardId}			->	board:users:{<boardId>}
+(KEY)board:user:xid:seq:{boardId}	->	board:user:xid:seq:{<boardId>}
+(KEY)board:user:{boardId}:{userId}	->	board:user:{<boardId>}:<userId>
+(KEY)board:col:{boardId}			->	board:col:{<boardId>}
+(KEY)board:col:{boardId}:{colId}	->	board:col:{<boardId>}:<colId>
+(KEY)msg:{messageId}				->	msg:{<boardId>}:<messageId>
+(KEY)msg:likes:{messageId}			->	msg:likes:{<boardId>}:<messageId>
+*/
+
+// MigrateKeys renames keys written by older versions to the hash-tagged layout in redis_keys.go.
+// RENAME keeps the TTL, so migrated boards still expire at their original time.
+// It is idempotent and must run against a standalone or sentinel deployment before switching to cluster mode,
+// since a RENAME between two slots is rejected by Redis Cluster.
+// Returns the number of boards migrated.
+func (c *RedisConnector) MigrateKeys() (int, bool) {
+	migrated := 0
+	var cursor uint64
+
+	for {
+		keys, next, err := c.client.Scan(c.ctx, cursor, keyBoard+"*", 500).Result()
+		if err != nil {
+			slog.Error("Failed scanning legacy board keys", "err", err)
+			return migrated, false
+		}
+
+		for _, key := range keys {
+			boardId, ok := legacyBoardId(key)
+			if !ok {
+				continue
+			}
+			if !c.migrateBoardKeys(boardId) {
+				return migrated, false
+			}
+			migrated++
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return migrated, true
+}
+
+// legacyBoardId extracts the boardId from a legacy "board:{boardId}" hash key.
+// Every other key under the "board:" prefix has a second segment or already carries a hash tag.
+func legacyBoardId(key string) (string, bool) {
+	id, found := strings.CutPrefix(key, keyBoard)
+	if !found || id == "" || strings.ContainsAny(id, ":{}") {
+		return "", false
+	}
+	return id, true
+}
+
+func (c *RedisConnector) migrateBoardKeys(boardId string) bool {
+	ctx := c.ctx
+
+	oldMsgsKey := keyBoardMsgs + boardId
+	oldCmtsKey := keyBoardCmts + boardId
+	oldUsersKey := keyBoardUsers + boardId
+	oldColsKey := keyBoardCols + boardId
+
+	// Read phase: collect member ids from the legacy sets
+	readPipe := c.client.Pipeline()
+	msgsCmd := readPipe.SMembers(ctx, oldMsgsKey)
+	cmtsCmd := readPipe.SMembers(ctx, oldCmtsKey)
+	usrsCmd := readPipe.SMembers(ctx, oldUsersKey)
+	colsCmd := readPipe.SMembers(ctx, oldColsKey)
+	if _, err := readPipe.Exec(ctx); err != nil {
+		slog.Error("Failed reading legacy board sets", "err", err, "boardId", boardId)
+		return false
+	}
+
+	renames := map[string]string{
+		keyBoard + boardId:              boardKey(boardId),
+		oldMsgsKey:                      boardMsgsKey(boardId),
+		keyBoardPinnnedMsgs + boardId:   boardPinnedMsgsKey(boardId),
+		oldCmtsKey:                      boardCmtsKey(boardId),
+		keyBoardUsersPresence + boardId: boardUsersPresenceKey(boardId),
+		oldUsersKey:                     boardAllUsersKey(boardId),
+		keyBoardUserXid + boardId:       boardUserXidKey(boardId),
+		oldColsKey:                      boardColsKey(boardId),
+	}
+	for _, id := range msgsCmd.Val() {
+		renames[keyMsg+id] = msgKey(boardId, id)
+		renames[keyMsgLikes+id] = msgLikesKey(boardId, id)
+	}
+	for _, id := range cmtsCmd.Val() {
+		renames[keyMsg+id] = msgKey(boardId, id)
+	}
+	for _, id := range usrsCmd.Val() {
+		renames[keyBoardUser+boardId+":"+id] = boardUserKey(boardId, id)
+	}
+	for _, id := range colsCmd.Val() {
+		renames[keyBoardCols+boardId+":"+id] = boardColKey(boardId, id)
+	}
+
+	// Write phase: rename whatever still exists. Missing keys (expired, never created) are skipped.
+	existsCmds := make(map[string]*redis.IntCmd, len(renames))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for oldKey := range renames {
+			existsCmds[oldKey] = pipe.Exists(ctx, oldKey)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed checking legacy keys", "err", err, "boardId", boardId)
+		return false
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for oldKey, newKey := range renames {
+			if existsCmds[oldKey].Val() == 1 {
+				pipe.Rename(ctx, oldKey, newKey)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed renaming legacy keys", "err", err, "boardId", boardId)
+		return false
+	}
+
+	slog.Info("Migrated board keys", "board", boardId)
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Supported values for REDIS_MODE
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// newRedisUniversalClient builds a Redis client from REDIS_CONNSTR and REDIS_MODE.
+//
+// standalone: redis[s]://<user>:<password>@<host>:<port>/<db>
+// sentinel:   redis[s]://<sentinel_user>:<sentinel_password>@<sentinel_host>:<port>/<db>?master_name=<name>&addr=<sentinel2>&password=<master_password>
+// cluster:    redis[s]://<user>:<password>@<host>:<port>?addr=<node2>&addr=<node3>
+//
+// The "rediss" scheme enables TLS. REDIS_TLS_* variables add a custom CA, a client certificate (mTLS) or disable verification.
+func newRedisUniversalClient(env EnvironmentConfig) (redis.UniversalClient, error) {
+	mode := strings.ToLower(strings.TrimSpace(env.RedisMode))
+
+	switch mode {
+	case "", RedisModeStandalone:
+		opt, err := redis.ParseURL(env.RedisConnStr)
+		if err != nil {
+			return nil, err
+		}
+		if opt.TLSConfig, err = redisTLSConfig(env, opt.TLSConfig); err != nil {
+			return nil, err
+		}
+		return redis.NewClient(opt), nil
+
+	case RedisModeSentinel:
+		opt, err := redis.ParseFailoverURL(env.RedisConnStr)
+		if err != nil {
+			return nil, err
+		}
+		if opt.MasterName == "" {
+			return nil, fmt.Errorf("master_name query parameter is required in REDIS_CONNSTR for sentinel mode")
+		}
+		if opt.TLSConfig, err = redisTLSConfig(env, opt.TLSConfig); err != nil {
+			return nil, err
+		}
+		return redis.NewFailoverClient(opt), nil
+
+	case RedisModeCluster:
+		opt, err := redis.ParseClusterURL(env.RedisConnStr)
+		if err != nil {
+			return nil, err
+		}
+		if opt.TLSConfig, err = redisTLSConfig(env, opt.TLSConfig); err != nil {
+			return nil, err
+		}
+		return redis.NewClusterClient(opt), nil
+	}
+
+	return nil, fmt.Errorf("unsupported REDIS_MODE %q (use %s, %s or %s)", env.RedisMode, RedisModeStandalone, RedisModeSentinel, RedisModeCluster)
+}
+
+// redisTLSConfig applies the REDIS_TLS_* settings on top of the TLS config derived from the connection string.
+// TLS stays disabled (nil) unless the "rediss" scheme was used.
+func redisTLSConfig(env EnvironmentConfig, base *tls.Config) (*tls.Config, error) {
+	customised := env.RedisTLSCACert != "" || env.RedisTLSCert != "" || env.RedisTLSKey != "" || env.RedisTLSSkipVerify
+	if base == nil {
+		if customised {
+			return nil, fmt.Errorf("REDIS_TLS_* settings require a rediss:// REDIS_CONNSTR")
+		}
+		return nil, nil
+	}
+
+	cfg := base.Clone()
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	if env.RedisTLSCACert != "" {
+		pem, err := os.ReadFile(env.RedisTLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading REDIS_TLS_CA_CERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in REDIS_TLS_CA_CERT %s", env.RedisTLSCACert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if env.RedisTLSCert != "" || env.RedisTLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(env.RedisTLSCert, env.RedisTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading REDIS_TLS_CERT/REDIS_TLS_KEY: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if env.RedisTLSSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+
+	return cfg, nil
+}
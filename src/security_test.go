@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 )
@@ -54,17 +55,104 @@ func TestIsOriginAllowed_EmptyOrigin(t *testing.T) {
 	}
 }
 
+func TestIsOriginAllowed_Patterns(t *testing.T) {
+	origConfig := config
+	t.Cleanup(func() { config = origConfig })
+
+	config.Server.AllowedOrigins = []string{"https://*.retro.example.com", "http://localhost:*", "https://app.example.com:443", "http://[::1]:8921"}
+
+	for origin, want := range map[string]bool{
+		"https://pr-12.retro.example.com":       true,
+		"https://PR-12.Retro.Example.com":       true,
+		"https://pr-12.retro.example.com:443":   true,
+		"http://localhost":                      true,
+		"http://localhost:5173":                 true,
+		"https://app.example.com":               true,
+		"http://[::1]:8921":                     true,
+		"http://[0:0:0:0:0:0:0:1]:8921":         true,
+		"https://retro.example.com":             false, // Wildcard needs a subdomain
+		"https://a.b.retro.example.com":         false, // Single label only
+		"http://pr-12.retro.example.com":        false, // Scheme
+		"wss://pr-12.retro.example.com":         false,
+		"https://pr-12.retro.example.com:8443":  false, // Port
+		"https://evilretro.example.com":         false, // Suffix without the dot
+		"https://retro.example.com.evil.com":    false,
+		"https://pr-12.retro.example.com.":      false, // Trailing dot
+		"https://evil.com#.retro.example.com":   false,
+		"https://evil.com?.retro.example.com":   false,
+		"https://evil.com/.retro.example.com":   false,
+		"https://evil.com\\.retro.example.com":  false,
+		"https://x.retro.example.com@evil.com":  false, // Userinfo
+		"https://evil.com@x.retro.example.com":  false,
+		"https://*.retro.example.com":           false, // Patterns aren't origins
+		"http://localhost:*":                    false,
+		"https://x%2eretro.example.com":         false,
+		"https://pr_12.retro.example.com":       false,
+		"https://-x.retro.example.com":          false,
+		"http://localhost:0":                    false,
+		"http://localhost:65536":                false,
+		"http://localhost:+80":                  false,
+		"https://app.example.com:4430":          false,
+		"http://[::2]:8921":                     false,
+		"null":                                  false,
+		"":                                      false,
+		"https://":                              false,
+		"pr-12.retro.example.com":               false,
+		" https://pr-12.retro.example.com/path": false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Origin", origin)
+		if got := isOriginAllowed(req); got != want {
+			t.Errorf("origin %q: allowed %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestValidateAllowedOrigins(t *testing.T) {
+	valid := []string{"https://example.com", "http://localhost:8921", "https://*.retro.example.com", "http://localhost:*", "http://[::1]:8921", "http://127.0.0.1"}
+	if err := validateAllowedOrigins(valid); err != nil {
+		t.Errorf("valid origins rejected: %v", err)
+	}
+
+	for _, invalid := range []string{
+		"example.com",
+		"ftp://example.com",
+		"https://example.com/",
+		"https://*",
+		"https://*.com",
+		"https://*example.com",
+		"https://a.*.example.com",
+		"https://*.*.example.com",
+		"*://example.com",
+		"https://user@example.com",
+		"https://example.com:port",
+		"https://[::1",
+		"https://[127.0.0.1]",
+		"https://exa mple.com",
+	} {
+		if err := validateAllowedOrigins([]string{invalid}); err == nil {
+			t.Errorf("%q accepted", invalid)
+		}
+	}
+}
+
+func TestSplitAllowedOrigins(t *testing.T) {
+	got := splitAllowedOrigins(" https://a.example.com, ,https://*.b.example.com ")
+	if !slices.Equal(got, []string{"https://a.example.com", "https://*.b.example.com"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
 // --------------------
 // Security headers middleware tests
 // --------------------
 
 func TestSecurityHeaders_SetsAllHeaders(t *testing.T) {
-	env := EnvironmentConfig{TurnstileEnabled: false}
 	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := securityHeaders(env, inner)
+	handler := securityHeaders(nil, inner)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rr := httptest.NewRecorder()
@@ -93,12 +181,16 @@ func TestSecurityHeaders_SetsAllHeaders(t *testing.T) {
 }
 
 func TestSecurityHeaders_CSPIncludesTurnstile_WhenEnabled(t *testing.T) {
-	env := EnvironmentConfig{TurnstileEnabled: true}
+	// TURNSTILE_ENABLED=true without a provider in config selects Turnstile
+	provider, err := newChallengeProvider(EnvironmentConfig{TurnstileEnabled: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := securityHeaders(env, inner)
+	handler := securityHeaders(provider, inner)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rr := httptest.NewRecorder()
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Store is the persistence and pub/sub backend used by the Hub, event handlers and HTTP handlers.
+// RedisConnector is the default implementation. DocStore keeps each board as one document, either in memory (NewMemoryStore)
+// or in an embedded bbolt file (NewBoltStore), for single-binary deployments.
+// Both must behave the same way, which is verified by the shared conformance suite in store_test.go.
+type Store interface {
+	// Pub/Sub. Broadcasts delivers the raw payloads published to subscribed channels.
+	Subscribe(channels ...string)
+	Unsubscribe(channels ...string)
+	Publish(channel string, payload any)
+	Broadcasts() <-chan string
+
+	// Boards
+	CreateBoard(b *Board, cols []*BoardColumn, retention time.Duration) bool // Expires retention after creation. 0 for the store's default.
+	BoardExists(boardId string) bool
+	GetBoard(boardId string) (*Board, bool)
+	IsBoardOwner(boardId string, userId string) bool
+	IsBoardLocked(boardId string) bool
+	UpdateMasking(b *Board, mask bool) bool
+	UpdateBoardLock(b *Board, lock bool) bool
+	UpdateBoardOwner(b *Board, owner string) bool
+	UpdateBoardPasscode(b *Board, passcodeHash string) bool
+	UpdateBoardInviteOnly(b *Board, inviteOnly bool) bool
+	UpdateTimer(b *Board, expiryDurationInSeconds uint16) bool
+	StopTimer(b *Board) bool
+	// UpdateBoardExpiry moves AutoDeleteAtUtc, and the expiry of all data of the board with it. See BoardExpiryEvent
+	UpdateBoardExpiry(b *Board, expiresAtUtc int64) bool
+	// BoardsExpiringBefore lists boards whose AutoDeleteAtUtc is before t, that haven't been deleted yet. See archive.go
+	BoardsExpiringBefore(t time.Time) ([]string, bool)
+	DeleteAll(boardId string) bool
+	GetBoardAggregatedData(boardId string) (*BoardAggregatedData, bool)
+
+	// Columns
+	GetBoardColumns(boardId string) ([]*BoardColumn, bool)
+	IsBoardColumnActive(boardId, colId string) bool
+	ResetBoardColumns(b *Board, oldCols []*BoardColumn, newCols []*BoardColumn) bool
+	MoveBoardColumns(b *Board, order []string) bool // order lists the ids of all active columns. Positions follow it, starting from 1.
+
+	// Users and presence
+	EnsureUser(boardId, userId, nickname string) (*User, bool)
+	GetUser(boardId string, userId string) (*User, bool)
+	GetUserByXid(boardId string, xid string) (*User, bool)
+	UpdateUserModerator(boardId, userId string, moderator bool) bool // Also bumps User.ModeratorEpoch
+	CommitUserPresence(boardId string, userId string) bool
+	RemoveUserPresence(boardId string, userId string) bool
+	// GetUserBoards lists the live boards the user created, owns or joined, newest first. See user_boards.go
+	// CreateBoard, EnsureUser and UpdateBoardOwner add boards to the user's index. Boards are dropped from it once they are gone.
+	GetUserBoards(userId string) ([]*Board, bool)
+	// UserTokenPending reports whether the user token can still be sent to the user, because no client acknowledged it yet.
+	UserTokenPending(userId string) bool
+	// AckUserToken marks the user token as received. The mark lives as long as the user's boards index.
+	AckUserToken(userId string) bool
+
+	// Messages and comments
+	GetMessage(boardId, msgId string) (*Message, bool)
+	GetMessages(boardId string) ([]*Message, bool)
+	GetMessagesByIds(ids []string, boardId string) ([]*Message, bool)
+	Save(msg *Message, modes ...SaveMode) bool
+	UpdateCategory(boardId, category string, msgId string, commentIds []string) bool
+	DeleteMessage(group string, msgId string, commentIds []string) bool
+	DeleteComment(group string, commentId string) bool
+
+	// Likes
+	Like(boardId, msgId string, by string, like bool) bool
+	GetLikesCount(boardId, msgId string) int64
+	HasLiked(boardId, msgId string, users []string) []bool
+	GetLikesInfo(boardId, by string, msgIds ...string) (map[string]LikeInfo, bool)
+	SaveOfflineLikes(boardId, msgId string, offlineLikes int64) bool
+
+	// Pins
+	UpdateMessagePin(boardId, msgId string, pin bool) bool
+
+	// Passcode attempts. Attempts are counted per board and IP, and the count resets once window has passed since the first one.
+	// ReservePasscodeAttempt counts an attempt before the passcode is checked, so parallel attempts can't get past the limit.
+	// Returns the attempts in the window, including this one. ReleasePasscodeAttempt takes back the attempt of a correct passcode.
+	ReservePasscodeAttempt(boardId, ip string, window time.Duration) (int64, bool)
+	ReleasePasscodeAttempt(boardId, ip string) bool
+
+	// Request rate limits. See ratelimiter.go
+	// CountRequest counts a request from an IP for a scope (e.g. "create"), in a fixed window that starts with the first request.
+	// Returns the number of requests in the current window and the time left until it resets.
+	CountRequest(scope, ip string, window time.Duration) (int64, time.Duration, bool)
+
+	// Invites. See invite.go
+	CreateInvite(b *Board, inv *Invite) bool
+	GetInvites(boardId string) ([]*Invite, bool) // Live invites only, oldest first
+	RevokeInvite(boardId, inviteId string) bool
+	// CheckInvite reports whether UseInvite would accept the user, without using the invite.
+	CheckInvite(boardId, inviteId, userId string) bool
+	// UseInvite counts the user as a use of the invite, atomically. Users already counted are accepted again without counting.
+	// Returns false if the invite doesn't exist, has expired or has no uses left.
+	UseInvite(boardId, inviteId, userId string) bool
+
+	// Bans. A banned user can't join again for the board's lifetime. See KickEvent
+	BanUser(b *Board, userId string) bool
+	IsBanned(boardId, userId string) bool
+
+	// Teams. See team.go
+	// Teams are kept for retention after they were created, and after every board added to them.
+	CreateTeam(t *Team, retention time.Duration) bool
+	GetTeam(teamId string) (*Team, bool)
+	AddTeamBoard(teamId string, b *Board, retention time.Duration) bool
+	AddTeamBoardParticipant(teamId, boardId, userId string) bool
+	GetTeamBoards(teamId string) ([]*TeamBoard, bool) // Newest first, with participant counts
+
+	Close()
+}
+
+// Supported values for STORE_BACKEND
+const (
+	StoreBackendRedis  = "redis"
+	StoreBackendMemory = "memory"
+	StoreBackendBolt   = "bolt"
+)
+
+// NewStore creates the Store selected by STORE_BACKEND.
+func NewStore(ctx context.Context, env EnvironmentConfig, timeToLive time.Duration) (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(env.StoreBackend)) {
+	case "", StoreBackendRedis:
+		return NewRedisConnector(ctx, timeToLive), nil
+	case StoreBackendMemory:
+		return NewMemoryStore(timeToLive), nil
+	case StoreBackendBolt:
+		// Durable boards can be kept longer than auto_delete_duration, which is sized for Redis memory.
+		if config.Data.DurableRetention != "" {
+			retention, err := parseDuration(config.Data.DurableRetention)
+			if err != nil {
+				return nil, fmt.Errorf("invalid durable_retention: %w", err)
+			}
+			timeToLive = retention
+		}
+		return NewBoltStore(env.BoltPath, timeToLive)
+	}
+	return nil, fmt.Errorf("unsupported STORE_BACKEND %q (use %s, %s or %s)", env.StoreBackend, StoreBackendRedis, StoreBackendMemory, StoreBackendBolt)
+}
+
+// HasMessagesForColumnsMarkedForRemoval reports whether any message still belongs to a column that exists in oldCols but not in newCols.
+func HasMessagesForColumnsMarkedForRemoval(s Store, boardId string, oldCols []*BoardColumn, newCols []*BoardColumn) (bool, error) {
+	// Compare oldCols vs newCols to find colIds that existed before but no longer exist.
+	oldMap := make(map[string]struct{}, len(oldCols))
+	newMap := make(map[string]struct{}, len(newCols))
+	for _, c := range oldCols {
+		oldMap[c.Id] = struct{}{}
+	}
+	for _, c := range newCols {
+		newMap[c.Id] = struct{}{}
+	}
+
+	var missingIds []string // missingIds are ids of columns marked for removal
+	for oldId := range oldMap {
+		if _, stillExists := newMap[oldId]; !stillExists {
+			missingIds = append(missingIds, oldId)
+		}
+	}
+
+	if len(missingIds) == 0 {
+		return false, nil
+	}
+
+	// Fetch messages
+	messages, ok := s.GetMessages(boardId)
+	if !ok {
+		return false, fmt.Errorf("unable to fetch messages for board %s", boardId)
+	}
+
+	// Convert list to a set for quick lookup
+	disableSet := make(map[string]struct{}, len(missingIds))
+	for _, id := range missingIds {
+		disableSet[id] = struct{}{}
+	}
+
+	// Check messages: any message belonging to a disabled column?
+	for _, m := range messages {
+		if _, exists := disableSet[m.Category]; exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
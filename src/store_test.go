@@ -0,0 +1,975 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testTTL = time.Hour
+
+// storeFactory returns a fresh Store and a function that moves the store's clock forward.
+type storeFactory func(t *testing.T) (Store, func(time.Duration))
+
+func newTestMemoryStore(t *testing.T) (Store, func(time.Duration)) {
+	t.Helper()
+	s := NewMemoryStore(testTTL)
+	t.Cleanup(s.Close)
+
+	offset := time.Duration(0)
+	s.now = func() time.Time { return time.Now().Add(offset) }
+	return s, func(d time.Duration) { offset += d }
+}
+
+func newTestBoltStore(t *testing.T) (Store, func(time.Duration)) {
+	t.Helper()
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "test.db"), testTTL)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	offset := time.Duration(0)
+	s.now = func() time.Time { return time.Now().Add(offset) }
+	return s, func(d time.Duration) { offset += d }
+}
+
+func newTestRedisStore(t *testing.T) (Store, func(time.Duration)) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s := newRedisConnector(context.Background(), rdb, testTTL)
+	t.Cleanup(s.Close)
+	return s, mr.FastForward
+}
+
+func TestStoreConformance_Memory(t *testing.T) { runStoreConformance(t, newTestMemoryStore) }
+func TestStoreConformance_Bolt(t *testing.T)   { runStoreConformance(t, newTestBoltStore) }
+func TestStoreConformance_Redis(t *testing.T)  { runStoreConformance(t, newTestRedisStore) }
+
+func TestBoltStore_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	s, err := NewBoltStore(path, testTTL)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	b := createTestBoard(t, s, "b1")
+	s.EnsureUser(b.Id, "u1", "Alice")
+	s.Save(&Message{Id: "m1", By: "u1", Group: b.Id, Content: "hello"}, AsNewMessage)
+	s.Like(b.Id, "m1", "u1", true)
+	s.Close()
+
+	s, err = NewBoltStore(path, testTTL)
+	if err != nil {
+		t.Fatalf("reopen NewBoltStore: %v", err)
+	}
+	defer s.Close()
+
+	if m, ok := s.GetMessage(b.Id, "m1"); !ok || m.Content != "hello" {
+		t.Errorf("GetMessage after reopen = %+v, ok=%v", m, ok)
+	}
+	if u, ok := s.GetUser(b.Id, "u1"); !ok || u.Xid != "1" {
+		t.Errorf("GetUser after reopen = %+v, ok=%v", u, ok)
+	}
+	if n := s.GetLikesCount(b.Id, "m1"); n != 1 {
+		t.Errorf("likes after reopen = %d, want 1", n)
+	}
+	if cols, _ := s.GetBoardColumns(b.Id); len(cols) != 2 {
+		t.Errorf("columns after reopen = %d, want 2", len(cols))
+	}
+}
+
+func TestDocStore_SweeperDeletesExpiredBoards(t *testing.T) {
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "test.db"), testTTL)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer s.Close()
+	createTestBoard(t, s, "old")
+
+	s.now = func() time.Time { return time.Now().Add(testTTL / 2) }
+	createTestBoard(t, s, "new")
+
+	s.now = func() time.Time { return time.Now().Add(testTTL + time.Minute) }
+	if n := s.deleteExpired(); n != 1 {
+		t.Errorf("deleteExpired = %d, want 1", n)
+	}
+	if _, ok := s.docs.get("old"); ok {
+		t.Error("expired board still stored")
+	}
+	if _, ok := s.docs.get("new"); !ok {
+		t.Error("live board was deleted")
+	}
+}
+
+// runStoreConformance checks that a Store implementation behaves like RedisConnector.
+func runStoreConformance(t *testing.T, newStore storeFactory) {
+	t.Run("BoardLifecycle", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		if !s.BoardExists(b.Id) {
+			t.Fatal("expected board to exist")
+		}
+		if s.BoardExists("missing") {
+			t.Fatal("expected missing board to not exist")
+		}
+
+		got, ok := s.GetBoard(b.Id)
+		if !ok {
+			t.Fatal("GetBoard failed")
+		}
+		if got.Name != "Retro" || got.Owner != "owner" || got.Mask || got.Lock {
+			t.Errorf("unexpected board %+v", got)
+		}
+		if got.AutoDeleteAtUtc-got.CreatedAtUtc != int64(testTTL.Seconds()) {
+			t.Errorf("AutoDeleteAtUtc = %d, want CreatedAtUtc + %v", got.AutoDeleteAtUtc, testTTL)
+		}
+
+		if !s.IsBoardOwner(b.Id, "owner") || s.IsBoardOwner(b.Id, "other") || s.IsBoardOwner(b.Id, "") {
+			t.Error("IsBoardOwner mismatch")
+		}
+		if s.IsBoardLocked(b.Id) {
+			t.Error("new board should not be locked")
+		}
+		if !s.IsBoardLocked("") || !s.IsBoardLocked("missing") {
+			t.Error("empty or missing board should be treated as locked")
+		}
+		if got.E2EE {
+			t.Error("new board should not be end-to-end encrypted")
+		}
+		s.CreateBoard(&Board{Id: "b-e2ee", Name: "Secret", Owner: "owner", Status: InProgress, E2EE: true}, nil, 0)
+		if got, ok := s.GetBoard("b-e2ee"); !ok || !got.E2EE {
+			t.Errorf("E2EE not stored: %+v", got)
+		}
+
+		s.UpdateMasking(b, true)
+		s.UpdateBoardLock(b, true)
+		s.UpdateBoardOwner(b, "new-owner")
+		got, _ = s.GetBoard(b.Id)
+		if !got.Mask || !got.Lock || got.Owner != "new-owner" {
+			t.Errorf("updates not applied: %+v", got)
+		}
+		if got.OwnerEpoch != 1 || b.OwnerEpoch != 1 {
+			t.Errorf("owner epoch = %d (stored), %d (in memory), want 1", got.OwnerEpoch, b.OwnerEpoch)
+		}
+
+		now := time.Now().UTC().Unix()
+		s.UpdateTimer(b, 60)
+		got, _ = s.GetBoard(b.Id)
+		if !timerIsRunning(got.TimerExpiresAtUtc, now) {
+			t.Errorf("timer should be running, expires at %d", got.TimerExpiresAtUtc)
+		}
+		s.StopTimer(b)
+		got, _ = s.GetBoard(b.Id)
+		if timerIsRunning(got.TimerExpiresAtUtc, time.Now().UTC().Unix()) {
+			t.Errorf("timer should be stopped, expires at %d", got.TimerExpiresAtUtc)
+		}
+
+		if !s.DeleteAll(b.Id) {
+			t.Fatal("DeleteAll failed")
+		}
+		if s.BoardExists(b.Id) {
+			t.Error("board should not exist after DeleteAll")
+		}
+	})
+
+	t.Run("Columns", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		cols, ok := s.GetBoardColumns(b.Id)
+		if !ok || len(cols) != 2 {
+			t.Fatalf("GetBoardColumns = %d cols, ok=%v, want 2", len(cols), ok)
+		}
+		if !s.IsBoardColumnActive(b.Id, "col01") || s.IsBoardColumnActive(b.Id, "col03") {
+			t.Error("IsBoardColumnActive mismatch")
+		}
+
+		newCols := []*BoardColumn{
+			{Id: "col01", Text: "Renamed", Color: "green", Position: 1},
+			{Id: "col03", Text: "Third", Color: "red", Position: 2},
+		}
+		if !s.ResetBoardColumns(b, cols, newCols) {
+			t.Fatal("ResetBoardColumns failed")
+		}
+		if s.IsBoardColumnActive(b.Id, "col02") || !s.IsBoardColumnActive(b.Id, "col03") {
+			t.Error("columns not reset")
+		}
+		cols, _ = s.GetBoardColumns(b.Id)
+		for _, c := range cols {
+			if c.Id == "col01" && c.Text != "Renamed" {
+				t.Errorf("col01 text = %q, want Renamed", c.Text)
+			}
+		}
+
+		// Column rules are stored with the column, and can be changed later
+		ruled := []*BoardColumn{
+			{Id: "col01", Text: "Renamed", Color: "green", Position: 1, MaxCards: 3, Mask: true, Description: "One per line"},
+			{Id: "col03", Text: "Third", Color: "red", Position: 2, Lock: true},
+		}
+		if !s.ResetBoardColumns(b, cols, ruled) {
+			t.Fatal("ResetBoardColumns with rules failed")
+		}
+		cols, _ = s.GetBoardColumns(b.Id)
+		slices.SortFunc(cols, func(x, y *BoardColumn) int { return x.Position - y.Position })
+		if len(cols) != 2 || *cols[0] != *ruled[0] || *cols[1] != *ruled[1] {
+			t.Errorf("columns with rules = %+v, %+v", cols[0], cols[1])
+		}
+		unruled := []*BoardColumn{
+			{Id: "col01", Text: "Renamed", Color: "green", Position: 1},
+			{Id: "col03", Text: "Third", Color: "red", Position: 2},
+		}
+		if !s.ResetBoardColumns(b, cols, unruled) {
+			t.Fatal("ResetBoardColumns without rules failed")
+		}
+		cols, _ = s.GetBoardColumns(b.Id)
+		slices.SortFunc(cols, func(x, y *BoardColumn) int { return x.Position - y.Position })
+		if *cols[0] != *unruled[0] || *cols[1] != *unruled[1] {
+			t.Errorf("columns after removing rules = %+v, %+v", cols[0], cols[1])
+		}
+
+		if cols, ok := s.GetBoardColumns("missing"); !ok || cols == nil || len(cols) != 0 {
+			t.Errorf("missing board should have no columns, got %v", cols)
+		}
+	})
+
+	t.Run("MoveColumns", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		if s.MoveBoardColumns(b, []string{"col02"}) || s.MoveBoardColumns(b, []string{"col02", "col03"}) {
+			t.Error("MoveBoardColumns accepted an order that doesn't match the columns")
+		}
+		if !s.MoveBoardColumns(b, []string{"col02", "col01"}) {
+			t.Fatal("MoveBoardColumns failed")
+		}
+		cols, _ := s.GetBoardColumns(b.Id)
+		slices.SortFunc(cols, func(x, y *BoardColumn) int { return x.Position - y.Position })
+		if len(cols) != 2 || cols[0].Id != "col02" || cols[0].Position != 1 || cols[1].Id != "col01" || cols[1].Position != 2 {
+			t.Errorf("columns after move = %+v, %+v", cols[0], cols[1])
+		}
+		if cols[0].Text != "Bad" {
+			t.Errorf("moved column text = %q, want Bad", cols[0].Text)
+		}
+		if s.MoveBoardColumns(&Board{Id: "missing"}, []string{"col01"}) {
+			t.Error("MoveBoardColumns succeeded on a missing board")
+		}
+	})
+
+	t.Run("UsersAndPresence", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		u1, ok := s.EnsureUser(b.Id, "u1", "Alice")
+		if !ok || u1.Xid != "1" {
+			t.Fatalf("EnsureUser u1 = %+v, ok=%v", u1, ok)
+		}
+		u2, _ := s.EnsureUser(b.Id, "u2", "Bob")
+		if u2.Xid != "2" {
+			t.Errorf("u2 xid = %q, want 2", u2.Xid)
+		}
+
+		again, _ := s.EnsureUser(b.Id, "u1", "Alicia")
+		if again.Xid != "1" || again.Nickname != "Alicia" {
+			t.Errorf("returning user = %+v, want xid 1 and new nickname", again)
+		}
+		if u, ok := s.GetUser(b.Id, "u1"); !ok || u.Nickname != "Alicia" {
+			t.Errorf("GetUser = %+v, ok=%v", u, ok)
+		}
+		if u, ok := s.GetUserByXid(b.Id, "2"); !ok || u.Id != "u2" {
+			t.Errorf("GetUserByXid = %+v, ok=%v", u, ok)
+		}
+		if _, ok := s.GetUser(b.Id, "missing"); ok {
+			t.Error("expected missing user")
+		}
+
+		if !s.UpdateUserModerator(b.Id, "u2", true) {
+			t.Error("UpdateUserModerator failed")
+		}
+		if u, _ := s.GetUser(b.Id, "u2"); !u.Moderator || u.ModeratorEpoch != 1 {
+			t.Errorf("u2 = %+v, want moderator at epoch 1", u)
+		}
+		if again, _ := s.EnsureUser(b.Id, "u2", "Bob"); !again.Moderator {
+			t.Error("rejoining cleared moderator")
+		}
+		s.UpdateUserModerator(b.Id, "u2", false)
+		s.UpdateUserModerator(b.Id, "u2", true)
+		if u, _ := s.GetUser(b.Id, "u2"); !u.Moderator || u.ModeratorEpoch != 3 {
+			t.Errorf("u2 = %+v, want moderator at epoch 3", u)
+		}
+		if s.UpdateUserModerator(b.Id, "missing", true) {
+			t.Error("UpdateUserModerator succeeded for a missing user")
+		}
+
+		s.CommitUserPresence(b.Id, "u1")
+		s.CommitUserPresence(b.Id, "u2")
+		s.RemoveUserPresence(b.Id, "u2")
+
+		data, ok := s.GetBoardAggregatedData(b.Id)
+		if !ok {
+			t.Fatal("GetBoardAggregatedData failed")
+		}
+		if len(data.Users) != 2 {
+			t.Errorf("users = %d, want 2", len(data.Users))
+		}
+		if _, active := data.ActiveUserIds["u1"]; !active || len(data.ActiveUserIds) != 1 {
+			t.Errorf("active users = %v, want only u1", data.ActiveUserIds)
+		}
+	})
+
+	t.Run("MessagesAndComments", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		msg := &Message{Id: "m1", By: "u1", Group: b.Id, Content: "hello", Category: "col01"}
+		cmt := &Message{Id: "c1", By: "u2", Group: b.Id, Content: "reply", Category: "col01", ParentId: "m1"}
+		if !s.Save(msg, AsNewMessage) || !s.Save(cmt, AsNewComment) {
+			t.Fatal("Save failed")
+		}
+
+		got, ok := s.GetMessage(b.Id, "m1")
+		if !ok || got.Content != "hello" {
+			t.Fatalf("GetMessage = %+v, ok=%v", got, ok)
+		}
+		if _, ok := s.GetMessage("other-board", "m1"); ok {
+			t.Error("message should not be reachable through another board")
+		}
+
+		msgs, _ := s.GetMessages(b.Id)
+		if len(msgs) != 1 || msgs[0].Id != "m1" {
+			t.Errorf("GetMessages = %v, want only m1", msgs)
+		}
+		byIds, _ := s.GetMessagesByIds([]string{"c1", "missing"}, b.Id)
+		if len(byIds) != 1 || byIds[0].Id != "c1" {
+			t.Errorf("GetMessagesByIds = %v, want only c1", byIds)
+		}
+
+		got.Content = "edited"
+		s.Save(got)
+		if m, _ := s.GetMessage(b.Id, "m1"); m.Content != "edited" {
+			t.Errorf("content = %q, want edited", m.Content)
+		}
+		msgs, _ = s.GetMessages(b.Id)
+		if len(msgs) != 1 {
+			t.Errorf("update must not add a new message, got %d", len(msgs))
+		}
+
+		s.UpdateCategory(b.Id, "col02", "m1", []string{"c1"})
+		for _, id := range []string{"m1", "c1"} {
+			if m, _ := s.GetMessage(b.Id, id); m.Category != "col02" {
+				t.Errorf("%s category = %q, want col02", id, m.Category)
+			}
+		}
+
+		data, _ := s.GetBoardAggregatedData(b.Id)
+		if len(data.Messages) != 1 || len(data.Comments) != 1 {
+			t.Errorf("aggregated messages=%d comments=%d, want 1 and 1", len(data.Messages), len(data.Comments))
+		}
+
+		s.Save(&Message{Id: "c2", By: "u2", Group: b.Id, ParentId: "m1"}, AsNewComment)
+		s.DeleteComment(b.Id, "c2")
+		if _, ok := s.GetMessage(b.Id, "c2"); ok {
+			t.Error("comment should be deleted")
+		}
+
+		s.DeleteMessage(b.Id, "m1", []string{"c1"})
+		for _, id := range []string{"m1", "c1"} {
+			if _, ok := s.GetMessage(b.Id, id); ok {
+				t.Errorf("%s should be deleted", id)
+			}
+		}
+		data, _ = s.GetBoardAggregatedData(b.Id)
+		if len(data.Messages) != 0 || len(data.Comments) != 0 {
+			t.Errorf("aggregated data still has messages=%d comments=%d", len(data.Messages), len(data.Comments))
+		}
+	})
+
+	t.Run("LikesAndPins", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b.Id}, AsNewMessage)
+
+		if !s.Like(b.Id, "m1", "u1", true) {
+			t.Fatal("first like should succeed")
+		}
+		if s.Like(b.Id, "m1", "u1", true) {
+			t.Error("liking twice should not be affected")
+		}
+		s.Like(b.Id, "m1", "u2", true)
+		if n := s.GetLikesCount(b.Id, "m1"); n != 2 {
+			t.Errorf("likes = %d, want 2", n)
+		}
+		if got := s.HasLiked(b.Id, "m1", []string{"u2", "u3", "u1"}); !slices.Equal(got, []bool{true, false, true}) {
+			t.Errorf("HasLiked = %v", got)
+		}
+
+		if !s.Like(b.Id, "m1", "u2", false) || s.Like(b.Id, "m1", "u2", false) {
+			t.Error("unlike mismatch")
+		}
+		info, _ := s.GetLikesInfo(b.Id, "u1", "m1", "m2")
+		if info["m1"] != (LikeInfo{Count: 1, Liked: true}) || info["m2"] != (LikeInfo{}) {
+			t.Errorf("GetLikesInfo = %v", info)
+		}
+
+		s.SaveOfflineLikes(b.Id, "m1", 5)
+		if m, _ := s.GetMessage(b.Id, "m1"); m.OfflineLikes != 5 {
+			t.Errorf("offline likes = %d, want 5", m.OfflineLikes)
+		}
+
+		s.UpdateMessagePin(b.Id, "m1", true)
+		data, _ := s.GetBoardAggregatedData(b.Id)
+		if !slices.Equal(data.PinnedMessageIds, []string{"m1"}) {
+			t.Errorf("pins = %v, want [m1]", data.PinnedMessageIds)
+		}
+		s.UpdateMessagePin(b.Id, "m1", false)
+		data, _ = s.GetBoardAggregatedData(b.Id)
+		if data.PinnedMessageIds == nil || len(data.PinnedMessageIds) != 0 {
+			t.Errorf("pins = %#v, want empty", data.PinnedMessageIds)
+		}
+
+		s.UpdateMessagePin(b.Id, "m1", true)
+		s.DeleteMessage(b.Id, "m1", nil)
+		if n := s.GetLikesCount(b.Id, "m1"); n != 0 {
+			t.Errorf("likes after delete = %d, want 0", n)
+		}
+		data, _ = s.GetBoardAggregatedData(b.Id)
+		if len(data.PinnedMessageIds) != 0 {
+			t.Errorf("pins after delete = %v, want empty", data.PinnedMessageIds)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		s, advance := newStore(t)
+		b := createTestBoard(t, s, "b1")
+		s.EnsureUser(b.Id, "u1", "Alice")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b.Id}, AsNewMessage)
+
+		advance(testTTL - time.Minute)
+		if !s.BoardExists(b.Id) {
+			t.Fatal("board expired too early")
+		}
+
+		advance(2 * time.Minute)
+		if s.BoardExists(b.Id) {
+			t.Error("board should have expired")
+		}
+		if _, ok := s.GetBoardAggregatedData(b.Id); ok {
+			t.Error("aggregated data should be gone")
+		}
+		if _, ok := s.GetMessage(b.Id, "m1"); ok {
+			t.Error("message should have expired")
+		}
+		if _, ok := s.GetUser(b.Id, "u1"); ok {
+			t.Error("user should have expired")
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		s, advance := newStore(t)
+		if !s.CreateBoard(&Board{Id: "b1", Name: "Retro", Owner: "owner", Status: InProgress}, []*BoardColumn{{Id: "col01", Text: "Good", Position: 1}}, 3*testTTL) {
+			t.Fatal("CreateBoard failed")
+		}
+		b, _ := s.GetBoard("b1")
+		if b.AutoDeleteAtUtc != b.CreatedAtUtc+int64(3*testTTL/time.Second) {
+			t.Errorf("AutoDeleteAtUtc = %d, want creation + retention", b.AutoDeleteAtUtc)
+		}
+
+		// Everything added later lives as long as the board
+		s.EnsureUser(b.Id, "u1", "Alice")
+		s.CommitUserPresence(b.Id, "u1")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b.Id, Category: "col01"}, AsNewMessage)
+		s.Save(&Message{Id: "c1", By: "u1", Group: b.Id, ParentId: "m1"}, AsNewComment)
+		s.Like(b.Id, "m1", "u1", true)
+		s.UpdateMessagePin(b.Id, "m1", true)
+
+		advance(2 * testTTL)
+		data, ok := s.GetBoardAggregatedData(b.Id)
+		if !ok {
+			t.Fatal("board expired before its retention")
+		}
+		if len(data.Messages) != 1 || len(data.Comments) != 1 || len(data.Users) != 1 || len(data.PinnedMessageIds) != 1 || len(data.ActiveUserIds) != 1 {
+			t.Errorf("data lost: %d messages, %d comments, %d users, %d pins", len(data.Messages), len(data.Comments), len(data.Users), len(data.PinnedMessageIds))
+		}
+		if s.GetLikesCount(b.Id, "m1") != 1 {
+			t.Error("likes lost")
+		}
+
+		advance(testTTL + time.Minute)
+		if s.BoardExists(b.Id) {
+			t.Error("board should have expired after its retention")
+		}
+		if _, ok := s.GetMessage(b.Id, "m1"); ok {
+			t.Error("message should have expired with the board")
+		}
+	})
+
+	t.Run("ExpiringBoards", func(t *testing.T) {
+		s, _ := newStore(t)
+		soon := createTestBoard(t, s, "b1")
+		if !s.CreateBoard(&Board{Id: "b2", Name: "Retro", Owner: "owner", Status: InProgress}, nil, 3*testTTL) {
+			t.Fatal("CreateBoard failed")
+		}
+
+		ids, ok := s.BoardsExpiringBefore(time.Now().Add(2 * testTTL))
+		if !ok || !slices.Equal(ids, []string{"b1"}) {
+			t.Errorf("expiring boards = %v", ids)
+		}
+
+		// Follows expiry changes
+		later, _ := s.GetBoard("b2")
+		s.UpdateBoardExpiry(soon, soon.AutoDeleteAtUtc+int64(3*testTTL/time.Second))
+		s.UpdateBoardExpiry(later, time.Now().Add(testTTL/2).Unix())
+		if ids, _ := s.BoardsExpiringBefore(time.Now().Add(2 * testTTL)); !slices.Equal(ids, []string{"b2"}) {
+			t.Errorf("expiring boards after update = %v", ids)
+		}
+	})
+
+	t.Run("UserBoards", func(t *testing.T) {
+		s, advance := newStore(t)
+		ids := func(userId string) []string {
+			t.Helper()
+			boards, ok := s.GetUserBoards(userId)
+			if !ok {
+				t.Fatalf("GetUserBoards(%q) failed", userId)
+			}
+			var ids []string
+			for _, b := range boards {
+				ids = append(ids, b.Id)
+			}
+			slices.Sort(ids) // Redis boards are created at the wall clock time, the order isn't comparable
+			return ids
+		}
+
+		b1 := createTestBoard(t, s, "b1")
+		s.EnsureUser(b1.Id, "u1", "Alice")
+		s.EnsureUser(b1.Id, "u1", "Alice")
+		s.CreateBoard(&Board{Id: "b2", Name: "Sprint 2", Owner: "u1", Creator: "u1", Status: InProgress}, nil, 3*testTTL)
+		if got := ids("owner"); !slices.Equal(got, []string{"b1"}) {
+			t.Errorf("creator's boards = %v", got)
+		}
+		if got := ids("u1"); !slices.Equal(got, []string{"b1", "b2"}) {
+			t.Errorf("boards = %v", got)
+		}
+		if got := ids("nobody"); len(got) != 0 {
+			t.Errorf("unknown user's boards = %v", got)
+		}
+		if !s.UserTokenPending("u1") || !s.AckUserToken("u1") || s.UserTokenPending("u1") {
+			t.Error("user token still pending after the acknowledgement")
+		}
+
+		// New owners have the board, even without joining
+		s.UpdateBoardOwner(b1, "u2")
+		if got := ids("u2"); !slices.Equal(got, []string{"b1"}) {
+			t.Errorf("new owner's boards = %v", got)
+		}
+
+		// Follows expiry changes, then boards are dropped once they expire
+		s.UpdateBoardExpiry(b1, b1.AutoDeleteAtUtc+int64(testTTL/time.Second))
+		advance(testTTL + time.Minute)
+		if got := ids("u1"); !slices.Equal(got, []string{"b1", "b2"}) {
+			t.Errorf("boards after extending = %v", got)
+		}
+		advance(testTTL)
+		if got := ids("u1"); !slices.Equal(got, []string{"b2"}) {
+			t.Errorf("boards after expiry = %v", got)
+		}
+		if got := ids("owner"); len(got) != 0 {
+			t.Errorf("creator's boards after expiry = %v", got)
+		}
+
+		s.DeleteAll("b2")
+		if got := ids("u1"); len(got) != 0 {
+			t.Errorf("boards after deleting = %v", got)
+		}
+
+		// Without boards, there is nothing to protect. A new token can be issued.
+		advance(3 * testTTL)
+		if !s.UserTokenPending("u1") {
+			t.Error("user token mark outlived the user's boards")
+		}
+	})
+
+	t.Run("Teams", func(t *testing.T) {
+		s, advance := newStore(t)
+		if !s.CreateTeam(&Team{Id: "t1", Name: "Platform"}, 2*testTTL) {
+			t.Fatal("CreateTeam failed")
+		}
+		team, ok := s.GetTeam("t1")
+		if !ok || team.Name != "Platform" || team.CreatedAtUtc == 0 || team.AutoDeleteAtUtc <= team.CreatedAtUtc {
+			t.Fatalf("team = %+v", team)
+		}
+		if _, ok := s.GetTeam("t2"); ok {
+			t.Error("unknown team found")
+		}
+		if boards, ok := s.GetTeamBoards("t1"); !ok || len(boards) != 0 {
+			t.Errorf("boards of a new team = %v", boards)
+		}
+
+		b1 := createTestBoard(t, s, "b1")
+		if !s.AddTeamBoard("t1", b1, 2*testTTL) {
+			t.Fatal("AddTeamBoard failed")
+		}
+		advance(time.Second)
+		s.CreateBoard(&Board{Id: "b2", Name: "Sprint 2", Owner: "owner", Status: InProgress}, nil, 0)
+		b2, _ := s.GetBoard("b2")
+		s.AddTeamBoard("t1", b2, 2*testTTL)
+		s.AddTeamBoardParticipant("t1", "b1", "u1")
+		s.AddTeamBoardParticipant("t1", "b1", "u2")
+		s.AddTeamBoardParticipant("t1", "b1", "u1")
+		if s.AddTeamBoardParticipant("t2", "b1", "u1") {
+			t.Error("participant added to unknown team")
+		}
+
+		boards, ok := s.GetTeamBoards("t1")
+		if !ok || len(boards) != 2 {
+			t.Fatalf("boards = %v", boards)
+		}
+		if boards[0].Id != "b2" || boards[0].Name != "Sprint 2" || boards[0].Participants != 0 {
+			t.Errorf("newest board = %+v", boards[0])
+		}
+		if boards[1].Id != "b1" || boards[1].Name != "Retro" || boards[1].CreatedAtUtc != b1.CreatedAtUtc ||
+			boards[1].AutoDeleteAtUtc != b1.AutoDeleteAtUtc || boards[1].Participants != 2 {
+			t.Errorf("board = %+v", boards[1])
+		}
+
+		// Summaries outlive the boards
+		advance(testTTL + time.Minute)
+		if s.BoardExists("b1") {
+			t.Fatal("board should have expired")
+		}
+		if boards, _ := s.GetTeamBoards("t1"); len(boards) != 2 || boards[1].Participants != 2 {
+			t.Errorf("boards after expiry = %v", boards)
+		}
+
+		// Teams expire team retention after the latest board was added
+		advance(testTTL - 2*time.Minute)
+		if _, ok := s.GetTeam("t1"); !ok {
+			t.Fatal("team expired before its retention")
+		}
+		advance(2 * time.Minute)
+		if _, ok := s.GetTeam("t1"); ok {
+			t.Error("team should have expired")
+		}
+		if boards, _ := s.GetTeamBoards("t1"); len(boards) != 0 {
+			t.Errorf("boards of expired team = %v", boards)
+		}
+	})
+
+	t.Run("ExpiryUpdate", func(t *testing.T) {
+		s, advance := newStore(t)
+
+		// Extending keeps everything of the board past the original expiry
+		b := createTestBoard(t, s, "b1")
+		s.EnsureUser(b.Id, "u1", "Alice")
+		s.CommitUserPresence(b.Id, "u1")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b.Id, Category: "col01"}, AsNewMessage)
+		s.Save(&Message{Id: "c1", By: "u1", Group: b.Id, ParentId: "m1"}, AsNewComment)
+		s.Like(b.Id, "m1", "u1", true)
+		s.UpdateMessagePin(b.Id, "m1", true)
+		s.BanUser(b, "u2")
+		if !s.UpdateBoardExpiry(b, b.AutoDeleteAtUtc+int64(testTTL/time.Second)) {
+			t.Fatal("UpdateBoardExpiry failed")
+		}
+		if got, _ := s.GetBoard(b.Id); got.AutoDeleteAtUtc != b.AutoDeleteAtUtc+int64(testTTL/time.Second) {
+			t.Errorf("AutoDeleteAtUtc = %d", got.AutoDeleteAtUtc)
+		}
+
+		advance(testTTL + time.Minute)
+		data, ok := s.GetBoardAggregatedData(b.Id)
+		if !ok {
+			t.Fatal("board expired at its original time")
+		}
+		if len(data.Messages) != 1 || len(data.Comments) != 1 || len(data.Users) != 1 || len(data.PinnedMessageIds) != 1 || len(data.ActiveUserIds) != 1 {
+			t.Errorf("data lost: %d messages, %d comments, %d users, %d pins", len(data.Messages), len(data.Comments), len(data.Users), len(data.PinnedMessageIds))
+		}
+		if s.GetLikesCount(b.Id, "m1") != 1 {
+			t.Error("likes lost")
+		}
+		if cols, _ := s.GetBoardColumns(b.Id); len(cols) != 2 {
+			t.Errorf("columns lost: %d", len(cols))
+		}
+		if !s.IsBanned(b.Id, "u2") {
+			t.Error("ban lost")
+		}
+		advance(testTTL)
+		if s.BoardExists(b.Id) {
+			t.Error("board should have expired at its new time")
+		}
+
+		// Shortening
+		b2 := createTestBoard(t, s, "b2")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b2.Id, Category: "col01"}, AsNewMessage)
+		if !s.UpdateBoardExpiry(b2, b2.AutoDeleteAtUtc-int64(testTTL/2/time.Second)) {
+			t.Fatal("UpdateBoardExpiry failed")
+		}
+		advance(testTTL/2 + time.Minute)
+		if s.BoardExists(b2.Id) {
+			t.Error("board should have expired at its new time")
+		}
+		if _, ok := s.GetMessage(b2.Id, "m1"); ok {
+			t.Error("message should have expired with the board")
+		}
+
+		// Keys written after an extension live as long as the board
+		b3 := createTestBoard(t, s, "b3")
+		if !s.UpdateBoardExpiry(b3, b3.AutoDeleteAtUtc+int64(testTTL/time.Second)) {
+			t.Fatal("UpdateBoardExpiry failed")
+		}
+		s.EnsureUser(b3.Id, "u1", "Alice")
+		s.CommitUserPresence(b3.Id, "u1")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b3.Id, Category: "col01"}, AsNewMessage)
+		s.Save(&Message{Id: "c1", By: "u1", Group: b3.Id, ParentId: "m1"}, AsNewComment)
+		s.Like(b3.Id, "m1", "u1", true)
+		s.UpdateMessagePin(b3.Id, "m1", true)
+		advance(testTTL + time.Minute)
+		data, ok = s.GetBoardAggregatedData(b3.Id)
+		if !ok {
+			t.Fatal("board expired at its original time")
+		}
+		if len(data.Messages) != 1 || len(data.Comments) != 1 || len(data.Users) != 1 || len(data.PinnedMessageIds) != 1 || len(data.ActiveUserIds) != 1 {
+			t.Errorf("data written after extending lost: %d messages, %d comments, %d users, %d pins", len(data.Messages), len(data.Comments), len(data.Users), len(data.PinnedMessageIds))
+		}
+		if s.GetLikesCount(b3.Id, "m1") != 1 {
+			t.Error("likes written after extending lost")
+		}
+		advance(testTTL)
+
+		// Keys written after shortening don't outlive the board
+		b4 := createTestBoard(t, s, "b4")
+		if !s.UpdateBoardExpiry(b4, b4.AutoDeleteAtUtc-int64(testTTL/2/time.Second)) {
+			t.Fatal("UpdateBoardExpiry failed")
+		}
+		s.EnsureUser(b4.Id, "u1", "Alice")
+		s.Save(&Message{Id: "m1", By: "u1", Group: b4.Id, Category: "col01"}, AsNewMessage)
+		s.Like(b4.Id, "m1", "u1", true)
+		advance(testTTL/2 + time.Minute)
+		if _, ok := s.GetMessage(b4.Id, "m1"); ok {
+			t.Error("message written after shortening outlived the board")
+		}
+		if s.GetLikesCount(b4.Id, "m1") != 0 {
+			t.Error("likes written after shortening outlived the board")
+		}
+		if _, ok := s.GetUser(b4.Id, "u1"); ok {
+			t.Error("user written after shortening outlived the board")
+		}
+	})
+
+	t.Run("Passcode", func(t *testing.T) {
+		s, advance := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		if !s.UpdateBoardPasscode(b, "hash") {
+			t.Fatal("UpdateBoardPasscode failed")
+		}
+		if got, _ := s.GetBoard(b.Id); got.PasscodeHash != "hash" {
+			t.Errorf("passcode hash = %q", got.PasscodeHash)
+		}
+
+		for want := int64(1); want <= 3; want++ {
+			if n, ok := s.ReservePasscodeAttempt(b.Id, "10.0.0.1", time.Minute); !ok || n != want {
+				t.Errorf("ReservePasscodeAttempt = %d, %v, want %d", n, ok, want)
+			}
+		}
+		// A correct passcode takes its attempt back
+		if !s.ReleasePasscodeAttempt(b.Id, "10.0.0.1") {
+			t.Error("ReleasePasscodeAttempt failed")
+		}
+		if n, _ := s.ReservePasscodeAttempt(b.Id, "10.0.0.1", time.Minute); n != 3 {
+			t.Errorf("attempts after release = %d, want 3", n)
+		}
+		if n, _ := s.ReservePasscodeAttempt(b.Id, "10.0.0.2", time.Minute); n != 1 {
+			t.Errorf("attempts for another IP = %d, want 1", n)
+		}
+
+		// The window starts with the first attempt and isn't extended by later ones
+		advance(2 * time.Minute)
+		if n, _ := s.ReservePasscodeAttempt(b.Id, "10.0.0.1", time.Minute); n != 1 {
+			t.Errorf("attempts restarted at %d, want 1", n)
+		}
+		// Releasing after the window doesn't leave a count behind
+		advance(2 * time.Minute)
+		s.ReleasePasscodeAttempt(b.Id, "10.0.0.1")
+		if n, _ := s.ReservePasscodeAttempt(b.Id, "10.0.0.1", time.Minute); n != 1 {
+			t.Errorf("attempts after a late release = %d, want 1", n)
+		}
+	})
+
+	t.Run("Invites", func(t *testing.T) {
+		s, advance := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		now := time.Now()
+		limited := newInvite(b, time.Hour, 2, now)
+		short := newInvite(b, 2*time.Minute, 0, now.Add(time.Second))
+		for _, inv := range []*Invite{limited, short} {
+			if !s.CreateInvite(b, inv) {
+				t.Fatalf("CreateInvite(%s) failed", inv.Id)
+			}
+		}
+
+		// Checking doesn't use the invite
+		for range 3 {
+			if !s.CheckInvite(b.Id, limited.Id, "u1") {
+				t.Fatal("CheckInvite rejected a valid invite")
+			}
+		}
+		if invites, _ := s.GetInvites(b.Id); invites[0].Uses != 0 {
+			t.Errorf("CheckInvite used the invite: %d uses", invites[0].Uses)
+		}
+
+		for _, u := range []string{"u1", "u1", "u2"} {
+			if !s.UseInvite(b.Id, limited.Id, u) {
+				t.Errorf("UseInvite(%s) rejected", u)
+			}
+		}
+		if s.UseInvite(b.Id, limited.Id, "u3") || s.CheckInvite(b.Id, limited.Id, "u3") {
+			t.Error("invite accepted a user over the limit")
+		}
+		if !s.CheckInvite(b.Id, limited.Id, "u2") {
+			t.Error("CheckInvite rejected a user already counted")
+		}
+		if !s.UseInvite(b.Id, limited.Id, "u2") {
+			t.Error("user already counted rejected")
+		}
+		if s.UseInvite(b.Id, "missing", "u1") || s.UseInvite("b2", limited.Id, "u1") || s.CheckInvite(b.Id, "missing", "u1") {
+			t.Error("UseInvite accepted an unknown invite")
+		}
+
+		invites, ok := s.GetInvites(b.Id)
+		if !ok || len(invites) != 2 || invites[0].Id != limited.Id || invites[1].Id != short.Id {
+			t.Fatalf("GetInvites = %+v, %v", invites, ok)
+		}
+		if invites[0].Uses != 2 || invites[0].MaxUses != 2 || invites[1].Uses != 0 {
+			t.Errorf("invite uses = %+v", invites)
+		}
+
+		advance(3 * time.Minute)
+		if s.UseInvite(b.Id, short.Id, "u1") || s.CheckInvite(b.Id, short.Id, "u1") {
+			t.Error("expired invite accepted")
+		}
+		if invites, _ := s.GetInvites(b.Id); len(invites) != 1 || invites[0].Id != limited.Id {
+			t.Errorf("invites after expiry = %+v", invites)
+		}
+
+		if !s.RevokeInvite(b.Id, limited.Id) {
+			t.Error("RevokeInvite failed")
+		}
+		if s.RevokeInvite(b.Id, limited.Id) {
+			t.Error("RevokeInvite of a revoked invite succeeded")
+		}
+		if s.UseInvite(b.Id, limited.Id, "u1") || s.CheckInvite(b.Id, limited.Id, "u1") {
+			t.Error("revoked invite accepted")
+		}
+
+		if !s.UpdateBoardInviteOnly(b, true) {
+			t.Fatal("UpdateBoardInviteOnly failed")
+		}
+		if got, _ := s.GetBoard(b.Id); !got.InviteOnly {
+			t.Error("board not invite only")
+		}
+	})
+
+	t.Run("RequestCounts", func(t *testing.T) {
+		s, advance := newStore(t)
+
+		for want := int64(1); want <= 3; want++ {
+			count, retryAfter, ok := s.CountRequest("create", "10.0.0.1", time.Minute)
+			if !ok || count != want {
+				t.Fatalf("count = %d, %v, want %d", count, ok, want)
+			}
+			if retryAfter <= 0 || retryAfter > time.Minute {
+				t.Errorf("retryAfter = %v", retryAfter)
+			}
+		}
+		// Scopes and IPs are counted separately
+		if count, _, _ := s.CountRequest("ws", "10.0.0.1", time.Minute); count != 1 {
+			t.Errorf("other scope count = %d", count)
+		}
+		if count, _, _ := s.CountRequest("create", "10.0.0.2", time.Minute); count != 1 {
+			t.Errorf("other ip count = %d", count)
+		}
+
+		advance(time.Minute + time.Second)
+		if count, _, _ := s.CountRequest("create", "10.0.0.1", time.Minute); count != 1 {
+			t.Errorf("count after window = %d, want 1", count)
+		}
+	})
+
+	t.Run("Bans", func(t *testing.T) {
+		s, _ := newStore(t)
+		b := createTestBoard(t, s, "b1")
+
+		if s.IsBanned(b.Id, "u1") {
+			t.Fatal("user banned before BanUser")
+		}
+		if !s.BanUser(b, "u1") {
+			t.Fatal("BanUser failed")
+		}
+		if !s.IsBanned(b.Id, "u1") || s.IsBanned(b.Id, "u2") {
+			t.Error("IsBanned mismatch")
+		}
+
+		s.DeleteAll(b.Id)
+		if s.IsBanned(b.Id, "u1") {
+			t.Error("ban survived DeleteAll")
+		}
+	})
+
+	t.Run("PubSub", func(t *testing.T) {
+		s, _ := newStore(t)
+		s.Subscribe("b1")
+		// Redis SUBSCRIBE is asynchronous. Give it a moment before publishing.
+		time.Sleep(50 * time.Millisecond)
+
+		s.Publish("b2", &BroadcastArgs{Event: &Event{Type: "delall", Group: "b2"}})
+		s.Publish("b1", &BroadcastArgs{Event: &Event{Type: "delall", Group: "b1"}})
+
+		select {
+		case payload := <-s.Broadcasts():
+			var args BroadcastArgs
+			if err := json.Unmarshal([]byte(payload), &args); err != nil {
+				t.Fatalf("invalid payload %q: %v", payload, err)
+			}
+			if args.Event.Group != "b1" {
+				t.Errorf("received broadcast for %q, want b1", args.Event.Group)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no broadcast received")
+		}
+
+		s.Unsubscribe("b1")
+		time.Sleep(50 * time.Millisecond)
+		s.Publish("b1", &BroadcastArgs{Event: &Event{Type: "delall", Group: "b1"}})
+		select {
+		case payload := <-s.Broadcasts():
+			t.Errorf("unexpected broadcast after unsubscribe: %s", payload)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func createTestBoard(t *testing.T, s Store, id string) *Board {
+	t.Helper()
+	b := &Board{Id: id, Name: "Retro", Owner: "owner", Status: InProgress}
+	cols := []*BoardColumn{
+		{Id: "col01", Text: "Good", Color: "green", Position: 1},
+		{Id: "col02", Text: "Bad", Color: "red", Position: 2},
+	}
+	if !s.CreateBoard(b, cols, 0) {
+		t.Fatalf("CreateBoard(%s) failed", id)
+	}
+	// Handlers always work with the stored board, which carries the expiry time.
+	stored, ok := s.GetBoard(id)
+	if !ok {
+		t.Fatalf("GetBoard(%s) failed", id)
+	}
+	return stored
+}
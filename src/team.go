@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/lithammer/shortuuid/v4"
+)
+
+// Team spaces.
+// A team groups the retros of a team. Anyone with the team link (/team/{id}) sees its boards, past and active,
+// with their creation dates and participant counts, and can create boards in it.
+// Team ids are random like board ids, the link is what gives access.
+//
+// Boards keep their own retention. The team keeps a summary of each of its boards, so past retros stay listed after the board
+// is deleted. Teams are kept for team_retention in [data] of config.toml, counted from the latest board created in them.
+// Without team_retention, teams are disabled.
+
+// Team is a team space.
+type Team struct {
+	Id              string `redis:"id"`
+	Name            string `redis:"name"`
+	CreatedAtUtc    int64  `redis:"createdAtUtc"`
+	AutoDeleteAtUtc int64  `redis:"autoDeleteAtUtc"`
+}
+
+// TeamBoard is the team's summary of one of its boards. It outlives the board.
+type TeamBoard struct {
+	Id              string `redis:"id"`
+	Name            string `redis:"name"`
+	CreatedAtUtc    int64  `redis:"createdAtUtc"`
+	AutoDeleteAtUtc int64  `redis:"autoDeleteAtUtc"`
+	Participants    int64  `redis:"-"` // Users who joined the board
+}
+
+type CreateTeamReq struct {
+	Name string `json:"name"`
+}
+
+type CreateTeamRes struct {
+	Id string `json:"id"`
+}
+
+type GetTeamRes struct {
+	Id           string             `json:"id"`
+	Name         string             `json:"name"`
+	CreatedAtUtc int64              `json:"createdAtUtc"`
+	Boards       []TeamBoardSummary `json:"boards"` // Newest first
+}
+
+type TeamBoardSummary struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	CreatedAtUtc    int64  `json:"createdAtUtc"`
+	AutoDeleteAtUtc int64  `json:"autoDeleteAtUtc"` // When the board is, or was, deleted
+	Participants    int64  `json:"participants"`
+	Active          bool   `json:"active"` // The board still exists
+}
+
+// teamRetention is how long teams are kept after their latest board was created. 0 when teams are disabled.
+func teamRetention() time.Duration {
+	if config.Data.TeamRetention == "" {
+		return 0
+	}
+	d, err := parseDuration(config.Data.TeamRetention)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+func teamsEnabled() bool {
+	return teamRetention() > 0
+}
+
+// validTeamId checks a team id from a request. They are generated like board ids.
+func validTeamId(id string) bool {
+	return id != "" && len(id) <= MaxIdSizeBytes && !strings.ContainsAny(id, "{}:")
+}
+
+// HandleCreateTeam creates a team space.
+func HandleCreateTeam(c Store, w http.ResponseWriter, r *http.Request) {
+	if !isOriginAllowed(r) {
+		slog.Warn("Rejected request with disallowed origin", "origin", r.Header.Get("Origin"), "remote", remoteIP(r))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	var req CreateTeamReq
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		if mr, ok := errors.AsType[*malformedRequest](err); ok {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			slog.Error("Error parsing CreateTeamRequest", "details", err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > config.Data.MaxTextLength {
+		http.Error(w, "Invalid team name", http.StatusBadRequest)
+		return
+	}
+
+	team := &Team{Id: shortuuid.New(), Name: req.Name}
+	if !c.CreateTeam(team, teamRetention()) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Created team", "team", team.Id)
+
+	data, err := json.Marshal(CreateTeamRes{Id: team.Id})
+	if err != nil {
+		slog.Error("Error marshalling CreateTeamRes", "details", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// HandleGetTeam lists the team's boards, past and active.
+func HandleGetTeam(c Store, w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !validTeamId(id) {
+		http.NotFound(w, r)
+		return
+	}
+	team, ok := c.GetTeam(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	boards, ok := c.GetTeamBoards(id)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	res := GetTeamRes{Id: team.Id, Name: team.Name, CreatedAtUtc: team.CreatedAtUtc, Boards: make([]TeamBoardSummary, 0, len(boards))}
+	for _, tb := range boards {
+		summary := TeamBoardSummary{Id: tb.Id, Name: tb.Name, CreatedAtUtc: tb.CreatedAtUtc, AutoDeleteAtUtc: tb.AutoDeleteAtUtc, Participants: tb.Participants}
+		// Live boards may have been renamed, or got another expiry, since
+		if b, live := c.GetBoard(tb.Id); live {
+			summary.Active, summary.Name, summary.AutoDeleteAtUtc = true, b.Name, b.AutoDeleteAtUtc
+		}
+		res.Boards = append(res.Boards, summary)
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		slog.Error("Error marshalling GetTeamRes", "details", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
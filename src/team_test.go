@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTeamSpace(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+	config.Server.AllowedOrigins = []string{"https://localhost"}
+	config.Data.MaxTextLength = 80
+	config.Data.MaxCategoryTextLength = 80
+	config.Data.AutoDeleteDuration = "1h"
+	config.Data.TeamRetention = "30d"
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	post := func(handler func(Store, http.ResponseWriter, *http.Request), path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Origin", "https://localhost")
+		w := httptest.NewRecorder()
+		handler(store, w, r)
+		return w
+	}
+	getTeam := func(id string) (*GetTeamRes, int) {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/team/"+id, nil), map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		HandleGetTeam(store, w, r)
+		var res GetTeamRes
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return &res, w.Code
+	}
+
+	for _, invalid := range []string{`{"name":""}`, `{"name":"   "}`, `{"name":"` + strings.Repeat("x", 81) + `"}`} {
+		if w := post(HandleCreateTeam, "/api/team/create", invalid); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", invalid, w.Code)
+		}
+	}
+	w := post(HandleCreateTeam, "/api/team/create", `{"name":" Platform "}`)
+	var created CreateTeamRes
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil || created.Id == "" {
+		t.Fatalf("create team: status %d, %s", w.Code, w.Body)
+	}
+
+	// Boards created in the team carry its name
+	createBoard := func(teamId string) *httptest.ResponseRecorder {
+		body := `{"name":"Sprint 1","owner":"alice","teamId":"` + teamId + `","columns":[{"id":"col01","text":"Good","color":"green","pos":1}]}`
+		return post(HandleCreateBoard, "/api/board/create", body)
+	}
+	if w := createBoard("missing"); w.Code != http.StatusBadRequest {
+		t.Errorf("board in unknown team: status %d", w.Code)
+	}
+	w = createBoard(created.Id)
+	var board CreateBoardRes
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &board) != nil {
+		t.Fatalf("create board: status %d, %s", w.Code, w.Body)
+	}
+	b, _ := store.GetBoard(board.Id)
+	if b.TeamId != created.Id || b.Team != "Platform" {
+		t.Errorf("board team = %q %q", b.TeamId, b.Team)
+	}
+	store.AddTeamBoardParticipant(created.Id, board.Id, "alice")
+
+	res, code := getTeam(created.Id)
+	if code != http.StatusOK || res.Name != "Platform" || len(res.Boards) != 1 {
+		t.Fatalf("get team: status %d, %+v", code, res)
+	}
+	if s := res.Boards[0]; s.Id != board.Id || s.Name != "Sprint 1" || !s.Active || s.Participants != 1 || s.AutoDeleteAtUtc != b.AutoDeleteAtUtc {
+		t.Errorf("board summary = %+v", s)
+	}
+
+	// Past boards stay listed
+	store.DeleteAll(board.Id)
+	if res, _ := getTeam(created.Id); len(res.Boards) != 1 || res.Boards[0].Active || res.Boards[0].Name != "Sprint 1" {
+		t.Errorf("deleted board = %+v", res.Boards)
+	}
+
+	for _, id := range []string{"missing", "{x}"} {
+		if _, code := getTeam(id); code != http.StatusNotFound {
+			t.Errorf("team %q: status %d", id, code)
+		}
+	}
+
+	// Without team_retention, boards can't be created in teams
+	config.Data.TeamRetention = ""
+	if w := createBoard(created.Id); w.Code != http.StatusBadRequest {
+		t.Errorf("teams disabled: status %d", w.Code)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 // Store
 type User struct {
-	Id       string `redis:"id"`
-	Xid      string `redis:"xid"`
-	Nickname string `redis:"nickname"`
+	Id        string `redis:"id"`
+	Xid       string `redis:"xid"`
+	Nickname  string `redis:"nickname"`
+	Moderator bool   `redis:"moderator"` // Set by the board owner. See moderator.go
+	// Bumped on every moderator change, so tokens of removed moderators stop working. See moderatorToken
+	ModeratorEpoch int64 `redis:"modEpoch"`
 }
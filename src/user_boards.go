@@ -0,0 +1,107 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+)
+
+// My boards.
+// The store keeps an index of the boards each user created, owns or joined, so users can find them again.
+// GET /api/user/{id}/boards lists them. Signed-in users need their session, see checkUserCredential.
+// User ids show up in shared URLs and logs, so anonymous users need their user token in the X-User-Token header.
+// It is sent with the RegisterResponse of the user's websocket connections, until the client acknowledges it with
+// a "utokack" event. Later connections with the same id don't get it, so knowing the id isn't enough. A response that
+// never arrived is sent again on the next connection. The store forgets the acknowledgement with the user's boards index.
+// Boards are dropped from the index once they expire or are deleted.
+
+const userTokenHeader = "X-User-Token"
+
+// Roles in UserBoard
+const (
+	UserBoardRoleOwner       = "owner"       // Current owner
+	UserBoardRoleCreator     = "creator"     // Created the board, and handed it over
+	UserBoardRoleParticipant = "participant" // Joined the board
+)
+
+type UserBoardsRes struct {
+	Boards []UserBoard `json:"boards"` // Newest first
+}
+
+type UserBoard struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	Team            string `json:"team"`
+	TeamId          string `json:"teamId,omitempty"`
+	Role            string `json:"role"`
+	CreatedAtUtc    int64  `json:"createdAtUtc"`
+	AutoDeleteAtUtc int64  `json:"autoDeleteAtUtc"`
+}
+
+func userBoardRole(b *Board, userId string) string {
+	switch userId {
+	case b.Owner:
+		return UserBoardRoleOwner
+	case b.Creator:
+		return UserBoardRoleCreator
+	default:
+		return UserBoardRoleParticipant
+	}
+}
+
+// sortUserBoards sorts newest first.
+func sortUserBoards(boards []*Board) {
+	slices.SortFunc(boards, func(x, y *Board) int {
+		return cmp.Or(cmp.Compare(y.CreatedAtUtc, x.CreatedAtUtc), cmp.Compare(x.Id, y.Id))
+	})
+}
+
+// HandleGetUserBoards lists the boards of the user.
+func HandleGetUserBoards(c Store, w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["id"]
+	if userId == "" || len(userId) > MaxIdSizeBytes {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	s, reason := checkUserCredential(r, userId)
+	if reason == "" && s == nil && !isUserToken(userId, r.Header.Get(userTokenHeader)) {
+		reason = "USERTOKEN"
+	}
+	if reason != "" {
+		slog.Warn("Rejected user boards request", "reason", reason, "ip", remoteIP(r))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	boards, ok := c.GetUserBoards(userId)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	res := UserBoardsRes{Boards: make([]UserBoard, 0, len(boards))}
+	for _, b := range boards {
+		res.Boards = append(res.Boards, UserBoard{
+			Id:              b.Id,
+			Name:            b.Name,
+			Team:            b.Team,
+			TeamId:          b.TeamId,
+			Role:            userBoardRole(b, userId),
+			CreatedAtUtc:    b.CreatedAtUtc,
+			AutoDeleteAtUtc: b.AutoDeleteAtUtc,
+		})
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		slog.Error("Error marshalling UserBoardsRes", "details", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func getUserBoards(t *testing.T, s Store, userId string) (*UserBoardsRes, int) {
+	t.Helper()
+	return getUserBoardsWithToken(t, s, userId, userToken(userId))
+}
+
+func getUserBoardsWithToken(t *testing.T, s Store, userId, token string) (*UserBoardsRes, int) {
+	t.Helper()
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/user/"+userId+"/boards", nil), map[string]string{"id": userId})
+	if token != "" {
+		r.Header.Set(userTokenHeader, token)
+	}
+	w := httptest.NewRecorder()
+	HandleGetUserBoards(s, w, r)
+	var res UserBoardsRes
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return &res, w.Code
+}
+
+func TestHandleGetUserBoards(t *testing.T) {
+	s, advance := newTestMemoryStore(t)
+	s.CreateBoard(&Board{Id: "b1", Name: "Sprint 1", Team: "Platform", Owner: "alice", Creator: "alice", Status: InProgress}, nil, 0)
+	b1, _ := s.GetBoard("b1")
+	s.EnsureUser("b1", "bob", "Bob")
+	s.EnsureUser("b1", "carol", "Carol")
+	s.UpdateBoardOwner(b1, "bob")
+	advance(time.Second)
+	s.CreateBoard(&Board{Id: "b2", Name: "Sprint 2", Owner: "alice", Creator: "alice", Status: InProgress}, nil, 0)
+
+	res, code := getUserBoards(t, s, "alice")
+	if code != http.StatusOK || len(res.Boards) != 2 {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	if b := res.Boards[0]; b.Id != "b2" || b.Role != UserBoardRoleOwner {
+		t.Errorf("newest board = %+v", b)
+	}
+	if b := res.Boards[1]; b.Id != "b1" || b.Name != "Sprint 1" || b.Team != "Platform" || b.Role != UserBoardRoleCreator ||
+		b.AutoDeleteAtUtc != b1.AutoDeleteAtUtc {
+		t.Errorf("handed over board = %+v", b)
+	}
+	for user, role := range map[string]string{"bob": UserBoardRoleOwner, "carol": UserBoardRoleParticipant} {
+		if res, _ := getUserBoards(t, s, user); len(res.Boards) != 1 || res.Boards[0].Role != role {
+			t.Errorf("%s's boards = %+v", user, res.Boards)
+		}
+	}
+	if res, code := getUserBoards(t, s, "dave"); code != http.StatusOK || res.Boards == nil || len(res.Boards) != 0 {
+		t.Errorf("without boards: status %d, %+v", code, res)
+	}
+
+	// Knowing the user id isn't enough
+	for name, token := range map[string]string{"no": "", "another user's": userToken("bob"), "forged": "forged"} {
+		if res, code := getUserBoardsWithToken(t, s, "alice", token); code != http.StatusUnauthorized || len(res.Boards) != 0 {
+			t.Errorf("%s token: status %d, %+v", name, code, res)
+		}
+	}
+}
+
+func TestHandleGetUserBoards_SignedIn(t *testing.T) {
+	app := newOIDCTestApp(t)
+	client := app.signIn(t)
+	userId := identityUserId(app.provider.URL, "alice@example")
+	if _, boardId := app.createBoard(t, client, userId); boardId == "" {
+		t.Fatal("create board failed")
+	}
+
+	get := func(client *http.Client, userId string) int {
+		resp, err := client.Get(app.url + "/api/user/" + userId + "/boards")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get(client, userId); code != http.StatusOK {
+		t.Errorf("own boards: %d", code)
+	}
+	// Like joining, the session decides who you are
+	if code := get(client, "someone-else"); code != http.StatusUnauthorized {
+		t.Errorf("boards of another user: %d", code)
+	}
+	if code := get(&http.Client{}, userId); code != http.StatusUnauthorized {
+		t.Errorf("anonymous request for the identity's boards: %d", code)
+	}
+}
+
+func TestWebsocket_UserTokenUntilAcknowledged(t *testing.T) {
+	prevOrigins, prevMaxText := config.Server.AllowedOrigins, config.Data.MaxTextLength
+	config.Server.AllowedOrigins = []string{"https://localhost"}
+	config.Data.MaxTextLength = 80
+	t.Cleanup(func() { config.Server.AllowedOrigins, config.Data.MaxTextLength = prevOrigins, prevMaxText })
+
+	store := NewMemoryStore(time.Hour)
+	t.Cleanup(store.Close)
+	store.CreateBoard(&Board{Id: "board1", Name: "Retro", Owner: "alice", Creator: "alice"}, []*BoardColumn{{Id: "col01", Text: "Good", Position: 1}}, 0)
+	hub := newHub(store)
+	go hub.run()
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/board/{board}/user/{user}/meet", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(hub, w, r)
+	})
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	// register joins as bob, acknowledges ack if set, and returns the user token of the RegisterResponse
+	register := func(ack string) string {
+		t.Helper()
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/board/board1/user/bob/meet?nickname=Bob"
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": []string{"https://localhost"}})
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		if err := conn.WriteJSON(map[string]any{"typ": "reg", "pyl": map[string]any{}}); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var res RegisterResponse
+		for res.Type != "reg" {
+			if err := conn.ReadJSON(&res); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+		}
+		if ack != "" {
+			if err := conn.WriteJSON(map[string]any{"typ": "utokack", "pyl": map[string]any{"token": ack}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.UserToken
+	}
+	waitAcked := func() bool {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if !store.UserTokenPending("bob") {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Never acknowledged, as if the response was lost. The next connection gets it again.
+	if token := register(""); token != userToken("bob") {
+		t.Fatalf("first connection got %q", token)
+	}
+	token := register("forged")
+	if token != userToken("bob") {
+		t.Fatalf("connection after a lost response got %q", token)
+	}
+	if waitAcked() {
+		t.Fatal("forged token acknowledged")
+	}
+
+	register(token)
+	if !waitAcked() {
+		t.Fatal("user token not acknowledged")
+	}
+	if token := register(""); token != "" {
+		t.Errorf("connection after the acknowledgement got %q", token)
+	}
+}
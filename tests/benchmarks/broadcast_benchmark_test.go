@@ -86,7 +86,7 @@ func setupBenchmarkData(c *RedisConnector) {
 		// Add some likes (User0, User1, User2 like every message)
 		for u := 0; u < 3; u++ {
 			likerID := fmt.Sprintf("user%d", u)
-			if !c.Like(msgID, likerID, true) {
+			if !c.Like(BenchBoardID, msgID, likerID, true) {
 				// It's okay if this fails on duplicate likes, but shouldn't fail on fresh data
 				slog.Warn("Like failed", "msg", msgID, "user", likerID)
 			}
@@ -23,8 +23,11 @@ type RegisterResponse struct {
 	TimerExpiresInSeconds     uint16            `json:"timerExpiresInSeconds"` // uint16 since we are restricting timer to max 1 hour (3600 seconds)
 	BoardMasking              bool              `json:"boardMasking"`
 	BoardLock                 bool              `json:"boardLock"`
+	BoardPasscode             bool              `json:"boardPasscode"`
+	BoardInviteOnly           bool              `json:"boardInviteOnly"`
 	IsBoardOwner              bool              `json:"isBoardOwner"`
 	IsBoardCreator            bool              `json:"isBoardCreator"`
+	IsBoardModerator          bool              `json:"isBoardModerator"`
 	ShowWelcomePopup          bool              `json:"showWelcomePopup"`
 }
 type BoardColumn struct {
@@ -35,10 +38,11 @@ type BoardColumn struct {
 	IsDefault bool   `redis:"isDefault" json:"isDefault"`
 }
 type UserDetails struct {
-	Nickname string `json:"nickname"`
-	Xid      string `json:"xid"`
-	Active   bool   `json:"active"`
-	IsOwner  bool   `json:"isOwner"`
+	Nickname    string `json:"nickname"`
+	Xid         string `json:"xid"`
+	Active      bool   `json:"active"`
+	IsOwner     bool   `json:"isOwner"`
+	IsModerator bool   `json:"isModerator"`
 }
 type UserJoiningResponse struct {
 	Type     string `json:"typ"`
@@ -87,15 +91,27 @@ type DeleteAllResponse struct {
 }
 
 type SettingsEvent struct {
-	OwnerXid *string `json:"ownerXid,omitempty"`
-	Mask     *bool   `json:"mask,omitempty"`
-	Lock     *bool   `json:"lock,omitempty"`
+	OwnerXid   *string          `json:"ownerXid,omitempty"`
+	Mask       *bool            `json:"mask,omitempty"`
+	Lock       *bool            `json:"lock,omitempty"`
+	Passcode   *string          `json:"passcode,omitempty"`
+	InviteOnly *bool            `json:"inviteOnly,omitempty"`
+	Moderator  *ModeratorChange `json:"moderator,omitempty"`
+}
+type ModeratorChange struct {
+	Xid       string `json:"xid"`
+	Moderator bool   `json:"moderator"`
 }
 type SettingsResponse struct {
-	Type     string `json:"typ"`
-	OwnerXid string `json:"ownerXid"`
-	Mask     bool   `json:"mask"`
-	Lock     bool   `json:"lock"`
+	Type           string           `json:"typ"`
+	OwnerXid       string           `json:"ownerXid"`
+	Mask           bool             `json:"mask"`
+	Lock           bool             `json:"lock"`
+	Passcode       bool             `json:"passcode"`
+	InviteOnly     bool             `json:"inviteOnly"`
+	Moderator      *ModeratorChange `json:"moderator,omitempty"`
+	OwnerToken     string           `json:"ownerToken,omitempty"`
+	ModeratorToken string           `json:"moderatorToken,omitempty"`
 }
 
 type CategoryChangeEvent struct {
@@ -141,6 +157,14 @@ type ColumnsChangeResponse struct {
 	BoardColumns []*BoardColumn `json:"columns"`
 }
 
+type ColumnMoveEvent struct {
+	Order []string `json:"order"`
+}
+type ColumnMoveResponse struct {
+	Type  string   `json:"typ"`
+	Order []string `json:"order"`
+}
+
 type BroadcastArgs struct {
 	Event Event `json:"event"`
 	// Message *Message `json:"message,omitempty"` // simplified
@@ -163,3 +187,38 @@ type PinMessageResponse struct {
 	Id   string `json:"id"`
 	Pin  bool   `json:"pin"`
 }
+
+type InviteCreateEvent struct {
+	ExpiresInSeconds int64 `json:"expiresInSeconds"`
+	MaxUses          int64 `json:"maxUses"`
+}
+
+type InviteRevokeEvent struct {
+	Id string `json:"id"`
+}
+
+type Invite struct {
+	Id           string `json:"id"`
+	CreatedAtUtc int64  `json:"createdAtUtc"`
+	ExpiresAtUtc int64  `json:"expiresAtUtc"`
+	MaxUses      int64  `json:"maxUses"`
+	Uses         int64  `json:"uses"`
+}
+
+type InvitesResponse struct {
+	Type    string    `json:"typ"`
+	Invites []*Invite `json:"invites"`
+}
+
+type KickEvent struct {
+	Xid         string `json:"xid"`
+	Ban         bool   `json:"ban"`
+	DeleteCards bool   `json:"deleteCards"`
+}
+
+type KickResponse struct {
+	Type       string   `json:"typ"`
+	Xid        string   `json:"xid"`
+	Ban        bool     `json:"ban"`
+	MessageIds []string `json:"messageIds,omitempty"`
+}
@@ -21,13 +21,14 @@ const (
 	MaxTextLength         int = 80
 )
 
-func CreateBoard(t *testing.T, ownerId string) string {
+// CreateBoard returns the board id and the owner token issued to the creator.
+func CreateBoard(t *testing.T, ownerId string) (string, string) {
 	payload := map[string]any{
 		"name":  "E2E Test Board",
 		"team":  "Test Team",
 		"owner": ownerId,
-		// "cfTurnstileResponse": "1x00000000000000000000AA", // Dummy always pass token
-		"cfTurnstileResponse": "",
+		// "challengeResponse": "1x00000000000000000000AA", // Dummy always pass token
+		"challengeResponse": "",
 		"columns": []map[string]any{
 			{"id": "col01", "text": "What went well", "isDefault": true, "color": "green", "pos": 1},
 			{"id": "col02", "text": "Challenges", "isDefault": true, "color": "red", "pos": 2},
@@ -64,7 +65,7 @@ func CreateBoard(t *testing.T, ownerId string) string {
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	require.NoError(t, err)
 
-	return result["id"]
+	return result["id"], result["ownerToken"]
 }
 
 // SetupBoardAndUsers creates a board and three users (Alice as owner, Bob as guest, Clark as guest) but does not connect them.
@@ -75,10 +76,11 @@ func SetupBoardAndUsers(t *testing.T) (string, *TestUser, *TestUser, *TestUser)
 	userCId := "user-c"
 
 	// Create Board
-	boardId := CreateBoard(t, userAId)
+	boardId, ownerToken := CreateBoard(t, userAId)
 	t.Logf("Created board: %s", boardId)
 
 	userA := NewUser(userAId, "Alice", boardId)
+	userA.Token = ownerToken
 	userB := NewUser(userBId, "Bob", boardId)
 	userC := NewUser(userCId, "Clark", boardId)
 
@@ -1,7 +1,9 @@
 package harness
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,15 +15,34 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
+// Websocket subprotocols (mirrored from source)
+const (
+	SubprotocolJSON    = "quickretro.json"
+	SubprotocolMsgpack = "quickretro.msgpack"
+)
+
+// credentialSubprotocol offers a credential in the handshake, like the web frontend (mirrored from source)
+func credentialSubprotocol(name, value string) string {
+	return "quickretro." + name + "." + base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
 type TestUser struct {
-	Id       string
-	Nickname string
-	Board    string
-	Conn     *websocket.Conn
-	Received chan Event
-	Done     chan struct{}
+	Id          string
+	Nickname    string
+	Board       string
+	Subprotocol string // Requested through Sec-WebSocket-Protocol before Connect. Empty means plain JSON.
+	Token       string // Owner token offered on Connect. Only set for the board creator.
+	ModToken    string // Moderator token offered on Connect.
+	Passcode    string // Board passcode offered on Connect, for passcode protected boards.
+	Invite      string // Invite id passed on Connect.
+	CloseReason string // Close reason sent by the server, e.g. "BOARDNOTFOUND". Read it after Done is closed.
+	Conn        *websocket.Conn
+	Handshake   *http.Response
+	Received    chan Event
+	Done        chan struct{}
 }
 
 func NewUser(id, nickname, board string) *TestUser {
@@ -44,6 +65,9 @@ func NewUser(id, nickname, board string) *TestUser {
 
 func (u *TestUser) Connect(baseUrl string) error {
 	urlStr := fmt.Sprintf("%s/ws/board/%s/user/%s/meet?nickname=%s", baseUrl, u.Board, u.Id, url.QueryEscape(u.Nickname))
+	if u.Invite != "" {
+		urlStr += "&invite=" + url.QueryEscape(u.Invite)
+	}
 	// Convert http(s) to ws(s)
 	if len(urlStr) > 4 && urlStr[:5] == "https" {
 		urlStr = "wss" + urlStr[5:]
@@ -51,17 +75,39 @@ func (u *TestUser) Connect(baseUrl string) error {
 		urlStr = "ws" + urlStr[4:]
 	}
 
-	dialer := websocket.DefaultDialer
+	dialer := *websocket.DefaultDialer
 	dialer.TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: true,
 		ServerName:         "localhost",
 	}
+	dialer.EnableCompression = true
+	if u.Subprotocol != "" {
+		dialer.Subprotocols = []string{u.Subprotocol}
+	}
+	// Credentials are offered as extra subprotocols, next to one the server can select
+	var credentials []string
+	if u.Token != "" {
+		credentials = append(credentials, credentialSubprotocol("token", u.Token))
+	}
+	if u.ModToken != "" {
+		credentials = append(credentials, credentialSubprotocol("modtoken", u.ModToken))
+	}
+	if u.Passcode != "" {
+		credentials = append(credentials, credentialSubprotocol("passcode", u.Passcode))
+	}
+	if len(credentials) > 0 {
+		if len(dialer.Subprotocols) == 0 {
+			dialer.Subprotocols = []string{SubprotocolJSON}
+		}
+		dialer.Subprotocols = append(dialer.Subprotocols, credentials...)
+	}
 
-	conn, _, err := dialer.Dial(urlStr, http.Header{"Origin": []string{"https://localhost"}})
+	conn, resp, err := dialer.Dial(urlStr, http.Header{"Origin": []string{"https://localhost"}})
 	if err != nil {
 		return err
 	}
 	u.Conn = conn
+	u.Handshake = resp
 
 	// // If u.Done is already closed or nil, recreate it
 	// u.Done = make(chan struct{})
@@ -70,12 +116,23 @@ func (u *TestUser) Connect(baseUrl string) error {
 	go func() {
 		defer close(u.Done)
 		for {
-			_, p, err := u.Conn.ReadMessage()
+			frameType, p, err := u.Conn.ReadMessage()
 			if err != nil {
+				if ce, ok := err.(*websocket.CloseError); ok {
+					u.CloseReason = ce.Text
+				}
 				log.Printf("[User %s] Read error: %v", u.Id, err)
 				return
 			}
 
+			// Scenarios only deal with JSON. Convert MessagePack frames.
+			if frameType == websocket.BinaryMessage {
+				if p, err = msgpackToJSON(p); err != nil {
+					log.Printf("[User %s] MessagePack decode error: %v", u.Id, err)
+					continue
+				}
+			}
+
 			var header struct {
 				Type string `json:"typ"`
 			}
@@ -110,9 +167,30 @@ func (u *TestUser) SendEvent(typ string, payload any) error {
 		Type:    typ,
 		Payload: data,
 	}
+	if u.Conn.Subprotocol() == SubprotocolMsgpack {
+		return u.writeMsgpack(typ, payload)
+	}
 	return u.Conn.WriteJSON(event)
 }
 
+func (u *TestUser) writeMsgpack(typ string, payload any) error {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json") // Same field names as JSON
+	if err := enc.Encode(map[string]any{"typ": typ, "pyl": payload}); err != nil {
+		return err
+	}
+	return u.Conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+func msgpackToJSON(p []byte) ([]byte, error) {
+	var v any
+	if err := msgpack.Unmarshal(p, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
 func (u *TestUser) Register() error {
 	reg := RegisterEvent{}
 	return u.SendEvent("reg", reg)
@@ -195,6 +273,28 @@ func (u *TestUser) LockBoard(lock bool) error {
 	return u.SendEvent("set", setEv)
 }
 
+func (u *TestUser) SetPasscode(passcode string) error {
+	setEv := SettingsEvent{
+		Passcode: &passcode,
+	}
+	return u.SendEvent("set", setEv)
+}
+
+func (u *TestUser) SetInviteOnly(inviteOnly bool) error {
+	setEv := SettingsEvent{
+		InviteOnly: &inviteOnly,
+	}
+	return u.SendEvent("set", setEv)
+}
+
+func (u *TestUser) CreateInvite(expiresInSeconds, maxUses int64) error {
+	return u.SendEvent("invcreate", InviteCreateEvent{ExpiresInSeconds: expiresInSeconds, MaxUses: maxUses})
+}
+
+func (u *TestUser) RevokeInvite(id string) error {
+	return u.SendEvent("invrevoke", InviteRevokeEvent{Id: id})
+}
+
 func (u *TestUser) TransferOwnership(ownerXid string) error {
 	setEv := SettingsEvent{
 		OwnerXid: &ownerXid,
@@ -202,6 +302,17 @@ func (u *TestUser) TransferOwnership(ownerXid string) error {
 	return u.SendEvent("set", setEv)
 }
 
+func (u *TestUser) SetModerator(xid string, moderator bool) error {
+	setEv := SettingsEvent{
+		Moderator: &ModeratorChange{Xid: xid, Moderator: moderator},
+	}
+	return u.SendEvent("set", setEv)
+}
+
+func (u *TestUser) Kick(xid string, ban, deleteCards bool) error {
+	return u.SendEvent("kick", KickEvent{Xid: xid, Ban: ban, DeleteCards: deleteCards})
+}
+
 func (u *TestUser) ChangeCategoryOfMessage(msgId, oldCategory, newCategory string) error {
 	return u.changeMessageCategory(msgId, oldCategory, newCategory, nil)
 }
@@ -272,6 +383,10 @@ func (u *TestUser) ChangeColumns(cols []*BoardColumn) error {
 	return u.SendEvent("colreset", colChangeEv)
 }
 
+func (u *TestUser) MoveColumns(order []string) error {
+	return u.SendEvent("colmove", ColumnMoveEvent{Order: order})
+}
+
 func (u *TestUser) MustWaitForEvent(t *testing.T, eventType string, target any) {
 	t.Helper()
 
@@ -48,11 +48,11 @@ func TestBoardCreation(t *testing.T) {
 
 	validPayload := func() map[string]any {
 		return map[string]any{
-			"name":                "Valid Board",
-			"team":                "Valid Team",
-			"owner":               "user-valid-owner",
-			"cfTurnstileResponse": "", // Disabled in dev/test
-			"columns":             validColumns,
+			"name":              "Valid Board",
+			"team":              "Valid Team",
+			"owner":             "user-valid-owner",
+			"challengeResponse": "", // Disabled in dev/test
+			"columns":           validColumns,
 		}
 	}
 
@@ -0,0 +1,79 @@
+package scenarios
+
+import (
+	"e2e_tests/harness"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Alice uses MessagePack, Bob the default JSON and Clark asks for JSON explicitly. They must see the same board.
+func TestEncodings(t *testing.T) {
+	_, userA, userB, userC := harness.SetupBoardAndUsers(t)
+	userA.Subprotocol = harness.SubprotocolMsgpack
+	userC.Subprotocol = harness.SubprotocolJSON
+
+	require.NoError(t, userA.Connect(harness.BaseURL))
+	require.NoError(t, userB.Connect(harness.BaseURL))
+	require.NoError(t, userC.Connect(harness.BaseURL))
+	t.Cleanup(func() {
+		userA.Close()
+		userB.Close()
+		userC.Close()
+	})
+
+	t.Run("Subprotocol and compression are negotiated", func(t *testing.T) {
+		require.Equal(t, harness.SubprotocolMsgpack, userA.Conn.Subprotocol())
+		require.Equal(t, "", userB.Conn.Subprotocol())
+		require.Equal(t, harness.SubprotocolJSON, userC.Conn.Subprotocol())
+		for _, u := range []*harness.TestUser{userA, userB, userC} {
+			require.Contains(t, u.Handshake.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+		}
+	})
+
+	t.Run("MessagePack user registers", func(t *testing.T) {
+		require.NoError(t, userA.Register())
+		var got harness.RegisterResponse
+		userA.MustWaitForEvent(t, "reg", &got)
+		require.True(t, got.IsBoardOwner)
+		require.Equal(t, 5, len(got.BoardColumns))
+
+		var joining harness.UserJoiningResponse
+		userB.MustWaitForEvent(t, "joining", &joining)
+		require.Equal(t, userA.Nickname, joining.Nickname)
+
+		require.NoError(t, userB.Register())
+		require.NoError(t, userC.Register())
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+	})
+
+	t.Run("Message sent with MessagePack reaches JSON users", func(t *testing.T) {
+		require.NoError(t, userA.SendMessage("enc-msg-1", "Sent as MessagePack", "col01"))
+
+		for _, u := range []*harness.TestUser{userB, userC} {
+			var got harness.MessageResponse
+			u.MustWaitForEvent(t, "msg", &got)
+			require.Equal(t, "enc-msg-1", got.Id)
+			require.Equal(t, "Sent as MessagePack", got.Content)
+			require.False(t, got.Mine)
+		}
+
+		var own harness.MessageResponse
+		userA.MustWaitForEvent(t, "msg", &own)
+		require.True(t, own.Mine)
+	})
+
+	t.Run("Like sent with JSON reaches MessagePack user", func(t *testing.T) {
+		require.NoError(t, userB.LikeMessage("enc-msg-1", true))
+
+		var got harness.LikeMessageResponse
+		userA.MustWaitForEvent(t, "like", &got)
+		require.Equal(t, int64(1), got.Likes)
+		require.False(t, got.Liked)
+
+		userB.FlushEvents()
+		userC.FlushEvents()
+	})
+}
@@ -0,0 +1,82 @@
+package scenarios
+
+import (
+	"e2e_tests/harness"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// closeReason connects the user and returns the reason the server closed the connection with.
+func closeReason(t *testing.T, u *harness.TestUser) string {
+	t.Helper()
+	require.NoError(t, u.Connect(harness.BaseURL))
+	select {
+	case <-u.Done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	return u.CloseReason
+}
+
+func TestInvites(t *testing.T) {
+	boardId, userA, userB, userC := harness.SetupTest(t, true)
+	var invite *harness.Invite
+
+	t.Run("Owner creates an invite only the owner sees", func(t *testing.T) {
+		var got harness.InvitesResponse
+		require.NoError(t, userA.CreateInvite(3600, 1))
+		userA.MustWaitForEvent(t, "invites", &got)
+		require.Len(t, got.Invites, 1)
+		require.EqualValues(t, 1, got.Invites[0].MaxUses)
+		invite = got.Invites[0]
+		require.NoError(t, userB.MustNotReceiveEvent("invites"))
+	})
+
+	t.Run("Invite only board rejects new users without an invite", func(t *testing.T) {
+		var got harness.SettingsResponse
+		require.NoError(t, userA.SetInviteOnly(true))
+		userB.MustWaitForEvent(t, "set", &got)
+		require.True(t, got.InviteOnly)
+		userA.FlushEvents()
+		userC.FlushEvents()
+
+		dave := harness.NewUser("user-d", "Dave", boardId)
+		require.Equal(t, "INVITEREQUIRED", closeReason(t, dave))
+	})
+
+	t.Run("Invite admits one user", func(t *testing.T) {
+		var got harness.RegisterResponse
+		erin := harness.NewUser("user-e", "Erin", boardId)
+		erin.Invite = invite.Id
+		require.NoError(t, erin.Connect(harness.BaseURL))
+		t.Cleanup(func() { erin.Close() })
+		require.NoError(t, erin.Register())
+		erin.MustWaitForEvent(t, "reg", &got)
+		require.True(t, got.BoardInviteOnly)
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+
+		frank := harness.NewUser("user-f", "Frank", boardId)
+		frank.Invite = invite.Id
+		require.Equal(t, "INVITEINVALID", closeReason(t, frank))
+	})
+
+	t.Run("Revoked invite is rejected", func(t *testing.T) {
+		var got harness.InvitesResponse
+		require.NoError(t, userA.CreateInvite(3600, 0))
+		userA.MustWaitForEvent(t, "invites", &got)
+		require.Len(t, got.Invites, 2)
+		unlimited := got.Invites[1]
+
+		require.NoError(t, userA.RevokeInvite(unlimited.Id))
+		userA.MustWaitForEvent(t, "invites", &got)
+		require.Len(t, got.Invites, 1)
+
+		gina := harness.NewUser("user-g", "Gina", boardId)
+		gina.Invite = unlimited.Id
+		require.Equal(t, "INVITEINVALID", closeReason(t, gina))
+	})
+}
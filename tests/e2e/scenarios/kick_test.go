@@ -0,0 +1,45 @@
+package scenarios
+
+import (
+	"e2e_tests/harness"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKick(t *testing.T) {
+	boardId, userA, userB, userC := harness.SetupTest(t, true)
+
+	t.Run("Only the owner can kick", func(t *testing.T) {
+		require.NoError(t, userB.Kick("3", true, false))
+		require.NoError(t, userC.MustNotReceiveEvent("kick"))
+	})
+
+	t.Run("Owner kicks, bans and deletes the cards of Carol", func(t *testing.T) {
+		require.NoError(t, userC.SendMessage("msg-kick-1", "spam", "col01"))
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+
+		var got harness.KickResponse
+		require.NoError(t, userA.Kick("3", true, true))
+		userB.MustWaitForEvent(t, "kick", &got)
+		require.Equal(t, "3", got.Xid)
+		require.True(t, got.Ban)
+		require.Equal(t, []string{"msg-kick-1"}, got.MessageIds)
+
+		select {
+		case <-userC.Done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("kicked connection not closed")
+		}
+		require.Equal(t, "KICKED", userC.CloseReason)
+		userA.FlushEvents()
+	})
+
+	t.Run("Banned user can't join again", func(t *testing.T) {
+		carol := harness.NewUser(userC.Id, userC.Nickname, boardId)
+		require.Equal(t, "BANNED", closeReason(t, carol))
+	})
+}
@@ -1328,6 +1328,67 @@ func TestColumnEditing(t *testing.T) {
 		userB.FlushEvents()
 	})
 
+	t.Run("Owner can rename and recolour a column with messages", func(t *testing.T) {
+		// col01 has a message from the previous test
+		newCols := []*harness.BoardColumn{
+			{Id: "col04", Text: "Appreciations", Color: "fuchsia", Position: 1, IsDefault: true},
+			{Id: "col02", Text: "Stop", Color: "red", Position: 2, IsDefault: false},
+			{Id: "col01", Text: "Keep doing", Color: "sky", Position: 3, IsDefault: false},
+		}
+
+		require.NoError(t, userA.ChangeColumns(newCols))
+
+		var got harness.ColumnsChangeResponse
+		userB.MustWaitForEvent(t, "colreset", &got)
+		require.Equal(t, 3, len(got.BoardColumns))
+		require.Equal(t, "Keep doing", got.BoardColumns[2].Text)
+		require.Equal(t, "sky", got.BoardColumns[2].Color)
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+	})
+
+	t.Run("Owner can move columns", func(t *testing.T) {
+		require.NoError(t, userA.MoveColumns([]string{"col01", "col04", "col02"}))
+
+		var got harness.ColumnMoveResponse
+		userB.MustWaitForEvent(t, "colmove", &got)
+		require.Equal(t, []string{"col01", "col04", "col02"}, got.Order)
+		userA.FlushEvents()
+		userB.FlushEvents()
+
+		require.NoError(t, userA.Register())
+		var regRes harness.RegisterResponse
+		userA.MustWaitForEvent(t, "reg", &regRes)
+		positions := make(map[string]int)
+		for _, col := range regRes.BoardColumns {
+			positions[col.Id] = col.Position
+		}
+		require.Equal(t, map[string]int{"col01": 1, "col04": 2, "col02": 3}, positions)
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+	})
+
+	t.Run("Column move validation", func(t *testing.T) {
+		// Guest
+		require.NoError(t, userB.MoveColumns([]string{"col02", "col04", "col01"}))
+		require.NoError(t, userA.MustNotReceiveEvent("colmove"))
+
+		// Missing, unknown and repeated columns
+		for _, order := range [][]string{
+			{"col02", "col04"},
+			{"col02", "col04", "col03"},
+			{"col02", "col04", "col04"},
+		} {
+			require.NoError(t, userA.MoveColumns(order))
+			require.NoError(t, userB.MustNotReceiveEvent("colmove"))
+		}
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+	})
+
 	t.Run("Column count validation", func(t *testing.T) {
 		// Too many columns (> 5)
 		newCols := []*harness.BoardColumn{
@@ -0,0 +1,101 @@
+package scenarios
+
+import (
+	"e2e_tests/harness"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerators(t *testing.T) {
+	boardId, userA, userB, userC := harness.SetupTest(t, true)
+	var modToken string
+
+	t.Run("Owner makes Bob a moderator", func(t *testing.T) {
+		var got harness.SettingsResponse
+		require.NoError(t, userA.SetModerator("2", true))
+		userC.MustWaitForEvent(t, "set", &got)
+		require.NotNil(t, got.Moderator)
+		require.Equal(t, "2", got.Moderator.Xid)
+		require.True(t, got.Moderator.Moderator)
+		require.Empty(t, got.ModeratorToken)
+
+		var bobGot harness.SettingsResponse
+		userB.MustWaitForEvent(t, "set", &bobGot)
+		require.NotEmpty(t, bobGot.ModeratorToken)
+		modToken = bobGot.ModeratorToken
+
+		userA.FlushEvents()
+	})
+
+	t.Run("Moderator can pin, lock and run the timer", func(t *testing.T) {
+		require.NoError(t, userC.SendMessage("msg-mod-1", "hello", "col01"))
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+
+		var pin harness.PinMessageResponse
+		require.NoError(t, userB.PinMessage("msg-mod-1", true))
+		userC.MustWaitForEvent(t, "pin", &pin)
+		require.True(t, pin.Pin)
+
+		var set harness.SettingsResponse
+		require.NoError(t, userB.LockBoard(true))
+		userC.MustWaitForEvent(t, "set", &set)
+		require.True(t, set.Lock)
+		require.NoError(t, userB.LockBoard(false))
+		userC.MustWaitForEvent(t, "set", &set)
+		require.False(t, set.Lock)
+
+		var timer harness.TimerResponse
+		require.NoError(t, userB.StartTimer(60))
+		userC.MustWaitForEvent(t, "timer", &timer)
+		require.NotZero(t, timer.ExpiresInSeconds)
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+	})
+
+	t.Run("Moderator can't transfer ownership or delete the board", func(t *testing.T) {
+		require.NoError(t, userB.TransferOwnership("2"))
+		require.NoError(t, userC.MustNotReceiveEvent("set"))
+		require.NoError(t, userB.DeleteBoard())
+		require.NoError(t, userC.MustNotReceiveEvent("delall"))
+	})
+
+	t.Run("Moderator is flagged when joining again", func(t *testing.T) {
+		var got harness.RegisterResponse
+		secondTab := harness.NewUser(userB.Id, userB.Nickname, boardId)
+		secondTab.ModToken = modToken
+		require.NoError(t, secondTab.Connect(harness.BaseURL))
+		t.Cleanup(func() { secondTab.Close() })
+		require.NoError(t, secondTab.Register())
+		secondTab.MustWaitForEvent(t, "reg", &got)
+		require.True(t, got.IsBoardModerator)
+		require.False(t, got.IsBoardOwner)
+		for _, u := range got.Users {
+			require.Equal(t, u.Xid == "2", u.IsModerator, "user %s", u.Xid)
+		}
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+	})
+
+	t.Run("Bob's id without his token is not a moderator", func(t *testing.T) {
+		var got harness.RegisterResponse
+		impostor := harness.NewUser(userB.Id, userB.Nickname, boardId)
+		impostor.ModToken = "forged"
+		require.NoError(t, impostor.Connect(harness.BaseURL))
+		t.Cleanup(func() { impostor.Close() })
+		require.NoError(t, impostor.Register())
+		impostor.MustWaitForEvent(t, "reg", &got)
+		require.False(t, got.IsBoardModerator)
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+		require.NoError(t, impostor.PinMessage("msg-mod-1", false))
+		require.NoError(t, userC.MustNotReceiveEvent("pin"))
+	})
+}
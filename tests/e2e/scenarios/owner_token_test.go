@@ -0,0 +1,67 @@
+package scenarios
+
+import (
+	"e2e_tests/harness"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerToken(t *testing.T) {
+	boardId, userA, userB, userC := harness.SetupTest(t, true)
+
+	// Knows the owner's user id, but not the token
+	impostor := harness.NewUser(userA.Id, userA.Nickname, boardId)
+	impostor.Token = "forged"
+	require.NoError(t, impostor.Connect(harness.BaseURL))
+	t.Cleanup(func() { impostor.Close() })
+	userA.FlushEvents()
+	userB.FlushEvents()
+	userC.FlushEvents()
+
+	t.Run("Owner id without a valid token is not owner", func(t *testing.T) {
+		var got harness.RegisterResponse
+		require.NoError(t, impostor.Register())
+		impostor.MustWaitForEvent(t, "reg", &got)
+		require.False(t, got.IsBoardOwner)
+
+		require.NoError(t, impostor.LockBoard(true))
+		require.NoError(t, userB.MustNotReceiveEvent("set"))
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+		userC.FlushEvents()
+	})
+
+	t.Run("Transfer sends a new token only to the new owner", func(t *testing.T) {
+		var newOwner, other harness.SettingsResponse
+		require.NoError(t, userA.TransferOwnership("2"))
+
+		userB.MustWaitForEvent(t, "set", &newOwner)
+		require.Equal(t, "2", newOwner.OwnerXid)
+		require.NotEmpty(t, newOwner.OwnerToken)
+		userB.Token = newOwner.OwnerToken
+
+		userC.MustWaitForEvent(t, "set", &other)
+		require.Empty(t, other.OwnerToken)
+
+		userA.FlushEvents()
+		impostor.FlushEvents()
+	})
+
+	t.Run("New owner keeps ownership when joining again with the token", func(t *testing.T) {
+		var got harness.RegisterResponse
+		secondTab := harness.NewUser(userB.Id, userB.Nickname, boardId)
+		secondTab.Token = userB.Token
+		require.NoError(t, secondTab.Connect(harness.BaseURL))
+		t.Cleanup(func() { secondTab.Close() })
+		require.NoError(t, secondTab.Register())
+		secondTab.MustWaitForEvent(t, "reg", &got)
+		require.True(t, got.IsBoardOwner)
+
+		userB.FlushEvents()
+		userA.FlushEvents()
+		userC.FlushEvents()
+		impostor.FlushEvents()
+	})
+}
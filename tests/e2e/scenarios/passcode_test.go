@@ -0,0 +1,48 @@
+package scenarios
+
+import (
+	"e2e_tests/harness"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasscode(t *testing.T) {
+	boardId, userA, userB, _ := harness.SetupTest(t, true)
+
+	t.Run("Owner sets a passcode", func(t *testing.T) {
+		var got harness.SettingsResponse
+		require.NoError(t, userA.SetPasscode("s3cret"))
+		userB.MustWaitForEvent(t, "set", &got)
+		require.True(t, got.Passcode)
+		userA.FlushEvents()
+	})
+
+	t.Run("Joining without the passcode is rejected", func(t *testing.T) {
+		dave := harness.NewUser("user-d", "Dave", boardId)
+		require.NoError(t, dave.Connect(harness.BaseURL))
+		select {
+		case <-dave.Done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("connection not closed")
+		}
+		require.Equal(t, "PASSCODEREQUIRED", dave.CloseReason)
+	})
+
+	t.Run("Joining with the passcode works", func(t *testing.T) {
+		var got harness.RegisterResponse
+		erin := harness.NewUser("user-e", "Erin", boardId)
+		erin.Passcode = "s3cret"
+		require.NoError(t, erin.Connect(harness.BaseURL))
+		t.Cleanup(func() { erin.Close() })
+		require.Contains(t, erin.Handshake.Header.Get("Set-Cookie"), "qr_join_"+boardId)
+
+		require.NoError(t, erin.Register())
+		erin.MustWaitForEvent(t, "reg", &got)
+		require.True(t, got.BoardPasscode)
+
+		userA.FlushEvents()
+		userB.FlushEvents()
+	})
+}